@@ -350,7 +350,12 @@ export const exitSiYuan = async () => {
     });
 };
 
-export const transactionError = () => {
+export const transactionError = (data?: IWebSocketData) => {
+    if (data && (4 === data.code || 5 === data.code)) {
+        // 块被其他用户锁定或者和其他用户的修改冲突，内核会推送重新加载文档
+        showMessage(data.msg, 6000, "error");
+        return;
+    }
     if (document.getElementById("transactionError")) {
         return;
     }
@@ -156,7 +156,7 @@ export class App {
                                 downloadProgress(data.data);
                                 break;
                             case "txerr":
-                                transactionError();
+                                transactionError(data);
                                 break;
                             case "syncing":
                                 processSync(data, this.plugins);
@@ -59,7 +59,7 @@ export const onMessage = (app: App, data: IWebSocketData) => {
                 openMobileFileById(app, data.data.id);
                 break;
             case"txerr":
-                transactionError();
+                transactionError(data);
                 break;
             case"statusbar":
                 progressStatus(data);
@@ -48,6 +48,7 @@ import {getAllModels} from "../layout/getAll";
 import {isSupportCSSHL} from "./render/searchMarkRender";
 import {renderAVAttribute} from "./render/av/blockAttr";
 import {genEmptyElement} from "../block/util";
+import {showMessage} from "../dialog/message";
 
 export class Protyle {
 
@@ -193,6 +194,10 @@ export class Protyle {
                                 }
                             });
                             break;
+                        case "txerr":
+                            // 和其他用户的修改冲突，内核已经保留了两边的内容或者拒绝了操作
+                            showMessage(data.msg, 6000, "error");
+                            break;
                         case "readonly":
                             window.siyuan.config.editor.readOnly = data.data;
                             setReadonlyByConfig(this.protyle, true);
@@ -1,11 +1,13 @@
 import {hideElements} from "../ui/hideElements";
 import {isSupportCSSHL} from "../render/searchMarkRender";
+import {removePresence} from "./presence";
 
 export const destroy = (protyle: IProtyle) => {
     if (!protyle) {
         return;
     }
     hideElements(["util"], protyle);
+    removePresence(protyle);
     if (isSupportCSSHL()) {
         protyle.highlight.markHL.clear();
         protyle.highlight.mark.clear();
@@ -21,6 +21,7 @@ import {stickyRow} from "../render/av/row";
 import {updateReadonly as updateReadonlyMethod} from "../breadcrumb/action";
 import {getContenteditableElement} from "../wysiwyg/getBlock";
 import {activeBlur} from "../../mobile/util/keyboardToolbar";
+import {updatePresence} from "./presence";
 
 export const onGet = (options: {
     data: IWebSocketData,
@@ -66,6 +67,7 @@ export const onGet = (options: {
     options.protyle.block.parentID = options.data.data.parentID;
     options.protyle.block.parent2ID = options.data.data.parent2ID;
     options.protyle.block.rootID = options.data.data.rootID;
+    updatePresence(options.protyle);
     options.protyle.block.showAll = false;
     options.protyle.block.mode = options.data.data.mode;
     options.protyle.block.blockCount = options.data.data.blockCount;
@@ -377,6 +379,7 @@ export const disabledProtyle = (protyle: IProtyle) => {
             protyle.breadcrumb.element.parentElement.querySelector('[data-type="outdent"]').classList.add("fn__none");
         }
     }
+    updatePresence(protyle);
     hideTooltip();
 };
 
@@ -435,6 +438,7 @@ export const enableProtyle = (protyle: IProtyle) => {
             protyle.breadcrumb.element.parentElement.querySelector('[data-type="outdent"]').classList.remove("fn__none");
         }
     }
+    updatePresence(protyle);
     hideTooltip();
 };
 
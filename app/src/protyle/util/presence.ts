@@ -0,0 +1,46 @@
+import {fetchPost} from "../../util/fetch";
+
+// 内核中超过 2 分钟没有刷新的状态会被移除
+const refreshInterval = 60 * 1000;
+const presences: { [protyleId: string]: { rootID: string, mode: string, timeout: number } } = {};
+
+/** 上报当前用户在文档中的状态，每个编辑器使用独立的推送会话，会话 ID 即编辑器 ID */
+export const updatePresence = (protyle: IProtyle, refresh = false) => {
+    if (!protyle.options.render.title || !protyle.block.rootID) {
+        return;
+    }
+    const mode = protyle.disabled ? "viewing" : "editing";
+    const presence = presences[protyle.id];
+    if (presence) {
+        if (!refresh && presence.rootID === protyle.block.rootID && presence.mode === mode) {
+            return;
+        }
+        clearTimeout(presence.timeout);
+    }
+    fetchPost("/api/presence/updatePresence", {
+        rootID: protyle.block.rootID,
+        session: protyle.id,
+        mode,
+    });
+    presences[protyle.id] = {
+        rootID: protyle.block.rootID,
+        mode,
+        timeout: window.setTimeout(() => {
+            updatePresence(protyle, true);
+        }, refreshInterval),
+    };
+};
+
+export const removePresence = (protyle: IProtyle) => {
+    const presence = presences[protyle.id];
+    if (!presence) {
+        return;
+    }
+    clearTimeout(presence.timeout);
+    delete presences[protyle.id];
+    fetchPost("/api/presence/updatePresence", {
+        rootID: presence.rootID,
+        session: protyle.id,
+        mode: "",
+    });
+};
@@ -130,7 +130,7 @@ class App {
                                 progressStatus(data);
                                 break;
                             case "txerr":
-                                transactionError();
+                                transactionError(data);
                                 break;
                             case "syncing":
                                 processSync(data, this.plugins);
@@ -84,13 +84,6 @@ func setBlockAttrs(c *gin.Context) {
 	}
 
 	attrs := arg["attrs"].(map[string]interface{})
-	if 1 == len(attrs) && "" != attrs["scroll"] {
-		// 不记录用户指南滚动位置
-		if b := treenode.GetBlockTree(id); nil != b && (model.IsUserGuide(b.BoxID)) {
-			attrs["scroll"] = ""
-		}
-	}
-
 	nameValues := map[string]string{}
 	for name, value := range attrs {
 		if nil == value { // API `setBlockAttrs` 中如果存在属性值设置为 `null` 时移除该属性 https://github.com/siyuan-note/siyuan/issues/5577
@@ -99,14 +92,28 @@ func setBlockAttrs(c *gin.Context) {
 			nameValues[name] = value.(string)
 		}
 	}
-	err := model.SetBlockAttrs(id, nameValues)
-	if err != nil {
+	if err := setBlockAttrs0(c, id, nameValues); err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		return
 	}
 }
 
+// setBlockAttrs0 检查块是否被锁定后设置块属性，/api/attr/setBlockAttrs 和 /api/v2 共用。
+func setBlockAttrs0(c *gin.Context, id string, nameValues map[string]string) (err error) {
+	if err = model.CheckBlocksLocked(model.GetGinContextUser(c), id); err != nil {
+		return
+	}
+
+	if 1 == len(nameValues) && "" != nameValues["scroll"] {
+		// 不记录用户指南滚动位置
+		if b := treenode.GetBlockTree(id); nil != b && (model.IsUserGuide(b.BoxID)) {
+			nameValues["scroll"] = ""
+		}
+	}
+	return model.SetBlockAttrs(id, nameValues)
+}
+
 func batchSetBlockAttrs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -118,6 +125,7 @@ func batchSetBlockAttrs(c *gin.Context) {
 
 	blockAttrsArg := arg["blockAttrs"].([]interface{})
 	var blockAttrs []map[string]interface{}
+	var ids []string
 	for _, blockAttrArg := range blockAttrsArg {
 		blockAttr := blockAttrArg.(map[string]interface{})
 		id := blockAttr["id"].(string)
@@ -135,12 +143,19 @@ func batchSetBlockAttrs(c *gin.Context) {
 			}
 		}
 
+		ids = append(ids, id)
 		blockAttrs = append(blockAttrs, map[string]interface{}{
 			"id":    id,
 			"attrs": nameValues,
 		})
 	}
 
+	if err := model.CheckBlocksLocked(model.GetGinContextUser(c), ids...); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	err := model.BatchSetBlockAttrs(blockAttrs)
 	if err != nil {
 		ret.Code = -1
@@ -159,6 +174,11 @@ func resetBlockAttrs(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
+	if err := model.CheckBlocksLocked(model.GetGinContextUser(c), id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
 	attrs := arg["attrs"].(map[string]interface{})
 	nameValues := map[string]string{}
 	for name, value := range attrs {
@@ -0,0 +1,92 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func getAuditLogs(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	page, pageSize := 1, 64
+	if nil != arg["page"] {
+		page = int(arg["page"].(float64))
+	}
+	if nil != arg["pageSize"] {
+		pageSize = int(arg["pageSize"].(float64))
+	}
+
+	cond := &sql.AuditCondition{}
+	if nil != arg["op"] {
+		cond.Op = arg["op"].(string)
+	}
+	if nil != arg["id"] {
+		cond.ID = arg["id"].(string)
+	}
+	if nil != arg["ip"] {
+		cond.IP = arg["ip"].(string)
+	}
+	if nil != arg["token"] {
+		cond.Token = arg["token"].(string)
+	}
+	if nil != arg["from"] {
+		cond.From = int64(arg["from"].(float64))
+	}
+	if nil != arg["to"] {
+		cond.To = int64(arg["to"].(float64))
+	}
+
+	logs, total, err := model.GetAuditLogs(cond, page, pageSize)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"logs":  logs,
+		"total": total,
+	}
+}
+
+func setAudit(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	enabled := arg["enabled"].(bool)
+	retentionDays := int(arg["retentionDays"].(float64))
+	model.SetAudit(enabled, retentionDays)
+	ret.Data = model.Conf.Audit
+}
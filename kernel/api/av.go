@@ -39,6 +39,12 @@ func batchReplaceAttributeViewBlocks(c *gin.Context) {
 
 	avID := arg["avID"].(string)
 	isDetached := arg["isDetached"].(bool)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	oldNewArg := arg["oldNew"].([]interface{})
 	var oldNew []map[string]string
 	for _, v := range oldNewArg {
@@ -68,6 +74,12 @@ func setAttrViewGroup(c *gin.Context) {
 
 	avID := arg["avID"].(string)
 	blockID := arg["blockID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	groupArg := arg["group"].(map[string]interface{})
 
 	data, err := gulu.JSON.MarshalJSON(groupArg)
@@ -102,6 +114,12 @@ func changeAttrViewLayout(c *gin.Context) {
 	blockID := arg["blockID"].(string)
 	avID := arg["avID"].(string)
 	layoutType := arg["layoutType"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	err := model.ChangeAttrViewLayout(blockID, avID, av.LayoutType(layoutType))
 	if err != nil {
 		ret.Code = -1
@@ -123,6 +141,11 @@ func duplicateAttributeViewBlock(c *gin.Context) {
 		return
 	}
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
 
 	newAvID, newBlockID, err := model.DuplicateDatabaseBlock(avID)
 	if err != nil {
@@ -185,6 +208,11 @@ func setDatabaseBlockView(c *gin.Context) {
 	blockID := arg["id"].(string)
 	viewID := arg["viewID"].(string)
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
 
 	err := model.SetDatabaseBlockView(blockID, avID, viewID)
 	if err != nil {
@@ -246,6 +274,12 @@ func appendAttributeViewDetachedBlocksWithValues(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	var values [][]*av.Value
 	for _, blocksVals := range arg["blocksValues"].([]interface{}) {
 		vals := blocksVals.([]interface{})
@@ -286,6 +320,12 @@ func addAttributeViewBlocks(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	blockID := ""
 	if blockIDArg := arg["blockID"]; nil != blockIDArg {
 		blockID = blockIDArg.(string)
@@ -324,6 +364,12 @@ func removeAttributeViewBlocks(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	var srcIDs []string
 	for _, v := range arg["srcIDs"].([]interface{}) {
 		srcIDs = append(srcIDs, v.(string))
@@ -354,6 +400,11 @@ func addAttributeViewKey(c *gin.Context) {
 	keyType := arg["keyType"].(string)
 	keyIcon := arg["keyIcon"].(string)
 	previousKeyID := arg["previousKeyID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
 
 	err := model.AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID)
 	if err != nil {
@@ -376,6 +427,12 @@ func removeAttributeViewKey(c *gin.Context) {
 
 	avID := arg["avID"].(string)
 	keyID := arg["keyID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	removeRelationDest := false
 	if nil != arg["removeRelationDest"] {
 		removeRelationDest = arg["removeRelationDest"].(bool)
@@ -401,6 +458,12 @@ func sortAttributeViewViewKey(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	viewID := ""
 	if viewIDArg := arg["viewID"]; nil != viewIDArg {
 		viewID = viewIDArg.(string)
@@ -430,6 +493,11 @@ func sortAttributeViewKey(c *gin.Context) {
 	avID := arg["avID"].(string)
 	keyID := arg["keyID"].(string)
 	previousKeyID := arg["previousKeyID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
 
 	err := model.SortAttributeViewKey(avID, keyID, previousKeyID)
 	if err != nil {
@@ -751,6 +819,12 @@ func setAttributeViewBlockAttr(c *gin.Context) {
 	avID := arg["avID"].(string)
 	keyID := arg["keyID"].(string)
 	rowID := arg["rowID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	value := arg["value"].(interface{})
 	updatedVal, err := model.UpdateAttributeViewCell(nil, avID, keyID, rowID, value)
 	if err != nil {
@@ -777,6 +851,12 @@ func batchSetAttributeViewBlockAttrs(c *gin.Context) {
 	}
 
 	avID := arg["avID"].(string)
+	if err := model.CheckAttributeViewLocked(model.GetGinContextUser(c), avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	values := arg["values"].([]interface{})
 	err := model.BatchUpdateAttributeViewCells(nil, avID, values)
 	if err != nil {
@@ -72,6 +72,7 @@ func moveOutlineHeading(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -125,6 +126,7 @@ func appendDailyNoteBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -178,6 +180,7 @@ func prependDailyNoteBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -239,6 +242,7 @@ func unfoldBlock(c *gin.Context) {
 		}
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -299,6 +303,7 @@ func foldBlock(c *gin.Context) {
 		}
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -373,6 +378,7 @@ func moveBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -420,6 +426,7 @@ func appendBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -468,6 +475,7 @@ func batchAppendBlock(c *gin.Context) {
 		})
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -513,6 +521,7 @@ func prependBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -561,6 +570,7 @@ func batchPrependBlock(c *gin.Context) {
 		})
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -624,6 +634,7 @@ func insertBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -717,6 +728,7 @@ func updateBlock(c *gin.Context) {
 		}
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -783,6 +795,7 @@ func batchInsertBlock(c *gin.Context) {
 		})
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -898,6 +911,7 @@ func batchUpdateBlock(c *gin.Context) {
 	}
 
 	tx.DoOperations = ops
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -931,6 +945,7 @@ func deleteBlock(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 
 	ret.Data = transactions
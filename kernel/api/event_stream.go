@@ -0,0 +1,169 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// subscribeEvents 通过 SSE 订阅内核推送的事件
+//
+// 事件 ID 由内核启动纪元和序号组成，断线重连时浏览器会通过 Last-Event-ID 请求头带上最后收到的事件 ID，也可以通过 since 参数指定。
+// 事件 ID 来自之前启动的内核时先推送 reset 事件，之后的部分事件已经丢失时先推送 gap 事件，订阅者都需要重新全量获取数据。
+//
+// @param
+//
+//	{
+//		since: string, // 从该事件 ID 之后开始推送 (optional)
+//		retry: string, // retry interval (ms) (optional)
+//		cmd: string, // 事件类型 (optional, multiple)
+//		notebook: string, // 笔记本 ID (optional, multiple)
+//		doc: string, // 文档 ID (optional, multiple)
+//	}
+//
+// @example
+//
+//	"http://localhost:6806/es/events/subscribe?cmd=transactions&cmd=rename&notebook=20210808180117-czj9bvb"
+func subscribeEvents(c *gin.Context) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+
+	since := c.Query("since")
+	if lastEventID := c.GetHeader("Last-Event-ID"); "" != lastEventID {
+		since = lastEventID
+	}
+	retry := UnifiedSSE.GetRetry(c)
+	filter := &model.EventStreamFilter{
+		Cmds:      c.QueryArray("cmd"),
+		Notebooks: c.QueryArray("notebook"),
+		Docs:      c.QueryArray("doc"),
+	}
+
+	ch, backlog, gap, reset, latest := util.SubscribeStreamEvents(since)
+	defer util.UnsubscribeStreamEvents(ch)
+
+	if reset {
+		// 使用最新的事件 ID，重连时不会再次收到 reset 事件
+		c.Render(-1, &sse.Event{Id: latest, Event: "reset", Retry: retry, Data: map[string]interface{}{"since": since, "epoch": util.StreamEpoch}})
+	} else if gap {
+		c.Render(-1, &sse.Event{Event: "gap", Retry: retry, Data: map[string]interface{}{"since": since}})
+	}
+	for _, evt := range backlog {
+		if filter.Match(evt) {
+			renderStreamEvent(c, evt, retry)
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	clientGone := c.Request.Context().Done()
+	for {
+		select {
+		case <-clientGone:
+			return
+		case <-heartbeat.C:
+			c.Writer.WriteString(": ping\n\n")
+			c.Writer.Flush()
+		case evt, ok := <-ch:
+			if !ok {
+				// 订阅者处理过慢被断开，客户端会使用最后收到的序号重连
+				return
+			}
+			if filter.Match(evt) {
+				renderStreamEvent(c, evt, retry)
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+func renderStreamEvent(c *gin.Context, evt *util.StreamEvent, retry uint) {
+	c.Render(-1, &sse.Event{
+		Id:    evt.ID,
+		Event: evt.Cmd,
+		Retry: retry,
+		Data:  evt,
+	})
+}
+
+func getEvents(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var since string
+	if nil != arg["since"] {
+		since = arg["since"].(string)
+	}
+	limit := 256
+	if nil != arg["limit"] {
+		limit = int(arg["limit"].(float64))
+	}
+	filter := &model.EventStreamFilter{}
+	if nil != arg["cmds"] {
+		for _, cmd := range arg["cmds"].([]interface{}) {
+			filter.Cmds = append(filter.Cmds, cmd.(string))
+		}
+	}
+	if nil != arg["notebooks"] {
+		for _, notebook := range arg["notebooks"].([]interface{}) {
+			filter.Notebooks = append(filter.Notebooks, notebook.(string))
+		}
+	}
+	if nil != arg["docs"] {
+		for _, doc := range arg["docs"].([]interface{}) {
+			filter.Docs = append(filter.Docs, doc.(string))
+		}
+	}
+
+	scanned, gap, reset, latest := util.GetStreamEvents(since, limit)
+	events := []*util.StreamEvent{}
+	for _, evt := range scanned {
+		if filter.Match(evt) {
+			events = append(events, evt)
+		}
+	}
+
+	// next 为下次请求使用的 since，过滤掉的事件也会推进序号
+	next := since
+	if 0 < len(scanned) {
+		next = scanned[len(scanned)-1].ID
+	} else if gap || reset || "" == since {
+		next = latest
+	}
+
+	ret.Data = map[string]interface{}{
+		"events": events,
+		"gap":    gap,
+		"reset":  reset,
+		"next":   next,
+	}
+}
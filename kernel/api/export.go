@@ -30,6 +30,7 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -43,8 +44,39 @@ func exportAttributeView(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	avID := arg["id"].(string)
 	blockID := arg["blockID"].(string)
+	if nil != arg["format"] {
+		// 按照视图的过滤、排序和分组导出为 CSV、XLSX 或 JSON
+		format := arg["format"].(string)
+		var viewID string
+		if nil != arg["viewID"] {
+			viewID = arg["viewID"].(string)
+		}
+		withBlockID := false
+		if nil != arg["withBlockID"] {
+			withBlockID = arg["withBlockID"].(bool)
+		}
+
+		exportPath, err := model.ExportAttributeView(export, avID, blockID, viewID, format, withBlockID)
+		if err != nil {
+			ret.Code = 1
+			ret.Msg = err.Error()
+			ret.Data = map[string]interface{}{"closeTimeout": 7000}
+			return
+		}
+
+		ret.Data = map[string]interface{}{
+			"path": exportPath,
+		}
+		return
+	}
+
 	zipPath, err := model.ExportAv2CSV(avID, blockID)
 	if err != nil {
 		ret.Code = 1
@@ -67,8 +99,50 @@ func exportEPUB(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "epub", ".epub")
+	name, zipPath, err := model.ExportEPUB(export, id)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"name": name,
+		"zip":  zipPath,
+	}
+}
+
+func exportLaTeX(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var bibAvID string
+	if nil != arg["bibAvID"] {
+		bibAvID = arg["bibAvID"].(string)
+	}
+	merge := false
+	if nil != arg["merge"] {
+		merge = arg["merge"].(bool)
+	}
+
+	name, zipPath := model.ExportLaTeX(export, id, bibAvID, merge)
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -84,8 +158,13 @@ func exportRTF(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "rtf", ".rtf")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "rtf", ".rtf")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -101,8 +180,13 @@ func exportODT(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "odt", ".odt")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "odt", ".odt")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -118,8 +202,13 @@ func exportMediaWiki(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "mediawiki", ".wiki")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "mediawiki", ".wiki")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -135,8 +224,13 @@ func exportOrgMode(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "org", ".org")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "org", ".org")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -152,8 +246,13 @@ func exportOPML(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "opml", ".opml")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "opml", ".opml")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -169,8 +268,13 @@ func exportTextile(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "textile", ".textile")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "textile", ".textile")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -186,8 +290,13 @@ func exportAsciiDoc(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "asciidoc", ".adoc")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "asciidoc", ".adoc")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -203,8 +312,13 @@ func exportReStructuredText(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "rst", ".rst")
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "rst", ".rst")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -276,6 +390,11 @@ func exportResources(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	var name string
 	if nil != arg["name"] {
 		name = util.TruncateLenFileName(arg["name"].(string))
@@ -296,7 +415,7 @@ func exportResources(c *gin.Context) {
 		resourcePaths = append(resourcePaths, resourcePath.(string))
 	}
 
-	zipFilePath, err := model.ExportResources(resourcePaths, name)
+	zipFilePath, err := model.ExportResources(export, resourcePaths, name)
 	if err != nil {
 		ret.Code = 1
 		ret.Msg = err.Error()
@@ -317,8 +436,13 @@ func exportNotebookMd(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	notebook := arg["notebook"].(string)
-	zipPath := model.ExportNotebookMarkdown(notebook)
+	zipPath := model.ExportNotebookMarkdown(export, notebook)
 	ret.Data = map[string]interface{}{
 		"name": path.Base(zipPath),
 		"zip":  zipPath,
@@ -334,13 +458,18 @@ func exportMds(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	idsArg := arg["ids"].([]interface{})
 	var ids []string
 	for _, id := range idsArg {
 		ids = append(ids, id.(string))
 	}
 
-	name, zipPath := model.ExportPandocConvertZip(ids, "", ".md")
+	name, zipPath := model.ExportPandocConvertZip(export, ids, "", ".md")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -356,8 +485,16 @@ func exportMd(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportPandocConvertZip([]string{id}, "", ".md")
+	if !checkExportDocFiltered(export, id, ret) {
+		return
+	}
+	name, zipPath := model.ExportPandocConvertZip(export, []string{id}, "", ".md")
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -373,8 +510,13 @@ func exportNotebookSY(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	zipPath := model.ExportNotebookSY(id)
+	zipPath := model.ExportNotebookSY(export, id)
 	ret.Data = map[string]interface{}{
 		"zip": zipPath,
 	}
@@ -389,8 +531,13 @@ func exportSY(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	name, zipPath := model.ExportSY(id)
+	name, zipPath := model.ExportSY(export, id)
 	ret.Data = map[string]interface{}{
 		"name": name,
 		"zip":  zipPath,
@@ -406,17 +553,25 @@ func exportMdContent(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
 	if util.InvalidIDPattern(id, ret) {
 		return
 	}
+	if !checkExportDocFiltered(export, id, ret) {
+		return
+	}
 
-	refMode := model.Conf.Export.BlockRefMode
+	refMode := export.BlockRefMode
 	if nil != arg["refMode"] {
 		refMode = int(arg["refMode"].(float64))
 	}
 
-	embedMode := model.Conf.Export.BlockEmbedMode
+	embedMode := export.BlockEmbedMode
 	if nil != arg["embedMode"] {
 		embedMode = int(arg["embedMode"].(float64))
 	}
@@ -431,7 +586,7 @@ func exportMdContent(c *gin.Context) {
 		fillCSSVar = arg["fillCSSVar"].(bool)
 	}
 
-	hPath, content := model.ExportMarkdownContent(id, refMode, embedMode, yfm, fillCSSVar)
+	hPath, content := model.ExportMarkdownContent(export, id, refMode, embedMode, yfm, fillCSSVar)
 	ret.Data = map[string]interface{}{
 		"hPath":   hPath,
 		"content": content,
@@ -447,7 +602,15 @@ func exportDocx(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
+	if !checkExportDocFiltered(export, id, ret) {
+		return
+	}
 	savePath := arg["savePath"].(string)
 	removeAssets := arg["removeAssets"].(bool)
 	merge := false
@@ -455,7 +618,7 @@ func exportDocx(c *gin.Context) {
 		merge = arg["merge"].(bool)
 	}
 
-	fullPath, err := model.ExportDocx(id, savePath, removeAssets, merge)
+	fullPath, err := model.ExportDocx(export, id, savePath, removeAssets, merge)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -476,9 +639,17 @@ func exportMdHTML(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
+	if !checkExportDocFiltered(export, id, ret) {
+		return
+	}
 	savePath := arg["savePath"].(string)
-	name, content := model.ExportMarkdownHTML(id, savePath, false, false)
+	name, content := model.ExportMarkdownHTML(export, id, savePath, false, false)
 	ret.Data = map[string]interface{}{
 		"id":      id,
 		"name":    name,
@@ -525,7 +696,15 @@ func exportPreviewHTML(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
+	if !checkExportDocFiltered(export, id, ret) {
+		return
+	}
 	keepFold := false
 	if nil != arg["keepFold"] {
 		keepFold = arg["keepFold"].(bool)
@@ -538,7 +717,7 @@ func exportPreviewHTML(c *gin.Context) {
 	if nil != arg["image"] {
 		image = arg["image"].(bool)
 	}
-	name, content, node := model.ExportHTML(id, "", true, image, keepFold, merge)
+	name, content, node := model.ExportHTML(export, id, "", true, image, keepFold, merge)
 	// 导出 PDF 预览时点击块引转换后的脚注跳转不正确 https://github.com/siyuan-note/siyuan/issues/5894
 	content = strings.ReplaceAll(content, "http://"+util.LocalHost+":"+util.ServerPort+"/#", "#")
 
@@ -568,7 +747,15 @@ func exportHTML(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
+	if !checkExportDocFiltered(export, id, ret) {
+		return
+	}
 	pdf := arg["pdf"].(bool)
 	savePath := arg["savePath"].(string)
 	keepFold := false
@@ -579,7 +766,7 @@ func exportHTML(c *gin.Context) {
 	if nil != arg["merge"] {
 		merge = arg["merge"].(bool)
 	}
-	name, content, _ := model.ExportHTML(id, savePath, pdf, false, keepFold, merge)
+	name, content, _ := model.ExportHTML(export, id, savePath, pdf, false, keepFold, merge)
 	ret.Data = map[string]interface{}{
 		"id":      id,
 		"name":    name,
@@ -596,6 +783,11 @@ func processPDF(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
 	path := arg["path"].(string)
 	merge := false
@@ -604,7 +796,7 @@ func processPDF(c *gin.Context) {
 	}
 	removeAssets := arg["removeAssets"].(bool)
 	watermark := arg["watermark"].(bool)
-	err := model.ProcessPDF(id, path, merge, removeAssets, watermark)
+	err := model.ProcessPDF(export, id, path, merge, removeAssets, watermark)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -621,8 +813,13 @@ func exportPreview(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
-	stdHTML := model.Preview(id)
+	stdHTML := model.Preview(export, id)
 	ret.Data = map[string]interface{}{
 		"html": stdHTML,
 	}
@@ -687,3 +884,33 @@ func exportAsFile(c *gin.Context) {
 		"file": path.Join("/export/", name),
 	}
 }
+
+// checkExportDocFiltered 导出单个文档时，文档被导出配置方案过滤则返回错误，避免导出只有标题的空文档。
+func checkExportDocFiltered(export *conf.Export, id string, ret *gulu.Result) (ok bool) {
+	if err := model.CheckExportDocFiltered(export, id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ok = true
+	return
+}
+
+// getExportConf 返回本次导出使用的导出配置，参数 profile 指定导出配置方案时使用配置方案覆盖导出配置。
+func getExportConf(arg map[string]interface{}, ret *gulu.Result) (export *conf.Export, ok bool) {
+	var profile string
+	if nil != arg["profile"] {
+		profile = arg["profile"].(string)
+	}
+
+	export, err := model.ResolveExportConf(profile)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ok = true
+	return
+}
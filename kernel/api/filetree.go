@@ -349,6 +349,11 @@ func getHPathByPath(c *gin.Context) {
 	}
 
 	p := arg["path"].(string)
+	if !model.GetPublishAccess(c).VisibleDocPath(notebook, p) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
 
 	hPath, err := model.GetHPathByPath(notebook, p)
 	if err != nil {
@@ -373,6 +378,7 @@ func getHPathsByPaths(c *gin.Context) {
 	for _, p := range pathsArg {
 		paths = append(paths, p.(string))
 	}
+	paths = model.GetPublishAccess(c).FilterTreePaths(paths)
 	hPath, err := model.GetHPathsByPaths(paths)
 	if err != nil {
 		ret.Code = -1
@@ -582,6 +588,12 @@ func removeDoc(c *gin.Context) {
 	}
 
 	p := arg["path"].(string)
+	if err := model.CheckDocsLocked(model.GetGinContextUser(c), util.GetTreeID(p)); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
 	model.RemoveDoc(notebook, p)
 }
 
@@ -599,15 +611,26 @@ func removeDocByID(c *gin.Context) {
 		return
 	}
 
-	tree, err := model.LoadTreeByBlockID(id)
-	if err != nil {
+	if err := removeDocByID0(c, id); err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		ret.Data = map[string]interface{}{"closeTimeout": 7000}
 		return
 	}
+}
+
+// removeDocByID0 删除文档及其子文档，/api/filetree/removeDocByID 和 /api/v2 共用，removeDoc 事件由 model.RemoveDoc 广播。
+func removeDocByID0(c *gin.Context, id string) (err error) {
+	if err = model.CheckDocsLocked(model.GetGinContextUser(c), id); err != nil {
+		return
+	}
 
+	tree, err := model.LoadTreeByBlockID(id)
+	if err != nil {
+		return
+	}
 	model.RemoveDoc(tree.Box, tree.Path)
+	return
 }
 
 func removeDocs(c *gin.Context) {
@@ -620,9 +643,15 @@ func removeDocs(c *gin.Context) {
 	}
 
 	pathsArg := arg["paths"].([]interface{})
-	var paths []string
+	var paths, rootIDs []string
 	for _, path := range pathsArg {
 		paths = append(paths, path.(string))
+		rootIDs = append(rootIDs, util.GetTreeID(path.(string)))
+	}
+	if err := model.CheckDocsLocked(model.GetGinContextUser(c), rootIDs...); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
 	}
 	model.RemoveDocs(paths)
 }
@@ -643,6 +672,11 @@ func renameDoc(c *gin.Context) {
 
 	p := arg["path"].(string)
 	title := arg["title"].(string)
+	if err := model.CheckDocsLocked(model.GetGinContextUser(c), util.GetTreeID(p)); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
 
 	err := model.RenameDoc(notebook, p, title)
 	if err != nil {
@@ -671,21 +705,25 @@ func renameDocByID(c *gin.Context) {
 	}
 
 	title := arg["title"].(string)
-
-	tree, err := model.LoadTreeByBlockID(id)
-	if err != nil {
+	if err := renameDocByID0(c, id, title); err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		ret.Data = map[string]interface{}{"closeTimeout": 7000}
 		return
 	}
+}
 
-	err = model.RenameDoc(tree.Box, tree.Path, title)
+// renameDocByID0 重命名文档，/api/filetree/renameDocByID 和 /api/v2 共用，rename 事件由 model.RenameDoc 广播。
+func renameDocByID0(c *gin.Context, id, title string) (err error) {
+	if err = model.CheckDocsLocked(model.GetGinContextUser(c), id); err != nil {
+		return
+	}
+
+	tree, err := model.LoadTreeByBlockID(id)
 	if err != nil {
-		ret.Code = -1
-		ret.Msg = err.Error()
 		return
 	}
+	return model.RenameDoc(tree.Box, tree.Path, title)
 }
 
 func duplicateDoc(c *gin.Context) {
@@ -844,21 +882,9 @@ func createDocWithMd(c *gin.Context) {
 		id = idArg.(string)
 	}
 
-	hPath := arg["path"].(string)
+	hPath := normalizeCreateDocHPath(arg["path"].(string))
 	markdown := arg["markdown"].(string)
 
-	baseName := path.Base(hPath)
-	dir := path.Dir(hPath)
-	r, _ := regexp.Compile("\r\n|\r|\n|\u2028|\u2029|\t|/")
-	baseName = r.ReplaceAllString(baseName, "")
-	if 512 < utf8.RuneCountInString(baseName) {
-		baseName = gulu.Str.SubStr(baseName, 512)
-	}
-	hPath = path.Join(dir, baseName)
-	if !strings.HasPrefix(hPath, "/") {
-		hPath = "/" + hPath
-	}
-
 	withMath := false
 	withMathArg := arg["withMath"]
 	if nil != withMathArg {
@@ -884,6 +910,22 @@ func createDocWithMd(c *gin.Context) {
 	pushCreate(box, b.Path, arg)
 }
 
+// normalizeCreateDocHPath 去掉文档名中的换行、制表符和斜杠，并限制文档名长度。
+func normalizeCreateDocHPath(hPath string) string {
+	baseName := path.Base(hPath)
+	dir := path.Dir(hPath)
+	r, _ := regexp.Compile("\r\n|\r|\n|\u2028|\u2029|\t|/")
+	baseName = r.ReplaceAllString(baseName, "")
+	if 512 < utf8.RuneCountInString(baseName) {
+		baseName = gulu.Str.SubStr(baseName, 512)
+	}
+	hPath = path.Join(dir, baseName)
+	if !strings.HasPrefix(hPath, "/") {
+		hPath = "/" + hPath
+	}
+	return hPath
+}
+
 func getDocCreateSavePath(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -1025,7 +1067,7 @@ func searchDocs(c *gin.Context) {
 	}
 
 	k := arg["k"].(string)
-	ret.Data = model.SearchDocsByKeyword(k, flashcard)
+	ret.Data = model.GetPublishAccess(c).FilterDocs(model.SearchDocsByKeyword(k, flashcard))
 }
 
 func listDocsByPath(c *gin.Context) {
@@ -1075,6 +1117,7 @@ func listDocsByPath(c *gin.Context) {
 		}
 	}
 
+	files = model.GetPublishAccess(c).FilterFiles(notebook, files)
 	ret.Data = map[string]interface{}{
 		"box":   notebook,
 		"path":  p,
@@ -270,3 +270,83 @@ func importZipMd(c *gin.Context) {
 		return
 	}
 }
+
+func importAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(200, ret)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		logging.LogErrorf("parse import attribute view failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	files := form.File["file"]
+	if 1 > len(files) {
+		logging.LogErrorf("parse import attribute view failed, no file found")
+		ret.Code = -1
+		ret.Msg = "no file found"
+		return
+	}
+	file := files[0]
+	reader, err := file.Open()
+	if err != nil {
+		logging.LogErrorf("read import attribute view failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	importDir := filepath.Join(util.TempDir, "import")
+	if err = os.MkdirAll(importDir, 0755); err != nil {
+		logging.LogErrorf("make import dir [%s] failed: %s", importDir, err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	writePath := filepath.Join(importDir, filepath.Base(file.Filename))
+	defer os.RemoveAll(writePath)
+	writer, err := os.OpenFile(writePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		logging.LogErrorf("open import attribute view [%s] failed: %s", writePath, err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	if _, err = io.Copy(writer, reader); err != nil {
+		logging.LogErrorf("write import attribute view failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	writer.Close()
+	reader.Close()
+
+	avID := form.Value["avID"][0]
+	mapping := map[string]string{}
+	if vals := form.Value["mapping"]; 0 < len(vals) && "" != vals[0] {
+		if err = gulu.JSON.UnmarshalJSON([]byte(vals[0]), &mapping); err != nil {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+	var upsertKeyID string
+	if vals := form.Value["upsertKeyID"]; 0 < len(vals) {
+		upsertKeyID = vals[0]
+	}
+
+	inserted, updated, err := model.ImportAttributeViewCSV(avID, writePath, mapping, upsertKeyID)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"inserted": inserted,
+		"updated":  updated,
+	}
+}
@@ -43,12 +43,17 @@ func copyStdMarkdown(c *gin.Context) {
 		return
 	}
 
+	export, ok := getExportConf(arg, ret)
+	if !ok {
+		return
+	}
+
 	id := arg["id"].(string)
 	assetsDestSpace2Underscore := false
 	if nil != arg["assetsDestSpace2Underscore"] {
 		assetsDestSpace2Underscore = arg["assetsDestSpace2Underscore"].(bool)
 	}
-	ret.Data = model.ExportStdMarkdown(id, assetsDestSpace2Underscore)
+	ret.Data = model.ExportStdMarkdown(export, id, assetsDestSpace2Underscore)
 }
 
 func html2BlockDOM(c *gin.Context) {
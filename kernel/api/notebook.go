@@ -421,6 +421,7 @@ func lsNotebooks(c *gin.Context) {
 		}
 	}
 
+	notebooks = model.GetPublishAccess(c).FilterNotebooks(notebooks)
 	ret.Data = map[string]interface{}{
 		"notebooks": notebooks,
 	}
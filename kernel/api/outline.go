@@ -44,6 +44,12 @@ func getDocOutline(c *gin.Context) {
 	}
 
 	rootID := arg["id"].(string)
+	if !model.GetPublishAccess(c).AllowBlock(rootID) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
 	headings, err := model.Outline(rootID, preview)
 	if err != nil {
 		ret.Code = 1
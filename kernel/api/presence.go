@@ -0,0 +1,136 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func lockBlock(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+	minutes := 0
+	if nil != arg["minutes"] {
+		minutes = int(arg["minutes"].(float64))
+	}
+
+	lock, err := model.LockBlock(id, model.GetGinContextUser(c), minutes)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = lock
+}
+
+func unlockBlock(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if util.InvalidIDPattern(id, ret) {
+		return
+	}
+
+	if err := model.UnlockBlock(id, model.GetGinContextUser(c), model.IsAdminRoleContext(c)); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+	}
+}
+
+func getBlockLocks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var rootID string
+	if nil != arg["rootID"] {
+		rootID = arg["rootID"].(string)
+	}
+	ret.Data = model.GetBlockLocks(rootID)
+}
+
+func updatePresence(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["rootID"].(string)
+	if util.InvalidIDPattern(rootID, ret) {
+		return
+	}
+	session := arg["session"].(string)
+	var mode string
+	if nil != arg["mode"] {
+		mode = arg["mode"].(string)
+	}
+	switch mode {
+	case "", "viewing", "editing":
+	default:
+		ret.Code = -1
+		ret.Msg = "invalid presence mode [" + mode + "]"
+		return
+	}
+
+	model.UpdatePresence(rootID, session, model.GetGinContextUser(c), mode)
+}
+
+func getPresence(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	rootID := arg["rootID"].(string)
+	ret.Data = map[string]interface{}{
+		"presences": model.GetPresences(rootID),
+		"locks":     model.GetBlockLocks(rootID),
+	}
+}
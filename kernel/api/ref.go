@@ -125,6 +125,8 @@ func getBacklink2(c *gin.Context) {
 		containChildren = val.(bool)
 	}
 	boxID, backlinks, backmentions, linkRefsCount, mentionsCount := model.GetBacklink2(id, keyword, mentionKeyword, sort, mentionSort, containChildren)
+	access := model.GetPublishAccess(c)
+	backlinks, backmentions = access.FilterPaths(backlinks), access.FilterPaths(backmentions)
 	ret.Data = map[string]interface{}{
 		"backlinks":     backlinks,
 		"linkRefsCount": linkRefsCount,
@@ -161,6 +163,8 @@ func getBacklink(c *gin.Context) {
 		containChildren = val.(bool)
 	}
 	boxID, backlinks, backmentions, linkRefsCount, mentionsCount := model.GetBacklink(id, keyword, mentionKeyword, beforeLen, containChildren)
+	access := model.GetPublishAccess(c)
+	backlinks, backmentions = access.FilterPaths(backlinks), access.FilterPaths(backmentions)
 	ret.Data = map[string]interface{}{
 		"backlinks":     backlinks,
 		"linkRefsCount": linkRefsCount,
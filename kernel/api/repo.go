@@ -63,6 +63,110 @@ func setRetentionIndexesDaily(c *gin.Context) {
 	model.Conf.Save()
 }
 
+func checkRepo(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	repair := false
+	if nil != arg["repair"] {
+		repair = arg["repair"].(bool)
+	}
+
+	result, err := model.CheckRepo(repair)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = result
+}
+
+func rotateRepoKey(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	pass := ""
+	if nil != arg["pass"] {
+		pass = arg["pass"].(string)
+	}
+
+	key, err := model.RotateRepoKey(pass)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"key": key,
+	}
+}
+
+func setRepoSnapshotInterval(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+	interval := int(arg["interval"].(float64))
+	if 0 > interval {
+		interval = 0
+	}
+
+	model.Conf.Repo.SnapshotInterval = interval
+	model.Conf.Save()
+}
+
+func setRepoSnapshotRetention(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	retention := model.Conf.Repo.SnapshotRetention
+	for key, count := range map[string]*int{
+		"hourly":  &retention.Hourly,
+		"daily":   &retention.Daily,
+		"weekly":  &retention.Weekly,
+		"monthly": &retention.Monthly,
+	} {
+		if nil != arg[key] {
+			*count = max(int(arg[key].(float64)), 0)
+		}
+	}
+	model.Conf.Save()
+}
+
+func setRepoSnapshotBeforeRiskyOps(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	model.Conf.Repo.SnapshotBeforeRiskyOps = arg["enabled"].(bool)
+	model.Conf.Save()
+}
+
 func getRepoFile(c *gin.Context) {
 	// Add internal kernel API `/api/repo/getRepoFile` https://github.com/siyuan-note/siyuan/issues/10101
 
@@ -147,6 +251,103 @@ func diffRepoSnapshots(c *gin.Context) {
 	}
 }
 
+func diffRepoSnapshotBlocks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	left := arg["left"].(string)
+	right := "" // 为空时和当前工作空间比较
+	if nil != arg["right"] {
+		right = arg["right"].(string)
+	}
+	var paths []string
+	if nil != arg["paths"] {
+		for _, p := range arg["paths"].([]interface{}) {
+			paths = append(paths, p.(string))
+		}
+	}
+
+	docs, err := model.DiffRepoSnapshotBlocks(left, right, paths)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"docs": docs,
+	}
+}
+
+func restoreRepoSnapshotDocs(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	var paths []string
+	for _, p := range arg["paths"].([]interface{}) {
+		paths = append(paths, p.(string))
+	}
+	if err := model.RestoreRepoSnapshotDocs(id, paths); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func restoreRepoSnapshotBlock(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	blockID := arg["blockID"].(string)
+	var docPath string
+	if nil != arg["path"] {
+		docPath = arg["path"].(string)
+	}
+	if err := model.RestoreRepoSnapshotBlock(id, docPath, blockID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func restoreRepoSnapshotAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	avID := arg["avID"].(string)
+	if err := model.RestoreRepoSnapshotAttributeView(id, avID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
 func getCloudSpace(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -324,6 +324,7 @@ func removeRiffCards(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
@@ -362,6 +363,7 @@ func addRiffCards(c *gin.Context) {
 		},
 	}
 
+	setTransactionsAuthor(c, transactions)
 	model.PerformTransactions(&transactions)
 	model.FlushTxQueue()
 
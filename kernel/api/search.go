@@ -30,6 +30,12 @@ func listInvalidBlockRefs(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
+	if nil != model.GetPublishAccess(c) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
 	arg, ok := util.JsonArg(c, ret)
 	if !ok {
 		return
@@ -64,6 +70,12 @@ func getAssetContent(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
+	if nil != model.GetPublishAccess(c) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
 	arg, ok := util.JsonArg(c, ret)
 	if !ok {
 		return
@@ -83,6 +95,12 @@ func fullTextSearchAssetContent(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
+	if nil != model.GetPublishAccess(c) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
 	arg, ok := util.JsonArg(c, ret)
 	if !ok {
 		return
@@ -131,7 +149,7 @@ func findReplace(c *gin.Context) {
 		}
 	}
 
-	err := model.FindReplace(k, r, replaceTypes, ids, paths, boxes, types, method, orderBy, groupBy)
+	err := model.FindReplace(k, r, replaceTypes, ids, paths, boxes, types, method, orderBy, groupBy, model.GetGinContextUser(c))
 	if err != nil {
 		ret.Code = 1
 		ret.Msg = err.Error()
@@ -159,7 +177,7 @@ func searchAsset(c *gin.Context) {
 		}
 	}
 
-	ret.Data = model.SearchAssetsByName(k, exts)
+	ret.Data = model.SearchAssetsByName(k, exts, model.GetPublishAccess(c))
 	return
 }
 
@@ -173,7 +191,7 @@ func searchTag(c *gin.Context) {
 	}
 
 	k := arg["k"].(string)
-	tags := model.SearchTags(k)
+	tags := model.SearchTags(k, model.GetPublishAccess(c))
 	if 1 > len(tags) {
 		tags = []string{}
 	}
@@ -262,6 +280,7 @@ func getEmbedBlock(c *gin.Context) {
 		breadcrumb = breadcrumbArg.(bool)
 	}
 
+	includeIDs = model.GetPublishAccess(c).FilterBlockIDs(includeIDs)
 	blocks := model.GetEmbedBlock(embedBlockID, includeIDs, headingMode, breadcrumb)
 	ret.Data = map[string]interface{}{
 		"blocks": blocks,
@@ -292,6 +311,12 @@ func searchEmbedBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
+	if nil != model.GetPublishAccess(c) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
 	arg, ok := util.JsonArg(c, ret)
 	if !ok {
 		return
@@ -350,7 +375,7 @@ func searchRefBlock(c *gin.Context) {
 	id := arg["id"].(string)
 	keyword := arg["k"].(string)
 	beforeLen := int(arg["beforeLen"].(float64))
-	blocks, newDoc := model.SearchRefBlock(id, rootID, keyword, beforeLen, isSquareBrackets, isDatabase)
+	blocks, newDoc := model.SearchRefBlock(id, rootID, keyword, beforeLen, isSquareBrackets, isDatabase, model.GetPublishAccess(c))
 	ret.Data = map[string]interface{}{
 		"blocks": blocks,
 		"newDoc": newDoc,
@@ -369,7 +394,14 @@ func fullTextSearchBlock(c *gin.Context) {
 	}
 
 	page, pageSize, query, paths, boxes, types, method, orderBy, groupBy := parseSearchBlockArgs(arg)
-	blocks, matchedBlockCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(query, boxes, paths, types, method, orderBy, groupBy, page, pageSize)
+	access := model.GetPublishAccess(c)
+	if nil != access && 2 == method {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
+	blocks, matchedBlockCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(query, boxes, paths, types, method, orderBy, groupBy, page, pageSize, access)
 	ret.Data = map[string]interface{}{
 		"blocks":            blocks,
 		"matchedBlockCount": matchedBlockCount,
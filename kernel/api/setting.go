@@ -370,12 +370,67 @@ func setExport(c *gin.Context) {
 		}
 	}
 
+	if nil == export.Filter {
+		export.Filter = model.Conf.Export.Filter
+	}
+	if nil == export.Profiles {
+		// 未传入导出配置方案时保留已有的配置方案
+		export.Profiles = model.Conf.Export.Profiles
+	}
+
 	model.Conf.Export = export
 	model.Conf.Save()
 
 	ret.Data = model.Conf.Export
 }
 
+func setExportProfile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	profile := &conf.ExportProfile{}
+	if err = gulu.JSON.UnmarshalJSON(param, profile); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	if err = model.SetExportProfile(profile); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = model.Conf.Export
+}
+
+func removeExportProfile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := arg["name"].(string)
+	model.RemoveExportProfile(name)
+	ret.Data = model.Conf.Export
+}
+
 func setFiletree(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -578,6 +633,38 @@ func setPublish(c *gin.Context) {
 	}
 }
 
+func setOIDC(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	oidc := conf.NewOIDC()
+	if err = gulu.JSON.UnmarshalJSON(param, oidc); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if err = model.SetOIDC(oidc); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = model.Conf.OIDC
+}
+
 func getPublish(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
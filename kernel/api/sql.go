@@ -44,6 +44,12 @@ func SQL(c *gin.Context) {
 		return
 	}
 
+	if nil != model.GetPublishAccess(c) {
+		ret.Code = -1
+		ret.Msg = model.ErrPublishScopeDenied.Error()
+		return
+	}
+
 	stmt := arg["stmt"].(string)
 	result, err := sql.Query(stmt, model.Conf.Search.Limit)
 	if err != nil {
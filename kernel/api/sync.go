@@ -478,6 +478,47 @@ func performSync(c *gin.Context) {
 	}
 }
 
+func previewSync(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	plan, err := model.PreviewSync()
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = plan
+}
+
+func confirmSync(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var cloudIndexID string
+	if nil != arg["cloudIndex"] {
+		cloudIndexID = arg["cloudIndex"].(string)
+	}
+	if err := model.ConfirmSync(cloudIndexID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+	}
+}
+
+func cancelSync(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	model.CancelSync()
+}
+
 func performBootSync(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -557,6 +598,41 @@ func setSyncGenerateConflictDoc(c *gin.Context) {
 	model.SetSyncGenerateConflictDoc(enabled)
 }
 
+func setSyncNotebookScope(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := arg["notebook"].(string)
+	scope := int(arg["scope"].(float64))
+	if err := model.SetSyncNotebookScope(notebook, scope); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func setSyncExcludePaths(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	var paths []string
+	for _, p := range arg["paths"].([]interface{}) {
+		paths = append(paths, p.(string))
+	}
+	model.SetSyncExcludePaths(paths)
+}
+
 func setSyncEnable(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -735,6 +811,194 @@ func setSyncProviderLocal(c *gin.Context) {
 	}
 }
 
+func setSyncProviderSFTP(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	sftpArg := arg["sftp"].(interface{})
+	data, err := gulu.JSON.MarshalJSON(sftpArg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	sftp := &conf.SFTP{}
+	if err = gulu.JSON.UnmarshalJSON(data, sftp); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	err = model.SetSyncProviderSFTP(sftp)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"sftp": sftp,
+	}
+}
+
+func setSyncProviderGit(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	gitArg := arg["git"].(interface{})
+	data, err := gulu.JSON.MarshalJSON(gitArg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	git := &conf.Git{}
+	if err = gulu.JSON.UnmarshalJSON(data, git); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	err = model.SetSyncProviderGit(git)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"git": git,
+	}
+}
+
+func setSyncProviderLAN(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	lanArg := arg["lan"].(interface{})
+	data, err := gulu.JSON.MarshalJSON(lanArg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	lan := &conf.LAN{}
+	if err = gulu.JSON.UnmarshalJSON(data, lan); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	err = model.SetSyncProviderLAN(lan)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"lan": lan,
+	}
+}
+
+func discoverLANSyncHosts(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"hosts": model.DiscoverLANSyncHosts(),
+	}
+}
+
+func getLANSyncPeerFiles(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	files, err := model.GetLANSyncHostFiles()
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"files": files,
+	}
+}
+
+func getLANSyncPeerFile(c *gin.Context) {
+	absPath, err := model.GetLANSyncHostFilePath(c.Query("path"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if !gulu.File.IsExist(absPath) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.File(absPath)
+}
+
+func putLANSyncPeerFile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	file, err := model.PutLANSyncHostFile(c.Query("path"), c.Request.Body)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = file
+}
+
+func removeLANSyncPeerFile(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	deviceID, _ := arg["deviceID"].(string)
+	if err := model.RemoveLANSyncHostFile(arg["path"].(string), deviceID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+	}
+}
+
+func lanSyncPeerWebSocket(c *gin.Context) {
+	model.ServeLANSyncPeerWebSocket(c.Writer, c.Request)
+}
+
 func setCloudSyncDir(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -578,6 +578,72 @@ func setAPIToken(c *gin.Context) {
 	model.Conf.Save()
 }
 
+func getAPITokens(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = map[string]interface{}{
+		"tokens": model.GetAPITokens(),
+	}
+}
+
+func createAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name := arg["name"].(string)
+	role := model.RoleReader
+	if nil != arg["role"] {
+		role = model.Role(arg["role"].(float64))
+	}
+	var notebooks, groups []string
+	if nil != arg["notebooks"] {
+		for _, notebook := range arg["notebooks"].([]interface{}) {
+			notebooks = append(notebooks, notebook.(string))
+		}
+	}
+	if nil != arg["groups"] {
+		for _, group := range arg["groups"].([]interface{}) {
+			groups = append(groups, group.(string))
+		}
+	}
+	var expired int64
+	if nil != arg["expired"] {
+		expired = int64(arg["expired"].(float64))
+	}
+
+	token, apiToken, err := model.CreateAPIToken(name, role, notebooks, groups, expired)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"token":    token,
+		"apiToken": apiToken,
+	}
+}
+
+func removeAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	model.RemoveAPIToken(id)
+}
+
 func setAccessAuthCode(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -60,16 +60,19 @@ func performTransactions(c *gin.Context) {
 		ret.Msg = "parses request failed"
 		return
 	}
+
+	app := arg["app"].(string)
+	session := arg["session"].(string)
 	for _, transaction := range transactions {
 		transaction.Timestamp = timestamp
+		transaction.Session = session
 	}
+	setTransactionsAuthor(c, transactions)
 
 	model.PerformTransactions(&transactions)
 
 	ret.Data = transactions
 
-	app := arg["app"].(string)
-	session := arg["session"].(string)
 	pushTransactions(app, session, transactions)
 
 	if model.IsMoveOutlineHeading(&transactions) {
@@ -82,6 +85,14 @@ func performTransactions(c *gin.Context) {
 	c.Header("Server-Timing", fmt.Sprintf("total;dur=%d", elapsed))
 }
 
+// setTransactionsAuthor 使用请求的身份设置事务的提交者，忽略客户端传入的值。
+func setTransactionsAuthor(c *gin.Context, transactions []*model.Transaction) {
+	author := model.GetGinContextUser(c)
+	for _, transaction := range transactions {
+		transaction.Author = author
+	}
+}
+
 func pushTransactions(app, session string, transactions []*model.Transaction) {
 	pushMode := util.PushModeBroadcastExcludeSelf
 	if 0 < len(transactions) && 0 < len(transactions[0].DoOperations) {
@@ -98,8 +109,32 @@ func pushTransactions(app, session string, transactions []*model.Transaction) {
 	evt.AppId = app
 	evt.SessionId = session
 	evt.Data = transactions
+	var mergedOps []*model.Operation
+	var conflicts []string
 	for _, tx := range transactions {
 		tx.WaitForCommit()
+		mergedOps = append(mergedOps, tx.MergedOperations()...)
+		conflicts = append(conflicts, tx.Conflicts()...)
 	}
 	util.PushEvent(evt)
+
+	if 0 < len(mergedOps) {
+		// 合并了其他会话的并发修改，提交者也需要应用合并后的结果
+		mergedEvt := util.NewCmdResult("transactions", 0, util.PushModeSingleSelf)
+		mergedEvt.AppId = app
+		mergedEvt.SessionId = session
+		mergedEvt.Data = []*model.Transaction{{DoOperations: mergedOps}}
+		util.PushEvent(mergedEvt)
+	}
+
+	if 0 < len(conflicts) {
+		// 和其他会话的修改冲突，重叠的内容两边都已保留或者操作被拒绝，提示提交者处理
+		conflictEvt := util.NewCmdResult("txerr", 0, util.PushModeSingleSelf)
+		conflictEvt.Code = model.TxErrCodeConflict
+		conflictEvt.AppId = app
+		conflictEvt.SessionId = session
+		conflictEvt.Msg = "Your changes conflict with concurrent changes by other users, please check the blocks"
+		conflictEvt.Data = map[string]interface{}{"ids": conflicts}
+		util.PushEvent(conflictEvt)
+	}
 }
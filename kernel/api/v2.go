@@ -0,0 +1,269 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/model"
+)
+
+// /api/v2 的错误码和 HTTP 状态码一致，成功时为 0。
+//
+// 鉴权等中间件终止的请求也会被转换为相同的格式，比如未登录时 HTTP 状态码和错误码都是 401。
+const (
+	v2CodeOK              = 0
+	v2CodeInvalidArgument = http.StatusBadRequest          // 请求参数不合法，data.errors 中为各个字段的校验错误
+	v2CodeUnauthorized    = http.StatusUnauthorized        // 未通过鉴权
+	v2CodeForbidden       = http.StatusForbidden           // 没有权限
+	v2CodeNotFound        = http.StatusNotFound            // 块、文档或者笔记本不存在
+	v2CodeFailed          = http.StatusUnprocessableEntity // 参数合法但是操作失败，msg 为失败原因
+	v2CodeInternal        = http.StatusInternalServerError // 内核内部错误
+)
+
+// v2Result 是 /api/v2 的统一响应格式。
+type v2Result struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+}
+
+// v2Error 是 /api/v2 处理函数返回的错误，其他错误会被视为 v2CodeFailed。
+type v2Error struct {
+	Code   int
+	Msg    string
+	Fields []*v2FieldError
+}
+
+func (e *v2Error) Error() string {
+	return e.Msg
+}
+
+// v2FieldError 描述了一个请求参数的校验错误。
+type v2FieldError struct {
+	Field string `json:"field" doc:"参数路径，比如 attrs.name"`
+	Rule  string `json:"rule" doc:"未通过的校验规则，比如 required、nodeid、oneof"`
+	Param string `json:"param,omitempty" doc:"校验规则的参数，比如 oneof 的可选值"`
+}
+
+type v2ErrorData struct {
+	Errors []*v2FieldError `json:"errors,omitempty"`
+}
+
+// v2Empty 用于没有参数或者没有返回数据的接口。
+type v2Empty struct{}
+
+func newV2NotFoundError(id string) *v2Error {
+	return &v2Error{Code: v2CodeNotFound, Msg: "block [" + id + "] not found"}
+}
+
+// v2Route 描述了一个 /api/v2 接口，用于生成 OpenAPI 文档。
+type v2Route struct {
+	Path     string
+	Summary  string
+	Public   bool // 不需要鉴权
+	Request  reflect.Type
+	Response reflect.Type
+}
+
+var v2Routes []*v2Route
+
+// handleV2 注册 /api/v2 接口，请求参数在调用 handler 前按照 binding 标签校验，校验失败时返回 v2CodeInvalidArgument。
+func handleV2[Req, Resp any](ginServer *gin.Engine, path, summary string, handler func(c *gin.Context, req *Req) (*Resp, error), middlewares ...gin.HandlerFunc) {
+	v2Routes = append(v2Routes, &v2Route{
+		Path:     path,
+		Summary:  summary,
+		Public:   1 > len(middlewares),
+		Request:  reflect.TypeOf((*Req)(nil)).Elem(),
+		Response: reflect.TypeOf((*Resp)(nil)).Elem(),
+	})
+
+	handlers := []gin.HandlerFunc{v2Envelope}
+	handlers = append(handlers, middlewares...)
+	handlers = append(handlers, func(c *gin.Context) {
+		if w, ok := c.Writer.(*v2EnvelopeWriter); ok {
+			// 中间件都已经通过，直接写入响应
+			w.passthrough = true
+			c.Writer = w.ResponseWriter
+		}
+
+		req := new(Req)
+		if err := bindV2Request(c, req); nil != err {
+			renderV2Error(c, err)
+			return
+		}
+
+		resp, err := handler(c, req)
+		if nil != err {
+			renderV2Error(c, err)
+			return
+		}
+		if nil == resp {
+			resp = new(Resp)
+		}
+		c.JSON(http.StatusOK, &v2Result{Code: v2CodeOK, Data: resp})
+	})
+	ginServer.Handle(http.MethodPost, path, handlers...)
+}
+
+func bindV2Request(c *gin.Context, req interface{}) error {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return &v2Error{Code: v2CodeInvalidArgument, Msg: "read request body failed: " + err.Error()}
+	}
+	if 1 > len(bytes.TrimSpace(body)) {
+		body = []byte("{}")
+	}
+
+	if err = binding.JSON.BindBody(body, req); nil == err {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		ret := &v2Error{Code: v2CodeInvalidArgument, Msg: "invalid arguments"}
+		for _, fe := range validationErrs {
+			// 去掉顶层结构体名，比如 v2GetBlockKramdownRequest.id
+			field := fe.Namespace()
+			if idx := strings.Index(field, "."); 0 < idx {
+				field = field[idx+1:]
+			}
+			ret.Fields = append(ret.Fields, &v2FieldError{Field: field, Rule: fe.Tag(), Param: fe.Param()})
+		}
+		return ret
+	}
+	return &v2Error{Code: v2CodeInvalidArgument, Msg: "parse request body failed: " + err.Error()}
+}
+
+func renderV2Error(c *gin.Context, err error) {
+	var v2Err *v2Error
+	if !errors.As(err, &v2Err) {
+		switch {
+		case errors.Is(err, model.ErrBlockNotFound), errors.Is(err, model.ErrTreeNotFound):
+			v2Err = &v2Error{Code: v2CodeNotFound, Msg: err.Error()}
+		default:
+			v2Err = &v2Error{Code: v2CodeFailed, Msg: err.Error()}
+		}
+	}
+
+	var data interface{}
+	if 0 < len(v2Err.Fields) {
+		data = &v2ErrorData{Errors: v2Err.Fields}
+	}
+	c.AbortWithStatusJSON(v2Err.Code, &v2Result{Code: v2Err.Code, Msg: v2Err.Msg, Data: data})
+}
+
+// v2Envelope 将鉴权等中间件终止请求时写入的响应转换为 /api/v2 的统一格式。
+func v2Envelope(c *gin.Context) {
+	w := &v2EnvelopeWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+	c.Writer = w
+	c.Next()
+	if w.passthrough {
+		return
+	}
+
+	c.Writer = w.ResponseWriter
+	if !w.written {
+		return
+	}
+
+	code := w.status
+	if http.StatusOK == code {
+		// 只读模式下修改数据的请求被终止时状态码为 200
+		code = v2CodeForbidden
+	}
+	result := gulu.Ret.NewResult()
+	result.Msg = http.StatusText(code)
+	if 0 < w.body.Len() {
+		if err := gulu.JSON.UnmarshalJSON(w.body.Bytes(), result); err != nil {
+			logging.LogWarnf("parse aborted response of [%s] failed: %s", c.Request.URL.Path, err)
+		}
+	}
+	c.JSON(code, &v2Result{Code: code, Msg: result.Msg})
+}
+
+// v2EnvelopeWriter 缓存中间件写入的响应，处理函数开始执行时设置 passthrough 并恢复原始的 ResponseWriter。
+type v2EnvelopeWriter struct {
+	gin.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	written     bool
+	passthrough bool
+}
+
+func (w *v2EnvelopeWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *v2EnvelopeWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *v2EnvelopeWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *v2EnvelopeWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *v2EnvelopeWriter) Status() int {
+	return w.status
+}
+
+func (w *v2EnvelopeWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *v2EnvelopeWriter) Written() bool {
+	return w.written
+}
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// 校验错误中使用 JSON 字段名
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if "-" == name {
+			return ""
+		}
+		if "" == name {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("nodeid", func(fl validator.FieldLevel) bool {
+		return ast.IsNodeIDPattern(fl.Field().String())
+	})
+}
@@ -0,0 +1,268 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+type v2VersionResponse struct {
+	Version string `json:"version" doc:"内核版本"`
+}
+
+func v2Version(c *gin.Context, req *v2Empty) (*v2VersionResponse, error) {
+	return &v2VersionResponse{Version: util.Ver}, nil
+}
+
+type v2ListNotebooksRequest struct {
+	Flashcard bool `json:"flashcard" doc:"只列出包含闪卡的笔记本"`
+}
+
+type v2Notebook struct {
+	ID     string `json:"id" binding:"required"`
+	Name   string `json:"name" binding:"required"`
+	Icon   string `json:"icon"`
+	Sort   int    `json:"sort"`
+	Closed bool   `json:"closed"`
+}
+
+type v2ListNotebooksResponse struct {
+	Notebooks []*v2Notebook `json:"notebooks" binding:"required"`
+}
+
+func v2ListNotebooks(c *gin.Context, req *v2ListNotebooksRequest) (*v2ListNotebooksResponse, error) {
+	var boxes []*model.Box
+	if req.Flashcard {
+		boxes = model.GetFlashcardNotebooks()
+	} else {
+		var err error
+		if boxes, err = model.ListNotebooks(); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := &v2ListNotebooksResponse{Notebooks: []*v2Notebook{}}
+	for _, box := range model.GetPublishAccess(c).FilterNotebooks(boxes) {
+		ret.Notebooks = append(ret.Notebooks, &v2Notebook{ID: box.ID, Name: box.Name, Icon: box.Icon, Sort: box.Sort, Closed: box.Closed})
+	}
+	return ret, nil
+}
+
+type v2CreateDocRequest struct {
+	Notebook string `json:"notebook" binding:"required,nodeid" doc:"笔记本 ID"`
+	Path     string `json:"path" binding:"required" doc:"文档的可读路径，比如 /foo/bar，上级文档不存在时会自动创建"`
+	Markdown string `json:"markdown" doc:"文档内容"`
+	ParentID string `json:"parentID" binding:"omitempty,nodeid" doc:"上级文档 ID"`
+	ID       string `json:"id" binding:"omitempty,nodeid" doc:"新文档的 ID，留空时自动生成"`
+	Tags     string `json:"tags" doc:"文档标签，多个标签使用逗号分隔"`
+}
+
+type v2DocResponse struct {
+	ID string `json:"id" binding:"required" doc:"文档 ID"`
+}
+
+func v2CreateDoc(c *gin.Context, req *v2CreateDocRequest) (*v2DocResponse, error) {
+	box := model.Conf.Box(req.Notebook)
+	if nil == box {
+		return nil, &v2Error{Code: v2CodeNotFound, Msg: "notebook [" + req.Notebook + "] not found"}
+	}
+
+	id := req.ID
+	if "" == id {
+		id = ast.NewNodeID()
+	}
+	id, err := model.CreateWithMarkdown(req.Tags, req.Notebook, normalizeCreateDocHPath(req.Path), req.Markdown, req.ParentID, id, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	model.FlushTxQueue()
+	if b, _ := model.GetBlock(id, nil); nil != b {
+		pushCreate(box, b.Path, map[string]interface{}{})
+	}
+	return &v2DocResponse{ID: id}, nil
+}
+
+type v2IDRequest struct {
+	ID string `json:"id" binding:"required,nodeid" doc:"块 ID"`
+}
+
+type v2RenameDocRequest struct {
+	ID    string `json:"id" binding:"required,nodeid" doc:"文档 ID"`
+	Title string `json:"title" doc:"新标题，为空时使用默认标题"`
+}
+
+func v2RenameDoc(c *gin.Context, req *v2RenameDocRequest) (*v2Empty, error) {
+	return nil, renameDocByID0(c, req.ID, req.Title)
+}
+
+func v2RemoveDoc(c *gin.Context, req *v2IDRequest) (*v2Empty, error) {
+	return nil, removeDocByID0(c, req.ID)
+}
+
+type v2GetBlockKramdownRequest struct {
+	ID   string `json:"id" binding:"required,nodeid" doc:"块 ID"`
+	Mode string `json:"mode" binding:"omitempty,oneof=md textmark" doc:"md 使用 Markdown 标记符导出，textmark 使用 span 标签导出，默认为 md"`
+}
+
+type v2GetBlockKramdownResponse struct {
+	ID       string `json:"id" binding:"required"`
+	Kramdown string `json:"kramdown" binding:"required"`
+}
+
+func v2GetBlockKramdown(c *gin.Context, req *v2GetBlockKramdownRequest) (*v2GetBlockKramdownResponse, error) {
+	if nil == treenode.GetBlockTree(req.ID) {
+		return nil, newV2NotFoundError(req.ID)
+	}
+
+	mode := req.Mode
+	if "" == mode {
+		mode = "md"
+	}
+	return &v2GetBlockKramdownResponse{ID: req.ID, Kramdown: model.GetBlockKramdown(req.ID, mode)}, nil
+}
+
+type v2GetChildBlocksResponse struct {
+	Blocks []*model.ChildBlock `json:"blocks" binding:"required"`
+}
+
+func v2GetChildBlocks(c *gin.Context, req *v2IDRequest) (*v2GetChildBlocksResponse, error) {
+	if nil == treenode.GetBlockTree(req.ID) {
+		return nil, newV2NotFoundError(req.ID)
+	}
+	return &v2GetChildBlocksResponse{Blocks: model.GetChildBlocks(req.ID)}, nil
+}
+
+type v2AppendBlockRequest struct {
+	ParentID string `json:"parentID" binding:"required,nodeid" doc:"父块 ID，比如文档 ID 或者列表项 ID"`
+	Markdown string `json:"markdown" doc:"插入的内容，可以包含多个块"`
+}
+
+type v2BlockIDsResponse struct {
+	IDs []string `json:"ids" binding:"required" doc:"插入的块 ID"`
+}
+
+func v2AppendBlock(c *gin.Context, req *v2AppendBlockRequest) (*v2BlockIDsResponse, error) {
+	if nil == treenode.GetBlockTree(req.ParentID) {
+		return nil, newV2NotFoundError(req.ParentID)
+	}
+
+	luteEngine := util.NewLute()
+	data, err := dataBlockDOM(req.Markdown, luteEngine)
+	if err != nil {
+		return nil, &v2Error{Code: v2CodeInvalidArgument, Msg: err.Error()}
+	}
+
+	ret := &v2BlockIDsResponse{IDs: []string{}}
+	if tree := luteEngine.BlockDOM2Tree(data); nil != tree {
+		for n := tree.Root.FirstChild; nil != n; n = n.Next {
+			if "" != n.ID {
+				ret.IDs = append(ret.IDs, n.ID)
+			}
+		}
+	}
+
+	transactions := []*model.Transaction{
+		{
+			DoOperations: []*model.Operation{
+				{
+					Action:   "appendInsert",
+					Data:     data,
+					ParentID: req.ParentID,
+				},
+			},
+		},
+	}
+	setTransactionsAuthor(c, transactions)
+	model.PerformTransactions(&transactions)
+	model.FlushTxQueue()
+	broadcastTransactions(transactions)
+	return ret, nil
+}
+
+func v2DeleteBlock(c *gin.Context, req *v2IDRequest) (*v2Empty, error) {
+	if nil == treenode.GetBlockTree(req.ID) {
+		return nil, newV2NotFoundError(req.ID)
+	}
+
+	transactions := []*model.Transaction{
+		{
+			DoOperations: []*model.Operation{
+				{
+					Action: "delete",
+					ID:     req.ID,
+				},
+			},
+		},
+	}
+	setTransactionsAuthor(c, transactions)
+	model.PerformTransactions(&transactions)
+	broadcastTransactions(transactions)
+	return nil, nil
+}
+
+type v2BlockAttrsResponse struct {
+	Attrs map[string]string `json:"attrs" binding:"required"`
+}
+
+func v2GetBlockAttrs(c *gin.Context, req *v2IDRequest) (*v2BlockAttrsResponse, error) {
+	if nil == treenode.GetBlockTree(req.ID) {
+		return nil, newV2NotFoundError(req.ID)
+	}
+	return &v2BlockAttrsResponse{Attrs: sql.GetBlockAttrs(req.ID)}, nil
+}
+
+type v2SetBlockAttrsRequest struct {
+	ID    string            `json:"id" binding:"required,nodeid" doc:"块 ID"`
+	Attrs map[string]string `json:"attrs" binding:"required" doc:"属性，值为空字符串时移除该属性，自定义属性需要以 custom- 开头"`
+}
+
+func v2SetBlockAttrs(c *gin.Context, req *v2SetBlockAttrsRequest) (*v2Empty, error) {
+	if nil == treenode.GetBlockTree(req.ID) {
+		return nil, newV2NotFoundError(req.ID)
+	}
+	return nil, setBlockAttrs0(c, req.ID, req.Attrs)
+}
+
+type v2SQLRequest struct {
+	Stmt string `json:"stmt" binding:"required" doc:"SQL 查询语句，结果条数受搜索设置中的数量限制"`
+}
+
+type v2SQLResponse struct {
+	Rows []map[string]interface{} `json:"rows" binding:"required"`
+}
+
+func v2SQL(c *gin.Context, req *v2SQLRequest) (*v2SQLResponse, error) {
+	if nil != model.GetPublishAccess(c) {
+		return nil, &v2Error{Code: v2CodeForbidden, Msg: model.ErrPublishScopeDenied.Error()}
+	}
+
+	rows, err := sql.Query(req.Stmt, model.Conf.Search.Limit)
+	if err != nil {
+		return nil, &v2Error{Code: v2CodeInvalidArgument, Msg: err.Error()}
+	}
+
+	if nil == rows {
+		rows = []map[string]interface{}{}
+	}
+	return &v2SQLResponse{Rows: rows}, nil
+}
@@ -0,0 +1,109 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// TestV2DocHandlersMatchV1 检查 /api/v2 的重命名和删除文档接口与 v1 接口走相同的处理流程。
+func TestV2DocHandlersMatchV1(t *testing.T) {
+	blockTreeDBPath := util.BlockTreeDBPath
+	util.BlockTreeDBPath = filepath.Join(t.TempDir(), "blocktree.db")
+	treenode.InitBlockTree(true)
+	t.Cleanup(func() {
+		treenode.CloseDatabase()
+		util.BlockTreeDBPath = blockTreeDBPath
+	})
+
+	doc := treenode.NewTree("20240101000000-box0000", "/20240101000000-doc0000.sy", "/doc", "doc")
+	treenode.IndexBlockTree(doc)
+	if _, err := model.LockBlock(doc.Root.FirstChild.ID, "bob", 10); err != nil {
+		t.Fatalf("lock block failed: %s", err)
+	}
+	t.Cleanup(func() { model.UnlockBlock(doc.Root.FirstChild.ID, "bob", true) })
+
+	cases := []struct {
+		name    string
+		id      string
+		wantErr string
+	}{
+		{"locked by other user", doc.ID, "is locked by [bob]"},
+		{"missing doc", "20240101000000-missing", model.ErrTreeNotFound.Error()},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v1Rename := callV1Handler(t, renameDocByID, map[string]interface{}{"id": c.id, "title": "foo"})
+			_, v2Rename := v2RenameDoc(newTestContext("/api/v2/filetree/renameDoc"), &v2RenameDocRequest{ID: c.id, Title: "foo"})
+			v1Remove := callV1Handler(t, removeDocByID, map[string]interface{}{"id": c.id})
+			_, v2Remove := v2RemoveDoc(newTestContext("/api/v2/filetree/removeDoc"), &v2IDRequest{ID: c.id})
+
+			results := []struct {
+				api string
+				v1  string
+				v2  error
+			}{
+				{"renameDoc", v1Rename, v2Rename},
+				{"removeDoc", v1Remove, v2Remove},
+			}
+			for _, r := range results {
+				if nil == r.v2 || !strings.Contains(r.v1, c.wantErr) || r.v1 != r.v2.Error() {
+					t.Fatalf("%s: want v1 and v2 error [%s], got v1 [%s] v2 [%v]", r.api, c.wantErr, r.v1, r.v2)
+				}
+			}
+			if nil == treenode.GetBlockTree(doc.ID) {
+				t.Fatalf("doc [%s] should not be removed", doc.ID)
+			}
+		})
+	}
+}
+
+func newTestContext(path string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, path, nil)
+	return ctx
+}
+
+// callV1Handler 调用 v1 接口并返回响应中的错误信息。
+func callV1Handler(t *testing.T, handler gin.HandlerFunc, arg map[string]interface{}) string {
+	body, _ := gulu.JSON.MarshalJSON(arg)
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/api/filetree/test", strings.NewReader(string(body)))
+	handler(ctx)
+
+	ret := gulu.Ret.NewResult()
+	if err := gulu.JSON.UnmarshalJSON(recorder.Body.Bytes(), ret); err != nil {
+		t.Fatalf("unmarshal response failed: %s", err)
+	}
+	if 0 == ret.Code {
+		return ""
+	}
+	return ret.Msg
+}
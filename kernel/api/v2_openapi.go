@@ -0,0 +1,242 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var (
+	v2OpenAPIDoc  map[string]interface{}
+	v2OpenAPIOnce sync.Once
+)
+
+// getV2OpenAPI 返回根据 /api/v2 接口的请求和响应结构体生成的 OpenAPI 3 文档。
+func getV2OpenAPI(c *gin.Context) {
+	v2OpenAPIOnce.Do(func() {
+		v2OpenAPIDoc = genV2OpenAPI()
+	})
+	c.JSON(http.StatusOK, v2OpenAPIDoc)
+}
+
+func genV2OpenAPI() map[string]interface{} {
+	g := &v2SchemaGenerator{schemas: map[string]interface{}{}}
+
+	errorResponse := func(desc string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": desc,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"},
+				},
+			},
+		}
+	}
+	g.schemas["Error"] = map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "msg"},
+		"properties": map[string]interface{}{
+			"code": map[string]interface{}{"type": "integer", "description": "错误码，和 HTTP 状态码一致"},
+			"msg":  map[string]interface{}{"type": "string"},
+			"data": map[string]interface{}{"nullable": true, "allOf": []interface{}{g.schema(reflect.TypeOf(v2ErrorData{}))}},
+		},
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range v2Routes {
+		group, action := v2RouteGroupAction(route.Path)
+		op := map[string]interface{}{
+			"operationId": group + strings.ToUpper(action[:1]) + action[1:],
+			"tags":        []string{group},
+			"summary":     route.Summary,
+			"requestBody": map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": g.schema(route.Request)},
+				},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":     "object",
+								"required": []string{"code", "msg", "data"},
+								"properties": map[string]interface{}{
+									"code": map[string]interface{}{"type": "integer", "enum": []int{v2CodeOK}},
+									"msg":  map[string]interface{}{"type": "string"},
+									"data": g.schema(route.Response),
+								},
+							},
+						},
+					},
+				},
+				"400": errorResponse("Invalid arguments"),
+				"401": errorResponse("Unauthorized"),
+				"403": errorResponse("Forbidden"),
+				"404": errorResponse("Not found"),
+				"422": errorResponse("Operation failed"),
+				"500": errorResponse("Internal error"),
+			},
+		}
+		if route.Public {
+			op["security"] = []interface{}{}
+		}
+		paths[route.Path] = map[string]interface{}{"post": op}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "SiYuan Kernel API",
+			"version": util.Ver,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "Token xxx",
+				},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"token": []string{}}},
+	}
+}
+
+func v2RouteGroupAction(p string) (group, action string) {
+	parts := strings.Split(strings.TrimPrefix(p, "/api/v2/"), "/")
+	group, action = parts[0], parts[len(parts)-1]
+	return
+}
+
+// v2SchemaGenerator 通过反射生成 JSON Schema，具名结构体放到 components.schemas 中引用。
+type v2SchemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+func (g *v2SchemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if reflect.Uint8 == t.Elem().Kind() {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := v2SchemaName(t)
+		if "" == name {
+			return g.structSchema(t)
+		}
+		if _, exists := g.schemas[name]; !exists {
+			g.schemas[name] = map[string]interface{}{} // 先占位，避免递归引用时死循环
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	// interface{} 等任意类型
+	return map[string]interface{}{}
+}
+
+func (g *v2SchemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	g.collectFields(t, properties, &required)
+
+	ret := map[string]interface{}{"type": "object", "properties": properties}
+	if 0 < len(required) {
+		ret["required"] = required
+	}
+	return ret
+}
+
+func (g *v2SchemaGenerator) collectFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")
+		name := jsonTag[0]
+		if "-" == name {
+			continue
+		}
+		if field.Anonymous && "" == name && reflect.Struct == field.Type.Kind() {
+			g.collectFields(field.Type, properties, required)
+			continue
+		}
+		if "" == name {
+			name = field.Name
+		}
+
+		prop := g.schema(field.Type)
+		if _, isRef := prop["$ref"]; isRef {
+			// 引用不能和其他关键字并列
+			prop = map[string]interface{}{"allOf": []interface{}{prop}}
+		}
+		if doc := field.Tag.Get("doc"); "" != doc {
+			prop["description"] = doc
+		}
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			key, param, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				*required = append(*required, name)
+			case "nodeid":
+				prop["pattern"] = `^\d{14}-[0-9a-z]{7}$`
+			case "oneof":
+				prop["enum"] = strings.Fields(param)
+			}
+		}
+		properties[name] = prop
+	}
+}
+
+// v2SchemaName 返回结构体在 components.schemas 中的名称，去掉 v2 前缀，匿名结构体返回空字符串。
+func v2SchemaName(t reflect.Type) string {
+	name := strings.TrimPrefix(t.Name(), "v2")
+	if "" == name {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -19,11 +19,26 @@ package conf
 import "github.com/88250/gulu"
 
 type API struct {
-	Token string `json:"token"`
+	Token  string      `json:"token"`
+	Tokens []*APIToken `json:"tokens"` // 具名 API 令牌，可以限定角色、笔记本和接口分组
+}
+
+// APIToken 描述了一个具名 API 令牌，令牌本身只在创建时返回，配置中仅保存其哈希。
+type APIToken struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	TokenHash string   `json:"tokenHash"` // 令牌的 SHA-256 哈希
+	Role      uint     `json:"role"`      // 角色，取值同 model.Role，仅支持管理员、编辑者和读者
+	Notebooks []string `json:"notebooks"` // 允许访问的笔记本 ID，为空时不限制
+	Groups    []string `json:"groups"`    // 允许调用的接口分组，即 /api/{group}/ 中的 group，为空时不限制
+	Expired   int64    `json:"expired"`   // 过期时间，单位毫秒，0 为永不过期
+	Created   int64    `json:"created"`
+	LastUsed  int64    `json:"lastUsed"` // 最近使用时间，单位毫秒
 }
 
 func NewAPI() *API {
 	return &API{
-		Token: gulu.Rand.String(16),
+		Token:  gulu.Rand.String(16),
+		Tokens: []*APIToken{},
 	}
 }
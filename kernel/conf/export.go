@@ -41,6 +41,47 @@ type Export struct {
 	PDFWatermarkDesc      string `json:"pdfWatermarkDesc"`      // PDF 导出时水印位置、大小和样式等
 	ImageWatermarkStr     string `json:"imageWatermarkStr"`     // 图片导出时水印文本或水印文件路径
 	ImageWatermarkDesc    string `json:"imageWatermarkDesc"`    // 图片导出时水印位置、大小和样式等
+	BibAvID               string `json:"bibAvID"`               // LaTeX 导出时参考文献数据库 ID，引用该数据库中的块时生成 \cite 和 .bib 文件
+
+	Filter   *ExportFilter    `json:"filter"`   // 导出时过滤块的规则
+	Profiles []*ExportProfile `json:"profiles"` // 导出配置方案
+
+	Profile *ExportProfile `json:"-"` // 本次导出使用的配置方案，仅在导出时有效，不持久化
+}
+
+// ExportFilter 描述了导出时需要移除的块，避免导出内部内容。行级备忘录是否导出由 Export.InlineMemo 控制。
+type ExportFilter struct {
+	Attrs      map[string]string `json:"attrs"`      // 移除包含这些属性的块，属性值为空时仅判断属性是否存在，比如 custom-private: true
+	Tags       []string          `json:"tags"`       // 移除包含这些标签的块，比如 draft
+	BlockTypes []string          `json:"blockTypes"` // 移除这些类型的块，使用块类型缩写，比如 c 为代码块，html 为 HTML 块
+	Comment    bool              `json:"comment"`    // 是否移除 HTML 注释和块备注
+}
+
+func NewExportFilter() *ExportFilter {
+	return &ExportFilter{
+		Attrs:      map[string]string{},
+		Tags:       []string{},
+		BlockTypes: []string{},
+	}
+}
+
+// ExportProfile 描述了一个命名的导出配置方案，用于针对不同受众复用导出配置。
+type ExportProfile struct {
+	Name         string                 `json:"name"`         // 配置方案名称
+	Overrides    map[string]interface{} `json:"overrides"`    // 覆盖的导出配置项，键为 Export 的 JSON 字段名，比如 blockRefMode
+	IncludeTags  []string               `json:"includeTags"`  // 仅导出包含这些标签的文档，为空时不限制
+	IncludeAttrs map[string]string      `json:"includeAttrs"` // 仅导出包含这些属性的文档，属性值为空时仅判断属性是否存在
+	ExcludeTags  []string               `json:"excludeTags"`  // 不导出包含这些标签的文档和块
+	ExcludeAttrs map[string]string      `json:"excludeAttrs"` // 不导出包含这些属性的文档和块，属性值为空时仅判断属性是否存在
+}
+
+func (export *Export) GetProfile(name string) *ExportProfile {
+	for _, profile := range export.Profiles {
+		if profile.Name == name {
+			return profile
+		}
+	}
+	return nil
 }
 
 func NewExport() *Export {
@@ -58,5 +99,6 @@ func NewExport() *Export {
 		MarkdownYFM:             false,
 		InlineMemo:              false,
 		PDFFooter:               "%page / %pages",
+		Filter:                  NewExportFilter(),
 	}
 }
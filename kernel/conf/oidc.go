@@ -0,0 +1,47 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// OIDC 描述了 OpenID Connect 单点登录配置，使用授权码模式和 PKCE 登录。
+type OIDC struct {
+	Enabled      bool          `json:"enabled"`      // 是否启用单点登录
+	Issuer       string        `json:"issuer"`       // 身份提供方地址，通过 {issuer}/.well-known/openid-configuration 发现端点
+	ClientID     string        `json:"clientID"`     // 客户端 ID
+	ClientSecret string        `json:"clientSecret"` // 客户端密钥，公开客户端留空
+	RedirectURL  string        `json:"redirectURL"`  // 回调地址，需要填写外部访问的完整地址，例如 https://example.com/auth/oidc/callback
+	Scopes       []string      `json:"scopes"`       // 请求的范围，openid 会自动添加
+	RoleClaim    string        `json:"roleClaim"`    // ID 令牌中用于映射角色的声明，值可以是字符串或者字符串数组
+	AdminValues  []string      `json:"adminValues"`  // 映射为管理员的声明值
+	EditorValues []string      `json:"editorValues"` // 映射为编辑者的声明值
+	ReaderValues []string      `json:"readerValues"` // 映射为读者的声明值
+	DefaultRole  int           `json:"defaultRole"`  // 没有匹配的声明值时使用的角色，-1 为拒绝登录
+	Publish      bool          `json:"publish"`      // 发布服务是否允许通过单点登录访问
+	PublishScope *PublishScope `json:"publishScope"` // 通过单点登录访问发布服务时的访问范围，为空时可以访问所有笔记本
+	SessionHours int           `json:"sessionHours"` // 登录会话有效时长，单位小时
+}
+
+func NewOIDC() *OIDC {
+	return &OIDC{
+		Scopes:       []string{"openid", "profile", "email"},
+		RoleClaim:    "groups",
+		AdminValues:  []string{},
+		EditorValues: []string{},
+		ReaderValues: []string{},
+		DefaultRole:  -1,
+		SessionHours: 24,
+	}
+}
@@ -28,9 +28,21 @@ type BasicAuth struct {
 }
 
 type BasicAuthAccount struct {
-	Username string `json:"username"` // 用户名
-	Password string `json:"password"` // 密码
-	Memo     string `json:"memo"`     // 备注
+	Username string        `json:"username"` // 用户名
+	Password string        `json:"password"` // 密码
+	Memo     string        `json:"memo"`     // 备注
+	Scope    *PublishScope `json:"scope"`    // 访问范围，为空时可以访问所有笔记本
+}
+
+// PublishScope 描述了发布服务账号可以访问的范围，满足任意一项即可访问。
+type PublishScope struct {
+	Notebooks []string `json:"notebooks"` // 笔记本 ID
+	Docs      []string `json:"docs"`      // 文档 ID，包括其子文档
+	Tags      []string `json:"tags"`      // 标签，包含这些标签的文档
+}
+
+func (scope *PublishScope) IsEmpty() bool {
+	return nil == scope || (1 > len(scope.Notebooks) && 1 > len(scope.Docs) && 1 > len(scope.Tags))
 }
 
 func NewPublish() *Publish {
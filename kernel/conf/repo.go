@@ -23,7 +23,8 @@ import (
 )
 
 type Repo struct {
-	Key []byte `json:"key"` // AES 密钥
+	Key         []byte `json:"key"`         // AES 密钥
+	RotatingKey []byte `json:"rotatingKey"` // 正在轮换的新密钥，轮换完成后替换 Key
 
 	// 同步索引计时，单位毫秒，超过该时间则提示用户索引性能下降
 	// If the data repo indexing time is greater than 12s, prompt user to purge the data repo https://github.com/siyuan-note/siyuan/issues/9613
@@ -33,13 +34,37 @@ type Repo struct {
 	// 自动清理数据仓库 Automatic purge for local data repo https://github.com/siyuan-note/siyuan/issues/13091
 	IndexRetentionDays    int `json:"indexRetentionDays"`    // 索引保留天数
 	RetentionIndexesDaily int `json:"retentionIndexesDaily"` // 每日保留索引数
+
+	// 定时快照
+	SnapshotInterval       int                `json:"snapshotInterval"`       // 定时快照间隔，单位分钟，0 为关闭
+	SnapshotRetention      *SnapshotRetention `json:"snapshotRetention"`      // 定时快照保留策略
+	SnapshotBeforeRiskyOps bool               `json:"snapshotBeforeRiskyOps"` // 是否在批量替换、清理资源等高风险操作前创建快照
+}
+
+// SnapshotRetention 描述了定时快照的 GFS 保留策略，每个周期保留的快照会被自动打上标签，0 为不保留该周期的快照。
+type SnapshotRetention struct {
+	Hourly  int `json:"hourly"`  // 保留最近多少小时的快照
+	Daily   int `json:"daily"`   // 保留最近多少天的快照
+	Weekly  int `json:"weekly"`  // 保留最近多少周的快照
+	Monthly int `json:"monthly"` // 保留最近多少月的快照
+}
+
+func NewSnapshotRetention() *SnapshotRetention {
+	return &SnapshotRetention{
+		Hourly:  24,
+		Daily:   7,
+		Weekly:  4,
+		Monthly: 12,
+	}
 }
 
 func NewRepo() *Repo {
 	return &Repo{
-		SyncIndexTiming:       12 * 1000,
-		IndexRetentionDays:    180,
-		RetentionIndexesDaily: 2,
+		SyncIndexTiming:        12 * 1000,
+		IndexRetentionDays:     180,
+		RetentionIndexesDaily:  2,
+		SnapshotRetention:      NewSnapshotRetention(),
+		SnapshotBeforeRiskyOps: true,
 	}
 }
 
@@ -29,8 +29,21 @@ type Sync struct {
 	S3                  *S3     `json:"s3"`                  // S3 对象存储服务配置
 	WebDAV              *WebDAV `json:"webdav"`              // WebDAV 服务配置
 	Local               *Local  `json:"local"`               // 本地文件系统 服务配置
+	SFTP                *SFTP   `json:"sftp"`                // SFTP 服务配置
+	Git                 *Git    `json:"git"`                 // Git 远端仓库配置
+	LAN                 *LAN    `json:"lan"`                 // 局域网同步配置
+
+	Notebooks    map[string]int `json:"notebooks"`    // 笔记本在当前设备上的同步范围，键为笔记本 ID，值为 SyncScopeXXX，未设置的笔记本为 SyncScopeSync
+	ExcludePaths []string       `json:"excludePaths"` // 当前设备不同步的路径，相对于 data 目录，语法和 syncignore 一致
+	SyncedIndex  string         `json:"syncedIndex"`  // 最近一次同步完成时的快照 ID
 }
 
+const (
+	SyncScopeSync         = 0 // 上传和下载
+	SyncScopeLocalOnly    = 1 // 仅保存在本地，不上传也不下载
+	SyncScopeDownloadOnly = 2 // 仅下载，同步前会丢弃本地修改
+)
+
 func NewSync() *Sync {
 	return &Sync{
 		CloudName:           "main",
@@ -40,6 +53,7 @@ func NewSync() *Sync {
 		GenerateConflictDoc: false,
 		Provider:            ProviderSiYuan,
 		Interval:            30,
+		Notebooks:           map[string]int{},
 	}
 }
 
@@ -70,11 +84,40 @@ type Local struct {
 	ConcurrentReqs int    `json:"concurrentReqs"` // 并发请求数
 }
 
+type SFTP struct {
+	Endpoint       string `json:"endpoint"`       // 服务地址，格式为 host:port
+	Username       string `json:"username"`       // 用户名
+	PrivateKey     string `json:"privateKey"`     // 私钥文件路径
+	Passphrase     string `json:"passphrase"`     // 私钥密码
+	HostKey        string `json:"hostKey"`        // 服务端公钥 SHA256 指纹，为空时使用 ~/.ssh/known_hosts 校验
+	Path           string `json:"path"`           // 远端存储目录
+	Timeout        int    `json:"timeout"`        // 超时时间，单位：秒
+	ConcurrentReqs int    `json:"concurrentReqs"` // 并发请求数
+}
+
+type Git struct {
+	Endpoint       string `json:"endpoint"`       // 远端仓库地址，支持 ssh 和 file:// 协议
+	Branch         string `json:"branch"`         // 分支
+	PrivateKey     string `json:"privateKey"`     // 私钥文件路径，为空时使用系统 ssh 配置
+	Timeout        int    `json:"timeout"`        // 超时时间，单位：秒
+	ConcurrentReqs int    `json:"concurrentReqs"` // 并发请求数
+}
+
+type LAN struct {
+	Endpoint       string `json:"endpoint"`       // 主机地址，格式为 host:port，为空时当前内核作为主机为局域网内的其他内核提供数据仓库
+	Token          string `json:"token"`          // 主机和对端共享的访问令牌
+	Timeout        int    `json:"timeout"`        // 超时时间，单位：秒
+	ConcurrentReqs int    `json:"concurrentReqs"` // 并发请求数
+}
+
 const (
 	ProviderSiYuan = 0 // ProviderSiYuan 为思源官方提供的云端存储服务
 	ProviderS3     = 2 // ProviderS3 为 S3 协议对象存储提供的云端存储服务
 	ProviderWebDAV = 3 // ProviderWebDAV 为 WebDAV 协议提供的云端存储服务
 	ProviderLocal  = 4 // ProviderLocal 为本地文件系统提供的存储服务
+	ProviderSFTP   = 5 // ProviderSFTP 为 SFTP 协议提供的存储服务
+	ProviderGit    = 6 // ProviderGit 为 Git 远端仓库提供的存储服务
+	ProviderLAN    = 7 // ProviderLAN 为局域网内其他内核提供的存储服务
 )
 
 func ProviderToStr(provider int) string {
@@ -87,6 +130,12 @@ func ProviderToStr(provider int) string {
 		return "WebDAV"
 	case ProviderLocal:
 		return "Local File System"
+	case ProviderSFTP:
+		return "SFTP"
+	case ProviderGit:
+		return "Git"
+	case ProviderLAN:
+		return "LAN"
 	}
 	return "Unknown"
 }
@@ -0,0 +1,28 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// Webhook 描述了一个外发的 Webhook，内容事件发生时向 URL 推送使用 HMAC-SHA256 签名的 JSON。
+type Webhook struct {
+	ID      string   `json:"id"`      // ID
+	Name    string   `json:"name"`    // 名称
+	URL     string   `json:"url"`     // 接收地址
+	Secret  string   `json:"secret"`  // 签名密钥
+	Events  []string `json:"events"`  // 订阅的事件，为空时订阅所有事件
+	Enabled bool     `json:"enabled"` // 是否启用
+	Created int64    `json:"created"` // 创建时间
+}
@@ -44,6 +44,9 @@ func StartCron() {
 	go every(30*time.Second, model.FlushAssetsTextsJob)
 	go every(30*time.Second, model.HookDesktopUIProcJob)
 	go every(24*time.Hour, model.AutoPurgeRepoJob)
+	go every(24*time.Hour, model.PurgeAuditLogJob)
+	go every(30*time.Second, model.WebhookRetryJob)
+	go every(time.Minute, model.AutoSnapshotRepoJob)
 	go every(30*time.Minute, model.AutoCheckMicrosoftDefenderJob)
 
 	// TODO: 移除旧方案 https://github.com/siyuan-note/siyuan/issues/14414 实现新的刷新机制
@@ -36,6 +36,7 @@ func main() {
 	sql.InitDatabase(false)
 	sql.InitHistoryDatabase(false)
 	sql.InitAssetContentDatabase(false)
+	sql.InitAuditDatabase()
 	sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
 	sql.SetIndexAssetPath(model.Conf.Search.IndexAssetPath)
 
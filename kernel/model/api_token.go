@@ -0,0 +1,529 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// APITokenContextKey 通过具名 API 令牌认证时，上下文中保存令牌 ID。
+const APITokenContextKey = "apiToken"
+
+// apiTokenEditableGroups 编辑者令牌和单点登录的编辑者可以调用的需要管理员角色的接口分组，仅涉及文档内容的编辑。
+var apiTokenEditableGroups = map[string]bool{
+	"attr":         true,
+	"av":           true,
+	"block":        true,
+	"filetree":     true,
+	"format":       true,
+	"notebook":     true,
+	"riff":         true,
+	"tag":          true,
+	"template":     true,
+	"transactions": true,
+}
+
+// apiTokenNotebookArgs 直接指定笔记本 ID 的参数，仅用于审计和事件等记录请求涉及的笔记本，不能用于鉴权。
+var apiTokenNotebookArgs = map[string]bool{
+	"notebook":   true,
+	"box":        true,
+	"toNotebook": true,
+}
+
+// apiTokenBlockArgs 指定块 ID 的参数，通过块树查询块所在的笔记本。
+var apiTokenBlockArgs = map[string]bool{
+	"id":         true,
+	"ids":        true,
+	"rootID":     true,
+	"parentID":   true,
+	"previousID": true,
+	"nextID":     true,
+	"fromID":     true,
+	"toID":       true,
+	"defID":      true,
+	"refTreeID":  true,
+}
+
+// apiTokenLastUsedSaveInterval 令牌的最近使用时间在内存中实时更新，超过该间隔才会保存到配置文件中。
+const apiTokenLastUsedSaveInterval = time.Minute
+
+func GetAPITokens() (ret []*conf.APIToken) {
+	Conf.m.Lock()
+	defer Conf.m.Unlock()
+
+	ret = []*conf.APIToken{}
+	for _, t := range Conf.Api.Tokens {
+		cloned := *t
+		ret = append(ret, &cloned)
+	}
+	return
+}
+
+// CreateAPIToken 创建具名 API 令牌，返回的令牌明文只有这一次可以获取。
+func CreateAPIToken(name string, role Role, notebooks, groups []string, expired int64) (token string, ret *conf.APIToken, err error) {
+	name = strings.TrimSpace(name)
+	if "" == name {
+		err = errors.New("token name is required")
+		return
+	}
+	if !IsValidRole(role, []Role{RoleAdministrator, RoleEditor, RoleReader}) {
+		err = errors.New("invalid token role")
+		return
+	}
+	if 0 != expired && expired <= time.Now().UnixMilli() {
+		err = errors.New("token expiration must be in the future")
+		return
+	}
+	for _, notebook := range notebooks {
+		if !ast.IsNodeIDPattern(notebook) {
+			err = errors.New("invalid notebook ID [" + notebook + "]")
+			return
+		}
+	}
+	for i, group := range groups {
+		groups[i] = strings.Trim(strings.TrimSpace(group), "/")
+	}
+
+	randomBytes := make([]byte, 24)
+	if _, err = rand.Read(randomBytes); err != nil {
+		return
+	}
+	token = "siyuan_" + hex.EncodeToString(randomBytes)
+
+	ret = &conf.APIToken{
+		ID:        ast.NewNodeID(),
+		Name:      name,
+		TokenHash: hashAPIToken(token),
+		Role:      uint(role),
+		Notebooks: notebooks,
+		Groups:    groups,
+		Expired:   expired,
+		Created:   time.Now().UnixMilli(),
+	}
+
+	Conf.m.Lock()
+	Conf.Api.Tokens = append(Conf.Api.Tokens, ret)
+	Conf.m.Unlock()
+	Conf.Save()
+	logging.LogInfof("created API token [%s, %s]", ret.ID, ret.Name)
+	return
+}
+
+func RemoveAPIToken(id string) {
+	Conf.m.Lock()
+	var tokens []*conf.APIToken
+	for _, t := range Conf.Api.Tokens {
+		if t.ID != id {
+			tokens = append(tokens, t)
+		}
+	}
+	if nil == tokens {
+		tokens = []*conf.APIToken{}
+	}
+	Conf.Api.Tokens = tokens
+	Conf.m.Unlock()
+	Conf.Save()
+	logging.LogInfof("removed API token [%s]", id)
+}
+
+// authAPIToken 校验具名 API 令牌，通过后设置角色并记录最近使用时间。
+//
+// found 为 false 时不是具名令牌，ok 为 false 时请求已经被终止。
+func authAPIToken(c *gin.Context, token string) (found, ok bool) {
+	apiToken := getAPIToken(token)
+	if nil == apiToken {
+		return
+	}
+	found = true
+
+	now := time.Now().UnixMilli()
+	if 0 != apiToken.Expired && apiToken.Expired <= now {
+		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed: API token has expired"})
+		c.Abort()
+		return
+	}
+	if err := checkAPITokenScope(c, apiToken); err != nil {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Permission denied: " + err.Error()})
+		c.Abort()
+		return
+	}
+
+	// 最近使用时间和配置文件的序列化共用配置锁，避免保存配置时产生数据竞争
+	Conf.m.Lock()
+	save := apiTokenLastUsedSaveInterval.Milliseconds() < now-apiToken.LastUsed
+	apiToken.LastUsed = now
+	Conf.m.Unlock()
+	if save {
+		Conf.Save()
+	}
+
+	c.Set(RoleContextKey, Role(apiToken.Role))
+	c.Set(APITokenContextKey, apiToken.ID)
+	ok = true
+	return
+}
+
+func getAPIToken(token string) *conf.APIToken {
+	hash := hashAPIToken(token)
+
+	Conf.m.Lock()
+	defer Conf.m.Unlock()
+	for _, t := range Conf.Api.Tokens {
+		if 1 == subtle.ConstantTimeCompare([]byte(t.TokenHash), []byte(hash)) {
+			return t
+		}
+	}
+	return nil
+}
+
+func hashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// checkAPITokenScope 检查请求是否在令牌允许的接口分组和笔记本范围内。
+//
+// 限定了笔记本的令牌只能调用 apiTokenNotebookRoutes 中声明了笔记本解析方式的接口，其他接口一律拒绝，
+// 包括按文件路径读写的 /api/file/ 和 SQL 查询等无法确定笔记本的接口。
+func checkAPITokenScope(c *gin.Context, apiToken *conf.APIToken) error {
+	group := apiRouteGroup(c.Request.URL.Path)
+	if 0 < len(apiToken.Groups) && !gulu.Str.Contains(group, apiToken.Groups) {
+		return errors.New("API group [" + group + "] is not allowed for this token")
+	}
+	if 1 > len(apiToken.Notebooks) {
+		return nil
+	}
+
+	resolve := apiTokenNotebookRoutes[c.Request.URL.Path]
+	if nil == resolve {
+		return errors.New("API [" + c.Request.URL.Path + "] is not available for tokens limited to specific notebooks")
+	}
+	arg, _ := readAPIRequestArg(c).(map[string]interface{})
+	if nil == arg {
+		return errors.New("this token is limited to specific notebooks, but the notebook of the request cannot be determined")
+	}
+	notebooks, ids, ok := resolve(arg)
+	if !ok || (1 > len(notebooks) && 1 > len(ids)) {
+		return errors.New("this token is limited to specific notebooks, but the notebook of the request cannot be determined")
+	}
+
+	ids = gulu.Str.RemoveDuplicatedElem(ids)
+	bts := treenode.GetBlockTrees(ids)
+	for _, id := range ids {
+		bt := bts[id]
+		if nil == bt {
+			return errors.New("block [" + id + "] not found")
+		}
+		notebooks = append(notebooks, bt.BoxID)
+	}
+	for _, notebook := range notebooks {
+		if !gulu.Str.Contains(notebook, apiToken.Notebooks) {
+			return errors.New("notebook [" + notebook + "] is not allowed for this token")
+		}
+	}
+	return nil
+}
+
+// apiTokenRouteNotebooks 解析请求参数中的笔记本 ID 和块 ID，ok 为 false 时说明参数不合法或无法确定笔记本。
+type apiTokenRouteNotebooks func(arg map[string]interface{}) (notebooks, ids []string, ok bool)
+
+// apiTokenNotebookRoutes 限定了笔记本的令牌可以调用的接口，以及每个接口确定笔记本的方式。
+//
+// 这里只列出只涉及参数中指定的笔记本或块的接口，搜索、反链、数据库等结果可能跨笔记本的接口不要加到这里。
+var apiTokenNotebookRoutes = map[string]apiTokenRouteNotebooks{
+	"/api/notebook/getNotebookConf": apiTokenArgNotebooks("notebook"),
+	"/api/notebook/setNotebookConf": apiTokenArgNotebooks("notebook"),
+	"/api/notebook/getNotebookInfo": apiTokenArgNotebooks("notebook"),
+	"/api/notebook/renameNotebook":  apiTokenArgNotebooks("notebook"),
+	"/api/notebook/setNotebookIcon": apiTokenArgNotebooks("notebook"),
+	"/api/notebook/openNotebook":    apiTokenArgNotebooks("notebook"),
+	"/api/notebook/closeNotebook":   apiTokenArgNotebooks("notebook"),
+
+	"/api/filetree/listDocsByPath":  apiTokenArgNotebooks("notebook"),
+	"/api/filetree/getDoc":          apiTokenArgBlocks("id"),
+	"/api/filetree/createDoc":       apiTokenArgNotebooks("notebook"),
+	"/api/filetree/createDocWithMd": apiTokenArgs(apiTokenArgNotebooks("notebook"), apiTokenArgBlocks("parentID")),
+	"/api/filetree/renameDoc":       apiTokenArgNotebooks("notebook"),
+	"/api/filetree/renameDocByID":   apiTokenArgBlocks("id"),
+	"/api/filetree/removeDoc":       apiTokenArgNotebooks("notebook"),
+	"/api/filetree/removeDocByID":   apiTokenArgBlocks("id"),
+	"/api/filetree/getHPathByPath":  apiTokenArgNotebooks("notebook"),
+	"/api/filetree/getHPathByID":    apiTokenArgBlocks("id"),
+	"/api/filetree/getPathByID":     apiTokenArgBlocks("id"),
+	"/api/filetree/getIDsByHPath":   apiTokenArgNotebooks("notebook"),
+
+	"/api/block/getBlockInfo":       apiTokenArgBlocks("id"),
+	"/api/block/getBlockDOM":        apiTokenArgBlocks("id"),
+	"/api/block/getBlockKramdown":   apiTokenArgBlocks("id"),
+	"/api/block/getChildBlocks":     apiTokenArgBlocks("id"),
+	"/api/block/getBlockBreadcrumb": apiTokenArgBlocks("id"),
+	"/api/block/getDocInfo":         apiTokenArgBlocks("id"),
+	"/api/block/getBlockTreeInfos":  apiTokenArgBlocks("ids"),
+	"/api/block/insertBlock":        apiTokenArgBlocks("parentID", "previousID", "nextID"),
+	"/api/block/prependBlock":       apiTokenArgBlocks("parentID"),
+	"/api/block/appendBlock":        apiTokenArgBlocks("parentID"),
+	"/api/block/updateBlock":        apiTokenArgBlocks("id"),
+	"/api/block/deleteBlock":        apiTokenArgBlocks("id"),
+	"/api/block/moveBlock":          apiTokenArgBlocks("id", "parentID", "previousID"),
+	"/api/block/foldBlock":          apiTokenArgBlocks("id"),
+	"/api/block/unfoldBlock":        apiTokenArgBlocks("id"),
+	"/api/block/batchInsertBlock":   apiTokenArgListBlocks("blocks", "parentID", "previousID", "nextID"),
+	"/api/block/batchAppendBlock":   apiTokenArgListBlocks("blocks", "parentID"),
+	"/api/block/batchUpdateBlock":   apiTokenArgListBlocks("blocks", "id"),
+
+	"/api/attr/getBlockAttrs":      apiTokenArgBlocks("id"),
+	"/api/attr/batchGetBlockAttrs": apiTokenArgBlocks("ids"),
+	"/api/attr/setBlockAttrs":      apiTokenArgBlocks("id"),
+	"/api/attr/resetBlockAttrs":    apiTokenArgBlocks("id"),
+	"/api/attr/batchSetBlockAttrs": apiTokenArgListBlocks("blockAttrs", "id"),
+
+	"/api/outline/getDocOutline": apiTokenArgBlocks("id"),
+	"/api/format/autoSpace":      apiTokenArgBlocks("id"),
+
+	"/api/riff/getTreeRiffDueCards":     apiTokenArgBlocks("rootID"),
+	"/api/riff/getTreeRiffCards":        apiTokenArgBlocks("id"),
+	"/api/riff/getNotebookRiffDueCards": apiTokenArgNotebooks("notebook"),
+	"/api/riff/getNotebookRiffCards":    apiTokenArgNotebooks("id"),
+
+	"/api/template/docSaveAsTemplate": apiTokenArgBlocks("id"),
+
+	"/api/transactions": apiTokenTransactions,
+}
+
+// apiTokenArgs 合并多个解析方式的结果。
+func apiTokenArgs(resolvers ...apiTokenRouteNotebooks) apiTokenRouteNotebooks {
+	return func(arg map[string]interface{}) (notebooks, ids []string, ok bool) {
+		for _, resolve := range resolvers {
+			n, i, o := resolve(arg)
+			if !o {
+				return
+			}
+			notebooks = append(notebooks, n...)
+			ids = append(ids, i...)
+		}
+		ok = true
+		return
+	}
+}
+
+// apiTokenArgNotebooks 参数 keys 直接指定了笔记本 ID。
+func apiTokenArgNotebooks(keys ...string) apiTokenRouteNotebooks {
+	return func(arg map[string]interface{}) (notebooks, ids []string, ok bool) {
+		for _, key := range keys {
+			val, exists := arg[key]
+			if !exists {
+				continue
+			}
+			notebook, isStr := val.(string)
+			if !isStr || !ast.IsNodeIDPattern(notebook) {
+				return
+			}
+			notebooks = append(notebooks, notebook)
+		}
+		ok = true
+		return
+	}
+}
+
+// apiTokenArgBlocks 参数 keys 指定了块 ID 或者块 ID 数组，通过块树确定所在的笔记本。
+func apiTokenArgBlocks(keys ...string) apiTokenRouteNotebooks {
+	return func(arg map[string]interface{}) (notebooks, ids []string, ok bool) {
+		for _, key := range keys {
+			val, exists := arg[key]
+			if !exists || "" == val {
+				continue
+			}
+			switch v := val.(type) {
+			case string:
+				if !ast.IsNodeIDPattern(v) {
+					return
+				}
+				ids = append(ids, v)
+			case []interface{}:
+				for _, item := range v {
+					id, isStr := item.(string)
+					if !isStr || !ast.IsNodeIDPattern(id) {
+						return
+					}
+					ids = append(ids, id)
+				}
+			default:
+				return
+			}
+		}
+		ok = true
+		return
+	}
+}
+
+// apiTokenArgListBlocks 参数 listKey 是对象数组，数组中每个对象的 keys 指定了块 ID。
+func apiTokenArgListBlocks(listKey string, keys ...string) apiTokenRouteNotebooks {
+	resolveItem := apiTokenArgBlocks(keys...)
+	return func(arg map[string]interface{}) (notebooks, ids []string, ok bool) {
+		list, isList := arg[listKey].([]interface{})
+		if !isList {
+			return
+		}
+		for _, item := range list {
+			m, isMap := item.(map[string]interface{})
+			if !isMap {
+				return
+			}
+			_, i, o := resolveItem(m)
+			if !o {
+				return
+			}
+			ids = append(ids, i...)
+		}
+		ok = true
+		return
+	}
+}
+
+// apiTokenTransactions 解析事务中每个操作涉及的块，插入操作的 id 是新块，只检查插入位置。
+//
+// 数据库中的行可能来自其他笔记本，所以涉及数据库（avID）的操作不允许使用限定了笔记本的令牌。
+func apiTokenTransactions(arg map[string]interface{}) (notebooks, ids []string, ok bool) {
+	transactions, isList := arg["transactions"].([]interface{})
+	if !isList {
+		return
+	}
+	resolvePosition := apiTokenArgBlocks("parentID", "previousID", "nextID")
+	resolveAll := apiTokenArgBlocks("id", "parentID", "previousID", "nextID")
+	for _, tx := range transactions {
+		m, isMap := tx.(map[string]interface{})
+		if !isMap {
+			return
+		}
+		var ops []interface{}
+		for _, key := range []string{"doOperations", "undoOperations"} {
+			if o, isOps := m[key].([]interface{}); isOps {
+				ops = append(ops, o...)
+			}
+		}
+		for _, op := range ops {
+			opArg, isOp := op.(map[string]interface{})
+			if !isOp {
+				return
+			}
+			if avID, _ := opArg["avID"].(string); "" != avID {
+				return
+			}
+
+			resolve := resolveAll
+			switch opArg["action"] {
+			case "insert", "prependInsert", "appendInsert":
+				resolve = resolvePosition
+			}
+			_, i, o := resolve(opArg)
+			if !o {
+				return
+			}
+			ids = append(ids, i...)
+		}
+	}
+	ok = true
+	return
+}
+
+// readAPIRequestArg 读取请求的 JSON 参数，读取后还原请求体供后续处理使用。
+func readAPIRequestArg(c *gin.Context) (ret interface{}) {
+	if nil == c.Request.Body {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err = gulu.JSON.UnmarshalJSON(body, &ret); err != nil {
+		ret = nil
+	}
+	return
+}
+
+// collectAPIRequestIDs 从 JSON 参数中收集笔记本 ID 和块 ID，包括事务等嵌套参数，结果不完整，不能用于鉴权。
+func collectAPIRequestIDs(arg interface{}, notebooks map[string]bool, ids *[]string) {
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			switch {
+			case apiTokenNotebookArgs[key]:
+				if notebook, ok := val.(string); ok && "" != notebook {
+					notebooks[notebook] = true
+				}
+			case apiTokenBlockArgs[key]:
+				switch id := val.(type) {
+				case string:
+					if ast.IsNodeIDPattern(id) {
+						*ids = append(*ids, id)
+					}
+				case []interface{}:
+					for _, item := range id {
+						if s, ok := item.(string); ok && ast.IsNodeIDPattern(s) {
+							*ids = append(*ids, s)
+						}
+					}
+				}
+			default:
+				collectAPIRequestIDs(val, notebooks, ids)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectAPIRequestIDs(item, notebooks, ids)
+		}
+	}
+}
+
+// apiRouteGroup 返回 /api/{group}/ 和 /api/v2/{group}/ 中的接口分组，不是 /api/ 下的路由时返回空字符串。
+func apiRouteGroup(p string) string {
+	if !strings.HasPrefix(p, "/api/") {
+		return ""
+	}
+	p = strings.TrimPrefix(p, "/api/")
+	p = strings.TrimPrefix(p, "v2/")
+	if idx := strings.Index(p, "/"); 0 < idx {
+		return p[:idx]
+	}
+	return p
+}
+
+func isAPITokenEditableContext(c *gin.Context) bool {
+	if _, exists := c.Get(APITokenContextKey); !exists {
+		return false
+	}
+	return RoleEditor == GetGinContextRole(c) && apiTokenEditableGroups[apiRouteGroup(c.Request.URL.Path)]
+}
@@ -0,0 +1,123 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestCheckAPITokenScope(t *testing.T) {
+	const allowed = "20240101000000-aaaaaaa"
+	const denied = "20240101000000-bbbbbbb"
+
+	cases := []struct {
+		name   string
+		token  *conf.APIToken
+		path   string
+		body   string
+		wantOK bool
+	}{
+		{"unscoped token", &conf.APIToken{}, "/api/query/sql", `{"stmt":"SELECT * FROM blocks"}`, true},
+		{"group allowed", &conf.APIToken{Groups: []string{"notebook"}}, "/api/notebook/lsNotebooks", `{}`, true},
+		{"group denied", &conf.APIToken{Groups: []string{"notebook"}}, "/api/block/getBlockDOM", `{}`, false},
+		{"notebook allowed", &conf.APIToken{Notebooks: []string{allowed}}, "/api/notebook/getNotebookConf", `{"notebook":"` + allowed + `"}`, true},
+		{"notebook denied", &conf.APIToken{Notebooks: []string{allowed}}, "/api/notebook/getNotebookConf", `{"notebook":"` + denied + `"}`, false},
+		{"notebook missing", &conf.APIToken{Notebooks: []string{allowed}}, "/api/notebook/getNotebookConf", `{}`, false},
+		{"notebook not a string", &conf.APIToken{Notebooks: []string{allowed}}, "/api/filetree/removeDoc", `{"notebook":["` + allowed + `"],"path":"/x.sy"}`, false},
+		{"sql route", &conf.APIToken{Notebooks: []string{allowed}}, "/api/query/sql", `{"stmt":"SELECT * FROM blocks","notebook":"` + allowed + `"}`, false},
+		{"raw path route", &conf.APIToken{Notebooks: []string{allowed}}, "/api/file/getFile", `{"path":"/data/` + denied + `/x.sy","notebook":"` + allowed + `"}`, false},
+		{"unlisted route", &conf.APIToken{Notebooks: []string{allowed}}, "/api/search/fullTextSearchBlock", `{"query":"foo","notebook":"` + allowed + `"}`, false},
+		{"invalid body", &conf.APIToken{Notebooks: []string{allowed}}, "/api/notebook/getNotebookConf", `notebook=` + allowed, false},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+			err := checkAPITokenScope(ctx, c.token)
+			if c.wantOK != (nil == err) {
+				t.Fatalf("want ok [%t], got err [%v]", c.wantOK, err)
+			}
+		})
+	}
+}
+
+func TestAPITokenNotebookRoutes(t *testing.T) {
+	const id1 = "20240101000000-aaaaaaa"
+	const id2 = "20240101000000-bbbbbbb"
+
+	cases := []struct {
+		name          string
+		path          string
+		arg           map[string]interface{}
+		wantNotebooks []string
+		wantIDs       []string
+		wantOK        bool
+	}{
+		{"block id", "/api/block/getBlockDOM", map[string]interface{}{"id": id1}, nil, []string{id1}, true},
+		{"invalid block id", "/api/block/getBlockDOM", map[string]interface{}{"id": "../foo"}, nil, nil, false},
+		{"block id array", "/api/attr/batchGetBlockAttrs", map[string]interface{}{"ids": []interface{}{id1, id2}}, nil, []string{id1, id2}, true},
+		{"insert position", "/api/block/insertBlock", map[string]interface{}{"parentID": id1, "previousID": id2, "nextID": ""}, nil, []string{id1, id2}, true},
+		{"nested list", "/api/attr/batchSetBlockAttrs", map[string]interface{}{"blockAttrs": []interface{}{map[string]interface{}{"id": id1}, map[string]interface{}{"id": id2}}}, nil, []string{id1, id2}, true},
+		{"nested list missing", "/api/attr/batchSetBlockAttrs", map[string]interface{}{}, nil, nil, false},
+		{"notebook and parent", "/api/filetree/createDocWithMd", map[string]interface{}{"notebook": id1, "parentID": id2}, []string{id1}, []string{id2}, true},
+		{
+			"transactions", "/api/transactions",
+			map[string]interface{}{"transactions": []interface{}{map[string]interface{}{
+				"doOperations":   []interface{}{map[string]interface{}{"action": "insert", "id": "20240101000000-ccccccc", "parentID": id1}},
+				"undoOperations": []interface{}{map[string]interface{}{"action": "delete", "id": id2}},
+			}}},
+			nil, []string{id1, id2}, true,
+		},
+		{
+			"transactions with attribute view", "/api/transactions",
+			map[string]interface{}{"transactions": []interface{}{map[string]interface{}{
+				"doOperations": []interface{}{map[string]interface{}{"action": "updateAttrViewCell", "id": id1, "avID": id2}},
+			}}},
+			nil, nil, false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resolve := apiTokenNotebookRoutes[c.path]
+			if nil == resolve {
+				t.Fatalf("route [%s] not found", c.path)
+			}
+			notebooks, ids, ok := resolve(c.arg)
+			if c.wantOK != ok {
+				t.Fatalf("ok: want [%t], got [%t]", c.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if strings.Join(c.wantNotebooks, ",") != strings.Join(notebooks, ",") {
+				t.Fatalf("notebooks: want %v, got %v", c.wantNotebooks, notebooks)
+			}
+			if strings.Join(c.wantIDs, ",") != strings.Join(ids, ",") {
+				t.Fatalf("ids: want %v, got %v", c.wantIDs, ids)
+			}
+		})
+	}
+}
@@ -339,7 +339,8 @@ func NetAssets2LocalAssets(rootID string, onlyImg bool, originalURL string) (err
 	return
 }
 
-func SearchAssetsByName(keyword string, exts []string) (ret []*cache.Asset) {
+// SearchAssetsByName 按名称搜索资源文件，access 不为 nil 时只返回发布服务账号可以访问的资源文件。
+func SearchAssetsByName(keyword string, exts []string, access *PublishAccess) (ret []*cache.Asset) {
 	ret = []*cache.Asset{}
 	var keywords []string
 	keywords = append(keywords, keyword)
@@ -386,6 +387,9 @@ func SearchAssetsByName(keyword string, exts []string) (ret []*cache.Asset) {
 		if 1 > hitNameCount+hitPathCount {
 			continue
 		}
+		if !access.AllowAsset(asset.Path) {
+			continue
+		}
 		pathHitCount[asset.Path] += hitNameCount + hitPathCount
 
 		hName := asset.HName
@@ -612,6 +616,9 @@ func RemoveUnusedAssets() (ret []string) {
 	}()
 
 	unusedAssets := UnusedAssets()
+	if 0 < len(unusedAssets) {
+		snapshotBeforeRiskyOp("remove unused assets")
+	}
 
 	historyDir, err := GetHistoryDir(HistoryOpClean)
 	if err != nil {
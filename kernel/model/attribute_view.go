@@ -556,22 +556,7 @@ func AppendAttributeViewDetachedBlocksWithValues(avID string, blocksValues [][]*
 
 			keyValues.Values = append(keyValues.Values, v)
 
-			if av.KeyTypeSelect == v.Type || av.KeyTypeMSelect == v.Type {
-				// 保存选项 https://github.com/siyuan-note/siyuan/issues/12475
-				key, _ := attrView.GetKey(v.KeyID)
-				if nil != key && 0 < len(v.MSelect) {
-					for _, valOpt := range v.MSelect {
-						if opt := key.GetOption(valOpt.Content); nil == opt {
-							// 不存在的选项新建保存
-							opt = &av.SelectOption{Name: valOpt.Content, Color: valOpt.Color}
-							key.Options = append(key.Options, opt)
-						} else {
-							// 已经存在的选项颜色需要保持不变
-							valOpt.Color = opt.Color
-						}
-					}
-				}
-			}
+			saveAttributeViewSelectOptions(attrView, v)
 		}
 	}
 
@@ -586,10 +571,32 @@ func AppendAttributeViewDetachedBlocksWithValues(avID string, blocksValues [][]*
 		return
 	}
 
+	fireWebhookEvent(WebhookEventAvRowAdded, map[string]interface{}{"avID": avID, "rowIDs": blockIDs})
 	ReloadAttrView(avID)
 	return
 }
 
+func saveAttributeViewSelectOptions(attrView *av.AttributeView, v *av.Value) {
+	if av.KeyTypeSelect != v.Type && av.KeyTypeMSelect != v.Type {
+		return
+	}
+
+	// 保存选项 https://github.com/siyuan-note/siyuan/issues/12475
+	key, _ := attrView.GetKey(v.KeyID)
+	if nil != key && 0 < len(v.MSelect) {
+		for _, valOpt := range v.MSelect {
+			if opt := key.GetOption(valOpt.Content); nil == opt {
+				// 不存在的选项新建保存
+				opt = &av.SelectOption{Name: valOpt.Content, Color: valOpt.Color}
+				key.Options = append(key.Options, opt)
+			} else {
+				// 已经存在的选项颜色需要保持不变
+				valOpt.Color = opt.Color
+			}
+		}
+	}
+}
+
 func DuplicateDatabaseBlock(avID string) (newAvID, newBlockID string, err error) {
 	storageAvDir := filepath.Join(util.DataDir, "storage", "av")
 	oldAvPath := filepath.Join(storageAvDir, avID+".json")
@@ -2723,6 +2730,7 @@ func AddAttributeViewBlock(tx *Transaction, srcs []map[string]interface{}, avID,
 	slices.Reverse(srcs) // https://github.com/siyuan-note/siyuan/issues/11286
 
 	now := time.Now().UnixMilli()
+	var rowIDs []string
 	for _, src := range srcs {
 		srcID := src["id"].(string)
 		if !ast.IsNodeIDPattern(srcID) {
@@ -2751,6 +2759,11 @@ func AddAttributeViewBlock(tx *Transaction, srcs []map[string]interface{}, avID,
 		if avErr := addAttributeViewBlock(now, avID, blockID, previousBlockID, srcID, srcContent, isDetached, ignoreFillFilter, tree, tx); nil != avErr {
 			return avErr
 		}
+		rowIDs = append(rowIDs, srcID)
+	}
+
+	if 0 < len(rowIDs) {
+		fireWebhookEvent(WebhookEventAvRowAdded, map[string]interface{}{"avID": avID, "blockID": blockID, "rowIDs": rowIDs})
 	}
 	return
 }
@@ -3991,20 +4004,26 @@ func BatchUpdateAttributeViewCells(tx *Transaction, avID string, values []interf
 		return
 	}
 
+	var events []map[string]interface{}
 	for _, value := range values {
 		v := value.(map[string]interface{})
 		keyID := v["keyID"].(string)
 		rowID := v["rowID"].(string)
 		valueData := v["value"]
-		_, err = updateAttributeViewValue(tx, attrView, keyID, rowID, valueData)
+		var val *av.Value
+		val, err = updateAttributeViewValue(tx, attrView, keyID, rowID, valueData)
 		if err != nil {
 			return
 		}
+		events = append(events, map[string]interface{}{"avID": avID, "keyID": keyID, "rowID": rowID, "value": val})
 	}
 
 	if err = av.SaveAttributeView(attrView); err != nil {
 		return
 	}
+	for _, evt := range events {
+		fireWebhookEvent(WebhookEventAvRowChanged, evt)
+	}
 
 	relatedAvIDs := av.GetSrcAvIDs(avID)
 	for _, relatedAvID := range relatedAvIDs {
@@ -4027,6 +4046,7 @@ func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID string, valueDa
 	if err = av.SaveAttributeView(attrView); err != nil {
 		return
 	}
+	fireWebhookEvent(WebhookEventAvRowChanged, map[string]interface{}{"avID": avID, "keyID": keyID, "rowID": rowID, "value": val})
 
 	relatedAvIDs := av.GetSrcAvIDs(avID)
 	for _, relatedAvID := range relatedAvIDs {
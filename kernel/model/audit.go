@@ -0,0 +1,214 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// auditPayloadMaxLen 审计日志中请求参数的最大长度，超过部分会被截断。
+const auditPayloadMaxLen = 2048
+
+// auditMaxIDs 审计日志中记录的 ID 的最大个数。
+const auditMaxIDs = 256
+
+// auditSecretArgs 审计日志中需要隐藏的参数。
+var auditSecretArgs = map[string]bool{
+	"accessauthcode": true,
+	"accesskey":      true,
+	"apikey":         true,
+	"clientsecret":   true,
+	"key":            true,
+	"pass":           true,
+	"password":       true,
+	"secret":         true,
+	"secretkey":      true,
+	"token":          true,
+}
+
+// auditPathArgs 指定文档路径的参数，审计日志中记录路径对应的文档 ID。
+var auditPathArgs = map[string]bool{
+	"path":      true,
+	"paths":     true,
+	"fromPath":  true,
+	"fromPaths": true,
+	"toPath":    true,
+}
+
+// Audit 记录修改数据的请求，所有修改数据的路由都需要在鉴权中间件之后加上该中间件。
+//
+// 局域网同步对端读写仓库文件和刷新界面的请求不是对数据的修改，不需要记录，否则每次同步都会产生大量审计日志。
+func Audit(c *gin.Context) {
+	if !Conf.Audit.Enabled {
+		return
+	}
+	auditRequest(c)
+}
+
+// auditRequest 处理修改数据的请求并记录审计日志，只解析 JSON 请求参数，上传等其他请求只记录调用者和操作。
+func auditRequest(c *gin.Context) {
+	var arg interface{}
+	if strings.HasPrefix(c.ContentType(), "application/json") || "" == c.ContentType() {
+		arg = readAPIRequestArg(c)
+	}
+
+	c.Next()
+
+	audit := &sql.Audit{
+		Created: time.Now().UnixMilli(),
+		Role:    int(GetGinContextRole(c)),
+		IP:      c.ClientIP(),
+		Op:      c.Request.URL.Path,
+		Status:  c.Writer.Status(),
+	}
+	if tokenID, exists := c.Get(APITokenContextKey); exists {
+		for _, t := range GetAPITokens() {
+			if t.ID == tokenID.(string) {
+				audit.Token = t.Name
+				break
+			}
+		}
+	}
+	if argMap, ok := arg.(map[string]interface{}); ok {
+		audit.App, _ = argMap["app"].(string)
+	}
+
+	notebooks := map[string]bool{}
+	var ids []string
+	collectAPIRequestIDs(arg, notebooks, &ids)
+	collectAuditPathIDs(arg, &ids)
+	for notebook := range notebooks {
+		ids = append(ids, notebook)
+	}
+	ids = gulu.Str.RemoveDuplicatedElem(ids)
+	if auditMaxIDs < len(ids) {
+		ids = ids[:auditMaxIDs]
+	}
+	audit.IDs = strings.Join(ids, ",")
+
+	if nil != arg {
+		if data, err := gulu.JSON.MarshalJSON(redactAuditArg(arg)); nil == err {
+			audit.Payload = string(data)
+			if auditPayloadMaxLen < len(audit.Payload) {
+				audit.Payload = strings.ToValidUTF8(audit.Payload[:auditPayloadMaxLen], "") + "..."
+			}
+		}
+	}
+
+	if err := sql.InsertAudit(audit); err != nil {
+		logging.LogErrorf("insert audit log [%s] failed: %s", audit.Op, err)
+	}
+}
+
+// collectAuditPathIDs 收集参数中文档路径对应的文档 ID，例如按路径删除文档时记录被删除的文档。
+func collectAuditPathIDs(arg interface{}, ids *[]string) {
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if !auditPathArgs[key] {
+				collectAuditPathIDs(val, ids)
+				continue
+			}
+
+			var paths []interface{}
+			switch p := val.(type) {
+			case string:
+				paths = append(paths, p)
+			case []interface{}:
+				paths = p
+			}
+			for _, p := range paths {
+				if s, ok := p.(string); ok && strings.HasSuffix(s, ".sy") {
+					if id := util.GetTreeID(s); ast.IsNodeIDPattern(id) {
+						*ids = append(*ids, id)
+					}
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectAuditPathIDs(item, ids)
+		}
+	}
+}
+
+func redactAuditArg(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		ret := map[string]interface{}{}
+		for key, val := range v {
+			if auditSecretArgs[strings.ToLower(key)] {
+				ret[key] = "******"
+				continue
+			}
+			ret[key] = redactAuditArg(val)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			ret = append(ret, redactAuditArg(item))
+		}
+		return ret
+	}
+	return arg
+}
+
+func GetAuditLogs(cond *sql.AuditCondition, page, pageSize int) (ret []*sql.Audit, total int, err error) {
+	if 1 > page {
+		page = 1
+	}
+	if 1 > pageSize || 512 < pageSize {
+		pageSize = 64
+	}
+	return sql.QueryAudits(cond, page, pageSize)
+}
+
+func SetAudit(enabled bool, retentionDays int) {
+	if 0 > retentionDays {
+		retentionDays = 0
+	}
+	Conf.Audit.Enabled = enabled
+	Conf.Audit.RetentionDays = retentionDays
+	Conf.Save()
+	PurgeAuditLogJob()
+}
+
+// PurgeAuditLogJob 按照保留天数删除过期的审计日志。
+func PurgeAuditLogJob() {
+	if 1 > Conf.Audit.RetentionDays {
+		return
+	}
+
+	expired := time.Now().AddDate(0, 0, -Conf.Audit.RetentionDays).UnixMilli()
+	count, err := sql.RemoveAuditsBefore(expired)
+	if err != nil {
+		logging.LogErrorf("purge audit logs failed: %s", err)
+		return
+	}
+	if 0 < count {
+		logging.LogInfof("purged [%d] expired audit logs", count)
+	}
+}
@@ -0,0 +1,82 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/88250/gulu"
+)
+
+func TestCollectAuditPathIDs(t *testing.T) {
+	cases := []struct {
+		name string
+		arg  string
+		want []string
+	}{
+		{"remove doc", `{"notebook":"20240101000000-aaaaaaa","path":"/20240101000000-bbbbbbb/20240101000000-ccccccc.sy"}`, []string{"20240101000000-ccccccc"}},
+		{"remove docs", `{"paths":["20240101000000-aaaaaaa/20240101000000-bbbbbbb.sy","20240101000000-aaaaaaa/20240101000000-ccccccc.sy"]}`, []string{"20240101000000-bbbbbbb", "20240101000000-ccccccc"}},
+		{"move docs", `{"fromPaths":["20240101000000-aaaaaaa/20240101000000-bbbbbbb.sy"],"toNotebook":"20240101000000-aaaaaaa","toPath":"/"}`, []string{"20240101000000-bbbbbbb"}},
+		{"folder path", `{"notebook":"20240101000000-aaaaaaa","path":"/20240101000000-bbbbbbb"}`, nil},
+		{"file path", `{"path":"/data/widgets/foo.sy"}`, nil},
+		{"nested", `{"docs":[{"path":"/20240101000000-bbbbbbb.sy"}]}`, []string{"20240101000000-bbbbbbb"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var arg interface{}
+			if err := gulu.JSON.UnmarshalJSON([]byte(c.arg), &arg); nil != err {
+				t.Fatal(err)
+			}
+			var ids []string
+			collectAuditPathIDs(arg, &ids)
+			if strings.Join(c.want, ",") != strings.Join(ids, ",") {
+				t.Fatalf("want %v, got %v", c.want, ids)
+			}
+		})
+	}
+}
+
+func TestRedactAuditArg(t *testing.T) {
+	cases := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"plain", `{"id":"20240101000000-aaaaaaa"}`, `{"id":"20240101000000-aaaaaaa"}`},
+		{"secret", `{"name":"foo","token":"bar"}`, `{"name":"foo","token":"******"}`},
+		{"case insensitive", `{"Password":"bar"}`, `{"Password":"******"}`},
+		{"nested", `{"conf":{"s3":{"secretKey":"bar"}},"list":[{"apiKey":"baz"}]}`, `{"conf":{"s3":{"secretKey":"******"}},"list":[{"apiKey":"******"}]}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var arg interface{}
+			if err := gulu.JSON.UnmarshalJSON([]byte(c.arg), &arg); nil != err {
+				t.Fatal(err)
+			}
+			data, err := gulu.JSON.MarshalJSON(redactAuditArg(arg))
+			if nil != err {
+				t.Fatal(err)
+			}
+			if c.want != string(data) {
+				t.Fatalf("want %s, got %s", c.want, data)
+			}
+		})
+	}
+}
@@ -118,6 +118,13 @@ func ParseXAuthToken(r *http.Request) *jwt.Token {
 			return token
 		}
 	}
+
+	// 单点登录会话
+	if cookie, err := r.Cookie(OIDCSessionCookieName); nil == err && "" != cookie.Value {
+		if token, err := ParseOIDCSession(cookie.Value); nil == err {
+			return token
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,232 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// BlockLock 描述了用户对块的编辑锁，锁定期间其他用户不能修改该块及其子块。
+type BlockLock struct {
+	ID      string `json:"id"`
+	RootID  string `json:"rootID"`
+	User    string `json:"user"`
+	Created int64  `json:"created"`
+	Expired int64  `json:"expired"` // 过期时间，单位毫秒，锁定者重新锁定可以续期
+}
+
+// blockLockDefaultMinutes 锁定块的默认时长。
+const blockLockDefaultMinutes = 30
+
+var (
+	blockLocks     = map[string]*BlockLock{}
+	blockLocksLock = sync.Mutex{}
+)
+
+// LockBlock 锁定块，已经被其他用户锁定时返回错误。
+func LockBlock(id, user string, minutes int) (ret *BlockLock, err error) {
+	if "" == user {
+		err = errors.New("locking blocks requires a signed-in user")
+		return
+	}
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+	if 1 > minutes {
+		minutes = blockLockDefaultMinutes
+	}
+
+	blockLocksLock.Lock()
+	now := time.Now().UnixMilli()
+	if lock := blockLocks[id]; nil != lock && now < lock.Expired && user != lock.User {
+		blockLocksLock.Unlock()
+		err = fmt.Errorf("block [%s] is locked by [%s]", id, lock.User)
+		return
+	}
+	ret = &BlockLock{ID: id, RootID: bt.RootID, User: user, Created: now, Expired: now + int64(minutes)*60*1000}
+	blockLocks[id] = ret
+	blockLocksLock.Unlock()
+
+	pushPresence(bt.RootID)
+	return
+}
+
+// UnlockBlock 解锁块，只有锁定者和管理员可以解锁。
+func UnlockBlock(id, user string, isAdmin bool) (err error) {
+	blockLocksLock.Lock()
+	lock := blockLocks[id]
+	if nil == lock {
+		blockLocksLock.Unlock()
+		return
+	}
+	if user != lock.User && !isAdmin {
+		blockLocksLock.Unlock()
+		return fmt.Errorf("block [%s] is locked by [%s]", id, lock.User)
+	}
+	delete(blockLocks, id)
+	blockLocksLock.Unlock()
+
+	pushPresence(lock.RootID)
+	return
+}
+
+// GetBlockLocks 获取文档中的块锁，rootID 为空时获取所有块锁。
+func GetBlockLocks(rootID string) (ret []*BlockLock) {
+	blockLocksLock.Lock()
+	defer blockLocksLock.Unlock()
+
+	ret = []*BlockLock{}
+	now := time.Now().UnixMilli()
+	for id, lock := range blockLocks {
+		if now >= lock.Expired {
+			delete(blockLocks, id)
+			continue
+		}
+		if "" == rootID || rootID == lock.RootID {
+			cloned := *lock
+			ret = append(ret, &cloned)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created < ret[j].Created })
+	return
+}
+
+// checkBlockLocks 检查操作涉及的块是否被其他用户锁定，锁定块时其子块也被锁定。
+func checkBlockLocks(author string, op *Operation) *TxErr {
+	blockLocksLock.Lock()
+	empty := 1 > len(blockLocks)
+	blockLocksLock.Unlock()
+	if empty {
+		return nil
+	}
+
+	ids := []string{op.ID, op.ParentID}
+	if "" != op.PreviousID {
+		// 在块后插入只会影响其父块
+		if bt := treenode.GetBlockTree(op.PreviousID); nil != bt {
+			ids = append(ids, bt.ParentID)
+		}
+	}
+	if "" != op.AvID {
+		// 修改数据库时所有引用该数据库的数据库块都会变化
+		ids = append(ids, treenode.GetMirrorAttrViewBlockIDs(op.AvID)...)
+	}
+	if lock := getOthersBlockLock(author, ids...); nil != lock {
+		return &TxErr{code: TxErrCodeBlockLocked, msg: fmt.Sprintf("Block is locked by [%s]", lock.User), id: lock.ID}
+	}
+	return nil
+}
+
+// CheckBlocksLocked 检查块是否被其他用户锁定，用于事务之外直接修改块的接口。
+func CheckBlocksLocked(user string, ids ...string) error {
+	if lock := getOthersBlockLock(user, ids...); nil != lock {
+		return fmt.Errorf("block [%s] is locked by [%s]", lock.ID, lock.User)
+	}
+	return nil
+}
+
+// CheckAttributeViewLocked 检查引用数据库的数据库块是否被其他用户锁定。
+func CheckAttributeViewLocked(user, avID string) error {
+	if "" == avID {
+		return nil
+	}
+	return CheckBlocksLocked(user, treenode.GetMirrorAttrViewBlockIDs(avID)...)
+}
+
+// CheckDocsLocked 检查文档及其子文档中是否有被其他用户锁定的块，用于重命名、删除文档。
+func CheckDocsLocked(user string, rootIDs ...string) error {
+	var locks []*BlockLock
+	for _, lock := range GetBlockLocks("") {
+		if user != lock.User {
+			locks = append(locks, lock)
+		}
+	}
+	if 1 > len(locks) {
+		return nil
+	}
+
+	for _, rootID := range rootIDs {
+		root := treenode.GetBlockTree(rootID)
+		if nil == root {
+			continue
+		}
+		childPrefix := strings.TrimSuffix(root.Path, ".sy") + "/"
+		for _, lock := range locks {
+			if lock.RootID == root.RootID {
+				return fmt.Errorf("block [%s] is locked by [%s]", lock.ID, lock.User)
+			}
+			if bt := treenode.GetBlockTree(lock.RootID); nil != bt && bt.BoxID == root.BoxID && strings.HasPrefix(bt.Path, childPrefix) {
+				return fmt.Errorf("block [%s] is locked by [%s]", lock.ID, lock.User)
+			}
+		}
+	}
+	return nil
+}
+
+func getOthersBlockLock(user string, ids ...string) *BlockLock {
+	blockLocksLock.Lock()
+	empty := 1 > len(blockLocks)
+	blockLocksLock.Unlock()
+	if empty {
+		return nil
+	}
+
+	for _, id := range ids {
+		if lock := getCoveringBlockLock(id); nil != lock && user != lock.User {
+			return lock
+		}
+	}
+	return nil
+}
+
+func getCoveringBlockLock(id string) *BlockLock {
+	now := time.Now().UnixMilli()
+	// 块树中的父块层级有限，这里限制查找深度避免异常数据导致死循环
+	for i := 0; i < 128 && "" != id; i++ {
+		blockLocksLock.Lock()
+		lock := blockLocks[id]
+		blockLocksLock.Unlock()
+		if nil != lock && now < lock.Expired {
+			return lock
+		}
+
+		bt := treenode.GetBlockTree(id)
+		if nil == bt || bt.ID == bt.RootID {
+			break
+		}
+		id = bt.ParentID
+	}
+	return nil
+}
+
+func pushBlockLockedErr(txErr *TxErr) {
+	util.PushTxErr(txErr.msg, txErr.code, map[string]interface{}{"id": txErr.id})
+	if bt := treenode.GetBlockTree(txErr.id); nil != bt {
+		// 前端已经应用了被拒绝的修改，需要重新加载文档
+		util.PushReloadDoc(bt.RootID)
+	}
+}
@@ -0,0 +1,95 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestCheckBlockLocks(t *testing.T) {
+	const box = "20240101000000-box0000"
+	parent := treenode.NewTree(box, "/20240101000000-parent0.sy", "/parent", "parent")
+	child := treenode.NewTree(box, "/20240101000000-parent0/20240101000000-child00.sy", "/parent/child", "child")
+	other := treenode.NewTree(box, "/20240101000000-other00.sy", "/other", "other")
+	setupBlockLockTrees(t, parent, child, other)
+
+	parentPara := parent.Root.FirstChild.ID
+	childPara := child.Root.FirstChild.ID
+	otherPara := other.Root.FirstChild.ID
+	now := time.Now().UnixMilli()
+
+	cases := []struct {
+		name      string
+		locks     []*BlockLock
+		ids       []string
+		docs      []string
+		wantBlock bool
+		wantDocs  bool
+	}{
+		{"no locks", nil, []string{parentPara}, []string{parent.ID}, false, false},
+		{"locked block", []*BlockLock{{ID: parentPara, RootID: parent.ID, User: "bob", Expired: now + 60000}}, []string{parentPara}, []string{parent.ID}, true, true},
+		{"locked by self", []*BlockLock{{ID: parentPara, RootID: parent.ID, User: "alice", Expired: now + 60000}}, []string{parentPara}, []string{parent.ID}, false, false},
+		{"expired lock", []*BlockLock{{ID: parentPara, RootID: parent.ID, User: "bob", Expired: now - 1}}, []string{parentPara}, []string{parent.ID}, false, false},
+		{"locked doc covers children", []*BlockLock{{ID: parent.ID, RootID: parent.ID, User: "bob", Expired: now + 60000}}, []string{parentPara}, nil, true, false},
+		{"lock in sub doc", []*BlockLock{{ID: childPara, RootID: child.ID, User: "bob", Expired: now + 60000}}, []string{parentPara}, []string{parent.ID}, false, true},
+		{"lock in parent doc", []*BlockLock{{ID: parentPara, RootID: parent.ID, User: "bob", Expired: now + 60000}}, []string{childPara}, []string{child.ID}, false, false},
+		{"unrelated doc", []*BlockLock{{ID: otherPara, RootID: other.ID, User: "bob", Expired: now + 60000}}, []string{parentPara, childPara}, []string{parent.ID}, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			blockLocksLock.Lock()
+			blockLocks = map[string]*BlockLock{}
+			for _, lock := range c.locks {
+				blockLocks[lock.ID] = lock
+			}
+			blockLocksLock.Unlock()
+
+			if err := CheckBlocksLocked("alice", c.ids...); c.wantBlock != (nil != err) {
+				t.Fatalf("check blocks want locked [%t], got err [%v]", c.wantBlock, err)
+			}
+			if err := CheckDocsLocked("alice", c.docs...); c.wantDocs != (nil != err) {
+				t.Fatalf("check docs want locked [%t], got err [%v]", c.wantDocs, err)
+			}
+			if txErr := checkBlockLocks("alice", &Operation{Action: "update", ID: c.ids[0]}); c.wantBlock != (nil != txErr) {
+				t.Fatalf("check operation want locked [%t], got err [%v]", c.wantBlock, txErr)
+			}
+		})
+	}
+}
+
+func setupBlockLockTrees(t *testing.T, trees ...*parse.Tree) {
+	blockTreeDBPath := util.BlockTreeDBPath
+	util.BlockTreeDBPath = filepath.Join(t.TempDir(), "blocktree.db")
+	treenode.InitBlockTree(true)
+	for _, tree := range trees {
+		treenode.IndexBlockTree(tree)
+	}
+	t.Cleanup(func() {
+		treenode.CloseDatabase()
+		util.BlockTreeDBPath = blockTreeDBPath
+		blockLocksLock.Lock()
+		blockLocks = map[string]*BlockLock{}
+		blockLocksLock.Unlock()
+	})
+}
@@ -263,6 +263,7 @@ func pushBroadcastAttrTransactions(oldAttrs map[string]string, node *ast.Node) {
 		UndoOperations: []*Operation{},
 	}}
 	util.PushEvent(evt)
+	fireWebhookEvent(WebhookEventAttrChanged, map[string]interface{}{"id": node.ID, "old": oldAttrs, "new": newAttrs})
 }
 
 func ResetBlockAttrs(id string, nameValues map[string]string) (err error) {
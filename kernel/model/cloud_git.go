@@ -0,0 +1,231 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+// gitRemote 将镜像目录作为 Git 工作区，数据仓库中加密后的对象以普通文件的形式提交到远端仓库的分支上。
+type gitRemote struct {
+	conf *conf.Git
+}
+
+func newGitRemote(gitConf *conf.Git) *gitRemote {
+	return &gitRemote{conf: gitConf}
+}
+
+func (remote *gitRemote) pull(dir string) (err error) {
+	if !gulu.File.IsDir(filepath.Join(dir, ".git")) {
+		if _, err = remote.git(dir, "init", "-q"); err != nil {
+			return
+		}
+		if _, err = remote.git(dir, "remote", "add", "origin", remote.conf.Endpoint); err != nil {
+			return
+		}
+	} else if _, err = remote.git(dir, "remote", "set-url", "origin", remote.conf.Endpoint); err != nil {
+		return
+	}
+
+	if _, err = remote.git(dir, "fetch", "-q", "origin"); err != nil {
+		return
+	}
+
+	branch := remote.branch()
+	remoteRef := "refs/remotes/origin/" + branch
+	if _, verifyErr := remote.git(dir, "rev-parse", "--verify", "-q", remoteRef); nil != verifyErr {
+		// 远端还没有该分支
+		_, err = remote.git(dir, "symbolic-ref", "HEAD", "refs/heads/"+branch)
+		return
+	}
+
+	// 未推送成功的本地提交直接丢弃，以远端为准，下次同步时会重新上传
+	if _, err = remote.git(dir, "checkout", "-q", "-B", branch, remoteRef); err != nil {
+		return
+	}
+	if _, err = remote.git(dir, "reset", "-q", "--hard", remoteRef); err != nil {
+		return
+	}
+	_, err = remote.git(dir, "clean", "-q", "-fdx")
+	return
+}
+
+// push 将镜像目录提交为一个没有父提交的提交并强制推送，远端分支上只保留最新的一个提交。
+//
+// 数据仓库自身保存了快照历史，Git 历史只会让远端仓库无限增长，所以每次推送都压缩为一个提交，本地定期清理掉不可达的对象。
+// 强制推送时校验远端分支仍然是拉取时的提交，避免覆盖其他设备的推送。
+func (remote *gitRemote) push(dir string) (err error) {
+	if _, err = remote.git(dir, "add", "-A"); err != nil {
+		return
+	}
+
+	status, err := remote.git(dir, "status", "--porcelain")
+	if err != nil {
+		return
+	}
+	_, headErr := remote.git(dir, "rev-parse", "--verify", "-q", "HEAD")
+	if "" == status && nil != headErr {
+		return // 还没有任何数据
+	}
+
+	branch := remote.branch()
+	lease := "--force-with-lease=refs/heads/" + branch + ":"
+	if remoteHead, verifyErr := remote.git(dir, "rev-parse", "--verify", "-q", "refs/remotes/origin/"+branch); nil == verifyErr {
+		lease += remoteHead
+	}
+
+	committed := "" != status || nil != headErr
+	if committed {
+		tree, writeErr := remote.git(dir, "write-tree")
+		if nil != writeErr {
+			return writeErr
+		}
+		msg := fmt.Sprintf("Sync from [%s] at [%s]", Conf.System.Name, time.Now().Format("2006-01-02 15:04:05"))
+		commit, commitErr := remote.git(dir, "-c", "user.name="+Conf.System.Name, "-c", "user.email="+Conf.System.ID+"@siyuan", "commit-tree", tree, "-m", msg)
+		if nil != commitErr {
+			return commitErr
+		}
+		if _, err = remote.git(dir, "reset", "-q", "--soft", commit); err != nil {
+			return
+		}
+	}
+
+	if _, err = remote.git(dir, "push", "-q", lease, "origin", "HEAD:refs/heads/"+branch); err != nil || !committed {
+		return
+	}
+
+	// 推送已经成功，清理失败不影响本次推送
+	if gcErr := remote.gcIfNeed(dir); nil != gcErr {
+		logging.LogWarnf("gc git mirror [%s] failed: %s", dir, gcErr)
+	}
+	return
+}
+
+const (
+	gitGCInterval  = 24 * time.Hour
+	gitGCLooseSize = 64 * 1024 // 松散对象超过该大小（KiB）时不等到间隔时间直接清理
+)
+
+// gcIfNeed 在距离上次清理超过间隔时间或者松散对象过多时清理镜像仓库中不可达的对象，上次清理时间记录在标记文件的修改时间上。
+func (remote *gitRemote) gcIfNeed(dir string) (err error) {
+	marker := filepath.Join(dir, ".git", "siyuan-gc")
+	info, statErr := os.Stat(marker)
+	if nil != statErr {
+		// 首次推送只记录时间
+		return os.WriteFile(marker, nil, 0644)
+	}
+	if gitGCInterval > time.Since(info.ModTime()) && gitGCLooseSize > remote.looseSize(dir) {
+		return
+	}
+
+	if _, err = remote.git(dir, "reflog", "expire", "--expire=now", "--all"); err != nil {
+		return
+	}
+	if _, err = remote.git(dir, "gc", "-q", "--prune=now"); err != nil {
+		return
+	}
+	now := time.Now()
+	return os.Chtimes(marker, now, now)
+}
+
+// looseSize 返回镜像仓库中松散对象的大小（KiB）。
+func (remote *gitRemote) looseSize(dir string) (ret int64) {
+	output, err := remote.git(dir, "count-objects", "-v")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if size, found := strings.CutPrefix(line, "size: "); found {
+			ret, _ = strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+			return
+		}
+	}
+	return
+}
+
+func (remote *gitRemote) branch() string {
+	if ret := strings.TrimSpace(remote.conf.Branch); "" != ret {
+		return ret
+	}
+	return "main"
+}
+
+func (remote *gitRemote) git(dir string, args ...string) (ret string, err error) {
+	sshCmd := "ssh -o BatchMode=yes -o ConnectTimeout=" + strconv.Itoa(remote.conf.Timeout)
+	if "" != remote.conf.PrivateKey {
+		sshCmd += " -o IdentitiesOnly=yes -i " + strconv.Quote(filepath.ToSlash(remote.conf.PrivateKey))
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_SSH_COMMAND="+sshCmd)
+	gulu.CmdAttr(cmd)
+	output, err := cmd.CombinedOutput()
+	ret = strings.TrimSpace(string(output))
+	if err != nil {
+		err = fmt.Errorf("git %s failed: %s, %s", args[0], err, ret)
+		logging.LogErrorf("%s", err)
+	}
+	return
+}
+
+// gitRemoteAddr 返回 Git 远端仓库的网络地址，本地仓库返回空字符串。
+func gitRemoteAddr(endpoint string) string {
+	endpoint = strings.TrimSpace(endpoint)
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil || "file" == u.Scheme {
+			return ""
+		}
+		if "" != u.Port() {
+			return u.Host
+		}
+
+		port := "22"
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		case "git":
+			port = "9418"
+		}
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+
+	// scp 风格的地址 user@host:path
+	if i := strings.Index(endpoint, ":"); 0 < i && !filepath.IsAbs(endpoint) {
+		host := endpoint[:i]
+		if at := strings.LastIndex(host, "@"); -1 < at {
+			host = host[at+1:]
+		}
+		return net.JoinHostPort(host, "22")
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestGitRemote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	setMirrorTestConf(t)
+
+	root := t.TempDir()
+	bare := filepath.Join(root, "remote.git")
+	if output, err := exec.Command("git", "init", "-q", "--bare", bare).CombinedOutput(); err != nil {
+		t.Fatalf("init bare repo failed: %s, %s", err, output)
+	}
+	gitConf := &conf.Git{Endpoint: "file://" + filepath.ToSlash(bare), Branch: "main", Timeout: 10}
+
+	deviceA, deviceB := filepath.Join(root, "a"), filepath.Join(root, "b")
+	remoteA, remoteB := newGitRemote(gitConf), newGitRemote(gitConf)
+	for _, dir := range []string{deviceA, deviceB} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		dir     string
+		remote  *gitRemote
+		pull    bool
+		writes  map[string]string
+		removes []string
+		wantErr bool
+		want    map[string]string
+	}{
+		{
+			name:   "first push",
+			dir:    deviceA,
+			remote: remoteA,
+			pull:   true,
+			writes: map[string]string{"objects/ab/cdef": "1", "refs/latest": "ab"},
+			want:   map[string]string{"objects/ab/cdef": "1", "refs/latest": "ab"},
+		},
+		{
+			name:    "push from another device",
+			dir:     deviceB,
+			remote:  remoteB,
+			pull:    true,
+			writes:  map[string]string{"objects/cd/ef01": "2", "refs/latest": "cd"},
+			removes: []string{"objects/ab/cdef"},
+			want:    map[string]string{"objects/cd/ef01": "2", "refs/latest": "cd"},
+		},
+		{
+			name:    "stale push is rejected",
+			dir:     deviceA,
+			remote:  remoteA,
+			writes:  map[string]string{"refs/latest": "stale"},
+			wantErr: true,
+			want:    map[string]string{"objects/cd/ef01": "2", "refs/latest": "cd"},
+		},
+	}
+
+	for _, step := range steps {
+		if step.pull {
+			if err := step.remote.pull(step.dir); err != nil {
+				t.Fatalf("%s: pull failed: %s", step.name, err)
+			}
+		}
+		for rel, data := range step.writes {
+			writeMirrorTestFile(t, step.dir, rel, data)
+		}
+		for _, rel := range step.removes {
+			if err := os.Remove(filepath.Join(step.dir, filepath.FromSlash(rel))); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := step.remote.push(step.dir)
+		if step.wantErr != (nil != err) {
+			t.Fatalf("%s: push error: want [%t], got [%v]", step.name, step.wantErr, err)
+		}
+
+		// 远端分支上始终只有一个提交
+		if count := gitTestOutput(t, bare, "rev-list", "--count", "main"); "1" != count {
+			t.Fatalf("%s: commits: want [1], got [%s]", step.name, count)
+		}
+
+		check := filepath.Join(root, "check")
+		os.RemoveAll(check)
+		if err := os.MkdirAll(check, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := newGitRemote(gitConf).pull(check); err != nil {
+			t.Fatalf("%s: pull check failed: %s", step.name, err)
+		}
+		assertMirrorTestFiles(t, step.name, check, step.want)
+	}
+}
+
+func TestGitRemoteGC(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	setMirrorTestConf(t)
+
+	root := t.TempDir()
+	bare := filepath.Join(root, "remote.git")
+	if output, err := exec.Command("git", "init", "-q", "--bare", bare).CombinedOutput(); err != nil {
+		t.Fatalf("init bare repo failed: %s, %s", err, output)
+	}
+	remote := newGitRemote(&conf.Git{Endpoint: "file://" + filepath.ToSlash(bare), Branch: "main", Timeout: 10})
+	dir := filepath.Join(root, "a")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.pull(dir); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(dir, ".git", "siyuan-gc")
+
+	steps := []struct {
+		name      string
+		markerAge time.Duration // 推送前将标记文件的修改时间调整到多久之前，为 0 时不调整
+		wantGC    bool
+	}{
+		{name: "first push only records time"},
+		{name: "within interval"},
+		{name: "interval elapsed", markerAge: gitGCInterval + time.Hour, wantGC: true},
+		{name: "within interval after gc"},
+	}
+
+	for i, step := range steps {
+		if 0 < step.markerAge {
+			aged := time.Now().Add(-step.markerAge)
+			if err := os.Chtimes(marker, aged, aged); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeMirrorTestFile(t, dir, "refs/latest", strconv.Itoa(i))
+		if err := remote.push(dir); err != nil {
+			t.Fatalf("%s: push failed: %s", step.name, err)
+		}
+
+		info, err := os.Stat(marker)
+		if err != nil {
+			t.Fatalf("%s: marker not found: %s", step.name, err)
+		}
+		if gc := gitGCInterval > time.Since(info.ModTime()) && 0 < step.markerAge; step.wantGC != gc {
+			t.Fatalf("%s: gc: want [%t], got [%t]", step.name, step.wantGC, gc)
+		}
+		// 没有清理时保留上次推送留下的松散对象
+		count := gitTestOutput(t, filepath.Join(dir, ".git"), "count-objects")
+		if loose := !strings.HasPrefix(count, "0 objects"); step.wantGC == loose {
+			t.Fatalf("%s: loose objects: [%s]", step.name, count)
+		}
+	}
+}
+
+func gitTestOutput(t *testing.T, gitDir string, args ...string) string {
+	output, err := exec.Command("git", append([]string{"--git-dir", gitDir}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("git %s failed: %s", args[0], err)
+	}
+	return string(output[:len(output)-1])
+}
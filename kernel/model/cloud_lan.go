@@ -0,0 +1,777 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/mdns"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 局域网同步：主机内核将数据仓库保存在本地目录中，通过 /api/sync/peer/ 接口向持有访问令牌的对端提供读写，
+// 对端内核通过本地镜像目录接入，数据仓库的合并逻辑和其他云端存储服务一致。
+// 数据对象在上传前已经使用数据仓库密钥加密。访问令牌不在网络上传输，对端使用令牌对每个请求的方法、路径、时间戳、随机数和请求体计算 HMAC 签名，
+// 主机校验签名并拒绝过期和重放的请求，所以局域网内的其他设备即使能够监听明文 HTTP 也无法冒充对端读写数据仓库。
+
+const (
+	lanSyncServiceName      = "_siyuan-sync._tcp" // mDNS 服务名
+	lanSyncTimestampHeader  = "X-SiYuan-Sync-Timestamp"
+	lanSyncNonceHeader      = "X-SiYuan-Sync-Nonce"
+	lanSyncSignatureHeader  = "X-SiYuan-Sync-Signature"
+	lanSyncSignatureMaxSkew = 5 * time.Minute  // 对端和主机允许的最大时间差
+	lanSyncMaxBodySize      = 32 * 1024 * 1024 // 单个请求体的最大字节数，数据仓库中的分块和索引远小于这个大小
+)
+
+// isLANSyncHost 判断当前内核是否作为局域网同步的主机。
+func isLANSyncHost() bool {
+	return conf.ProviderLAN == Conf.Sync.Provider && "" == Conf.Sync.LAN.Endpoint
+}
+
+// lanSyncHostDir 返回主机上保存数据仓库的目录，对端推送的数据也保存在这里。
+func lanSyncHostDir() string {
+	return filepath.Join(util.WorkspaceDir, "repo-lan")
+}
+
+func lanSyncHostURL(endpoint string) string {
+	endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return endpoint
+}
+
+type LANSyncFile struct {
+	Path    string `json:"path"`    // 相对于主机数据仓库目录的路径
+	Size    int64  `json:"size"`    // 文件大小
+	Updated int64  `json:"updated"` // 修改时间，单位：毫秒
+}
+
+// lanRemote 通过主机内核的 HTTP 接口将镜像目录和主机上的数据仓库目录保持一致。
+type lanRemote struct {
+	conf   *conf.LAN
+	client *http.Client
+	files  map[string]*LANSyncFile // 主机文件状态，键为相对路径
+}
+
+func newLANRemote(lanConf *conf.LAN) *lanRemote {
+	return &lanRemote{
+		conf:   lanConf,
+		client: &http.Client{Transport: httpclient.NewTransport(false), Timeout: time.Duration(lanConf.Timeout) * time.Second},
+		files:  map[string]*LANSyncFile{},
+	}
+}
+
+func (remote *lanRemote) pull(dir string) (err error) {
+	resp, err := remote.request(http.MethodGet, "getFiles", nil, nil)
+	if err != nil {
+		return
+	}
+	result, err := remote.parseResult(resp)
+	if err != nil {
+		return
+	}
+
+	files := map[string]*LANSyncFile{}
+	var downloads []string
+	data, _ := gulu.JSON.MarshalJSON(result.Data.(map[string]interface{})["files"])
+	var hostFiles []*LANSyncFile
+	if err = gulu.JSON.UnmarshalJSON(data, &hostFiles); err != nil {
+		return
+	}
+	for _, f := range hostFiles {
+		if cloudSyncLockPath == path.Base(f.Path) {
+			continue // 同步锁直接在主机上读写
+		}
+		files[f.Path] = f
+		if local, statErr := os.Stat(filepath.Join(dir, filepath.FromSlash(f.Path))); nil == statErr &&
+			local.Size() == f.Size && local.ModTime().UnixMilli() == f.Updated {
+			continue
+		}
+		downloads = append(downloads, f.Path)
+	}
+
+	err = mirrorParallel(remote.conf.ConcurrentReqs, downloads, func(rel string) error {
+		return remote.download(dir, files[rel])
+	})
+	if err != nil {
+		return
+	}
+
+	// 删除镜像目录中主机上已经不存在的文件
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr || d.IsDir() {
+			return walkErr
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if nil == files[filepath.ToSlash(rel)] {
+			return os.Remove(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	remote.files = files
+	logging.LogInfof("pulled [%d] files from lan host [%s]", len(downloads), remote.conf.Endpoint)
+	return
+}
+
+func (remote *lanRemote) push(dir string) (err error) {
+	var uploads []string
+	locals := map[string]bool{}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr || d.IsDir() {
+			return walkErr
+		}
+		info, infoErr := d.Info()
+		if nil != infoErr {
+			return infoErr
+		}
+
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if cloudSyncLockPath == path.Base(rel) {
+			return nil
+		}
+		locals[rel] = true
+		if f := remote.files[rel]; nil != f && f.Size == info.Size() && f.Updated == info.ModTime().UnixMilli() {
+			return nil
+		}
+		uploads = append(uploads, rel)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	lock := sync.Mutex{}
+	err = mirrorParallel(remote.conf.ConcurrentReqs, uploads, func(rel string) error {
+		f, uploadErr := remote.upload(dir, rel)
+		if nil != uploadErr {
+			return uploadErr
+		}
+		lock.Lock()
+		remote.files[rel] = f
+		lock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	for rel := range remote.files {
+		if locals[rel] {
+			continue
+		}
+
+		resp, reqErr := remote.request(http.MethodPost, "removeFile", nil, []byte(`{"path":`+strconv.Quote(rel)+`}`))
+		if nil != reqErr {
+			return reqErr
+		}
+		if _, err = remote.parseResult(resp); err != nil {
+			return
+		}
+		delete(remote.files, rel)
+	}
+	return
+}
+
+func (remote *lanRemote) download(dir string, file *LANSyncFile) (err error) {
+	resp, err := remote.request(http.MethodGet, "getFile", url.Values{"path": {file.Path}}, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	localPath := filepath.Join(dir, filepath.FromSlash(file.Path))
+	if err = os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return
+	}
+	tmp := localPath + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(dst, resp.Body); err != nil {
+		dst.Close()
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, localPath); err != nil {
+		return
+	}
+	updated := time.UnixMilli(file.Updated)
+	return os.Chtimes(localPath, updated, updated)
+}
+
+func (remote *lanRemote) upload(dir, rel string) (ret *LANSyncFile, err error) {
+	localPath := filepath.Join(dir, filepath.FromSlash(rel))
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return
+	}
+
+	resp, err := remote.request(http.MethodPut, "putFile", url.Values{"path": {rel}}, data)
+	if err != nil {
+		return
+	}
+	result, err := remote.parseResult(resp)
+	if err != nil {
+		return
+	}
+
+	// 使用主机上的修改时间，便于下次比较
+	hostFile := result.Data.(map[string]interface{})
+	ret = &LANSyncFile{Path: rel, Size: int64(hostFile["size"].(float64)), Updated: int64(hostFile["updated"].(float64))}
+	updated := time.UnixMilli(ret.Updated)
+	err = os.Chtimes(localPath, updated, updated)
+	return
+}
+
+func (remote *lanRemote) readLock(rel string) (ret []byte, err error) {
+	resp, err := remote.request(http.MethodGet, "getFile", url.Values{"path": {rel}}, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// writeLock 由主机原子地检查并写入同步锁，其他设备持有并且还没有失效时返回 dejavu.ErrLockCloudFailed。
+func (remote *lanRemote) writeLock(rel string, data []byte) (err error) {
+	resp, err := remote.request(http.MethodPut, "putFile", url.Values{"path": {rel}}, data)
+	if err != nil {
+		return
+	}
+	if _, err = remote.parseResult(resp); nil != err && dejavu.ErrLockCloudFailed.Error() == err.Error() {
+		err = dejavu.ErrLockCloudFailed
+	}
+	return
+}
+
+func (remote *lanRemote) removeLock(rel string) (err error) {
+	body, _ := gulu.JSON.MarshalJSON(map[string]interface{}{"path": rel, "deviceID": Conf.System.ID})
+	resp, err := remote.request(http.MethodPost, "removeFile", nil, body)
+	if err != nil {
+		return
+	}
+	_, err = remote.parseResult(resp)
+	return
+}
+
+func (remote *lanRemote) request(method, action string, query url.Values, body []byte) (ret *http.Response, err error) {
+	u := lanSyncHostURL(remote.conf.Endpoint) + "/api/sync/peer/" + action
+	if 0 < len(query) {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", util.UserAgent)
+	signLANSyncRequest(req.Header, remote.conf.Token, method, req.URL.RequestURI(), body)
+
+	ret, err = remote.client.Do(req)
+	if err != nil {
+		return
+	}
+	if http.StatusOK != ret.StatusCode {
+		ret.Body.Close()
+		err = fmt.Errorf("request lan host [%s] failed [%d]", u, ret.StatusCode)
+		if http.StatusUnauthorized == ret.StatusCode {
+			err = fmt.Errorf("lan host [%s] rejected the token", remote.conf.Endpoint)
+		} else if http.StatusNotFound == ret.StatusCode {
+			err = cloud.ErrCloudObjectNotFound
+		}
+		return
+	}
+	return
+}
+
+func (remote *lanRemote) parseResult(resp *http.Response) (ret *gulu.Result, err error) {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	ret = gulu.Ret.NewResult()
+	if err = gulu.JSON.UnmarshalJSON(data, ret); err != nil {
+		return
+	}
+	if 0 != ret.Code {
+		err = errors.New(ret.Msg)
+	}
+	return
+}
+
+// signLANSyncRequest 使用访问令牌为请求签名，签名覆盖方法、路径和查询参数、时间戳、随机数以及请求体的摘要。
+func signLANSyncRequest(header http.Header, token, method, requestURI string, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	nonceBytes := make([]byte, 16)
+	rand.Read(nonceBytes)
+	nonce := hex.EncodeToString(nonceBytes)
+	header.Set(lanSyncTimestampHeader, timestamp)
+	header.Set(lanSyncNonceHeader, nonce)
+	header.Set(lanSyncSignatureHeader, lanSyncSignature(token, method, requestURI, timestamp, nonce, body))
+}
+
+func lanSyncSignature(token, method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	lanSyncNonces     = map[string]time.Time{} // 时间窗口内已经使用过的随机数
+	lanSyncNoncesLock = sync.Mutex{}
+)
+
+// useLANSyncNonce 记录随机数，随机数在时间窗口内已经使用过时返回 false。
+func useLANSyncNonce(nonce string, now time.Time) bool {
+	lanSyncNoncesLock.Lock()
+	defer lanSyncNoncesLock.Unlock()
+
+	for n, expired := range lanSyncNonces {
+		if now.After(expired) {
+			delete(lanSyncNonces, n)
+		}
+	}
+	if _, ok := lanSyncNonces[nonce]; ok {
+		return false
+	}
+	lanSyncNonces[nonce] = now.Add(2 * lanSyncSignatureMaxSkew)
+	return true
+}
+
+// CheckLANSyncPeerAuth 校验对端的请求签名，仅在当前内核作为局域网同步主机时开放。
+//
+// 校验签名需要读取完整的请求体，请求体大小限制为 lanSyncMaxBodySize，读取后重新放回请求中供后续处理。
+func CheckLANSyncPeerAuth(c *gin.Context) {
+	if !Conf.Sync.Enabled || !isLANSyncHost() || "" == Conf.Sync.LAN.Token {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, lanSyncMaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	timestamp := c.GetHeader(lanSyncTimestampHeader)
+	nonce := c.GetHeader(lanSyncNonceHeader)
+	signature := c.GetHeader(lanSyncSignatureHeader)
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || "" == nonce || "" == signature {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	if skew := now.Sub(time.UnixMilli(millis)); lanSyncSignatureMaxSkew < skew || -lanSyncSignatureMaxSkew > skew {
+		logging.LogWarnf("lan sync peer [%s] request expired, clock skew [%s]", c.ClientIP(), skew)
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	expected := lanSyncSignature(Conf.Sync.LAN.Token, c.Request.Method, c.Request.URL.RequestURI(), timestamp, nonce, body)
+	if 1 != subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if !useLANSyncNonce(nonce, now) {
+		logging.LogWarnf("lan sync peer [%s] request replayed", c.ClientIP())
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	c.Next()
+}
+
+func GetLANSyncHostFiles() (ret []*LANSyncFile, err error) {
+	ret = []*LANSyncFile{}
+	dir := lanSyncHostDir()
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr || d.IsDir() || strings.HasSuffix(d.Name(), ".tmp") {
+			return walkErr
+		}
+		info, infoErr := d.Info()
+		if nil != infoErr {
+			return infoErr
+		}
+		rel, _ := filepath.Rel(dir, p)
+		ret = append(ret, &LANSyncFile{Path: filepath.ToSlash(rel), Size: info.Size(), Updated: info.ModTime().UnixMilli()})
+		return nil
+	})
+	return
+}
+
+// GetLANSyncHostFilePath 返回主机数据仓库目录下的文件绝对路径，不允许访问目录之外的文件。
+func GetLANSyncHostFilePath(p string) (ret string, err error) {
+	p = path.Clean("/" + strings.TrimSpace(p))
+	dir := lanSyncHostDir()
+	ret = filepath.Join(dir, filepath.FromSlash(p))
+	if !util.IsSubPath(dir, ret) {
+		err = fmt.Errorf("invalid path [%s]", p)
+	}
+	return
+}
+
+// PutLANSyncHostFile 写入对端推送的文件。
+//
+// 写入时持有主机的同步锁，和主机自身的数据同步互斥，对端获取同步锁时在主机上原子地检查并写入。
+func PutLANSyncHostFile(p string, reader io.Reader) (ret *LANSyncFile, err error) {
+	absPath, err := GetLANSyncHostFilePath(p)
+	if err != nil {
+		return
+	}
+
+	lockSync()
+	defer unlockSync()
+
+	if err = os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return
+	}
+	if cloudSyncLockPath == filepath.Base(absPath) {
+		var data []byte
+		if data, err = io.ReadAll(reader); err != nil {
+			return
+		}
+		if err = checkLANSyncHostLock(absPath, data); err != nil {
+			return
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	tmp := absPath + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	size, err := io.Copy(dst, reader)
+	if err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, absPath); err != nil {
+		return
+	}
+
+	updated := time.UnixMilli(time.Now().UnixMilli())
+	if err = os.Chtimes(absPath, updated, updated); err != nil {
+		return
+	}
+	ret = &LANSyncFile{Path: p, Size: size, Updated: updated.UnixMilli()}
+	return
+}
+
+// checkLANSyncHostLock 检查对端能否写入同步锁，锁不存在、已经失效或者由该对端持有时才能写入。
+func checkLANSyncHostLock(absPath string, data []byte) (err error) {
+	deviceID, _ := cloudSyncLockOwner(data)
+	if "" == deviceID {
+		return errors.New("invalid sync lock")
+	}
+
+	existing, err := os.ReadFile(absPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	if owner, live := cloudSyncLockOwner(existing); live && owner != deviceID {
+		logging.LogWarnf("lan sync host repo is locked by device [%s], rejected device [%s]", owner, deviceID)
+		return dejavu.ErrLockCloudFailed
+	}
+	return
+}
+
+// RemoveLANSyncHostFile 删除对端推送的文件，同步锁只有持有锁的设备才能删除。
+func RemoveLANSyncHostFile(p, deviceID string) (err error) {
+	absPath, err := GetLANSyncHostFilePath(p)
+	if err != nil {
+		return
+	}
+
+	lockSync()
+	defer unlockSync()
+
+	if cloudSyncLockPath == filepath.Base(absPath) {
+		data, readErr := os.ReadFile(absPath)
+		if nil != readErr {
+			return // 锁不存在
+		}
+		if owner, _ := cloudSyncLockOwner(data); owner != deviceID {
+			return // 锁已经被其他设备接管
+		}
+	}
+	if err = os.Remove(absPath); nil != err && errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+type lanSyncPeer struct {
+	conn   *websocket.Conn
+	kernel *OnlineKernel
+	lock   sync.Mutex // 同一个连接不能并发写入
+}
+
+func (peer *lanSyncPeer) send(data map[string]interface{}) {
+	result := gulu.Ret.NewResult()
+	result.Data = data
+
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	if err := peer.conn.WriteJSON(result); err != nil {
+		logging.LogWarnf("write lan sync peer [%s] websocket message failed: %s", peer.kernel.Hostname, err)
+	}
+}
+
+var (
+	lanSyncPeers          = map[*websocket.Conn]*lanSyncPeer{}
+	lanSyncPeersLock      = sync.Mutex{}
+	lanSyncPeerUpgrader   = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }} // 已经通过访问令牌校验
+	lanSyncMDNSServer     *mdns.Server
+	lanSyncMDNSServerLock = sync.Mutex{}
+)
+
+// ServeLANSyncPeerWebSocket 为对端提供同步感知，和思源云端的同步 websocket 使用相同的消息格式。
+//
+// 对端同步完成后发送 synced 消息，主机转发给其他对端，并按需从本地数据仓库目录同步。
+func ServeLANSyncPeerWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := lanSyncPeerUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logging.LogErrorf("upgrade lan sync peer websocket failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	peer := &lanSyncPeer{conn: conn, kernel: &OnlineKernel{
+		ID:       r.Header.Get("x-siyuan-kernel"),
+		Hostname: r.Header.Get("x-siyuan-hostname"),
+		OS:       r.Header.Get("x-siyuan-os"),
+		Ver:      r.Header.Get("x-siyuan-ver"),
+	}}
+	lanSyncPeersLock.Lock()
+	lanSyncPeers[conn] = peer
+	lanSyncPeersLock.Unlock()
+	logging.LogInfof("lan sync peer [%s] connected", peer.kernel.Hostname)
+	broadcastLANSyncKernels()
+
+	defer func() {
+		lanSyncPeersLock.Lock()
+		delete(lanSyncPeers, conn)
+		lanSyncPeersLock.Unlock()
+		logging.LogInfof("lan sync peer [%s] disconnected", peer.kernel.Hostname)
+		broadcastLANSyncKernels()
+	}()
+
+	for {
+		request := map[string]interface{}{}
+		if err = conn.ReadJSON(&request); err != nil {
+			return
+		}
+
+		if "synced" == request["cmd"] {
+			notifyLANSyncPeers(peer)
+			if Conf.Sync.Perception {
+				go SyncDataDownload()
+			}
+		}
+	}
+}
+
+// notifyLANSyncPeers 通知除 from 以外的对端进行同步。
+func notifyLANSyncPeers(from *lanSyncPeer) {
+	lanSyncPeersLock.Lock()
+	var peers []*lanSyncPeer
+	for _, peer := range lanSyncPeers {
+		if peer != from {
+			peers = append(peers, peer)
+		}
+	}
+	lanSyncPeersLock.Unlock()
+
+	for _, peer := range peers {
+		peer.send(map[string]interface{}{"cmd": "synced"})
+	}
+}
+
+func broadcastLANSyncKernels() {
+	kernels := []*OnlineKernel{{ID: KernelID, Hostname: util.GetDeviceName(), OS: runtime.GOOS, Ver: util.Ver}}
+	lanSyncPeersLock.Lock()
+	var peers []*lanSyncPeer
+	for _, peer := range lanSyncPeers {
+		peers = append(peers, peer)
+		kernels = append(kernels, peer.kernel)
+	}
+	lanSyncPeersLock.Unlock()
+
+	onlineKernelsLock.Lock()
+	onlineKernels = kernels
+	onlineKernelsLock.Unlock()
+
+	for _, peer := range peers {
+		peer.send(map[string]interface{}{"cmd": "kernels", "kernels": kernels})
+	}
+}
+
+func dialLANSyncWebSocket() (c *websocket.Conn, err error) {
+	endpoint := lanSyncHostURL(Conf.Sync.LAN.Endpoint) + "/api/sync/peer/ws"
+	endpoint = "ws" + strings.TrimPrefix(endpoint, "http")
+	header := http.Header{
+		"User-Agent":        []string{util.UserAgent},
+		"x-siyuan-kernel":   []string{KernelID},
+		"x-siyuan-ver":      []string{util.Ver},
+		"x-siyuan-os":       []string{runtime.GOOS},
+		"x-siyuan-hostname": []string{util.GetDeviceName()},
+	}
+	signLANSyncRequest(header, Conf.Sync.LAN.Token, http.MethodGet, "/api/sync/peer/ws", nil)
+	c, _, err = websocket.DefaultDialer.Dial(endpoint, header)
+	if err == nil {
+		closedSyncWebSocket.Store(false)
+	}
+	return
+}
+
+// refreshLANSyncAdvertise 在当前内核作为局域网同步主机时通过 mDNS 广播同步服务，否则停止广播。
+func refreshLANSyncAdvertise() {
+	lanSyncMDNSServerLock.Lock()
+	defer lanSyncMDNSServerLock.Unlock()
+
+	advertise := Conf.Sync.Enabled && isLANSyncHost() && (Conf.System.NetworkServe || util.ContainerDocker == util.Container)
+	if advertise == (nil != lanSyncMDNSServer) {
+		return
+	}
+
+	if !advertise {
+		if err := lanSyncMDNSServer.Shutdown(); err != nil {
+			logging.LogWarnf("shutdown lan sync mdns server failed: %s", err)
+		}
+		lanSyncMDNSServer = nil
+		logging.LogInfof("stopped advertising lan sync host")
+		return
+	}
+
+	port, err := strconv.Atoi(util.ServerPort)
+	if err != nil || 1 > port {
+		logging.LogWarnf("invalid server port [%s] for lan sync host", util.ServerPort)
+		return
+	}
+	service, err := mdns.NewMDNSService(Conf.System.ID, lanSyncServiceName, "", "", port, nil, []string{"id=" + Conf.System.ID, "name=" + Conf.System.Name})
+	if err != nil {
+		logging.LogErrorf("create lan sync mdns service failed: %s", err)
+		return
+	}
+	if lanSyncMDNSServer, err = mdns.NewServer(&mdns.Config{Zone: service}); err != nil {
+		logging.LogErrorf("start lan sync mdns server failed: %s", err)
+		return
+	}
+	logging.LogInfof("advertising lan sync host on port [%d]", port)
+}
+
+type LANSyncHost struct {
+	ID       string `json:"id"`       // 主机的设备 ID
+	Name     string `json:"name"`     // 主机的设备名称
+	Endpoint string `json:"endpoint"` // 主机地址，格式为 host:port
+}
+
+// DiscoverLANSyncHosts 通过 mDNS 发现局域网内的同步主机。
+func DiscoverLANSyncHosts() (ret []*LANSyncHost) {
+	ret = []*LANSyncHost{}
+
+	entries := make(chan *mdns.ServiceEntry, 16)
+	params := mdns.DefaultParams(lanSyncServiceName)
+	params.Entries = entries
+	params.Timeout = 3 * time.Second
+	params.DisableIPv6 = true
+	go func() {
+		defer close(entries)
+		if err := mdns.Query(params); err != nil {
+			logging.LogWarnf("discover lan sync hosts failed: %s", err)
+		}
+	}()
+
+	endpoints := map[string]bool{}
+	for entry := range entries {
+		if nil == entry.AddrV4 {
+			continue
+		}
+
+		host := &LANSyncHost{Endpoint: net.JoinHostPort(entry.AddrV4.String(), strconv.Itoa(entry.Port))}
+		for _, field := range entry.InfoFields {
+			if id, ok := strings.CutPrefix(field, "id="); ok {
+				host.ID = id
+			} else if name, ok := strings.CutPrefix(field, "name="); ok {
+				host.Name = name
+			}
+		}
+		if Conf.System.ID == host.ID || endpoints[host.Endpoint] {
+			continue
+		}
+		endpoints[host.Endpoint] = true
+		ret = append(ret, host)
+	}
+	return
+}
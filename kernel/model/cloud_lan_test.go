@@ -0,0 +1,235 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestCheckLANSyncPeerAuth(t *testing.T) {
+	origin := Conf
+	Conf = &AppConf{Sync: &conf.Sync{Enabled: true, Provider: conf.ProviderLAN, LAN: &conf.LAN{Token: "secret"}}}
+	t.Cleanup(func() { Conf = origin })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/api/sync/peer/putFile", CheckLANSyncPeerAuth, func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(data))
+	})
+
+	const uri = "/api/sync/peer/putFile?path=objects%2Fab"
+	body := []byte("data")
+	cases := []struct {
+		name   string
+		sign   func(header http.Header)
+		uri    string
+		body   []byte
+		status int
+	}{
+		{
+			name:   "valid",
+			sign:   func(header http.Header) { signLANSyncRequest(header, "secret", http.MethodPut, uri, body) },
+			status: http.StatusOK,
+		},
+		{
+			name:   "no signature",
+			sign:   func(header http.Header) {},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "wrong token",
+			sign:   func(header http.Header) { signLANSyncRequest(header, "other", http.MethodPut, uri, body) },
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "tampered path",
+			sign:   func(header http.Header) { signLANSyncRequest(header, "secret", http.MethodPut, uri, body) },
+			uri:    "/api/sync/peer/putFile?path=refs%2Flatest",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "tampered body",
+			sign:   func(header http.Header) { signLANSyncRequest(header, "secret", http.MethodPut, uri, body) },
+			body:   []byte("evil"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "expired",
+			sign: func(header http.Header) {
+				timestamp := strconv.FormatInt(time.Now().Add(-2*lanSyncSignatureMaxSkew).UnixMilli(), 10)
+				header.Set(lanSyncTimestampHeader, timestamp)
+				header.Set(lanSyncNonceHeader, "expired")
+				header.Set(lanSyncSignatureHeader, lanSyncSignature("secret", http.MethodPut, uri, timestamp, "expired", body))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "too large",
+			sign:   func(header http.Header) { signLANSyncRequest(header, "secret", http.MethodPut, uri, body) },
+			body:   make([]byte, lanSyncMaxBodySize+1),
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, c := range cases {
+		reqURI, reqBody := uri, body
+		if "" != c.uri {
+			reqURI = c.uri
+		}
+		if nil != c.body {
+			reqBody = c.body
+		}
+		req := httptest.NewRequest(http.MethodPut, reqURI, bytes.NewReader(reqBody))
+		c.sign(req.Header)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if c.status != recorder.Code {
+			t.Errorf("%s: status want [%d], got [%d]", c.name, c.status, recorder.Code)
+			continue
+		}
+		if http.StatusOK == c.status && string(reqBody) != recorder.Body.String() {
+			t.Errorf("%s: body want [%s], got [%s]", c.name, reqBody, recorder.Body.String())
+		}
+	}
+
+	// 同一个签名不能重放
+	header := http.Header{}
+	signLANSyncRequest(header, "secret", http.MethodPut, uri, body)
+	for i, status := range []int{http.StatusOK, http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodPut, uri, bytes.NewReader(body))
+		req.Header = header.Clone()
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if status != recorder.Code {
+			t.Errorf("replay [%d]: status want [%d], got [%d]", i, status, recorder.Code)
+		}
+	}
+}
+
+func TestLANSyncRemoteLock(t *testing.T) {
+	origin, originWorkspace := Conf, util.WorkspaceDir
+	Conf = &AppConf{System: &conf.System{ID: "a"}}
+	util.WorkspaceDir = t.TempDir()
+	t.Cleanup(func() { Conf, util.WorkspaceDir = origin, originWorkspace })
+
+	// 和 api 中的对端接口一致，省略签名校验
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/sync/peer/getFile", func(c *gin.Context) {
+		absPath, err := GetLANSyncHostFilePath(c.Query("path"))
+		if nil != err || !gulu.File.IsExist(absPath) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.File(absPath)
+	})
+	router.PUT("/api/sync/peer/putFile", func(c *gin.Context) {
+		ret := gulu.Ret.NewResult()
+		if _, err := PutLANSyncHostFile(c.Query("path"), c.Request.Body); err != nil {
+			ret.Code, ret.Msg = -1, err.Error()
+		}
+		c.JSON(http.StatusOK, ret)
+	})
+	router.POST("/api/sync/peer/removeFile", func(c *gin.Context) {
+		ret := gulu.Ret.NewResult()
+		arg := map[string]interface{}{}
+		c.BindJSON(&arg)
+		deviceID, _ := arg["deviceID"].(string)
+		if err := RemoveLANSyncHostFile(arg["path"].(string), deviceID); err != nil {
+			ret.Code, ret.Msg = -1, err.Error()
+		}
+		c.JSON(http.StatusOK, ret)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	remote := newLANRemote(&conf.LAN{Endpoint: server.URL, Timeout: 10, ConcurrentReqs: 1})
+	lockRel := "main/" + cloudSyncLockPath
+	lockPath := filepath.Join(lanSyncHostDir(), "main", cloudSyncLockPath)
+	expired := time.Now().Add(-2 * cloudSyncLockTimeout)
+
+	steps := []struct {
+		name      string
+		device    string
+		prepare   []byte // 直接写入主机的锁
+		remove    bool
+		wantErr   error
+		wantOwner string // 为空表示主机上没有锁
+	}{
+		{name: "create", device: "a", wantOwner: "a"},
+		{name: "held by another device", device: "b", wantErr: dejavu.ErrLockCloudFailed, wantOwner: "a"},
+		{name: "refresh", device: "a", wantOwner: "a"},
+		{name: "remove lock of another device", device: "b", remove: true, wantOwner: "a"},
+		{name: "take over expired lock", device: "b", prepare: lanTestLock("a", expired), wantOwner: "b"},
+		{name: "remove", device: "b", remove: true},
+	}
+
+	for _, step := range steps {
+		if nil != step.prepare {
+			if err := os.WriteFile(lockPath, step.prepare, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		Conf.System.ID = step.device
+		var err error
+		if step.remove {
+			err = remote.removeLock(lockRel)
+		} else {
+			err = remote.writeLock(lockRel, lanTestLock(step.device, time.Now()))
+		}
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: want error [%v], got [%v]", step.name, step.wantErr, err)
+		}
+
+		data, readErr := remote.readLock(lockRel)
+		if "" == step.wantOwner {
+			if !errors.Is(readErr, cloud.ErrCloudObjectNotFound) {
+				t.Fatalf("%s: lock should not exist, got [%v]", step.name, readErr)
+			}
+			continue
+		}
+		if nil != readErr {
+			t.Fatalf("%s: read lock failed: %s", step.name, readErr)
+		}
+		if owner, _ := cloudSyncLockOwner(data); step.wantOwner != owner {
+			t.Fatalf("%s: owner: want [%s], got [%s]", step.name, step.wantOwner, owner)
+		}
+	}
+}
+
+func lanTestLock(deviceID string, lockTime time.Time) []byte {
+	ret, _ := gulu.JSON.MarshalJSON(map[string]interface{}{"deviceID": deviceID, "time": lockTime.UnixMilli()})
+	return ret
+}
@@ -0,0 +1,264 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/panjf2000/ants/v2"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+// mirrorRemote 负责在本地镜像目录和远端之间传输数据。
+type mirrorRemote interface {
+	// pull 将远端数据拉取到镜像目录。
+	pull(dir string) error
+
+	// push 将镜像目录中的变更推送到远端。
+	push(dir string) error
+}
+
+// mirrorLocker 由能够在远端原子创建文件的远端实现，同步锁不经过镜像目录，直接在远端读写，避免根据过期的镜像判断锁的状态。
+type mirrorLocker interface {
+	// readLock 读取远端的同步锁，不存在时返回 cloud.ErrCloudObjectNotFound。
+	readLock(rel string) ([]byte, error)
+
+	// writeLock 在远端独占创建同步锁，本设备持有时原子替换，其他设备持有并且还没有失效时返回 dejavu.ErrLockCloudFailed。
+	writeLock(rel string, data []byte) error
+
+	// removeLock 删除本设备持有的同步锁。
+	removeLock(rel string) error
+}
+
+// mirrorCloud 通过本地镜像目录接入 SFTP、Git 和局域网主机这类远端存储。
+//
+// 读写由本地文件系统存储服务在镜像目录上完成，首次访问前从远端拉取。
+// 数据对象总是先于引用写入，所以只在写入引用和同步锁后推送，推送时一并带上之前写入的数据对象。
+// 远端实现了 mirrorLocker 时同步锁直接在远端读写，释放锁前先推送镜像目录。
+type mirrorCloud struct {
+	cloud.Cloud
+
+	dir     string
+	lockRel string // 同步锁相对镜像目录的路径
+	remote  mirrorRemote
+	lock    sync.Mutex
+	pulled  bool
+}
+
+func newMirrorCloud(cloudConf *cloud.Conf, remote mirrorRemote, key string, timeout, concurrentReqs int) *mirrorCloud {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:7]
+	// 镜像目录放在数据仓库目录下，避免临时目录被清理后需要重新全量拉取
+	dir := filepath.Join(Conf.Repo.GetSaveDir(), "mirror", strings.ToLower(conf.ProviderToStr(Conf.Sync.Provider))+"-"+hash)
+	cloudConf.Local = &cloud.ConfLocal{
+		Endpoint:       dir,
+		Timeout:        timeout,
+		ConcurrentReqs: concurrentReqs,
+	}
+	return &mirrorCloud{
+		Cloud:   cloud.NewLocal(&cloud.BaseCloud{Conf: cloudConf}),
+		dir:     dir,
+		lockRel: path.Join(cloudConf.Dir, cloudSyncLockPath),
+		remote:  remote,
+	}
+}
+
+// locker 返回直接在远端读写同步锁的远端，不是同步锁或者远端不支持时返回 nil。
+func (mirror *mirrorCloud) locker(filePath string) mirrorLocker {
+	if 2 != mirrorFilePhase(filePath) {
+		return nil
+	}
+	ret, _ := mirror.remote.(mirrorLocker)
+	return ret
+}
+
+func (mirror *mirrorCloud) ensurePulled() (err error) {
+	mirror.lock.Lock()
+	defer mirror.lock.Unlock()
+
+	if mirror.pulled {
+		return
+	}
+
+	if err = os.MkdirAll(mirror.dir, 0755); err != nil {
+		return
+	}
+	if err = mirror.remote.pull(mirror.dir); err != nil {
+		logging.LogErrorf("pull remote to mirror [%s] failed: %s", mirror.dir, err)
+		return
+	}
+	mirror.pulled = true
+	return
+}
+
+func (mirror *mirrorCloud) pushIfNeed(filePath string) (err error) {
+	if 0 == mirrorFilePhase(filePath) {
+		return
+	}
+
+	return mirror.push()
+}
+
+func (mirror *mirrorCloud) push() (err error) {
+	mirror.lock.Lock()
+	defer mirror.lock.Unlock()
+	if err = mirror.remote.push(mirror.dir); err != nil {
+		logging.LogErrorf("push mirror [%s] to remote failed: %s", mirror.dir, err)
+	}
+	return
+}
+
+func (mirror *mirrorCloud) UploadObject(filePath string, overwrite bool) (length int64, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	if length, err = mirror.Cloud.UploadObject(filePath, overwrite); err != nil {
+		return
+	}
+	err = mirror.pushIfNeed(filePath)
+	return
+}
+
+func (mirror *mirrorCloud) UploadBytes(filePath string, data []byte, overwrite bool) (length int64, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	if locker := mirror.locker(filePath); nil != locker {
+		if err = locker.writeLock(mirror.lockRel, data); err != nil {
+			return
+		}
+		length = int64(len(data))
+		return
+	}
+	if length, err = mirror.Cloud.UploadBytes(filePath, data, overwrite); err != nil {
+		return
+	}
+	err = mirror.pushIfNeed(filePath)
+	return
+}
+
+func (mirror *mirrorCloud) RemoveObject(filePath string) (err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	if locker := mirror.locker(filePath); nil != locker {
+		// 释放锁前推送持有锁期间写入的数据
+		if err = mirror.push(); err != nil {
+			return
+		}
+		return locker.removeLock(mirror.lockRel)
+	}
+	if err = mirror.Cloud.RemoveObject(filePath); err != nil {
+		return
+	}
+	err = mirror.pushIfNeed(filePath)
+	return
+}
+
+func (mirror *mirrorCloud) DownloadObject(filePath string) (data []byte, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	if locker := mirror.locker(filePath); nil != locker {
+		return locker.readLock(mirror.lockRel)
+	}
+	return mirror.Cloud.DownloadObject(filePath)
+}
+
+func (mirror *mirrorCloud) GetRepos() (repos []*cloud.Repo, size int64, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	return mirror.Cloud.GetRepos()
+}
+
+func (mirror *mirrorCloud) GetTags() (tags []*cloud.Ref, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	return mirror.Cloud.GetTags()
+}
+
+func (mirror *mirrorCloud) GetIndexes(page int) (indexes []*entity.Index, pageCount, totalCount int, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	return mirror.Cloud.GetIndexes(page)
+}
+
+func (mirror *mirrorCloud) GetRefsFiles() (fileIDs []string, refs []*cloud.Ref, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	return mirror.Cloud.GetRefsFiles()
+}
+
+func (mirror *mirrorCloud) GetChunks(checkChunkIDs []string) (chunkIDs []string, err error) {
+	if err = mirror.ensurePulled(); err != nil {
+		return
+	}
+	return mirror.Cloud.GetChunks(checkChunkIDs)
+}
+
+// mirrorFilePhase 返回文件的推送阶段：0 为数据对象，1 为引用，2 为同步锁。
+func mirrorFilePhase(filePath string) int {
+	if strings.HasSuffix(filePath, "lock-sync") {
+		return 2
+	}
+	if strings.Contains(filePath, "refs/") {
+		return 1
+	}
+	return 0
+}
+
+// mirrorParallel 并发处理镜像目录中的文件，返回第一个出现的错误。
+func mirrorParallel(concurrentReqs int, rels []string, fn func(rel string) error) (err error) {
+	if 1 > len(rels) {
+		return
+	}
+
+	var errLock sync.Mutex
+	waitGroup := &sync.WaitGroup{}
+	p, _ := ants.NewPoolWithFunc(concurrentReqs, func(arg interface{}) {
+		defer waitGroup.Done()
+		if fnErr := fn(arg.(string)); nil != fnErr {
+			errLock.Lock()
+			if nil == err {
+				err = fnErr
+			}
+			errLock.Unlock()
+		}
+	})
+	defer p.Release()
+	for _, rel := range rels {
+		waitGroup.Add(1)
+		if invokeErr := p.Invoke(rel); nil != invokeErr {
+			waitGroup.Done()
+			return invokeErr
+		}
+	}
+	waitGroup.Wait()
+	return
+}
@@ -0,0 +1,157 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestMirrorFilePhase(t *testing.T) {
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"repo/objects/ab/cdef", 0},
+		{"repo/indexes/abcdef", 0},
+		{"repo/refs/latest", 1},
+		{"repo/refs/tags/v1", 1},
+		{"repo/lock-sync", 2},
+	}
+
+	for _, c := range cases {
+		if got := mirrorFilePhase(c.path); c.want != got {
+			t.Errorf("phase of [%s]: want [%d], got [%d]", c.path, c.want, got)
+		}
+	}
+}
+
+func TestMirrorCloudLock(t *testing.T) {
+	remote := &mirrorTestLocker{}
+	mirror := &mirrorCloud{dir: t.TempDir(), lockRel: "repo/" + cloudSyncLockPath, remote: remote}
+
+	if _, err := mirror.DownloadObject(cloudSyncLockPath); !errors.Is(err, cloud.ErrCloudObjectNotFound) {
+		t.Fatalf("download missing lock: want [%v], got [%v]", cloud.ErrCloudObjectNotFound, err)
+	}
+	if _, err := mirror.UploadBytes(cloudSyncLockPath, []byte("a"), true); err != nil {
+		t.Fatal(err)
+	}
+	if "a" != string(remote.lock) || 0 != remote.pushed {
+		t.Fatalf("upload lock: want [a] without push, got [%s] with [%d] pushes", remote.lock, remote.pushed)
+	}
+	if data, err := mirror.DownloadObject(cloudSyncLockPath); err != nil || "a" != string(data) {
+		t.Fatalf("download lock: want [a], got [%s], [%v]", data, err)
+	}
+
+	// 释放锁前推送镜像目录
+	if err := mirror.RemoveObject(cloudSyncLockPath); err != nil {
+		t.Fatal(err)
+	}
+	if nil != remote.lock || 1 != remote.pushed {
+		t.Fatalf("remove lock: want removed after push, got [%s] with [%d] pushes", remote.lock, remote.pushed)
+	}
+
+	// 同步锁不写入镜像目录
+	assertMirrorTestFiles(t, "mirror", mirror.dir, map[string]string{})
+}
+
+// mirrorTestLocker 在内存中保存同步锁，记录推送次数。
+type mirrorTestLocker struct {
+	lock   []byte
+	pushed int
+}
+
+func (locker *mirrorTestLocker) pull(dir string) error { return nil }
+
+func (locker *mirrorTestLocker) push(dir string) error {
+	locker.pushed++
+	return nil
+}
+
+func (locker *mirrorTestLocker) readLock(rel string) ([]byte, error) {
+	if nil == locker.lock {
+		return nil, cloud.ErrCloudObjectNotFound
+	}
+	return locker.lock, nil
+}
+
+func (locker *mirrorTestLocker) writeLock(rel string, data []byte) error {
+	locker.lock = data
+	return nil
+}
+
+func (locker *mirrorTestLocker) removeLock(rel string) error {
+	locker.lock = nil
+	return nil
+}
+
+func setMirrorTestConf(t *testing.T) {
+	origin := Conf
+	Conf = &AppConf{System: &conf.System{ID: "test-device", Name: "test"}}
+	t.Cleanup(func() { Conf = origin })
+}
+
+func writeMirrorTestFile(t *testing.T, dir, rel, data string) {
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertMirrorTestFiles 校验目录中的文件和内容，忽略 .git 目录。
+func assertMirrorTestFiles(t *testing.T, name, dir string, want map[string]string) {
+	got := map[string]string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr {
+			return walkErr
+		}
+		if d.IsDir() {
+			if ".git" == d.Name() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		data, readErr := os.ReadFile(p)
+		if nil != readErr {
+			return readErr
+		}
+		rel, _ := filepath.Rel(dir, p)
+		got[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(want) != len(got) {
+		t.Fatalf("%s: files: want %v, got %v", name, want, got)
+	}
+	for rel, data := range want {
+		if got[rel] != data {
+			t.Fatalf("%s: files: want %v, got %v", name, want, got)
+		}
+	}
+}
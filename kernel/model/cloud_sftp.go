@@ -0,0 +1,485 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpRemote 通过 SFTP 协议将镜像目录和远端目录保持一致。
+type sftpRemote struct {
+	conf  *conf.SFTP
+	files map[string]*sftpRemoteFile // 远端文件状态，键为相对路径
+}
+
+type sftpRemoteFile struct {
+	size    int64
+	modTime time.Time
+}
+
+func newSFTPRemote(sftpConf *conf.SFTP) *sftpRemote {
+	return &sftpRemote{conf: sftpConf, files: map[string]*sftpRemoteFile{}}
+}
+
+func (remote *sftpRemote) pull(dir string) (err error) {
+	client, closer, err := remote.connect()
+	if err != nil {
+		return
+	}
+	defer closer()
+
+	root := remote.root()
+	if err = client.MkdirAll(root); err != nil {
+		return
+	}
+
+	files := map[string]*sftpRemoteFile{}
+	var downloads []string
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err = walker.Err(); err != nil {
+			return
+		}
+
+		info := walker.Stat()
+		if info.IsDir() {
+			continue
+		}
+
+		rel := remote.rel(walker.Path())
+		if strings.HasSuffix(rel, ".tmp") {
+			continue // 其他设备未完成上传的临时文件
+		}
+		if cloudSyncLockPath == path.Base(rel) {
+			continue // 同步锁直接在远端读写
+		}
+		files[rel] = &sftpRemoteFile{size: info.Size(), modTime: info.ModTime()}
+		if local, statErr := os.Stat(filepath.Join(dir, filepath.FromSlash(rel))); nil == statErr &&
+			local.Size() == info.Size() && local.ModTime().Equal(info.ModTime()) {
+			continue
+		}
+		downloads = append(downloads, rel)
+	}
+
+	err = mirrorParallel(remote.conf.ConcurrentReqs, downloads, func(rel string) error {
+		return remote.download(client, dir, rel, files[rel].modTime)
+	})
+	if err != nil {
+		return
+	}
+
+	// 删除镜像目录中远端已经不存在的文件
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr || d.IsDir() {
+			return walkErr
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if nil == files[filepath.ToSlash(rel)] {
+			return os.Remove(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	remote.files = files
+	logging.LogInfof("pulled [%d] files from sftp [%s]", len(downloads), remote.conf.Endpoint)
+	return
+}
+
+func (remote *sftpRemote) push(dir string) (err error) {
+	var uploads []string
+	locals := map[string]bool{}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if nil != walkErr || d.IsDir() {
+			return walkErr
+		}
+		info, infoErr := d.Info()
+		if nil != infoErr {
+			return infoErr
+		}
+
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if cloudSyncLockPath == path.Base(rel) {
+			return nil
+		}
+		locals[rel] = true
+		if f := remote.files[rel]; nil != f && f.size == info.Size() && f.modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		uploads = append(uploads, rel)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var removes []string
+	for rel := range remote.files {
+		if !locals[rel] {
+			removes = append(removes, rel)
+		}
+	}
+	if 1 > len(uploads) && 1 > len(removes) {
+		return
+	}
+
+	client, closer, err := remote.connect()
+	if err != nil {
+		return
+	}
+	defer closer()
+
+	// 先上传数据对象，再上传引用，最后上传同步锁，中途失败时远端的引用不会指向缺失的数据对象
+	phases := make([][]string, 3)
+	for _, rel := range uploads {
+		phase := mirrorFilePhase(rel)
+		phases[phase] = append(phases[phase], rel)
+	}
+	lock := sync.Mutex{}
+	for i, phase := range phases {
+		concurrentReqs := remote.conf.ConcurrentReqs
+		if 0 < i {
+			concurrentReqs = 1
+		}
+		err = mirrorParallel(concurrentReqs, phase, func(rel string) error {
+			f, uploadErr := remote.upload(client, dir, rel)
+			if nil != uploadErr {
+				return uploadErr
+			}
+			lock.Lock()
+			remote.files[rel] = f
+			lock.Unlock()
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	// 删除时顺序相反，先删除引用再删除数据对象，同步锁最后删除
+	removeOrder := []int{1, 0, 2}
+	sort.SliceStable(removes, func(i, j int) bool {
+		return removeOrder[mirrorFilePhase(removes[i])] < removeOrder[mirrorFilePhase(removes[j])]
+	})
+	for _, rel := range removes {
+		if err = client.Remove(path.Join(remote.root(), rel)); nil != err && !errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		err = nil
+		delete(remote.files, rel)
+	}
+	return
+}
+
+func (remote *sftpRemote) download(client *sftp.Client, dir, rel string, modTime time.Time) (err error) {
+	src, err := client.Open(path.Join(remote.root(), rel))
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	localPath := filepath.Join(dir, filepath.FromSlash(rel))
+	if err = os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return
+	}
+	tmp := localPath + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, localPath); err != nil {
+		return
+	}
+	return os.Chtimes(localPath, modTime, modTime)
+}
+
+func (remote *sftpRemote) upload(client *sftp.Client, dir, rel string) (ret *sftpRemoteFile, err error) {
+	localPath := filepath.Join(dir, filepath.FromSlash(rel))
+	src, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	remotePath := path.Join(remote.root(), rel)
+	if err = client.MkdirAll(path.Dir(remotePath)); err != nil {
+		return
+	}
+	// 先写入临时文件再重命名，远端不会出现写了一半的文件
+	tmp := remotePath + "." + Conf.System.ID + ".tmp"
+	dst, err := client.Create(tmp)
+	if err != nil {
+		return
+	}
+	size, err := io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return
+	}
+	if err = dst.Close(); err != nil {
+		return
+	}
+	if err = client.PosixRename(tmp, remotePath); err != nil {
+		return
+	}
+
+	// SFTP 只保存秒级的修改时间，本地和远端统一截断到秒，便于下次比较
+	modTime := time.Now().Truncate(time.Second)
+	if err = client.Chtimes(remotePath, modTime, modTime); err != nil {
+		return
+	}
+	if err = os.Chtimes(localPath, modTime, modTime); err != nil {
+		return
+	}
+	ret = &sftpRemoteFile{size: size, modTime: modTime}
+	return
+}
+
+func (remote *sftpRemote) readLock(rel string) (ret []byte, err error) {
+	client, closer, err := remote.connect()
+	if err != nil {
+		return
+	}
+	defer closer()
+	return remote.readFile(client, path.Join(remote.root(), rel))
+}
+
+// writeLock 使用 O_EXCL 在远端独占创建同步锁，多个设备同时创建时只有一个能成功。
+//
+// 接管已经失效的锁时先将其重命名移走再独占创建，移走的内容和读到的不一致说明其他设备刚刚刷新或者接管了锁，还原后放弃。
+func (remote *sftpRemote) writeLock(rel string, data []byte) (err error) {
+	client, closer, err := remote.connect()
+	if err != nil {
+		return
+	}
+	defer closer()
+
+	lockPath := path.Join(remote.root(), rel)
+	if err = client.MkdirAll(path.Dir(lockPath)); err != nil {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		f, createErr := client.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if nil == createErr {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); nil == err {
+				err = closeErr
+			}
+			return
+		}
+
+		existing, readErr := remote.readFile(client, lockPath)
+		if errors.Is(readErr, cloud.ErrCloudObjectNotFound) {
+			continue // 锁刚被释放
+		}
+		if nil != readErr {
+			return readErr
+		}
+
+		deviceID, held := cloudSyncLockHeld(existing)
+		if held {
+			logging.LogWarnf("sftp data repo is locked by device [%s]", deviceID)
+			return dejavu.ErrLockCloudFailed
+		}
+		if Conf.System.ID == deviceID {
+			// 刷新本设备持有的锁
+			return remote.writeFile(client, lockPath, data)
+		}
+
+		stale := lockPath + "." + Conf.System.ID + ".stale.tmp"
+		client.Remove(stale)
+		if err = client.Rename(lockPath, stale); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+				continue
+			}
+			return
+		}
+		if moved, _ := remote.readFile(client, stale); !bytes.Equal(moved, existing) {
+			if restoreErr := client.Rename(stale, lockPath); nil != restoreErr {
+				logging.LogWarnf("restore sftp lock [%s] failed: %s", lockPath, restoreErr)
+			}
+			return dejavu.ErrLockCloudFailed
+		}
+		client.Remove(stale)
+	}
+	return dejavu.ErrLockCloudFailed
+}
+
+func (remote *sftpRemote) removeLock(rel string) (err error) {
+	client, closer, err := remote.connect()
+	if err != nil {
+		return
+	}
+	defer closer()
+
+	lockPath := path.Join(remote.root(), rel)
+	data, err := remote.readFile(client, lockPath)
+	if err != nil {
+		if errors.Is(err, cloud.ErrCloudObjectNotFound) {
+			err = nil
+		}
+		return
+	}
+	if deviceID, _ := cloudSyncLockHeld(data); Conf.System.ID != deviceID {
+		return // 锁已经被其他设备接管
+	}
+	if err = client.Remove(lockPath); nil != err && errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+func (remote *sftpRemote) readFile(client *sftp.Client, remotePath string) (ret []byte, err error) {
+	f, err := client.Open(remotePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = cloud.ErrCloudObjectNotFound
+		}
+		return
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// writeFile 先写入临时文件再重命名，原子替换远端文件。
+func (remote *sftpRemote) writeFile(client *sftp.Client, remotePath string, data []byte) (err error) {
+	tmp := remotePath + "." + Conf.System.ID + ".tmp"
+	f, err := client.Create(tmp)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	return client.PosixRename(tmp, remotePath)
+}
+
+// root 返回远端存储目录，相对路径基于 SFTP 用户的主目录。
+func (remote *sftpRemote) root() string {
+	ret := strings.TrimSpace(remote.conf.Path)
+	if "" == ret {
+		return "."
+	}
+	return path.Clean(ret)
+}
+
+func (remote *sftpRemote) rel(remotePath string) string {
+	if root := remote.root(); "." != root {
+		return strings.TrimPrefix(strings.TrimPrefix(remotePath, root), "/")
+	}
+	return remotePath
+}
+
+func (remote *sftpRemote) connect() (client *sftp.Client, closer func(), err error) {
+	key, err := os.ReadFile(remote.conf.PrivateKey)
+	if err != nil {
+		return
+	}
+	var signer ssh.Signer
+	if "" != remote.conf.Passphrase {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(remote.conf.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(remote.conf.HostKey)
+	if err != nil {
+		return
+	}
+
+	sshClient, err := ssh.Dial("tcp", remote.conf.Endpoint, &ssh.ClientConfig{
+		User:            remote.conf.Username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         time.Duration(remote.conf.Timeout) * time.Second,
+	})
+	if err != nil {
+		return
+	}
+
+	client, err = sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return
+	}
+	closer = func() {
+		client.Close()
+		sshClient.Close()
+	}
+	return
+}
+
+// sftpHostKeyCallback 优先使用配置的服务端公钥指纹校验，未配置时使用 ~/.ssh/known_hosts 校验。
+func sftpHostKeyCallback(fingerprint string) (ret ssh.HostKeyCallback, err error) {
+	fingerprint = strings.TrimSpace(fingerprint)
+	if "" != fingerprint {
+		if !strings.HasPrefix(fingerprint, "SHA256:") {
+			fingerprint = "SHA256:" + fingerprint
+		}
+		ret = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if actual := ssh.FingerprintSHA256(key); actual != fingerprint {
+				return fmt.Errorf("host key fingerprint mismatch [host=%s, expected=%s, actual=%s]", hostname, fingerprint, actual)
+			}
+			return nil
+		}
+		return
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	return knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+}
@@ -0,0 +1,285 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/pkg/sftp"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTPRemote(t *testing.T) {
+	setMirrorTestConf(t)
+
+	root := t.TempDir()
+	sftpConf := newSFTPTestConf(t, root)
+	remoteDir := filepath.FromSlash(sftpConf.Path)
+
+	deviceA, deviceB := filepath.Join(root, "a"), filepath.Join(root, "b")
+	remoteA, remoteB := newSFTPRemote(sftpConf), newSFTPRemote(sftpConf)
+	steps := []struct {
+		name    string
+		dir     string
+		remote  *sftpRemote
+		writes  map[string]string
+		removes []string
+		want    map[string]string
+	}{
+		{
+			name:   "first push",
+			dir:    deviceA,
+			remote: remoteA,
+			writes: map[string]string{"repo/objects/ab/cdef": "1", "repo/refs/latest": "ab", "repo/lock-sync": "a"},
+			want:   map[string]string{"repo/objects/ab/cdef": "1", "repo/refs/latest": "ab"},
+		},
+		{
+			name:    "push from another device",
+			dir:     deviceB,
+			remote:  remoteB,
+			writes:  map[string]string{"repo/objects/cd/ef01": "2", "repo/refs/latest": "cd"},
+			removes: []string{"repo/objects/ab/cdef"},
+			want:    map[string]string{"repo/objects/cd/ef01": "2", "repo/refs/latest": "cd"},
+		},
+	}
+
+	for _, step := range steps {
+		err := os.MkdirAll(step.dir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = step.remote.pull(step.dir); err != nil {
+			t.Fatalf("%s: pull failed: %s", step.name, err)
+		}
+		for rel, data := range step.writes {
+			writeMirrorTestFile(t, step.dir, rel, data)
+		}
+		for _, rel := range step.removes {
+			if err = os.Remove(filepath.Join(step.dir, filepath.FromSlash(rel))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = step.remote.push(step.dir); err != nil {
+			t.Fatalf("%s: push failed: %s", step.name, err)
+		}
+
+		// 远端不应残留临时文件
+		assertMirrorTestFiles(t, step.name, remoteDir, step.want)
+
+		check := filepath.Join(root, "check")
+		os.RemoveAll(check)
+		if err = os.MkdirAll(check, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = newSFTPRemote(sftpConf).pull(check); err != nil {
+			t.Fatalf("%s: pull check failed: %s", step.name, err)
+		}
+		assertMirrorTestFiles(t, step.name, check, step.want)
+	}
+
+	// 未配置的服务端公钥指纹不能通过校验
+	sftpConf.HostKey = "SHA256:invalid"
+	if err := newSFTPRemote(sftpConf).pull(filepath.Join(root, "check")); nil == err {
+		t.Fatalf("pull with mismatched host key should fail")
+	}
+}
+
+func TestSFTPRemoteLock(t *testing.T) {
+	setMirrorTestConf(t)
+
+	sftpConf := newSFTPTestConf(t, t.TempDir())
+	remote := newSFTPRemote(sftpConf)
+	lockRel := "repo/" + cloudSyncLockPath
+	lockPath := filepath.Join(filepath.FromSlash(sftpConf.Path), filepath.FromSlash(lockRel))
+	expired := time.Now().Add(-2 * cloudSyncLockTimeout)
+
+	steps := []struct {
+		name      string
+		device    string
+		prepare   []byte // 直接写入远端的锁
+		remove    bool
+		lockTime  time.Time
+		wantErr   error
+		wantOwner string // 为空表示远端没有锁
+	}{
+		{name: "create", device: "a", lockTime: time.Now(), wantOwner: "a"},
+		{name: "held by another device", device: "b", lockTime: time.Now(), wantErr: dejavu.ErrLockCloudFailed, wantOwner: "a"},
+		{name: "refresh", device: "a", lockTime: time.Now(), wantOwner: "a"},
+		{name: "remove lock of another device", device: "b", remove: true, wantOwner: "a"},
+		{name: "take over expired lock", device: "b", prepare: sftpTestLock("a", expired), lockTime: time.Now(), wantOwner: "b"},
+		{name: "remove", device: "b", remove: true},
+		{name: "create after remove", device: "a", lockTime: time.Now(), wantOwner: "a"},
+	}
+
+	for _, step := range steps {
+		if nil != step.prepare {
+			if err := os.WriteFile(lockPath, step.prepare, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		Conf.System.ID = step.device
+		var err error
+		if step.remove {
+			err = remote.removeLock(lockRel)
+		} else {
+			err = remote.writeLock(lockRel, sftpTestLock(step.device, step.lockTime))
+		}
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: want error [%v], got [%v]", step.name, step.wantErr, err)
+		}
+
+		data, readErr := remote.readLock(lockRel)
+		if "" == step.wantOwner {
+			if !errors.Is(readErr, cloud.ErrCloudObjectNotFound) {
+				t.Fatalf("%s: lock should not exist, got [%v]", step.name, readErr)
+			}
+			continue
+		}
+		if nil != readErr {
+			t.Fatalf("%s: read lock failed: %s", step.name, readErr)
+		}
+		if owner, _ := cloudSyncLockHeld(data); step.wantOwner != owner {
+			t.Fatalf("%s: owner: want [%s], got [%s]", step.name, step.wantOwner, owner)
+		}
+		// 远端不应残留临时文件
+		entries, _ := os.ReadDir(filepath.Dir(lockPath))
+		if 1 != len(entries) {
+			t.Fatalf("%s: remote files: want [1], got [%d]", step.name, len(entries))
+		}
+	}
+}
+
+func sftpTestLock(deviceID string, lockTime time.Time) []byte {
+	ret, _ := gulu.JSON.MarshalJSON(map[string]interface{}{"deviceID": deviceID, "time": lockTime.UnixMilli()})
+	return ret
+}
+
+// newSFTPTestConf 生成客户端密钥并启动本地 SFTP 服务，返回连接该服务的配置，远端目录为 root/remote。
+func newSFTPTestConf(t *testing.T, root string) *conf.SFTP {
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(root, "id_ed25519")
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sshClientPub, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, hostKey := startSFTPTestServer(t, sshClientPub)
+	return &conf.SFTP{
+		Endpoint:       addr,
+		Username:       "test",
+		PrivateKey:     keyPath,
+		HostKey:        ssh.FingerprintSHA256(hostKey),
+		Path:           filepath.ToSlash(filepath.Join(root, "remote")),
+		Timeout:        10,
+		ConcurrentReqs: 4,
+	}
+}
+
+// startSFTPTestServer 启动一个只接受指定公钥的本地 SFTP 服务。
+func startSFTPTestServer(t *testing.T, clientKey ssh.PublicKey) (addr string, hostKey ssh.PublicKey) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown public key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if nil != acceptErr {
+				return
+			}
+			go serveSFTPTestConn(conn, config)
+		}
+	}()
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func serveSFTPTestConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if "session" != newChan.ChannelType() {
+			newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, acceptErr := newChan.Accept()
+		if nil != acceptErr {
+			return
+		}
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				req.Reply("subsystem" == req.Type && 4 < len(req.Payload) && "sftp" == string(req.Payload[4:]), nil)
+			}
+		}(requests)
+
+		server, serverErr := sftp.NewServer(channel)
+		if nil != serverErr {
+			return
+		}
+		go func() {
+			server.Serve()
+			server.Close()
+		}()
+	}
+}
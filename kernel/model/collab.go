@@ -0,0 +1,458 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 多人协同编辑时的并发冲突处理。
+//
+// 这里不是 OT/CRDT，只在块级别检测其他会话的并发修改：
+//   - 更新块时以撤销操作中编辑前的 DOM 作为基础版本做三方合并，不重叠的修改都会保留；
+//     重叠的部分同时保留两边的内容，并向提交者返回冲突，由用户手动处理；块属性不参与逐字比较，按属性单独合并
+//   - 移动块时如果块已经被其他会话移动到别处，或者目标位置的块已经被其他会话删除，则拒绝操作并返回冲突
+//   - 修改已经被其他会话删除的块时拒绝操作并返回冲突
+//
+// 合并后的结果会推送给包括提交者在内的所有会话，被拒绝的操作会让提交者重新加载文档。
+
+// collabWindow 块被其他会话修改或者被删除后，在该时长内的并发操作需要处理冲突。
+const collabWindow = 10 * time.Minute
+
+// collabMergeMaxCost 逐字比较的最大计算量，超过后不再细分差异，整段作为一处修改。
+const collabMergeMaxCost = 1024 * 1024
+
+type collabBlockWrite struct {
+	session string
+	time    time.Time
+}
+
+type collabBlockDelete struct {
+	rootID  string
+	session string
+	time    time.Time
+}
+
+var (
+	collabLock          = sync.Mutex{}
+	collabBlockWrites   = map[string]*collabBlockWrite{}  // 块 ID -> 最近修改该块的会话
+	collabBlockDeletes  = map[string]*collabBlockDelete{} // 块 ID -> 最近删除的块
+	collabLastPruneTime = time.Now()
+)
+
+// recordCollabBlockWrite 记录最近修改块的会话，返回之前修改该块的会话是否和当前会话不同。
+func recordCollabBlockWrite(id, session string) (concurrent bool) {
+	collabLock.Lock()
+	defer collabLock.Unlock()
+
+	pruneCollabRecords()
+	now := time.Now()
+	if last := collabBlockWrites[id]; nil != last && last.session != session && collabWindow > now.Sub(last.time) {
+		concurrent = true
+	}
+	collabBlockWrites[id] = &collabBlockWrite{session: session, time: now}
+	return
+}
+
+func recordCollabBlockDelete(id, rootID, session string) {
+	collabLock.Lock()
+	defer collabLock.Unlock()
+
+	pruneCollabRecords()
+	collabBlockDeletes[id] = &collabBlockDelete{rootID: rootID, session: session, time: time.Now()}
+	delete(collabBlockWrites, id)
+}
+
+// checkCollabDeletedBlock 在找不到块时调用，块刚被其他会话删除的话返回冲突，否则返回 nil 由调用方按块不存在处理。
+func (tx *Transaction) checkCollabDeletedBlock(id string) *TxErr {
+	collabLock.Lock()
+	deleted := collabBlockDeletes[id]
+	collabLock.Unlock()
+	if nil == deleted || deleted.session == tx.Session || collabWindow < time.Since(deleted.time) {
+		return nil
+	}
+
+	logging.LogInfof("rejected operation on block [%s] deleted by another session", id)
+	return tx.collabConflict(id, deleted.rootID, "Block has been deleted by another user")
+}
+
+// checkConcurrentMove 检查块在客户端看到的位置之后是否被其他会话移动过，是的话返回冲突，不覆盖其他会话的移动。
+func (tx *Transaction) checkConcurrentMove(operation *Operation, srcNode *ast.Node, rootID string) *TxErr {
+	id := operation.ID
+	if !recordCollabBlockWrite(id, tx.Session) {
+		return nil
+	}
+
+	for _, undo := range tx.UndoOperations {
+		if "move" != undo.Action || id != undo.ID {
+			continue
+		}
+		if collabBlockAt(srcNode, undo.PreviousID, undo.ParentID) {
+			return nil
+		}
+		logging.LogInfof("rejected moving block [%s] moved by another session", id)
+		return tx.collabConflict(id, rootID, "Block has been moved by another user")
+	}
+	return nil
+}
+
+// collabBlockAt 判断块是否仍在撤销操作记录的位置。
+func collabBlockAt(node *ast.Node, previousID, parentID string) bool {
+	if "" != previousID {
+		return nil != node.Previous && previousID == node.Previous.ID
+	}
+	return nil != node.Parent && parentID == node.Parent.ID && (nil == node.Previous || "" == node.Previous.ID)
+}
+
+// collabConflict 记录冲突的块，rootID 不为空时让各会话重新加载文档。
+func (tx *Transaction) collabConflict(id, rootID, msg string) *TxErr {
+	tx.conflicts = append(tx.conflicts, id)
+	if "" != rootID {
+		util.PushReloadDoc(rootID)
+	}
+	return &TxErr{code: TxErrCodeConflict, msg: msg, id: id}
+}
+
+func pruneCollabRecords() {
+	now := time.Now()
+	if time.Minute > now.Sub(collabLastPruneTime) {
+		return
+	}
+	collabLastPruneTime = now
+	for id, w := range collabBlockWrites {
+		if collabWindow < now.Sub(w.time) {
+			delete(collabBlockWrites, id)
+		}
+	}
+	for id, d := range collabBlockDeletes {
+		if collabWindow < now.Sub(d.time) {
+			delete(collabBlockDeletes, id)
+		}
+	}
+}
+
+// mergeConcurrentUpdate 合并块的并发修改，返回合并后的块 DOM，ok 为 false 时不需要合并。
+func (tx *Transaction) mergeConcurrentUpdate(operation *Operation, tree *parse.Tree, data string) (ret string, ok bool) {
+	id := operation.ID
+	if !recordCollabBlockWrite(id, tx.Session) {
+		return
+	}
+
+	var baseDOM string
+	for _, undo := range tx.UndoOperations {
+		if "update" == undo.Action && id == undo.ID {
+			baseDOM, _ = undo.Data.(string)
+			break
+		}
+	}
+	oldNode := treenode.GetNodeInTree(tree, id)
+	if "" == baseDOM || nil == oldNode {
+		return
+	}
+
+	// 三个版本都通过相同的转换得到 Markdown，避免格式差异被当作修改
+	base := tx.collabBlockVersion(strings.ReplaceAll(baseDOM, editor.FrontEndCaret, ""))
+	server := tx.collabBlockVersion(tx.luteEngine.RenderNodeBlockDOM(oldNode))
+	client := tx.collabBlockVersion(data)
+	if !server.changed(base) || !client.changed(server) || "" == base.kramdown || "" == client.kramdown {
+		return
+	}
+
+	merged, conflicts := merge3([]rune(base.kramdown), []rune(server.kramdown), []rune(client.kramdown))
+	mergedTree := parse.Parse("", []byte(string(merged)), tx.luteEngine.ParseOptions)
+	mergedNode := treenode.GetNodeInTree(mergedTree, id)
+	if nil == mergedNode {
+		logging.LogWarnf("merge concurrent update of block [%s] failed, use the latest update", id)
+		return
+	}
+	ast.Walk(mergedNode, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || "" == n.ID {
+			return ast.WalkContinue
+		}
+		if mergeCollabIAL(n, base.ials[n.ID], server.ials[n.ID], client.ials[n.ID]) {
+			conflicts++
+		}
+		return ast.WalkContinue
+	})
+
+	ret = tx.luteEngine.RenderNodeBlockDOM(mergedNode)
+	ok = true
+	tx.mergedOps = append(tx.mergedOps, operation)
+	if 0 < conflicts {
+		// 重叠的修改两边都保留在块中，由提交者处理
+		tx.conflicts = append(tx.conflicts, id)
+	}
+	logging.LogInfof("merged concurrent update of block [%s] by [%s] with [%d] conflicts", id, tx.Author, conflicts)
+	return
+}
+
+// collabBlock 块的一个版本，块属性单独保存，Markdown 中的块属性只保留 ID，避免更新时间等属性的变化被当作内容修改。
+type collabBlock struct {
+	kramdown string
+	ials     map[string][][]string // 块 ID -> 块属性
+}
+
+// changed 判断块的内容或者除更新时间以外的块属性是否不同。
+func (b *collabBlock) changed(other *collabBlock) bool {
+	if b.kramdown != other.kramdown || len(b.ials) != len(other.ials) {
+		return true
+	}
+	for id, ial := range b.ials {
+		otherIAL, exists := other.ials[id]
+		if !exists || collabIALString(ial) != collabIALString(otherIAL) {
+			return true
+		}
+	}
+	return false
+}
+
+// collabIALString 返回除更新时间以外的块属性，用于比较。
+func collabIALString(ial [][]string) string {
+	var attrs []string
+	for _, kv := range ial {
+		if "updated" == kv[0] {
+			continue
+		}
+		attrs = append(attrs, kv[0]+"="+kv[1])
+	}
+	sort.Strings(attrs)
+	return strings.Join(attrs, " ")
+}
+
+func (tx *Transaction) collabBlockVersion(dom string) (ret *collabBlock) {
+	ret = &collabBlock{ials: map[string][][]string{}}
+	subTree := tx.luteEngine.BlockDOM2Tree(dom)
+	if nil == subTree || nil == subTree.Root.FirstChild {
+		return
+	}
+
+	ast.Walk(subTree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		if ast.NodeKramdownBlockIAL == n.Type {
+			if id := parse.IAL2Map(parse.Tokens2IAL(n.Tokens))["id"]; "" != id {
+				n.Tokens = parse.IAL2Tokens([][]string{{"id", id}})
+			}
+			return ast.WalkContinue
+		}
+		if n.IsBlock() && "" != n.ID && ast.NodeDocument != n.Type {
+			ret.ials[n.ID] = n.KramdownIAL
+			n.KramdownIAL = [][]string{{"id", n.ID}}
+		}
+		return ast.WalkContinue
+	})
+	ret.kramdown = treenode.FormatNode(subTree.Root, tx.luteEngine)
+	return
+}
+
+// mergeCollabIAL 合并块属性并设置到合并后的块上，提交者和其他会话都修改了的属性保留其他会话的值并返回 true。
+//
+// 更新时间由服务端维护，取两边较新的值。
+func mergeCollabIAL(node *ast.Node, base, server, client [][]string) (conflicted bool) {
+	switch {
+	case nil == server && nil == client:
+		return
+	case nil == server:
+		node.KramdownIAL = client
+		return
+	case nil == client:
+		node.KramdownIAL = server
+		return
+	}
+
+	var baseNode *ast.Node
+	if nil != base {
+		baseNode = &ast.Node{KramdownIAL: base}
+	}
+	node.KramdownIAL = append([][]string{}, server...)
+	conflicted = mergeSyncIAL(baseNode, node, &ast.Node{KramdownIAL: client})
+	serverUpdated := parse.IAL2Map(server)["updated"]
+	if clientUpdated := parse.IAL2Map(client)["updated"]; clientUpdated > serverUpdated {
+		node.SetIALAttr("updated", clientUpdated)
+	}
+	return
+}
+
+// collabEdit 描述了相对于基础版本的一处修改，将 [start, end) 替换为 text。
+type collabEdit struct {
+	start, end int
+	text       []rune
+}
+
+func (e *collabEdit) equal(other *collabEdit) bool {
+	return e.start == other.start && e.end == other.end && string(e.text) == string(other.text)
+}
+
+// overlaps 判断两处修改是否冲突，在同一位置插入不算冲突。
+func (e *collabEdit) overlaps(other *collabEdit) bool {
+	if e.start < other.end && other.start < e.end {
+		return true
+	}
+	// 插入位置在另一处修改的范围内部
+	if e.start == e.end && other.start < e.start && e.start < other.end {
+		return true
+	}
+	return other.start == other.end && e.start < other.start && other.start < e.end
+}
+
+// merge3 三方合并，ours 是服务端当前版本，theirs 是当前提交的版本，冲突区域先后保留 ours 和 theirs 的内容。
+func merge3(base, ours, theirs []rune) (ret []rune, conflicts int) {
+	a, b := diffRunes(base, ours), diffRunes(base, theirs)
+	pos, i, j := 0, 0, 0
+	apply := func(e *collabEdit) {
+		ret = append(ret, base[pos:e.start]...)
+		ret = append(ret, e.text...)
+		pos = e.end
+	}
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case j >= len(b):
+			apply(a[i])
+			i++
+		case i >= len(a):
+			apply(b[j])
+			j++
+		case a[i].equal(b[j]):
+			apply(a[i])
+			i++
+			j++
+		case !a[i].overlaps(b[j]):
+			if a[i].start < b[j].start || (a[i].start == b[j].start && a[i].start == a[i].end) {
+				apply(a[i])
+				i++
+			} else {
+				apply(b[j])
+				j++
+			}
+		default:
+			// 合并所有相互重叠的修改为一个冲突区域，区域内两边的修改都保留
+			conflicts++
+			start, end := min(a[i].start, b[j].start), max(a[i].end, b[j].end)
+			var oursEdits, theirsEdits []*collabEdit
+			for extended := true; extended; {
+				extended = false
+				region := &collabEdit{start: start, end: end}
+				for i < len(a) && (a[i].start < end || region.overlaps(a[i])) {
+					end = max(end, a[i].end)
+					oursEdits = append(oursEdits, a[i])
+					i++
+					extended = true
+				}
+				for j < len(b) && (b[j].start < end || region.overlaps(b[j])) {
+					end = max(end, b[j].end)
+					theirsEdits = append(theirsEdits, b[j])
+					j++
+					extended = true
+				}
+			}
+
+			ret = append(ret, base[pos:start]...)
+			ret = append(ret, applyCollabEdits(base, start, end, oursEdits)...)
+			ret = append(ret, applyCollabEdits(base, start, end, theirsEdits)...)
+			pos = end
+		}
+	}
+	ret = append(ret, base[pos:]...)
+	return
+}
+
+// applyCollabEdits 返回 base[start:end] 应用修改后的内容。
+func applyCollabEdits(base []rune, start, end int, edits []*collabEdit) (ret []rune) {
+	p := start
+	for _, e := range edits {
+		ret = append(ret, base[p:e.start]...)
+		ret = append(ret, e.text...)
+		p = e.end
+	}
+	ret = append(ret, base[p:end]...)
+	return
+}
+
+// diffRunes 计算从 base 到 other 的修改，先去掉公共前后缀，再通过最长公共子序列细分差异。
+func diffRunes(base, other []rune) (ret []*collabEdit) {
+	prefix := 0
+	for prefix < len(base) && prefix < len(other) && base[prefix] == other[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(base)-prefix && suffix < len(other)-prefix && base[len(base)-1-suffix] == other[len(other)-1-suffix] {
+		suffix++
+	}
+	x, y := base[prefix:len(base)-suffix], other[prefix:len(other)-suffix]
+	if 0 == len(x) && 0 == len(y) {
+		return
+	}
+	if 0 == len(x) || 0 == len(y) || collabMergeMaxCost < len(x)*len(y) {
+		return []*collabEdit{{start: prefix, end: prefix + len(x), text: y}}
+	}
+
+	// lcs[i][j] 为 x[i:] 和 y[j:] 的最长公共子序列长度
+	n, m := len(x), len(y)
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; 0 <= i; i-- {
+		for j := m - 1; 0 <= j; j-- {
+			if x[i] == y[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	var cur *collabEdit
+	flush := func() {
+		if nil != cur {
+			ret = append(ret, cur)
+			cur = nil
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && x[i] == y[j]:
+			flush()
+			i++
+			j++
+		case j < m && (i >= n || lcs[i*(m+1)+j+1] >= lcs[(i+1)*(m+1)+j]):
+			if nil == cur {
+				cur = &collabEdit{start: prefix + i, end: prefix + i}
+			}
+			cur.text = append(cur.text, y[j])
+			j++
+		default:
+			if nil == cur {
+				cur = &collabEdit{start: prefix + i, end: prefix + i}
+			}
+			cur.end = prefix + i + 1
+			i++
+		}
+	}
+	flush()
+	return
+}
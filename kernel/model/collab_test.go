@@ -0,0 +1,260 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestMerge3(t *testing.T) {
+	cases := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{"no changes", "foo bar", "foo bar", "foo bar", "foo bar", 0},
+		{"only ours", "foo bar", "foo baz", "foo bar", "foo baz", 0},
+		{"only theirs", "foo bar", "foo bar", "fox bar", "fox bar", 0},
+		{"same change", "foo bar", "foo baz", "foo baz", "foo baz", 0},
+		{"separate changes", "foo bar baz", "FOO bar baz", "foo bar BAZ", "FOO bar BAZ", 0},
+		{"insert at same position", "foo", "afoo", "bfoo", "abfoo", 0},
+		{"overlapping changes keep both", "foo bar baz", "foo BAR baz", "foo qux baz", "foo BARqux baz", 1},
+		{"delete and change keep both", "abc xyz", "abc", "abc XYZ", "abc XYZ", 1},
+		{"conflict and separate change", "one two three", "ONE tWo three", "one TWO threE", "ONE tWoTWO threE", 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, conflicts := merge3([]rune(c.base), []rune(c.ours), []rune(c.theirs))
+			if c.want != string(got) || c.wantConflicts != conflicts {
+				t.Fatalf("want [%s] with [%d] conflicts, got [%s] with [%d] conflicts", c.want, c.wantConflicts, string(got), conflicts)
+			}
+		})
+	}
+}
+
+func TestDiffRunes(t *testing.T) {
+	cases := []struct {
+		name  string
+		base  string
+		other string
+	}{
+		{"equal", "foo", "foo"},
+		{"insert", "foo", "fooo bar"},
+		{"delete", "foo bar", "fbar"},
+		{"replace", "foo bar baz", "foo qux baz"},
+		{"multiple", "abcdef", "aXcdYf"},
+		{"from empty", "", "foo"},
+		{"to empty", "foo", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			base := []rune(c.base)
+			edits := diffRunes(base, []rune(c.other))
+			if got := string(applyCollabEdits(base, 0, len(base), edits)); c.other != got {
+				t.Fatalf("want [%s], got [%s]", c.other, got)
+			}
+		})
+	}
+}
+
+func TestCollabBlockAt(t *testing.T) {
+	parent := &ast.Node{Type: ast.NodeDocument, ID: "20240101000000-parent0"}
+	first := &ast.Node{Type: ast.NodeParagraph, ID: "20240101000000-first00"}
+	second := &ast.Node{Type: ast.NodeParagraph, ID: "20240101000000-second0"}
+	parent.AppendChild(first)
+	parent.AppendChild(second)
+
+	cases := []struct {
+		name       string
+		node       *ast.Node
+		previousID string
+		parentID   string
+		want       bool
+	}{
+		{"after previous", second, first.ID, parent.ID, true},
+		{"after other previous", second, "20240101000000-other00", parent.ID, false},
+		{"first child", first, "", parent.ID, true},
+		{"no longer first child", second, "", parent.ID, false},
+		{"other parent", first, "", "20240101000000-other00", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := collabBlockAt(c.node, c.previousID, c.parentID); c.want != got {
+				t.Fatalf("want [%t], got [%t]", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCollabConcurrentOperations(t *testing.T) {
+	const id = "20240101000000-block00"
+	const rootID = "20240101000000-root000"
+	parent := &ast.Node{Type: ast.NodeDocument, ID: rootID}
+	first := &ast.Node{Type: ast.NodeParagraph, ID: "20240101000000-first00"}
+	node := &ast.Node{Type: ast.NodeParagraph, ID: id}
+	parent.AppendChild(first)
+	parent.AppendChild(node)
+	movedBack := &Operation{Action: "move", ID: id, PreviousID: first.ID, ParentID: rootID}
+	movedAway := &Operation{Action: "move", ID: id, PreviousID: "20240101000000-other00", ParentID: rootID}
+
+	cases := []struct {
+		name         string
+		deleteBy     string // 删除块的会话，为空时不删除
+		writeBy      string // 之前修改块的会话，为空时没有修改
+		undo         *Operation
+		wantDeleted  bool
+		wantMoved    bool
+		wantConflict int
+	}{
+		{"untouched", "", "", movedBack, false, false, 0},
+		{"deleted by self", "s1", "", movedBack, false, false, 0},
+		{"deleted by other", "s2", "", movedBack, true, false, 1},
+		{"moved by self", "", "s1", movedAway, false, false, 0},
+		{"edited by other in place", "", "s2", movedBack, false, false, 0},
+		{"moved by other", "", "s2", movedAway, false, true, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			collabLock.Lock()
+			collabBlockWrites = map[string]*collabBlockWrite{}
+			collabBlockDeletes = map[string]*collabBlockDelete{}
+			collabLock.Unlock()
+			if "" != c.deleteBy {
+				recordCollabBlockDelete(id, rootID, c.deleteBy)
+			}
+			if "" != c.writeBy {
+				recordCollabBlockWrite(id, c.writeBy)
+			}
+
+			tx := &Transaction{Session: "s1", UndoOperations: []*Operation{c.undo}}
+			if txErr := tx.checkCollabDeletedBlock(id); c.wantDeleted != (nil != txErr) {
+				t.Fatalf("check deleted want conflict [%t], got [%v]", c.wantDeleted, txErr)
+			}
+			if txErr := tx.checkConcurrentMove(&Operation{Action: "move", ID: id}, node, rootID); c.wantMoved != (nil != txErr) {
+				t.Fatalf("check moved want conflict [%t], got [%v]", c.wantMoved, txErr)
+			}
+			if c.wantConflict != len(tx.Conflicts()) {
+				t.Fatalf("want [%d] conflicts, got %v", c.wantConflict, tx.Conflicts())
+			}
+		})
+	}
+}
+
+func TestMergeConcurrentUpdate(t *testing.T) {
+	const id = "20240101000000-aaaaaaa"
+	const base = "foo bar baz\n{: id=\"" + id + "\" updated=\"20240101000000\"}\n"
+
+	cases := []struct {
+		name         string
+		server       string
+		client       string
+		wantMerged   bool
+		wantText     string
+		wantAttrs    map[string]string
+		wantConflict bool
+	}{
+		{
+			name:       "edits on different words",
+			server:     "foo1 bar baz\n{: id=\"" + id + "\" updated=\"20240102000000\"}\n",
+			client:     "foo bar baz2\n{: id=\"" + id + "\" updated=\"20240101000000\"}\n",
+			wantMerged: true,
+			wantText:   "foo1 bar baz2",
+			wantAttrs:  map[string]string{"updated": "20240102000000"},
+		},
+		{
+			name:         "edits on the same word",
+			server:       "foo qux baz\n{: id=\"" + id + "\" updated=\"20240102000000\"}\n",
+			client:       "foo quux baz\n{: id=\"" + id + "\" updated=\"20240103000000\"}\n",
+			wantMerged:   true,
+			wantText:     "foo quxquux baz",
+			wantAttrs:    map[string]string{"updated": "20240103000000"},
+			wantConflict: true,
+		},
+		{
+			name:       "text edited and attribute set",
+			server:     "foo1 bar baz\n{: id=\"" + id + "\" updated=\"20240102000000\"}\n",
+			client:     "foo bar baz\n{: id=\"" + id + "\" custom-a=\"1\" updated=\"20240101000000\"}\n",
+			wantMerged: true,
+			wantText:   "foo1 bar baz",
+			wantAttrs:  map[string]string{"custom-a": "1", "updated": "20240102000000"},
+		},
+		{
+			name:       "server only refreshed updated",
+			server:     "foo bar baz\n{: id=\"" + id + "\" updated=\"20240102000000\"}\n",
+			client:     "foo bar baz2\n{: id=\"" + id + "\" updated=\"20240101000000\"}\n",
+			wantMerged: false,
+		},
+	}
+
+	luteEngine := util.NewLute()
+	renderDOM := func(kramdown string) string {
+		tree := parse.Parse("", []byte(kramdown), luteEngine.ParseOptions)
+		return luteEngine.RenderNodeBlockDOM(treenode.GetNodeInTree(tree, id))
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			collabLock.Lock()
+			collabBlockWrites = map[string]*collabBlockWrite{}
+			collabLock.Unlock()
+			recordCollabBlockWrite(id, "s2")
+
+			tree := parse.Parse("", []byte(c.server), luteEngine.ParseOptions)
+			tx := &Transaction{
+				Session:        "s1",
+				luteEngine:     luteEngine,
+				UndoOperations: []*Operation{{Action: "update", ID: id, Data: renderDOM(base)}},
+			}
+			ret, ok := tx.mergeConcurrentUpdate(&Operation{Action: "update", ID: id}, tree, renderDOM(c.client))
+			if c.wantMerged != ok {
+				t.Fatalf("merged: want [%t], got [%t]", c.wantMerged, ok)
+			}
+			if !ok {
+				return
+			}
+
+			merged := luteEngine.BlockDOM2Tree(ret).Root.FirstChild
+			if nil == merged || id != merged.ID {
+				t.Fatalf("merged block [%s] not found in [%s]", id, ret)
+			}
+			if text := strings.TrimSpace(treenode.ExportNodeStdMd(merged, luteEngine)); c.wantText != text {
+				t.Fatalf("text: want [%s], got [%s]", c.wantText, text)
+			}
+			attrs := parse.IAL2Map(merged.KramdownIAL)
+			for k, v := range c.wantAttrs {
+				if v != attrs[k] {
+					t.Fatalf("attr [%s]: want [%s], got [%s]", k, v, attrs[k])
+				}
+			}
+			if c.wantConflict != (0 < len(tx.Conflicts())) {
+				t.Fatalf("conflict: want [%t], got %v", c.wantConflict, tx.Conflicts())
+			}
+		})
+	}
+}
@@ -77,6 +77,9 @@ type AppConf struct {
 	Api            *conf.API        `json:"api"`            // API
 	Repo           *conf.Repo       `json:"repo"`           // 数据仓库
 	Publish        *conf.Publish    `json:"publish"`        // 发布服务
+	Audit          *conf.Audit      `json:"audit"`          // 审计日志
+	OIDC           *conf.OIDC       `json:"oidc"`           // 单点登录
+	Webhooks       []*conf.Webhook  `json:"webhooks"`       // Webhook
 	OpenHelp       bool             `json:"openHelp"`       // 启动后是否需要打开用户指南
 	ShowChangelog  bool             `json:"showChangelog"`  // 是否显示版本更新日志
 	CloudRegion    int              `json:"cloudRegion"`    // 云端区域，0：中国大陆，1：北美
@@ -273,6 +276,9 @@ func InitConf() {
 	if nil == Conf.Export {
 		Conf.Export = conf.NewExport()
 	}
+	if nil == Conf.Export.Filter {
+		Conf.Export.Filter = conf.NewExportFilter()
+	}
 	if 0 == Conf.Export.BlockRefMode || 1 == Conf.Export.BlockRefMode || 5 == Conf.Export.BlockRefMode {
 		// 废弃导出选项引用块转换为原始块和引述块 https://github.com/siyuan-note/siyuan/issues/3155
 		// 锚点哈希模式和脚注模式合并 https://github.com/siyuan-note/siyuan/issues/13331
@@ -374,6 +380,24 @@ func InitConf() {
 	Conf.Sync.Local.Endpoint = util.NormalizeLocalPath(Conf.Sync.Local.Endpoint)
 	Conf.Sync.Local.Timeout = util.NormalizeTimeout(Conf.Sync.Local.Timeout)
 	Conf.Sync.Local.ConcurrentReqs = util.NormalizeConcurrentReqs(Conf.Sync.Local.ConcurrentReqs, conf.ProviderLocal)
+	if nil == Conf.Sync.Notebooks {
+		Conf.Sync.Notebooks = map[string]int{}
+	}
+	if nil == Conf.Sync.SFTP {
+		Conf.Sync.SFTP = &conf.SFTP{}
+	}
+	Conf.Sync.SFTP.Timeout = util.NormalizeTimeout(Conf.Sync.SFTP.Timeout)
+	Conf.Sync.SFTP.ConcurrentReqs = util.NormalizeConcurrentReqs(Conf.Sync.SFTP.ConcurrentReqs, conf.ProviderSFTP)
+	if nil == Conf.Sync.Git {
+		Conf.Sync.Git = &conf.Git{Branch: "main"}
+	}
+	Conf.Sync.Git.Timeout = util.NormalizeTimeout(Conf.Sync.Git.Timeout)
+	Conf.Sync.Git.ConcurrentReqs = util.NormalizeConcurrentReqs(Conf.Sync.Git.ConcurrentReqs, conf.ProviderGit)
+	if nil == Conf.Sync.LAN {
+		Conf.Sync.LAN = &conf.LAN{}
+	}
+	Conf.Sync.LAN.Timeout = util.NormalizeTimeout(Conf.Sync.LAN.Timeout)
+	Conf.Sync.LAN.ConcurrentReqs = util.NormalizeConcurrentReqs(Conf.Sync.LAN.ConcurrentReqs, conf.ProviderLAN)
 
 	if util.ContainerDocker == util.Container {
 		Conf.Sync.Perception = false
@@ -382,6 +406,9 @@ func InitConf() {
 	if nil == Conf.Api {
 		Conf.Api = conf.NewAPI()
 	}
+	if nil == Conf.Api.Tokens {
+		Conf.Api.Tokens = []*conf.APIToken{}
+	}
 
 	if nil == Conf.Bazaar {
 		Conf.Bazaar = conf.NewBazaar()
@@ -390,6 +417,24 @@ func InitConf() {
 	if nil == Conf.Publish {
 		Conf.Publish = conf.NewPublish()
 	}
+
+	if nil == Conf.Audit {
+		Conf.Audit = conf.NewAudit()
+	}
+	if 0 > Conf.Audit.RetentionDays {
+		Conf.Audit.RetentionDays = 0
+	}
+
+	if nil == Conf.OIDC {
+		Conf.OIDC = conf.NewOIDC()
+	}
+	if 1 > Conf.OIDC.SessionHours {
+		Conf.OIDC.SessionHours = 24
+	}
+
+	if nil == Conf.Webhooks {
+		Conf.Webhooks = []*conf.Webhook{}
+	}
 	if Conf.OpenHelp && Conf.Publish.Enable {
 		Conf.OpenHelp = false
 	}
@@ -412,6 +457,14 @@ func InitConf() {
 	if 1 > Conf.Repo.RetentionIndexesDaily {
 		Conf.Repo.RetentionIndexesDaily = 2
 	}
+	if 0 > Conf.Repo.SnapshotInterval {
+		Conf.Repo.SnapshotInterval = 0
+	}
+	if nil == Conf.Repo.SnapshotRetention {
+		// 旧版配置中没有快照相关的配置项，高风险操作前创建快照默认开启
+		Conf.Repo.SnapshotRetention = conf.NewSnapshotRetention()
+		Conf.Repo.SnapshotBeforeRiskyOps = true
+	}
 	if 0 < len(Conf.Repo.Key) {
 		logging.LogInfof("repo key [%x]", sha1.Sum(Conf.Repo.Key))
 	}
@@ -638,6 +691,7 @@ func Close(force, setCurrentWorkspace bool, execInstallPkg int) (exitCode int) {
 
 	// Improve indexing completeness when exiting https://github.com/siyuan-note/siyuan/issues/12039
 	sql.FlushQueue()
+	FlushWebhookQueue()
 
 	util.IsExiting.Store(true)
 	waitSecondForExecInstallPkg := false
@@ -928,9 +982,11 @@ func HideConfSecret(c *AppConf) {
 	c.Api = &conf.API{}
 	c.Flashcard = &conf.Flashcard{}
 	c.LocalIPs = []string{}
+	c.OIDC = &conf.OIDC{}
 	c.Publish = &conf.Publish{}
 	c.Repo = &conf.Repo{}
 	c.Sync = &conf.Sync{}
+	c.Webhooks = []*conf.Webhook{}
 	c.System.AppDir = ""
 	c.System.ConfDir = ""
 	c.System.DataDir = ""
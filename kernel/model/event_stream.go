@@ -0,0 +1,93 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// EventStreamFilter 描述了外部订阅事件的过滤条件，条件之间为且的关系，为空的条件不过滤。
+type EventStreamFilter struct {
+	Cmds      []string // 事件类型，即推送的 cmd
+	Notebooks []string // 笔记本 ID
+	Docs      []string // 文档 ID
+}
+
+// Match 判断事件是否满足过滤条件，指定了笔记本或者文档时无法确定范围的事件（比如 reloadui）会被过滤掉。
+func (f *EventStreamFilter) Match(evt *util.StreamEvent) bool {
+	if 0 < len(f.Cmds) && !gulu.Str.Contains(evt.Cmd, f.Cmds) {
+		return false
+	}
+	if 1 > len(f.Notebooks) && 1 > len(f.Docs) {
+		return true
+	}
+
+	boxes, rootIDs := getStreamEventScope(evt)
+	if 0 < len(f.Notebooks) && !containsAnyKey(boxes, f.Notebooks) {
+		return false
+	}
+	if 0 < len(f.Docs) && !containsAnyKey(rootIDs, f.Docs) {
+		return false
+	}
+	return true
+}
+
+// getStreamEventScope 从推送数据中收集块 ID 和笔记本 ID，通过块树得到事件涉及的文档和笔记本。
+//
+// 已经删除的块没有块树，这时将块 ID 本身视为文档 ID，以便文档删除等事件仍然可以按照文档过滤。
+func getStreamEventScope(evt *util.StreamEvent) (boxes, rootIDs map[string]bool) {
+	boxes, rootIDs = map[string]bool{}, map[string]bool{}
+
+	var data interface{}
+	if err := gulu.JSON.UnmarshalJSON(evt.Data, &data); err != nil {
+		return
+	}
+
+	var ids []string
+	if id, ok := data.(string); ok && ast.IsNodeIDPattern(id) {
+		// reloaddoc 等推送的数据直接是文档 ID
+		ids = append(ids, id)
+	} else {
+		collectAPIRequestIDs(data, boxes, &ids)
+	}
+	if 1 > len(ids) {
+		return
+	}
+
+	bts := treenode.GetBlockTrees(ids)
+	for _, id := range ids {
+		if bt := bts[id]; nil != bt {
+			rootIDs[bt.RootID] = true
+			boxes[bt.BoxID] = true
+		} else {
+			rootIDs[id] = true
+		}
+	}
+	return
+}
+
+func containsAnyKey(m map[string]bool, keys []string) bool {
+	for _, key := range keys {
+		if m[key] {
+			return true
+		}
+	}
+	return false
+}
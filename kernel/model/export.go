@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -55,6 +56,7 @@ import (
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/riff"
 	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -120,53 +122,7 @@ func ExportAv2CSV(avID, blockID string) (zipPath string, err error) {
 	for _, row := range table.Rows {
 		var rowVal []string
 		for _, cell := range row.Cells {
-			var val string
-			if nil != cell.Value {
-				if av.KeyTypeDate == cell.Value.Type {
-					if nil != cell.Value.Date {
-						cell.Value.Date = av.NewFormattedValueDate(cell.Value.Date.Content, cell.Value.Date.Content2, av.DateFormatNone, cell.Value.Date.IsNotTime, cell.Value.Date.HasEndDate)
-					}
-				} else if av.KeyTypeCreated == cell.Value.Type {
-					if nil != cell.Value.Created {
-						cell.Value.Created = av.NewFormattedValueCreated(cell.Value.Created.Content, 0, av.CreatedFormatNone)
-					}
-				} else if av.KeyTypeUpdated == cell.Value.Type {
-					if nil != cell.Value.Updated {
-						cell.Value.Updated = av.NewFormattedValueUpdated(cell.Value.Updated.Content, 0, av.UpdatedFormatNone)
-					}
-				} else if av.KeyTypeMAsset == cell.Value.Type {
-					if nil != cell.Value.MAsset {
-						buf := &bytes.Buffer{}
-						for _, a := range cell.Value.MAsset {
-							if av.AssetTypeImage == a.Type {
-								buf.WriteString("![")
-								buf.WriteString(a.Name)
-								buf.WriteString("](")
-								buf.WriteString(a.Content)
-								buf.WriteString(") ")
-							} else if av.AssetTypeFile == a.Type {
-								buf.WriteString("[")
-								buf.WriteString(a.Name)
-								buf.WriteString("](")
-								buf.WriteString(a.Content)
-								buf.WriteString(") ")
-							} else {
-								buf.WriteString(a.Content)
-								buf.WriteString(" ")
-							}
-						}
-						val = strings.TrimSpace(buf.String())
-					}
-				} else if av.KeyTypeLineNumber == cell.Value.Type {
-					val = strconv.Itoa(rowNum)
-				}
-
-				if "" == val {
-					val = cell.Value.String(true)
-				}
-			}
-
-			rowVal = append(rowVal, val)
+			rowVal = append(rowVal, getAttrViewValueText(cell.Value, rowNum))
 		}
 		if err = writer.Write(rowVal); err != nil {
 			logging.LogErrorf("write csv row [%s] failed: %s", rowVal, err)
@@ -266,9 +222,14 @@ func Export2Liandi(id string) (err error) {
 		apiURL += "/" + articleId
 	}
 
+	exportConf, err := ResolveExportConf("")
+	if err != nil {
+		return
+	}
+
 	title := path.Base(tree.HPath)
 	tags := tree.Root.IALAttr("tags")
-	content := exportMarkdownContent0(tree, util.GetCloudForumAssetsServer()+time.Now().Format("2006/01")+"/siyuan/"+Conf.GetUser().UserId+"/", true,
+	content := exportMarkdownContent0(exportConf, tree, util.GetCloudForumAssetsServer()+time.Now().Format("2006/01")+"/siyuan/"+Conf.GetUser().UserId+"/", true,
 		".md", 3, 1, 1,
 		"#", "#",
 		"", "",
@@ -380,12 +341,12 @@ func ExportSystemLog() (zipPath string) {
 	return
 }
 
-func ExportNotebookSY(id string) (zipPath string) {
-	zipPath = exportBoxSYZip(id)
+func ExportNotebookSY(exportConf *conf.Export, id string) (zipPath string) {
+	zipPath = exportBoxSYZip(exportConf, id)
 	return
 }
 
-func ExportSY(id string) (name, zipPath string) {
+func ExportSY(exportConf *conf.Export, id string) (name, zipPath string) {
 	block := treenode.GetBlockTree(id)
 	if nil == block {
 		logging.LogErrorf("not found block [%s]", id)
@@ -404,7 +365,7 @@ func ExportSY(id string) (name, zipPath string) {
 	for _, docFile := range docFiles {
 		docPaths = append(docPaths, docFile.path)
 	}
-	zipPath = exportSYZip(boxID, path.Dir(rootPath), baseFolderName, docPaths)
+	zipPath = exportSYZip(exportConf, boxID, path.Dir(rootPath), baseFolderName, docPaths)
 	name = util.GetTreeID(block.Path)
 	return
 }
@@ -521,7 +482,7 @@ func exportData(exportFolder string) (zipPath string, err error) {
 	return
 }
 
-func ExportResources(resourcePaths []string, mainName string) (exportFilePath string, err error) {
+func ExportResources(exportConf *conf.Export, resourcePaths []string, mainName string) (exportFilePath string, err error) {
 	FlushTxQueue()
 
 	// 用于导出的临时文件夹完整路径
@@ -549,6 +510,24 @@ func ExportResources(resourcePaths []string, mainName string) (exportFilePath st
 		}
 	}
 
+	if nil != exportConf.Profile {
+		// 移除被导出配置方案过滤的文档
+		filepath.Walk(exportFolderPath, func(p string, info os.FileInfo, walkErr error) error {
+			if nil != walkErr || info.IsDir() || !strings.HasSuffix(info.Name(), ".sy") {
+				return nil
+			}
+
+			id := strings.TrimSuffix(info.Name(), ".sy")
+			if !ast.IsNodeIDPattern(id) {
+				return nil
+			}
+			if tree, _ := LoadTreeByBlockID(id); isExportDocFiltered(exportConf, tree) {
+				os.Remove(p)
+			}
+			return nil
+		})
+	}
+
 	zipFilePath := exportFolderPath + ".zip" // 导出的 *.zip 文件完整路径
 	zip, err := gulu.Zip.Create(zipFilePath)
 	if err != nil {
@@ -571,14 +550,14 @@ func ExportResources(resourcePaths []string, mainName string) (exportFilePath st
 	return
 }
 
-func Preview(id string) (retStdHTML string) {
-	blockRefMode := Conf.Export.BlockRefMode
+func Preview(exportConf *conf.Export, id string) (retStdHTML string) {
+	blockRefMode := exportConf.BlockRefMode
 	tree, _ := LoadTreeByBlockID(id)
-	tree = exportTree(tree, false, false, true,
-		blockRefMode, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
+	tree = exportTree(exportConf, tree, false, false, true,
+		blockRefMode, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
 		"#", "#", // 这里固定使用 # 包裹标签，否则无法正确解析标签 https://github.com/siyuan-note/siyuan/issues/13857
-		Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
-		Conf.Export.AddTitle, Conf.Export.InlineMemo, true, true, &map[string]*parse.Tree{})
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		exportConf.AddTitle, exportConf.InlineMemo, true, true, &map[string]*parse.Tree{})
 	luteEngine := NewLute()
 	enableLuteInlineSyntax(luteEngine)
 	luteEngine.SetFootnotes(true)
@@ -609,7 +588,7 @@ func Preview(id string) (retStdHTML string) {
 	return
 }
 
-func ExportDocx(id, savePath string, removeAssets, merge bool) (fullPath string, err error) {
+func ExportDocx(exportConf *conf.Export, id, savePath string, removeAssets, merge bool) (fullPath string, err error) {
 	if !util.IsValidPandocBin(Conf.Export.PandocBin) {
 		Conf.Export.PandocBin = util.PandocBinPath
 		Conf.Save()
@@ -624,7 +603,7 @@ func ExportDocx(id, savePath string, removeAssets, merge bool) (fullPath string,
 		return
 	}
 	defer os.Remove(tmpDir)
-	name, content := ExportMarkdownHTML(id, tmpDir, true, merge)
+	name, content := ExportMarkdownHTML(exportConf, id, tmpDir, true, merge)
 	content = strings.ReplaceAll(content, "  \n", "<br>\n")
 
 	tmpDocxPath := filepath.Join(tmpDir, name+".docx")
@@ -635,7 +614,7 @@ func ExportDocx(id, savePath string, removeAssets, merge bool) (fullPath string,
 	}
 
 	// Pandoc template for exporting docx https://github.com/siyuan-note/siyuan/issues/8740
-	docxTemplate := util.RemoveInvalid(Conf.Export.DocxTemplate)
+	docxTemplate := util.RemoveInvalid(exportConf.DocxTemplate)
 	docxTemplate = strings.TrimSpace(docxTemplate)
 	if "" != docxTemplate {
 		if !gulu.File.IsExist(docxTemplate) {
@@ -675,7 +654,7 @@ func ExportDocx(id, savePath string, removeAssets, merge bool) (fullPath string,
 	return
 }
 
-func ExportMarkdownHTML(id, savePath string, docx, merge bool) (name, dom string) {
+func ExportMarkdownHTML(exportConf *conf.Export, id, savePath string, docx, merge bool) (name, dom string) {
 	bt := treenode.GetBlockTree(id)
 	if nil == bt {
 		return
@@ -692,12 +671,12 @@ func ExportMarkdownHTML(id, savePath string, docx, merge bool) (name, dom string
 		}
 	}
 
-	blockRefMode := Conf.Export.BlockRefMode
-	tree = exportTree(tree, true, false, true,
-		blockRefMode, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
-		Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
-		Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
-		Conf.Export.AddTitle, Conf.Export.InlineMemo, true, true, &map[string]*parse.Tree{})
+	blockRefMode := exportConf.BlockRefMode
+	tree = exportTree(exportConf, tree, true, false, true,
+		blockRefMode, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		exportConf.AddTitle, exportConf.InlineMemo, true, true, &map[string]*parse.Tree{})
 	name = path.Base(tree.HPath)
 	name = util.FilterFileName(name) // 导出 PDF、HTML 和 Word 时未移除不支持的文件名符号 https://github.com/siyuan-note/siyuan/issues/5614
 	savePath = strings.TrimSpace(savePath)
@@ -812,7 +791,7 @@ func ExportMarkdownHTML(id, savePath string, docx, merge bool) (name, dom string
 	return
 }
 
-func ExportHTML(id, savePath string, pdf, image, keepFold, merge bool) (name, dom string, node *ast.Node) {
+func ExportHTML(exportConf *conf.Export, id, savePath string, pdf, image, keepFold, merge bool) (name, dom string, node *ast.Node) {
 	savePath = strings.TrimSpace(savePath)
 
 	bt := treenode.GetBlockTree(id)
@@ -832,7 +811,7 @@ func ExportHTML(id, savePath string, pdf, image, keepFold, merge bool) (name, do
 		}
 	}
 
-	blockRefMode := Conf.Export.BlockRefMode
+	blockRefMode := exportConf.BlockRefMode
 	var headings []*ast.Node
 	if pdf { // 导出 PDF 需要标记目录书签
 		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
@@ -855,11 +834,11 @@ func ExportHTML(id, savePath string, pdf, image, keepFold, merge bool) (name, do
 		}
 	}
 
-	tree = exportTree(tree, true, keepFold, true,
-		blockRefMode, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
-		Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
-		Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
-		Conf.Export.AddTitle, Conf.Export.InlineMemo, true, true, &map[string]*parse.Tree{})
+	tree = exportTree(exportConf, tree, true, keepFold, true,
+		blockRefMode, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		exportConf.AddTitle, exportConf.InlineMemo, true, true, &map[string]*parse.Tree{})
 	name = path.Base(tree.HPath)
 	name = util.FilterFileName(name) // 导出 PDF、HTML 和 Word 时未移除不支持的文件名符号 https://github.com/siyuan-note/siyuan/issues/5614
 
@@ -1011,7 +990,7 @@ func processIFrame(tree *parse.Tree) {
 	}
 }
 
-func ProcessPDF(id, p string, merge, removeAssets, watermark bool) (err error) {
+func ProcessPDF(exportConf *conf.Export, id, p string, merge, removeAssets, watermark bool) (err error) {
 	tree, _ := LoadTreeByBlockID(id)
 	if nil == tree {
 		return
@@ -1058,7 +1037,7 @@ func ProcessPDF(id, p string, merge, removeAssets, watermark bool) (err error) {
 
 	processPDFBookmarks(pdfCtx, headings)
 	processPDFLinkEmbedAssets(pdfCtx, assetDests, removeAssets)
-	processPDFWatermark(pdfCtx, watermark)
+	processPDFWatermark(exportConf, pdfCtx, watermark)
 
 	pdfcpuVer := model.VersionStr
 	model.VersionStr = "SiYuan v" + util.Ver + " (pdfcpu " + pdfcpuVer + ")"
@@ -1069,7 +1048,7 @@ func ProcessPDF(id, p string, merge, removeAssets, watermark bool) (err error) {
 	return
 }
 
-func processPDFWatermark(pdfCtx *model.Context, watermark bool) {
+func processPDFWatermark(exportConf *conf.Export, pdfCtx *model.Context, watermark bool) {
 	// Support adding the watermark on export PDF https://github.com/siyuan-note/siyuan/issues/9961
 	// https://pdfcpu.io/core/watermark
 
@@ -1077,7 +1056,7 @@ func processPDFWatermark(pdfCtx *model.Context, watermark bool) {
 		return
 	}
 
-	str := Conf.Export.PDFWatermarkStr
+	str := exportConf.PDFWatermarkStr
 	if "" == str {
 		return
 	}
@@ -1095,7 +1074,7 @@ func processPDFWatermark(pdfCtx *model.Context, watermark bool) {
 		}
 	}
 
-	desc := Conf.Export.PDFWatermarkDesc
+	desc := exportConf.PDFWatermarkDesc
 	if "text" == mode && util.ContainsCJK(str) {
 		// 中日韩文本水印需要安装字体文件
 		descParts := strings.Split(desc, ",")
@@ -1449,7 +1428,7 @@ func processPDFLinkEmbedAssets(pdfCtx *model.Context, assetDests []string, remov
 	}
 }
 
-func ExportStdMarkdown(id string, assetsDestSpace2Underscore bool) string {
+func ExportStdMarkdown(exportConf *conf.Export, id string, assetsDestSpace2Underscore bool) string {
 	tree, err := LoadTreeByBlockID(id)
 	if err != nil {
 		logging.LogErrorf("load tree by block id [%s] failed: %s", id, err)
@@ -1462,7 +1441,7 @@ func ExportStdMarkdown(id string, assetsDestSpace2Underscore bool) string {
 	}
 
 	var defBlockIDs []string
-	if 4 == Conf.Export.BlockRefMode { // 脚注+锚点哈希
+	if 4 == exportConf.BlockRefMode { // 脚注+锚点哈希
 		// 导出锚点哈希，这里先记录下所有定义块的 ID
 		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
 			if !entering {
@@ -1487,14 +1466,14 @@ func ExportStdMarkdown(id string, assetsDestSpace2Underscore bool) string {
 	}
 	defBlockIDs = gulu.Str.RemoveDuplicatedElem(defBlockIDs)
 
-	return exportMarkdownContent0(tree, cloudAssetsBase, assetsDestSpace2Underscore,
-		".md", Conf.Export.BlockRefMode, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
-		Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
-		Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
-		Conf.Export.AddTitle, Conf.Export.InlineMemo, defBlockIDs, true, false, &map[string]*parse.Tree{})
+	return exportMarkdownContent0(exportConf, tree, cloudAssetsBase, assetsDestSpace2Underscore,
+		".md", exportConf.BlockRefMode, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		exportConf.AddTitle, exportConf.InlineMemo, defBlockIDs, true, false, &map[string]*parse.Tree{})
 }
 
-func ExportPandocConvertZip(ids []string, pandocTo, ext string) (name, zipPath string) {
+func ExportPandocConvertZip(exportConf *conf.Export, ids []string, pandocTo, ext string) (name, zipPath string) {
 	block := treenode.GetBlockTree(ids[0])
 	box := Conf.Box(block.BoxID)
 	baseFolderName := path.Base(block.HPath)
@@ -1512,13 +1491,13 @@ func ExportPandocConvertZip(ids []string, pandocTo, ext string) (name, zipPath s
 		}
 	}
 
-	defBlockIDs, trees, docPaths := prepareExportTrees(docPaths)
-	zipPath = exportPandocConvertZip(baseFolderName, docPaths, defBlockIDs, "gfm+footnotes+hard_line_breaks", pandocTo, ext, trees)
+	defBlockIDs, trees, docPaths := prepareExportTrees(exportConf, docPaths)
+	zipPath = exportPandocConvertZip(exportConf, baseFolderName, docPaths, defBlockIDs, "gfm+footnotes+hard_line_breaks", pandocTo, ext, trees)
 	name = util.GetTreeID(block.Path)
 	return
 }
 
-func ExportNotebookMarkdown(boxID string) (zipPath string) {
+func ExportNotebookMarkdown(exportConf *conf.Export, boxID string) (zipPath string) {
 	util.PushEndlessProgress(Conf.Language(65))
 	defer util.ClearPushProgress(100)
 
@@ -1534,8 +1513,8 @@ func ExportNotebookMarkdown(boxID string) (zipPath string) {
 		docPaths = append(docPaths, docFile.path)
 	}
 
-	defBlockIDs, trees, docPaths := prepareExportTrees(docPaths)
-	zipPath = exportPandocConvertZip(box.Name, docPaths, defBlockIDs, "", "", ".md", trees)
+	defBlockIDs, trees, docPaths := prepareExportTrees(exportConf, docPaths)
+	zipPath = exportPandocConvertZip(exportConf, box.Name, docPaths, defBlockIDs, "", "", ".md", trees)
 	return
 }
 
@@ -1595,7 +1574,7 @@ func yfm(docIAL map[string]string) string {
 	return buf.String()
 }
 
-func exportBoxSYZip(boxID string) (zipPath string) {
+func exportBoxSYZip(exportConf *conf.Export, boxID string) (zipPath string) {
 	util.PushEndlessProgress(Conf.Language(65))
 	defer util.ClearPushProgress(100)
 
@@ -1611,11 +1590,11 @@ func exportBoxSYZip(boxID string) (zipPath string) {
 	for _, docFile := range docFiles {
 		docPaths = append(docPaths, docFile.path)
 	}
-	zipPath = exportSYZip(boxID, "/", baseFolderName, docPaths)
+	zipPath = exportSYZip(exportConf, boxID, "/", baseFolderName, docPaths)
 	return
 }
 
-func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (zipPath string) {
+func exportSYZip(exportConf *conf.Export, boxID, rootDirPath, baseFolderName string, docPaths []string) (zipPath string) {
 	defer util.ClearPushProgress(100)
 
 	dir, name := path.Split(baseFolderName)
@@ -1646,6 +1625,10 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 		if err != nil {
 			continue
 		}
+		if isExportDocFiltered(exportConf, tree) {
+			continue
+		}
+		filterExportTree(exportConf, tree)
 		trees[tree.ID] = tree
 
 		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", i+1, len(docPaths), tree.Root.IALAttr("title"))))
@@ -1657,9 +1640,9 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", count, len(docPaths), tree.Root.IALAttr("title"))))
 
 		refs := map[string]*parse.Tree{}
-		exportRefTrees(tree, &[]string{}, &refs, &treeCache)
+		exportRefTrees(exportConf, tree, &[]string{}, &refs, &treeCache)
 		for refTreeID, refTree := range refs {
-			if nil == trees[refTreeID] {
+			if nil == trees[refTreeID] && !isExportDocFiltered(exportConf, refTree) {
 				refTrees[refTreeID] = refTree
 			}
 		}
@@ -1672,10 +1655,9 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 	// 按文件夹结构复制选择的树
 	total := len(trees) + len(refTrees)
 	for _, tree := range trees {
-		readPath := filepath.Join(util.DataDir, tree.Box, tree.Path)
-		data, readErr := filelock.ReadFile(readPath)
-		if nil != readErr {
-			logging.LogErrorf("read file [%s] failed: %s", readPath, readErr)
+		data, renderErr := renderExportSY(tree)
+		if nil != renderErr {
+			logging.LogErrorf("render tree [%s] failed: %s", tree.Path, renderErr)
 			continue
 		}
 
@@ -1698,10 +1680,9 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 	count = 0
 	// 引用树放在导出文件夹根路径下
 	for treeID, tree := range refTrees {
-		readPath := filepath.Join(util.DataDir, tree.Box, tree.Path)
-		data, readErr := filelock.ReadFile(readPath)
-		if nil != readErr {
-			logging.LogErrorf("read file [%s] failed: %s", readPath, readErr)
+		data, renderErr := renderExportSY(tree)
+		if nil != renderErr {
+			logging.LogErrorf("render tree [%s] failed: %s", tree.Path, renderErr)
 			continue
 		}
 
@@ -1933,6 +1914,21 @@ func exportSYZip(boxID, rootDirPath, baseFolderName string, docPaths []string) (
 	return
 }
 
+// renderExportSY 将过滤后的树渲染为 .sy 文件内容。
+func renderExportSY(tree *parse.Tree) (ret []byte, err error) {
+	luteEngine := util.NewLute()
+	renderer := render.NewJSONRenderer(tree, luteEngine.RenderOptions)
+	ret = renderer.Render()
+	if !util.UseSingleLineSave {
+		buf := bytes.Buffer{}
+		if err = json.Indent(&buf, ret, "", "\t"); err != nil {
+			return
+		}
+		ret = buf.Bytes()
+	}
+	return
+}
+
 func exportRelationAvs(avID, exportStorageAvDir string) {
 	avIDs := hashset.New()
 	walkRelationAvs(avID, avIDs)
@@ -1973,7 +1969,7 @@ func walkRelationAvs(avID string, exportAvIDs *hashset.Set) {
 	}
 }
 
-func ExportMarkdownContent(id string, refMode, embedMode int, addYfm, fillCSSVar bool) (hPath, exportedMd string) {
+func ExportMarkdownContent(exportConf *conf.Export, id string, refMode, embedMode int, addYfm, fillCSSVar bool) (hPath, exportedMd string) {
 	bt := treenode.GetBlockTree(id)
 	if nil == bt {
 		return
@@ -1981,11 +1977,11 @@ func ExportMarkdownContent(id string, refMode, embedMode int, addYfm, fillCSSVar
 
 	tree := prepareExportTree(bt)
 	hPath = tree.HPath
-	exportedMd = exportMarkdownContent0(tree, "", false,
-		".md", refMode, embedMode, Conf.Export.FileAnnotationRefMode,
-		Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
-		Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
-		Conf.Export.AddTitle, Conf.Export.InlineMemo, nil, true, fillCSSVar, &map[string]*parse.Tree{})
+	exportedMd = exportMarkdownContent0(exportConf, tree, "", false,
+		".md", refMode, embedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		exportConf.AddTitle, exportConf.InlineMemo, nil, true, fillCSSVar, &map[string]*parse.Tree{})
 	docIAL := parse.IAL2Map(tree.Root.KramdownIAL)
 	if addYfm {
 		exportedMd = yfm(docIAL) + exportedMd
@@ -1993,31 +1989,31 @@ func ExportMarkdownContent(id string, refMode, embedMode int, addYfm, fillCSSVar
 	return
 }
 
-func exportMarkdownContent(id, ext string, exportRefMode int, defBlockIDs []string, singleFile bool, treeCache *map[string]*parse.Tree) (tree *parse.Tree, exportedMd string, isEmpty bool) {
-	tree, err := loadTreeWithCache(id, treeCache)
+func exportMarkdownContent(exportConf *conf.Export, id, ext string, exportRefMode int, defBlockIDs []string, singleFile bool, treeCache *map[string]*parse.Tree) (tree *parse.Tree, exportedMd string, isEmpty bool) {
+	tree, err := loadExportTreeWithCache(exportConf, id, treeCache)
 	if err != nil {
 		logging.LogErrorf("load tree by block id [%s] failed: %s", id, err)
 		return
 	}
 	isEmpty = nil == tree.Root.FirstChild.FirstChild
-	exportedMd = exportMarkdownContent0(tree, "", false,
-		ext, exportRefMode, Conf.Export.BlockEmbedMode, Conf.Export.FileAnnotationRefMode,
-		Conf.Export.TagOpenMarker, Conf.Export.TagCloseMarker,
-		Conf.Export.BlockRefTextLeft, Conf.Export.BlockRefTextRight,
-		Conf.Export.AddTitle, Conf.Export.InlineMemo, defBlockIDs, singleFile, false, treeCache)
+	exportedMd = exportMarkdownContent0(exportConf, tree, "", false,
+		ext, exportRefMode, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		exportConf.AddTitle, exportConf.InlineMemo, defBlockIDs, singleFile, false, treeCache)
 	docIAL := parse.IAL2Map(tree.Root.KramdownIAL)
-	if Conf.Export.MarkdownYFM {
+	if exportConf.MarkdownYFM {
 		// 导出 Markdown 时在文档头添加 YFM 开关 https://github.com/siyuan-note/siyuan/issues/7727
 		exportedMd = yfm(docIAL) + exportedMd
 	}
 	return
 }
 
-func exportMarkdownContent0(tree *parse.Tree, cloudAssetsBase string, assetsDestSpace2Underscore bool,
+func exportMarkdownContent0(exportConf *conf.Export, tree *parse.Tree, cloudAssetsBase string, assetsDestSpace2Underscore bool,
 	ext string, blockRefMode, blockEmbedMode, fileAnnotationRefMode int,
 	tagOpenMarker, tagCloseMarker string, blockRefTextLeft, blockRefTextRight string,
 	addTitle, inlineMemo bool, defBlockIDs []string, singleFile, fillCSSVar bool, treeCache *map[string]*parse.Tree) (ret string) {
-	tree = exportTree(tree, false, false, false,
+	tree = exportTree(exportConf, tree, false, false, false,
 		blockRefMode, blockEmbedMode, fileAnnotationRefMode,
 		tagOpenMarker, tagCloseMarker,
 		blockRefTextLeft, blockRefTextRight,
@@ -2133,7 +2129,7 @@ func exportMarkdownContent0(tree *parse.Tree, cloudAssetsBase string, assetsDest
 	return
 }
 
-func exportTree(tree *parse.Tree, wysiwyg, keepFold, avHiddenCol bool,
+func exportTree(exportConf *conf.Export, tree *parse.Tree, wysiwyg, keepFold, avHiddenCol bool,
 	blockRefMode, blockEmbedMode, fileAnnotationRefMode int,
 	tagOpenMarker, tagCloseMarker string,
 	blockRefTextLeft, blockRefTextRight string,
@@ -2141,21 +2137,24 @@ func exportTree(tree *parse.Tree, wysiwyg, keepFold, avHiddenCol bool,
 	luteEngine := NewLute()
 	ret = tree
 	id := tree.Root.ID
-	(*treeCache)[tree.ID] = tree
 
 	// 解析查询嵌入节点
 	depth := 0
-	resolveEmbedR(ret.Root, blockEmbedMode, luteEngine, &[]string{}, &depth)
+	resolveEmbedR(exportConf, ret.Root, blockEmbedMode, luteEngine, &[]string{}, &depth)
+
+	// 按照导出过滤规则和导出配置方案过滤块，过滤后才能放入缓存
+	filterExportTree(exportConf, ret)
+	(*treeCache)[tree.ID] = tree
 
 	// 将块超链接转换为引用
 	depth = 0
-	blockLink2Ref(ret, ret.ID, treeCache, &depth)
+	blockLink2Ref(exportConf, ret, ret.ID, treeCache, &depth)
 
 	// 收集引用转脚注+锚点哈希
 	var refFootnotes []*refAsFootnotes
 	if 4 == blockRefMode && singleFile {
 		depth = 0
-		collectFootnotesDefs(ret, ret.ID, &refFootnotes, treeCache, &depth)
+		collectFootnotesDefs(exportConf, ret, ret.ID, &refFootnotes, treeCache, &depth)
 	}
 
 	currentTreeNodeIDs := map[string]bool{}
@@ -2291,7 +2290,7 @@ func exportTree(tree *parse.Tree, wysiwyg, keepFold, avHiddenCol bool,
 
 	if 4 == blockRefMode { // 脚注+锚点哈希
 		unlinks = nil
-		footnotesDefBlock := resolveFootnotesDefs(&refFootnotes, ret, currentTreeNodeIDs, blockRefTextLeft, blockRefTextRight, treeCache)
+		footnotesDefBlock := resolveFootnotesDefs(exportConf, &refFootnotes, ret, currentTreeNodeIDs, blockRefTextLeft, blockRefTextRight, treeCache)
 		if nil != footnotesDefBlock {
 			// 如果是聚焦导出，可能存在没有使用的脚注定义块，在这里进行清理
 			// Improve focus export conversion of block refs to footnotes https://github.com/siyuan-note/siyuan/issues/10647
@@ -2780,7 +2779,7 @@ func exportTree(tree *parse.Tree, wysiwyg, keepFold, avHiddenCol bool,
 	return ret
 }
 
-func resolveFootnotesDefs(refFootnotes *[]*refAsFootnotes, currentTree *parse.Tree, currentTreeNodeIDs map[string]bool, blockRefTextLeft, blockRefTextRight string, treeCache *map[string]*parse.Tree) (footnotesDefBlock *ast.Node) {
+func resolveFootnotesDefs(exportConf *conf.Export, refFootnotes *[]*refAsFootnotes, currentTree *parse.Tree, currentTreeNodeIDs map[string]bool, blockRefTextLeft, blockRefTextRight string, treeCache *map[string]*parse.Tree) (footnotesDefBlock *ast.Node) {
 	if 1 > len(*refFootnotes) {
 		return nil
 	}
@@ -2788,7 +2787,7 @@ func resolveFootnotesDefs(refFootnotes *[]*refAsFootnotes, currentTree *parse.Tr
 	footnotesDefBlock = &ast.Node{Type: ast.NodeFootnotesDefBlock}
 	var rendered []string
 	for _, foot := range *refFootnotes {
-		t, err := loadTreeWithCache(foot.defID, treeCache)
+		t, err := loadExportTreeWithCache(exportConf, foot.defID, treeCache)
 		if nil != err {
 			return
 		}
@@ -2847,7 +2846,7 @@ func resolveFootnotesDefs(refFootnotes *[]*refAsFootnotes, currentTree *parse.Tr
 					stmt = strings.ReplaceAll(stmt, editor.IALValEscNewLine, "\n")
 					sqlBlocks := sql.SelectBlocksRawStmt(stmt, 1, Conf.Search.Limit)
 					for _, b := range sqlBlocks {
-						subNodes := renderBlockMarkdownR(b.ID, &rendered)
+						subNodes := renderBlockMarkdownR(exportConf, b.ID, &rendered)
 						for _, subNode := range subNodes {
 							if ast.NodeListItem == subNode.Type {
 								parentList := &ast.Node{Type: ast.NodeList, ListData: &ast.ListData{Typ: subNode.ListData.Typ}}
@@ -2914,7 +2913,7 @@ func resolveFootnotesDefs(refFootnotes *[]*refAsFootnotes, currentTree *parse.Tr
 	return
 }
 
-func blockLink2Ref(currentTree *parse.Tree, id string, treeCache *map[string]*parse.Tree, depth *int) {
+func blockLink2Ref(exportConf *conf.Export, currentTree *parse.Tree, id string, treeCache *map[string]*parse.Tree, depth *int) {
 	*depth++
 	if 4096 < *depth {
 		return
@@ -2924,7 +2923,7 @@ func blockLink2Ref(currentTree *parse.Tree, id string, treeCache *map[string]*pa
 	if nil == b {
 		return
 	}
-	t, err := loadTreeWithCache(b.RootID, treeCache)
+	t, err := loadExportTreeWithCache(exportConf, b.RootID, treeCache)
 	if nil != err {
 		return
 	}
@@ -2934,17 +2933,17 @@ func blockLink2Ref(currentTree *parse.Tree, id string, treeCache *map[string]*pa
 		logging.LogErrorf("not found node [%s] in tree [%s]", b.ID, t.Root.ID)
 		return
 	}
-	blockLink2Ref0(currentTree, node, treeCache, depth)
+	blockLink2Ref0(exportConf, currentTree, node, treeCache, depth)
 	if ast.NodeHeading == node.Type {
 		children := treenode.HeadingChildren(node)
 		for _, c := range children {
-			blockLink2Ref0(currentTree, c, treeCache, depth)
+			blockLink2Ref0(exportConf, currentTree, c, treeCache, depth)
 		}
 	}
 	return
 }
 
-func blockLink2Ref0(currentTree *parse.Tree, node *ast.Node, treeCache *map[string]*parse.Tree, depth *int) {
+func blockLink2Ref0(exportConf *conf.Export, currentTree *parse.Tree, node *ast.Node, treeCache *map[string]*parse.Tree, depth *int) {
 	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
 		if !entering {
 			return ast.WalkContinue
@@ -2955,17 +2954,17 @@ func blockLink2Ref0(currentTree *parse.Tree, node *ast.Node, treeCache *map[stri
 			n.TextMarkBlockRefID = strings.TrimPrefix(n.TextMarkAHref, "siyuan://blocks/")
 			n.TextMarkBlockRefSubtype = "s"
 
-			blockLink2Ref(currentTree, n.TextMarkBlockRefID, treeCache, depth)
+			blockLink2Ref(exportConf, currentTree, n.TextMarkBlockRefID, treeCache, depth)
 			return ast.WalkSkipChildren
 		} else if treenode.IsBlockRef(n) {
 			defID, _, _ := treenode.GetBlockRef(n)
-			blockLink2Ref(currentTree, defID, treeCache, depth)
+			blockLink2Ref(exportConf, currentTree, defID, treeCache, depth)
 		}
 		return ast.WalkContinue
 	})
 }
 
-func collectFootnotesDefs(currentTree *parse.Tree, id string, refFootnotes *[]*refAsFootnotes, treeCache *map[string]*parse.Tree, depth *int) {
+func collectFootnotesDefs(exportConf *conf.Export, currentTree *parse.Tree, id string, refFootnotes *[]*refAsFootnotes, treeCache *map[string]*parse.Tree, depth *int) {
 	*depth++
 	if 4096 < *depth {
 		return
@@ -2974,7 +2973,7 @@ func collectFootnotesDefs(currentTree *parse.Tree, id string, refFootnotes *[]*r
 	if nil == b {
 		return
 	}
-	t, err := loadTreeWithCache(b.RootID, treeCache)
+	t, err := loadExportTreeWithCache(exportConf, b.RootID, treeCache)
 	if nil != err {
 		return
 	}
@@ -2984,17 +2983,17 @@ func collectFootnotesDefs(currentTree *parse.Tree, id string, refFootnotes *[]*r
 		logging.LogErrorf("not found node [%s] in tree [%s]", b.ID, t.Root.ID)
 		return
 	}
-	collectFootnotesDefs0(currentTree, node, refFootnotes, treeCache, depth)
+	collectFootnotesDefs0(exportConf, currentTree, node, refFootnotes, treeCache, depth)
 	if ast.NodeHeading == node.Type {
 		children := treenode.HeadingChildren(node)
 		for _, c := range children {
-			collectFootnotesDefs0(currentTree, c, refFootnotes, treeCache, depth)
+			collectFootnotesDefs0(exportConf, currentTree, c, refFootnotes, treeCache, depth)
 		}
 	}
 	return
 }
 
-func collectFootnotesDefs0(currentTree *parse.Tree, node *ast.Node, refFootnotes *[]*refAsFootnotes, treeCache *map[string]*parse.Tree, depth *int) {
+func collectFootnotesDefs0(exportConf *conf.Export, currentTree *parse.Tree, node *ast.Node, refFootnotes *[]*refAsFootnotes, treeCache *map[string]*parse.Tree, depth *int) {
 	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
 		if !entering {
 			return ast.WalkContinue
@@ -3016,7 +3015,7 @@ func collectFootnotesDefs0(currentTree *parse.Tree, node *ast.Node, refFootnotes
 					refNum:        strconv.Itoa(len(*refFootnotes) + 1),
 					refAnchorText: anchorText,
 				})
-				collectFootnotesDefs(currentTree, defID, refFootnotes, treeCache, depth)
+				collectFootnotesDefs(exportConf, currentTree, defID, refFootnotes, treeCache, depth)
 			}
 			return ast.WalkSkipChildren
 		}
@@ -3101,7 +3100,7 @@ func processFileAnnotationRef(refID string, n *ast.Node, fileAnnotationRefMode i
 	return ast.WalkSkipChildren
 }
 
-func exportPandocConvertZip(baseFolderName string, docPaths, defBlockIDs []string,
+func exportPandocConvertZip(exportConf *conf.Export, baseFolderName string, docPaths, defBlockIDs []string,
 	pandocFrom, pandocTo, ext string, treeCache *map[string]*parse.Tree) (zipPath string) {
 	defer util.ClearPushProgress(100)
 
@@ -3121,7 +3120,7 @@ func exportPandocConvertZip(baseFolderName string, docPaths, defBlockIDs []strin
 		return
 	}
 
-	exportRefMode := Conf.Export.BlockRefMode
+	exportRefMode := exportConf.BlockRefMode
 	wrotePathHash := map[string]string{}
 	assetsPathMap, err := allAssetAbsPaths()
 	if nil != err {
@@ -3132,7 +3131,11 @@ func exportPandocConvertZip(baseFolderName string, docPaths, defBlockIDs []strin
 	luteEngine := util.NewLute()
 	for i, p := range docPaths {
 		id := util.GetTreeID(p)
-		tree, md, isEmpty := exportMarkdownContent(id, ext, exportRefMode, defBlockIDs, false, treeCache)
+		if tree, _ := loadExportTreeWithCache(exportConf, id, treeCache); isExportDocFiltered(exportConf, tree) {
+			continue
+		}
+
+		tree, md, isEmpty := exportMarkdownContent(exportConf, id, ext, exportRefMode, defBlockIDs, false, treeCache)
 		if nil == tree {
 			continue
 		}
@@ -3255,7 +3258,7 @@ func getExportBlockRefLinkText(blockRef *ast.Node, blockRefTextLeft, blockRefTex
 	return
 }
 
-func prepareExportTrees(docPaths []string) (defBlockIDs []string, trees *map[string]*parse.Tree, relatedDocPaths []string) {
+func prepareExportTrees(exportConf *conf.Export, docPaths []string) (defBlockIDs []string, trees *map[string]*parse.Tree, relatedDocPaths []string) {
 	trees = &map[string]*parse.Tree{}
 	treeCache := &map[string]*parse.Tree{}
 	defBlockIDs = []string{}
@@ -3265,11 +3268,11 @@ func prepareExportTrees(docPaths []string) (defBlockIDs []string, trees *map[str
 			continue
 		}
 
-		tree, err := loadTreeWithCache(rootID, treeCache)
+		tree, err := loadExportTreeWithCache(exportConf, rootID, treeCache)
 		if err != nil {
 			continue
 		}
-		exportRefTrees(tree, &defBlockIDs, trees, treeCache)
+		exportRefTrees(exportConf, tree, &defBlockIDs, trees, treeCache)
 
 		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", i+1, len(docPaths), tree.Root.IALAttr("title"))))
 	}
@@ -3281,7 +3284,7 @@ func prepareExportTrees(docPaths []string) (defBlockIDs []string, trees *map[str
 	return
 }
 
-func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache *map[string]*parse.Tree) {
+func exportRefTrees(exportConf *conf.Export, tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache *map[string]*parse.Tree) {
 	if nil != (*retTrees)[tree.ID] {
 		return
 	}
@@ -3307,7 +3310,7 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 			if (*treeCache)[defBlock.RootID] != nil {
 				defTree = (*treeCache)[defBlock.RootID]
 			} else {
-				defTree, err = loadTreeWithCache(defBlock.RootID, treeCache)
+				defTree, err = loadExportTreeWithCache(exportConf, defBlock.RootID, treeCache)
 				if err != nil {
 					return ast.WalkSkipChildren
 				}
@@ -3315,7 +3318,7 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 			}
 			*defBlockIDs = append(*defBlockIDs, defID)
 
-			exportRefTrees(defTree, defBlockIDs, retTrees, treeCache)
+			exportRefTrees(exportConf, defTree, defBlockIDs, retTrees, treeCache)
 		} else if treenode.IsBlockLink(n) {
 			defID := strings.TrimPrefix(n.TextMarkAHref, "siyuan://blocks/")
 			if "" == defID {
@@ -3331,7 +3334,7 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 			if (*treeCache)[defBlock.RootID] != nil {
 				defTree = (*treeCache)[defBlock.RootID]
 			} else {
-				defTree, err = loadTreeWithCache(defBlock.RootID, treeCache)
+				defTree, err = loadExportTreeWithCache(exportConf, defBlock.RootID, treeCache)
 				if err != nil {
 					return ast.WalkSkipChildren
 				}
@@ -3339,7 +3342,7 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 			}
 			*defBlockIDs = append(*defBlockIDs, defID)
 
-			exportRefTrees(defTree, defBlockIDs, retTrees, treeCache)
+			exportRefTrees(exportConf, defTree, defBlockIDs, retTrees, treeCache)
 		} else if ast.NodeAttributeView == n.Type {
 			// 导出数据库所在文档时一并导出绑定块所在文档
 			// Export the binding block docs when exporting the doc where the database is located https://github.com/siyuan-note/siyuan/issues/11486
@@ -3370,7 +3373,7 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 				if (*treeCache)[defBlock.RootID] != nil {
 					defTree = (*treeCache)[defBlock.RootID]
 				} else {
-					defTree, err = loadTreeWithCache(defBlock.RootID, treeCache)
+					defTree, err = loadExportTreeWithCache(exportConf, defBlock.RootID, treeCache)
 					if err != nil {
 						continue
 					}
@@ -3378,7 +3381,7 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 				}
 				*defBlockIDs = append(*defBlockIDs, val.BlockID)
 
-				exportRefTrees(defTree, defBlockIDs, retTrees, treeCache)
+				exportRefTrees(exportConf, defTree, defBlockIDs, retTrees, treeCache)
 			}
 		}
 		return ast.WalkContinue
@@ -3387,17 +3390,6 @@ func exportRefTrees(tree *parse.Tree, defBlockIDs *[]string, retTrees, treeCache
 	*defBlockIDs = gulu.Str.RemoveDuplicatedElem(*defBlockIDs)
 }
 
-func loadTreeWithCache(id string, treeCache *map[string]*parse.Tree) (tree *parse.Tree, err error) {
-	if tree = (*treeCache)[id]; nil != tree {
-		return
-	}
-	tree, err = LoadTreeByBlockID(id)
-	if nil == err && nil != tree {
-		(*treeCache)[id] = tree
-	}
-	return
-}
-
 func getAttrViewTable(attrView *av.AttributeView, view *av.View, query string) (ret *av.Table) {
 	switch view.LayoutType {
 	case av.LayoutTypeGallery:
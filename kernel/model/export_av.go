@@ -0,0 +1,529 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/xuri/excelize/v2"
+)
+
+type avExportField struct {
+	ID   string     `json:"id"`
+	Name string     `json:"name"`
+	Type av.KeyType `json:"type"`
+}
+
+type avExportRow struct {
+	ID      string           `json:"id,omitempty"`
+	BlockID string           `json:"blockID,omitempty"`
+	Values  []*avExportValue `json:"values"` // 按字段顺序排列，字段名可能重复，所以不能使用字段名作为键
+
+	texts []string
+	raw   []*av.Value
+}
+
+type avExportValue struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type avExportGroup struct {
+	Name string         `json:"name,omitempty"`
+	Rows []*avExportRow `json:"rows"`
+}
+
+type avExportData struct {
+	ID     string           `json:"id"`
+	Name   string           `json:"name"`
+	View   string           `json:"view"`
+	Fields []*avExportField `json:"fields"`
+	Groups []*avExportGroup `json:"groups"`
+}
+
+// ExportAttributeView 按照视图当前的过滤、排序和分组规则导出数据库，format 支持 csv、xlsx 和 json。
+//
+// withBlockID 为 true 时导出行 ID 和绑定块 ID，便于导入时对应到已有的行。
+func ExportAttributeView(exportConf *conf.Export, avID, blockID, viewID, format string, withBlockID bool) (exportPath string, err error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case "csv", "xlsx", "json":
+	default:
+		err = errors.New("unsupported export format [" + format + "]")
+		return
+	}
+
+	viewable, attrView, err := RenderAttributeView(blockID, avID, viewID, "", 1, math.MaxInt32)
+	if err != nil {
+		return
+	}
+
+	data := &avExportData{ID: attrView.ID, Name: getAttrViewName(attrView)}
+	if view := attrView.GetView(viewable.GetID()); nil != view {
+		data.View = view.Name
+	}
+	data.Fields = avExportFields(viewable)
+	groups := avExportViewables(viewable)
+	treeCache := &map[string]*parse.Tree{}
+	if 1 > len(groups) {
+		data.Groups = append(data.Groups, avExportRows(exportConf, viewable, data.Fields, withBlockID, treeCache))
+	} else {
+		for _, group := range groups {
+			data.Groups = append(data.Groups, avExportRows(exportConf, group, data.Fields, withBlockID, treeCache))
+		}
+	}
+
+	name := util.FilterFileName(data.Name)
+	if "" == name {
+		name = attrView.ID
+	}
+	exportFolder := filepath.Join(util.TempDir, "export", "av")
+	if err = os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("mkdir [%s] failed: %s", exportFolder, err)
+		return
+	}
+	p := filepath.Join(exportFolder, name+"."+format)
+
+	switch format {
+	case "csv":
+		err = writeAttributeViewCSV(p, data, withBlockID)
+	case "xlsx":
+		err = writeAttributeViewXLSX(p, data, withBlockID)
+	case "json":
+		var content []byte
+		content, err = gulu.JSON.MarshalIndentJSON(data, "", "  ")
+		if nil == err {
+			err = filelock.WriteFile(p, content)
+		}
+	}
+	if err != nil {
+		logging.LogErrorf("export attribute view [%s] to [%s] failed: %s", avID, p, err)
+		return
+	}
+
+	exportPath = "/export/av/" + url.PathEscape(filepath.Base(p))
+	return
+}
+
+func avExportFields(viewable av.Viewable) (ret []*avExportField) {
+	switch viewable.GetType() {
+	case av.LayoutTypeTable:
+		for _, col := range viewable.(*av.Table).Columns {
+			if !col.Hidden {
+				ret = append(ret, &avExportField{ID: col.ID, Name: col.Name, Type: col.Type})
+			}
+		}
+	case av.LayoutTypeGallery:
+		for _, field := range viewable.(*av.Gallery).Fields {
+			if !field.Hidden {
+				ret = append(ret, &avExportField{ID: field.ID, Name: field.Name, Type: field.Type})
+			}
+		}
+	}
+	return
+}
+
+func avExportViewables(viewable av.Viewable) (ret []av.Viewable) {
+	var groups []av.Viewable
+	switch viewable.GetType() {
+	case av.LayoutTypeTable:
+		groups = viewable.(*av.Table).Groups
+	case av.LayoutTypeGallery:
+		groups = viewable.(*av.Gallery).Groups
+	}
+
+	for _, group := range groups {
+		if avExportBaseInstance(group).GroupHidden {
+			continue
+		}
+		ret = append(ret, group)
+	}
+	return
+}
+
+func avExportBaseInstance(viewable av.Viewable) *av.BaseInstance {
+	switch viewable.GetType() {
+	case av.LayoutTypeTable:
+		return viewable.(*av.Table).BaseInstance
+	case av.LayoutTypeGallery:
+		return viewable.(*av.Gallery).BaseInstance
+	}
+	return &av.BaseInstance{}
+}
+
+func avExportRows(exportConf *conf.Export, viewable av.Viewable, fields []*avExportField, withBlockID bool, treeCache *map[string]*parse.Tree) (ret *avExportGroup) {
+	ret = &avExportGroup{Name: avExportBaseInstance(viewable).GroupName, Rows: []*avExportRow{}}
+	collection, ok := viewable.(av.Collection)
+	if !ok {
+		return
+	}
+
+	i := -1
+	for _, item := range collection.GetItems() {
+		var boundBlockID string
+		if blockVal := item.GetBlockValue(); nil != blockVal && !blockVal.IsDetached && nil != blockVal.Block {
+			boundBlockID = blockVal.Block.ID
+		}
+		if isExportBlockFiltered(exportConf, boundBlockID, treeCache) {
+			// 绑定的块被导出过滤规则或导出配置方案过滤时不导出该行
+			continue
+		}
+		i++
+
+		row := &avExportRow{Values: []*avExportValue{}}
+		if withBlockID {
+			row.ID = item.GetID()
+			row.BlockID = boundBlockID
+		}
+
+		for _, field := range fields {
+			value := item.GetValue(field.ID)
+			typed := getAttrViewValueTyped(value, i+1, withBlockID)
+			if av.KeyTypeLineNumber == field.Type {
+				typed = i + 1
+			}
+			row.Values = append(row.Values, &avExportValue{ID: field.ID, Name: field.Name, Value: typed})
+			row.raw = append(row.raw, value)
+			text := getAttrViewValueText(value, i+1)
+			if av.KeyTypeLineNumber == field.Type {
+				text = strconv.Itoa(i + 1)
+			}
+			row.texts = append(row.texts, text)
+		}
+		ret.Rows = append(ret.Rows, row)
+	}
+	return
+}
+
+func writeAttributeViewCSV(p string, data *avExportData, withBlockID bool) (err error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("\xEF\xBB\xBF") // 写入 UTF-8 BOM，避免使用 Microsoft Excel 打开乱码
+	writer := csv.NewWriter(buf)
+
+	grouped := 1 < len(data.Groups) || (1 == len(data.Groups) && "" != data.Groups[0].Name)
+	var header []string
+	if grouped {
+		header = append(header, "group")
+	}
+	if withBlockID {
+		header = append(header, "id", "blockID")
+	}
+	for _, field := range data.Fields {
+		header = append(header, field.Name)
+	}
+	if err = writer.Write(header); err != nil {
+		return
+	}
+
+	for _, group := range data.Groups {
+		for _, row := range group.Rows {
+			var record []string
+			if grouped {
+				record = append(record, group.Name)
+			}
+			if withBlockID {
+				record = append(record, row.ID, row.BlockID)
+			}
+			record = append(record, row.texts...)
+			if err = writer.Write(record); err != nil {
+				return
+			}
+		}
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return
+	}
+	return filelock.WriteFile(p, buf.Bytes())
+}
+
+func writeAttributeViewXLSX(p string, data *avExportData, withBlockID bool) (err error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	// 每个分组导出为一个工作表
+	styles := map[int]int{} // 数字格式 -> 样式 ID
+	defaultSheet := f.GetSheetName(0)
+	sheetNames := map[string]bool{}
+	for i, group := range data.Groups {
+		sheet := xlsxSheetName(group.Name, data.Name, i, sheetNames)
+		if 0 == i {
+			if err = f.SetSheetName(defaultSheet, sheet); err != nil {
+				return
+			}
+		} else if _, err = f.NewSheet(sheet); err != nil {
+			return
+		}
+
+		var header []interface{}
+		if withBlockID {
+			header = append(header, "id", "blockID")
+		}
+		for _, field := range data.Fields {
+			header = append(header, field.Name)
+		}
+		if err = f.SetSheetRow(sheet, "A1", &header); err != nil {
+			return
+		}
+
+		for j, row := range group.Rows {
+			var record []interface{}
+			if withBlockID {
+				record = append(record, row.ID, row.BlockID)
+			}
+			numFmts := map[int]int{}
+			for k, field := range data.Fields {
+				value, numFmt := xlsxCellValue(field.Type, row.raw[k], row.Values[k].Value, row.texts[k])
+				if 0 < numFmt {
+					numFmts[len(record)+1] = numFmt
+				}
+				record = append(record, value)
+			}
+
+			cell, _ := excelize.CoordinatesToCellName(1, j+2)
+			if err = f.SetSheetRow(sheet, cell, &record); err != nil {
+				return
+			}
+
+			// 日期需要设置数字格式，否则显示为序列号
+			for col, numFmt := range numFmts {
+				style, ok := styles[numFmt]
+				if !ok {
+					if style, err = f.NewStyle(&excelize.Style{NumFmt: numFmt}); err != nil {
+						return
+					}
+					styles[numFmt] = style
+				}
+				cell, _ = excelize.CoordinatesToCellName(col, j+2)
+				if err = f.SetCellStyle(sheet, cell, cell, style); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return f.SaveAs(p)
+}
+
+func xlsxSheetName(groupName, avName string, index int, used map[string]bool) (ret string) {
+	ret = groupName
+	if "" == ret {
+		ret = avName
+	}
+	ret = strings.NewReplacer("[", "", "]", "", ":", "", "*", "", "?", "", "/", "", "\\", "").Replace(ret)
+	ret = strings.TrimSpace(ret)
+	if "" == ret {
+		ret = "Sheet" + strconv.Itoa(index+1)
+	}
+	ret = gulu.Str.SubStr(ret, 28) // 工作表名称最长 31 个字符，预留去重后缀
+	for name, i := ret, 2; used[strings.ToLower(ret)]; i++ {
+		ret = name + " " + strconv.Itoa(i)
+	}
+	used[strings.ToLower(ret)] = true
+	return
+}
+
+// xlsxCellValue 返回单元格的值和数字格式，日期返回 time.Time 写入为日期单元格，数字格式 14 为日期，22 为日期时间。
+func xlsxCellValue(keyType av.KeyType, value *av.Value, typed interface{}, text string) (ret interface{}, numFmt int) {
+	switch keyType {
+	case av.KeyTypeNumber, av.KeyTypeCheckbox, av.KeyTypeLineNumber:
+		if nil == typed {
+			return "", 0
+		}
+		return typed, 0
+	case av.KeyTypeDate:
+		// 日期范围无法使用一个日期单元格表示，仍然导出为文本
+		if nil != value && nil != value.Date && value.Date.IsNotEmpty && !(value.Date.HasEndDate && value.Date.IsNotEmpty2) {
+			if value.Date.IsNotTime {
+				return xlsxTime(value.Date.Content), 14
+			}
+			return xlsxTime(value.Date.Content), 22
+		}
+	case av.KeyTypeCreated:
+		if nil != value && nil != value.Created {
+			return xlsxTime(value.Created.Content), 22
+		}
+	case av.KeyTypeUpdated:
+		if nil != value && nil != value.Updated {
+			return xlsxTime(value.Updated.Content), 22
+		}
+	}
+	return text, 0
+}
+
+// xlsxTime 返回本地时间的墙上时钟，Excel 日期不包含时区。
+func xlsxTime(mills int64) time.Time {
+	t := time.UnixMilli(mills)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// getAttrViewValueText 返回字段值导出时的文本内容。
+func getAttrViewValueText(value *av.Value, rowNum int) (ret string) {
+	if nil == value {
+		return
+	}
+
+	switch value.Type {
+	case av.KeyTypeDate:
+		if nil != value.Date {
+			ret = av.NewFormattedValueDate(value.Date.Content, value.Date.Content2, av.DateFormatNone, value.Date.IsNotTime, value.Date.HasEndDate).FormattedContent
+		}
+	case av.KeyTypeCreated:
+		if nil != value.Created {
+			ret = av.NewFormattedValueCreated(value.Created.Content, 0, av.CreatedFormatNone).FormattedContent
+		}
+	case av.KeyTypeUpdated:
+		if nil != value.Updated {
+			ret = av.NewFormattedValueUpdated(value.Updated.Content, 0, av.UpdatedFormatNone).FormattedContent
+		}
+	case av.KeyTypeMAsset:
+		buf := &bytes.Buffer{}
+		for _, a := range value.MAsset {
+			if av.AssetTypeImage == a.Type {
+				buf.WriteString("![")
+				buf.WriteString(a.Name)
+				buf.WriteString("](")
+				buf.WriteString(a.Content)
+				buf.WriteString(") ")
+			} else if av.AssetTypeFile == a.Type {
+				buf.WriteString("[")
+				buf.WriteString(a.Name)
+				buf.WriteString("](")
+				buf.WriteString(a.Content)
+				buf.WriteString(") ")
+			} else {
+				buf.WriteString(a.Content)
+				buf.WriteString(" ")
+			}
+		}
+		ret = strings.TrimSpace(buf.String())
+	case av.KeyTypeLineNumber:
+		ret = strconv.Itoa(rowNum)
+	}
+
+	if "" == ret {
+		ret = value.String(true)
+	}
+	return
+}
+
+// getAttrViewValueTyped 返回字段值导出时的类型化内容，用于 JSON 和 XLSX 导出。
+func getAttrViewValueTyped(value *av.Value, rowNum int, withBlockID bool) interface{} {
+	if nil == value {
+		return nil
+	}
+
+	switch value.Type {
+	case av.KeyTypeNumber:
+		if nil == value.Number || !value.Number.IsNotEmpty {
+			return nil
+		}
+		return value.Number.Content
+	case av.KeyTypeCheckbox:
+		return nil != value.Checkbox && value.Checkbox.Checked
+	case av.KeyTypeDate:
+		if nil == value.Date || !value.Date.IsNotEmpty {
+			return nil
+		}
+		ret := map[string]interface{}{"start": avExportTime(value.Date.Content, value.Date.IsNotTime)}
+		if value.Date.HasEndDate && value.Date.IsNotEmpty2 {
+			ret["end"] = avExportTime(value.Date.Content2, value.Date.IsNotTime)
+		}
+		return ret
+	case av.KeyTypeCreated:
+		if nil == value.Created {
+			return nil
+		}
+		return avExportTime(value.Created.Content, false)
+	case av.KeyTypeUpdated:
+		if nil == value.Updated {
+			return nil
+		}
+		return avExportTime(value.Updated.Content, false)
+	case av.KeyTypeSelect:
+		if 1 > len(value.MSelect) {
+			return nil
+		}
+		return value.MSelect[0].Content
+	case av.KeyTypeMSelect:
+		ret := []string{}
+		for _, opt := range value.MSelect {
+			ret = append(ret, opt.Content)
+		}
+		return ret
+	case av.KeyTypeMAsset:
+		ret := []map[string]interface{}{}
+		for _, a := range value.MAsset {
+			ret = append(ret, map[string]interface{}{"type": a.Type, "name": a.Name, "content": a.Content})
+		}
+		return ret
+	case av.KeyTypeRelation:
+		ret := []interface{}{}
+		if nil == value.Relation {
+			return ret
+		}
+		for i, content := range value.Relation.Contents {
+			if !withBlockID {
+				ret = append(ret, content.String(true))
+				continue
+			}
+
+			id := ""
+			if i < len(value.Relation.BlockIDs) {
+				id = value.Relation.BlockIDs[i]
+			}
+			ret = append(ret, map[string]interface{}{"id": id, "content": content.String(true)})
+		}
+		return ret
+	case av.KeyTypeRollup:
+		ret := []interface{}{}
+		if nil == value.Rollup {
+			return ret
+		}
+		for _, content := range value.Rollup.Contents {
+			ret = append(ret, getAttrViewValueTyped(content, rowNum, withBlockID))
+		}
+		return ret
+	case av.KeyTypeLineNumber:
+		return rowNum
+	}
+	return value.String(false)
+}
+
+func avExportTime(mills int64, isNotTime bool) string {
+	t := time.UnixMilli(mills)
+	if isNotTime {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
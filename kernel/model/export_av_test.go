@@ -0,0 +1,138 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/xuri/excelize/v2"
+)
+
+func newAvExportTestTable(date time.Time) *av.Table {
+	column := func(id, name string, typ av.KeyType) *av.TableColumn {
+		return &av.TableColumn{BaseInstanceField: &av.BaseInstanceField{ID: id, Name: name, Type: typ}}
+	}
+	cell := func(v *av.Value) *av.TableCell {
+		return &av.TableCell{BaseValue: &av.BaseValue{ID: v.ID, Value: v, ValueType: v.Type}}
+	}
+
+	return &av.Table{
+		BaseInstance: &av.BaseInstance{},
+		Columns: []*av.TableColumn{
+			column("k1", "Name", av.KeyTypeText),
+			column("k2", "Name", av.KeyTypeText),
+			column("k3", "Due", av.KeyTypeDate),
+			column("k4", "Period", av.KeyTypeDate),
+		},
+		Rows: []*av.TableRow{{
+			ID: "r1",
+			Cells: []*av.TableCell{
+				cell(&av.Value{ID: "v1", KeyID: "k1", Type: av.KeyTypeText, Text: &av.ValueText{Content: "first"}}),
+				cell(&av.Value{ID: "v2", KeyID: "k2", Type: av.KeyTypeText, Text: &av.ValueText{Content: "second"}}),
+				cell(&av.Value{ID: "v3", KeyID: "k3", Type: av.KeyTypeDate, Date: &av.ValueDate{Content: date.UnixMilli(), IsNotEmpty: true, IsNotTime: true}}),
+				cell(&av.Value{ID: "v4", KeyID: "k4", Type: av.KeyTypeDate, Date: &av.ValueDate{Content: date.UnixMilli(), IsNotEmpty: true, HasEndDate: true, Content2: date.Add(24 * time.Hour).UnixMilli(), IsNotEmpty2: true}}),
+			},
+		}},
+	}
+}
+
+func TestAvExportRowsSameFieldName(t *testing.T) {
+	table := newAvExportTestTable(time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local))
+	fields := avExportFields(table)
+	group := avExportRows(nil, table, fields, false, &map[string]*parse.Tree{})
+	if 1 != len(group.Rows) {
+		t.Fatalf("got %d rows, want 1", len(group.Rows))
+	}
+
+	data, err := json.Marshal(group.Rows[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var row struct {
+		Values []struct {
+			ID    string      `json:"id"`
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"values"`
+	}
+	if err = json.Unmarshal(data, &row); err != nil {
+		t.Fatal(err)
+	}
+	if 4 != len(row.Values) {
+		t.Fatalf("got %d values, want 4: %s", len(row.Values), data)
+	}
+	for i, want := range []struct{ id, name, value string }{{"k1", "Name", "first"}, {"k2", "Name", "second"}} {
+		got := row.Values[i]
+		if got.ID != want.id || got.Name != want.name || got.Value != want.value {
+			t.Errorf("value %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestWriteAttributeViewXLSXDates(t *testing.T) {
+	date := time.Date(2024, 3, 15, 0, 0, 0, 0, time.Local)
+	table := newAvExportTestTable(date)
+	data := &avExportData{Name: "test", Fields: avExportFields(table)}
+	data.Groups = append(data.Groups, avExportRows(nil, table, data.Fields, false, &map[string]*parse.Tree{}))
+
+	p := filepath.Join(t.TempDir(), "test.xlsx")
+	if err := writeAttributeViewXLSX(p, data, false); err != nil {
+		t.Fatalf("write xlsx failed: %s", err)
+	}
+
+	f, err := excelize.OpenFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+
+	// 单个日期写入为带日期格式的数字单元格
+	raw, err := f.GetCellValue(sheet, "C2", excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		t.Fatalf("date cell raw value [%s] is not a number", raw)
+	}
+	if 45366 != int(serial) { // 2024-03-15 的 Excel 日期序列号
+		t.Errorf("date cell serial = %v, want 45366", serial)
+	}
+	styleID, err := f.GetCellStyle(sheet, "C2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	style, err := f.GetStyle(styleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if 14 != style.NumFmt {
+		t.Errorf("date cell number format = %d, want 14", style.NumFmt)
+	}
+
+	// 日期范围仍然导出为文本
+	if cellType, _ := f.GetCellType(sheet, "D2"); excelize.CellTypeSharedString != cellType && excelize.CellTypeInlineString != cellType {
+		t.Errorf("date range cell type = %v, want string", cellType)
+	}
+}
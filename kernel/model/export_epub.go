@@ -0,0 +1,701 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/88250/lute/render"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 导出 EPUB 时块锚点的占位符，渲染为 HTML 后替换为 <span id="..."></span>
+const (
+	epubAnchorOpen  = "\uE000"
+	epubAnchorClose = "\uE001"
+)
+
+// epubAnchorID 返回块在章节中的锚点 ID，块 ID 以数字开头，在 XHTML 中不是合法的 ID，需要加上前缀。
+func epubAnchorID(id string) string {
+	return "b-" + id
+}
+
+type epubChapter struct {
+	file  string      // 章节文件名，比如 chapter-001.xhtml
+	title string      // 章节标题
+	docID string      // 章节所在文档 ID
+	isDoc bool        // 是否是文档章节（文档标题和第一个顶级标题之前的内容）
+	nodes []*ast.Node // 章节包含的块
+	depth int         // 文档层级
+	html  string      // 渲染后的 XHTML 内容
+}
+
+type epubDoc struct {
+	tree  *parse.Tree
+	depth int
+}
+
+type epubBook struct {
+	exportConf *conf.Export
+	docs       []*epubDoc
+	chapters   []*epubChapter
+	nodeFile   map[string]string // 块 ID -> 所在章节文件
+	docFile    map[string]string // 文档 ID -> 文档章节文件
+	assets     []string
+	fonts      []string          // 嵌入的字体文件绝对路径
+	fontFamily map[string]string // 字体文件绝对路径 -> 主题样式中声明的 font-family
+}
+
+// ExportEPUB 导出文档树为 EPUB3 电子书，每个子文档和顶级标题作为一个章节，不依赖 Pandoc。
+func ExportEPUB(exportConf *conf.Export, id string) (name, epubPath string, err error) {
+	defer util.ClearPushProgress(100)
+
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		err = ErrBlockNotFound
+		return
+	}
+
+	util.PushEndlessProgress(Conf.language(65))
+
+	book := &epubBook{exportConf: exportConf, nodeFile: map[string]string{}, docFile: map[string]string{}}
+	treeCache := &map[string]*parse.Tree{}
+	book.addDoc(bt, 0, treeCache)
+	if "d" == bt.Type {
+		rootBlock := &Block{Box: bt.BoxID, ID: bt.ID, Path: bt.Path}
+		if err = buildBlockChildren(rootBlock); err != nil {
+			return
+		}
+		book.addSubDocs(rootBlock, 1, treeCache)
+	}
+
+	book.splitChapters()
+	luteEngine := NewLute()
+	for i, chapter := range book.chapters {
+		chapter.html = book.renderChapter(chapter, luteEngine)
+		util.PushEndlessProgress(Conf.language(65) + " " + fmt.Sprintf(Conf.language(70), fmt.Sprintf("%d/%d %s", i+1, len(book.chapters), chapter.title)))
+	}
+	book.collectFonts()
+
+	rootTree := book.docs[0].tree
+	baseName := util.FilterFileName(path.Base(rootTree.HPath))
+	if "" == baseName || "." == baseName {
+		baseName = rootTree.ID
+	}
+	exportFolder := filepath.Join(util.TempDir, "export")
+	if err = os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+
+	p := filepath.Join(exportFolder, baseName+".epub")
+	if err = book.write(p, rootTree); err != nil {
+		logging.LogErrorf("write epub [%s] failed: %s", p, err)
+		return
+	}
+
+	name = util.GetTreeID(bt.Path)
+	epubPath = "/export/" + url.PathEscape(filepath.Base(p))
+	return
+}
+
+func (book *epubBook) addDoc(bt *treenode.BlockTree, depth int, treeCache *map[string]*parse.Tree) {
+	tree := prepareExportTree(bt)
+	// 块引用统一转换为 siyuan://blocks/ 块超链接，渲染时再替换为章节内部链接
+	exportConf := book.exportConf
+	tree = exportTree(exportConf, tree, false, false, true,
+		2, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		false, exportConf.InlineMemo, false, true, treeCache)
+	book.docs = append(book.docs, &epubDoc{tree: tree, depth: depth})
+}
+
+func (book *epubBook) addSubDocs(block *Block, depth int, treeCache *map[string]*parse.Tree) {
+	for _, child := range block.Children {
+		bt := treenode.GetBlockTree(child.ID)
+		if nil == bt {
+			continue
+		}
+		book.addDoc(bt, depth, treeCache)
+		book.addSubDocs(child, depth+1, treeCache)
+	}
+}
+
+// splitChapters 按照文档和文档下的顶级标题拆分章节，并为标题和被引用的块插入锚点。
+func (book *epubBook) splitChapters() {
+	refDefIDs := map[string]bool{}
+	for _, doc := range book.docs {
+		ast.Walk(doc.tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if entering && treenode.IsBlockLink(n) {
+				refDefIDs[strings.TrimPrefix(n.TextMarkAHref, "siyuan://blocks/")] = true
+			}
+			return ast.WalkContinue
+		})
+	}
+
+	for _, doc := range book.docs {
+		root := doc.tree.Root
+		minLevel := 7
+		for c := root.FirstChild; nil != c; c = c.Next {
+			if ast.NodeHeading == c.Type && c.HeadingLevel < minLevel {
+				minLevel = c.HeadingLevel
+			}
+		}
+
+		current := &epubChapter{title: root.IALAttr("title"), docID: doc.tree.ID, isDoc: true, depth: doc.depth}
+		book.addChapter(current)
+		book.docFile[doc.tree.ID] = current.file
+		for c := root.FirstChild; nil != c; {
+			next := c.Next
+			if ast.NodeHeading == c.Type && minLevel == c.HeadingLevel {
+				current = &epubChapter{title: strings.TrimSpace(c.Text()), docID: doc.tree.ID, depth: doc.depth}
+				book.addChapter(current)
+			}
+			current.nodes = append(current.nodes, c)
+			c = next
+		}
+	}
+
+	for _, chapter := range book.chapters {
+		var anchors []*ast.Node
+		for _, node := range chapter.nodes {
+			ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+				if !entering || !n.IsBlock() || "" == n.ID {
+					return ast.WalkContinue
+				}
+
+				book.nodeFile[n.ID] = chapter.file
+				if ast.NodeHeading == n.Type || refDefIDs[n.ID] {
+					anchors = append(anchors, n)
+				}
+				return ast.WalkContinue
+			})
+		}
+
+		for _, n := range anchors {
+			insertEPUBAnchor(n)
+		}
+	}
+}
+
+func (book *epubBook) addChapter(chapter *epubChapter) {
+	book.chapters = append(book.chapters, chapter)
+	chapter.file = fmt.Sprintf("chapter-%03d.xhtml", len(book.chapters))
+}
+
+func insertEPUBAnchor(n *ast.Node) {
+	anchor := &ast.Node{Type: ast.NodeText, Tokens: []byte(epubAnchorOpen + n.ID + epubAnchorClose)}
+	leaf := n
+	if ast.NodeHeading != n.Type && ast.NodeParagraph != n.Type {
+		leaf = treenode.FirstLeafBlock(n)
+	}
+	if nil == leaf {
+		return
+	}
+
+	if ast.NodeHeading == leaf.Type || ast.NodeParagraph == leaf.Type {
+		if first := leaf.FirstChild; nil != first && ast.NodeTaskListItemMarker == first.Type {
+			first.InsertAfter(anchor)
+		} else if nil != first {
+			first.InsertBefore(anchor)
+		} else {
+			leaf.AppendChild(anchor)
+		}
+		return
+	}
+
+	p := &ast.Node{Type: ast.NodeParagraph}
+	p.AppendChild(anchor)
+	leaf.InsertBefore(p)
+}
+
+func (book *epubBook) renderChapter(chapter *epubChapter, luteEngine *lute.Lute) string {
+	tree := parse.Parse("", []byte(""), luteEngine.ParseOptions)
+	first := tree.Root.FirstChild
+	if chapter.isDoc {
+		title := &ast.Node{Type: ast.NodeHeading, HeadingLevel: 1}
+		title.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(chapter.title)})
+		first.InsertBefore(title)
+	}
+	for _, n := range chapter.nodes {
+		first.InsertBefore(n)
+	}
+
+	luteEngine.SetFootnotes(true)
+	luteEngine.SetKramdownIAL(false)
+	luteEngine.SetUnorderedListMarker("-")
+	renderer := render.NewProtyleExportMdRenderer(tree, luteEngine.RenderOptions)
+	md := gulu.Str.FromBytes(renderer.Render())
+
+	htmlEngine := lute.New()
+	htmlEngine.SetSoftBreak2HardBreak(false)
+	htmlEngine.SetCodeSyntaxHighlight(false)
+	htmlEngine.SetFootnotes(true)
+	htmlStr := htmlEngine.Md2HTML(md)
+	for {
+		start := strings.Index(htmlStr, epubAnchorOpen)
+		if 0 > start {
+			break
+		}
+		end := strings.Index(htmlStr[start:], epubAnchorClose)
+		if 0 > end {
+			htmlStr = strings.ReplaceAll(htmlStr, epubAnchorOpen, "")
+			break
+		}
+		id := htmlStr[start+len(epubAnchorOpen) : start+end]
+		htmlStr = htmlStr[:start] + "<span id=\"" + epubAnchorID(id) + "\"></span>" + htmlStr[start+end+len(epubAnchorClose):]
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		logging.LogErrorf("parse chapter [%s] HTML failed: %s", chapter.title, err)
+		return ""
+	}
+
+	doc.Find("a").Each(func(i int, selection *goquery.Selection) {
+		href, ok := selection.Attr("href")
+		if !ok || !strings.HasPrefix(href, "siyuan://blocks/") {
+			return
+		}
+
+		defID := strings.TrimPrefix(href, "siyuan://blocks/")
+		if file := book.docFile[defID]; "" != file {
+			selection.SetAttr("href", file)
+		} else if file = book.nodeFile[defID]; "" != file {
+			selection.SetAttr("href", file+"#"+epubAnchorID(defID))
+		} else {
+			// 引用了书籍以外的块，仅保留锚文本
+			selection.ReplaceWithHtml(util.EscapeHTML(selection.Text()))
+		}
+	})
+	doc.Find("img").Each(func(i int, selection *goquery.Selection) {
+		src, ok := selection.Attr("src")
+		if !ok {
+			return
+		}
+		if strings.Contains(src, "?") {
+			src = src[:strings.LastIndex(src, "?")]
+			selection.SetAttr("src", src)
+		}
+		if strings.HasPrefix(src, "assets/") {
+			if unescaped, unescapeErr := url.PathUnescape(src); nil == unescapeErr {
+				src = unescaped
+			}
+			book.assets = append(book.assets, src)
+			selection.SetAttr("src", epubHref(src))
+		}
+		if _, hasAlt := selection.Attr("alt"); !hasAlt {
+			selection.SetAttr("alt", "")
+		}
+	})
+	book.assets = gulu.Str.RemoveDuplicatedElem(book.assets)
+
+	ret, err := doc.Find("body").Html()
+	if err != nil {
+		logging.LogErrorf("render chapter [%s] HTML failed: %s", chapter.title, err)
+		return ""
+	}
+	return ret
+}
+
+// collectFonts 收集需要嵌入的字体：编辑器字体和当前主题样式中 @font-face 引用的字体文件。
+func (book *epubBook) collectFonts() {
+	book.fontFamily = map[string]string{}
+	if "" != Conf.Editor.FontFamily {
+		if fontPath := util.GetSysFontPath(Conf.Editor.FontFamily); "" != fontPath {
+			book.fonts = append(book.fonts, fontPath)
+		}
+	}
+
+	theme := Conf.Appearance.ThemeLight
+	if 1 == Conf.Appearance.Mode {
+		theme = Conf.Appearance.ThemeDark
+	}
+	for _, face := range themeFontFaces(filepath.Join(util.ThemesPath, theme)) {
+		book.fonts = append(book.fonts, face.path)
+		book.fontFamily[face.path] = face.family
+	}
+	book.fonts = gulu.Str.RemoveDuplicatedElem(book.fonts)
+}
+
+var (
+	cssFontFaceRegexp   = regexp.MustCompile(`(?is)@font-face\s*\{(.*?)\}`)
+	cssFontFamilyRegexp = regexp.MustCompile(`(?i)font-family\s*:\s*["']?([^;"']+?)["']?\s*(;|$)`)
+	cssURLRegexp        = regexp.MustCompile(`(?i)url\(\s*["']?([^)"']+)["']?\s*\)`)
+)
+
+type epubFontFace struct {
+	path   string // 字体文件绝对路径
+	family string
+}
+
+// themeFontFaces 解析主题目录下样式文件中的 @font-face，返回其中引用的本地字体文件，主题目录中未被引用的字体文件不会被嵌入。
+func themeFontFaces(themeDir string) (ret []*epubFontFace) {
+	filepath.Walk(themeDir, func(p string, info os.FileInfo, err error) error {
+		if nil != err || info.IsDir() || ".css" != strings.ToLower(filepath.Ext(p)) {
+			return nil
+		}
+
+		data, readErr := os.ReadFile(p)
+		if nil != readErr {
+			logging.LogWarnf("read theme style [%s] failed: %s", p, readErr)
+			return nil
+		}
+
+		for _, face := range cssFontFaceRegexp.FindAllStringSubmatch(string(data), -1) {
+			family := ""
+			if m := cssFontFamilyRegexp.FindStringSubmatch(face[1]); nil != m {
+				family = strings.TrimSpace(m[1])
+			}
+
+			for _, u := range cssURLRegexp.FindAllStringSubmatch(face[1], -1) {
+				src := strings.TrimSpace(u[1])
+				if idx := strings.IndexAny(src, "?#"); 0 <= idx {
+					src = src[:idx]
+				}
+				if "" == src || strings.Contains(src, ":") || strings.HasPrefix(src, "/") {
+					// 跳过远程字体、data URI 和绝对路径
+					continue
+				}
+				if unescaped, unescapeErr := url.PathUnescape(src); nil == unescapeErr {
+					src = unescaped
+				}
+
+				switch strings.ToLower(filepath.Ext(src)) {
+				case ".woff2", ".woff", ".ttf", ".otf":
+				default:
+					continue
+				}
+
+				fontPath := filepath.Join(filepath.Dir(p), filepath.FromSlash(src))
+				if !util.IsSubPath(themeDir, fontPath) || !gulu.File.IsExist(fontPath) {
+					continue
+				}
+				fontFamily := family
+				if "" == fontFamily {
+					fontFamily = strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+				}
+				ret = append(ret, &epubFontFace{path: fontPath, family: fontFamily})
+			}
+		}
+		return nil
+	})
+	return
+}
+
+var epubLangRegexp = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// epubLang 返回书籍语言，custom-lang 不是合法的 BCP 47 语言标签时回退到界面语言。
+func epubLang(customLang string) string {
+	for _, lang := range []string{customLang, Conf.Lang} {
+		lang = strings.ReplaceAll(strings.TrimSpace(lang), "_", "-")
+		if epubLangRegexp.MatchString(lang) {
+			return lang
+		}
+	}
+	return "en"
+}
+
+// epubHref 按路径段转义资源文件路径，空格和 # 等字符在 XHTML 和 OPF 的 href/src 中需要编码。
+func epubHref(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (book *epubBook) write(p string, rootTree *parse.Tree) (err error) {
+	f, err := os.Create(p)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	// mimetype 必须是第一个文件并且不能压缩
+	mimetype, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return
+	}
+	if _, err = mimetype.Write([]byte("application/epub+zip")); err != nil {
+		return
+	}
+
+	container := `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+	if err = writeEPUBEntry(w, "META-INF/container.xml", []byte(container)); err != nil {
+		return
+	}
+
+	ial := parse.IAL2Map(rootTree.Root.KramdownIAL)
+	lang := util.EscapeHTML(epubLang(ial["custom-lang"]))
+
+	manifest := &bytes.Buffer{}
+	var fontFamilies []string
+	fontFaces := &bytes.Buffer{}
+	for i, fontPath := range book.fonts {
+		data, readErr := os.ReadFile(fontPath)
+		if nil != readErr {
+			logging.LogWarnf("read font [%s] failed: %s", fontPath, readErr)
+			continue
+		}
+
+		fontName := fmt.Sprintf("font-%02d%s", i+1, strings.ToLower(filepath.Ext(fontPath)))
+		if err = writeEPUBEntry(w, "OEBPS/fonts/"+fontName, data); err != nil {
+			return
+		}
+		family := book.fontFamily[fontPath]
+		if "" == family {
+			family = strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+		}
+		if 0 == i && "" != Conf.Editor.FontFamily && fontPath == util.GetSysFontPath(Conf.Editor.FontFamily) {
+			family = Conf.Editor.FontFamily
+		}
+		fontFamilies = append(fontFamilies, "\""+family+"\"")
+		fontFaces.WriteString("@font-face {\n  font-family: \"" + family + "\";\n  src: url(\"fonts/" + fontName + "\");\n}\n")
+		manifest.WriteString(fmt.Sprintf("    <item id=\"font-%02d\" href=\"fonts/%s\" media-type=\"%s\"/>\n", i+1, fontName, epubMediaType(fontName)))
+	}
+
+	css := fontFaces.String() + "body {\n  font-family: " + strings.Join(append(fontFamilies, "serif"), ", ") + ";\n  line-height: 1.6;\n}\n" +
+		"img {\n  max-width: 100%;\n}\n" +
+		"pre {\n  white-space: pre-wrap;\n}\n" +
+		"table {\n  border-collapse: collapse;\n}\n" +
+		"th, td {\n  border: 1px solid #999;\n  padding: 0.2em 0.4em;\n}\n" +
+		"blockquote {\n  margin-left: 1em;\n  padding-left: 1em;\n  border-left: 0.2em solid #ccc;\n}\n"
+	if err = writeEPUBEntry(w, "OEBPS/style.css", []byte(css)); err != nil {
+		return
+	}
+	manifest.WriteString("    <item id=\"style\" href=\"style.css\" media-type=\"text/css\"/>\n")
+
+	// 题头图不一定出现在正文中，但需要作为封面图加入清单
+	coverImg := treenode.GetDocTitleImgPath(rootTree.Root)
+	if idx := strings.Index(coverImg, "?"); 0 < idx {
+		coverImg = coverImg[:idx]
+	}
+	if unescaped, unescapeErr := url.PathUnescape(coverImg); nil == unescapeErr {
+		coverImg = unescaped
+	}
+	if strings.HasPrefix(coverImg, "assets/") && !gulu.Str.Contains(coverImg, book.assets) {
+		book.assets = append(book.assets, coverImg)
+	}
+	for i, asset := range book.assets {
+		absPath, getErr := GetAssetAbsPath(asset)
+		if nil != getErr {
+			logging.LogWarnf("resolve path of asset [%s] failed: %s", asset, getErr)
+			continue
+		}
+		data, readErr := os.ReadFile(absPath)
+		if nil != readErr {
+			logging.LogWarnf("read asset [%s] failed: %s", absPath, readErr)
+			continue
+		}
+		if err = writeEPUBEntry(w, "OEBPS/"+asset, data); err != nil {
+			return
+		}
+
+		properties := ""
+		if asset == coverImg {
+			properties = " properties=\"cover-image\""
+		}
+		manifest.WriteString(fmt.Sprintf("    <item id=\"asset-%d\" href=\"%s\" media-type=\"%s\"%s/>\n", i+1, util.EscapeHTML(epubHref(asset)), epubMediaType(asset), properties))
+	}
+
+	spine := &bytes.Buffer{}
+	for i, chapter := range book.chapters {
+		xhtml := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE html>\n" +
+			"<html xmlns=\"http://www.w3.org/1999/xhtml\" xmlns:epub=\"http://www.idpf.org/2007/ops\" xml:lang=\"" + lang + "\" lang=\"" + lang + "\">\n" +
+			"<head>\n<meta charset=\"UTF-8\"/>\n<title>" + util.EscapeHTML(chapter.title) + "</title>\n" +
+			"<link rel=\"stylesheet\" type=\"text/css\" href=\"style.css\"/>\n</head>\n" +
+			"<body>\n<section epub:type=\"chapter\">\n" + chapter.html + "\n</section>\n</body>\n</html>\n"
+		if err = writeEPUBEntry(w, "OEBPS/"+chapter.file, []byte(xhtml)); err != nil {
+			return
+		}
+		manifest.WriteString(fmt.Sprintf("    <item id=\"chapter-%03d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, chapter.file))
+		spine.WriteString(fmt.Sprintf("    <itemref idref=\"chapter-%03d\"/>\n", i+1))
+	}
+
+	if err = writeEPUBEntry(w, "OEBPS/nav.xhtml", []byte(book.renderNav(lang))); err != nil {
+		return
+	}
+	manifest.WriteString("    <item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+
+	title := ial["title"]
+	modified := time.Now().UTC()
+	if updated, parseErr := time.ParseInLocation("20060102150405", ial["updated"], time.Local); nil == parseErr {
+		modified = updated.UTC()
+	}
+	metadata := &bytes.Buffer{}
+	metadata.WriteString("    <dc:identifier id=\"book-id\">urn:siyuan:" + rootTree.ID + "</dc:identifier>\n")
+	metadata.WriteString("    <dc:title>" + util.EscapeHTML(title) + "</dc:title>\n")
+	metadata.WriteString("    <dc:language>" + lang + "</dc:language>\n")
+	metaKeys := [][]string{
+		{"custom-author", "creator"},
+		{"custom-publisher", "publisher"},
+		{"custom-description", "description"},
+		{"custom-rights", "rights"},
+		{"custom-subject", "subject"},
+	}
+	for _, metaKey := range metaKeys {
+		if val := strings.TrimSpace(ial[metaKey[0]]); "" != val {
+			metadata.WriteString("    <dc:" + metaKey[1] + ">" + util.EscapeHTML(val) + "</dc:" + metaKey[1] + ">\n")
+		}
+	}
+	if tags := strings.TrimSpace(ial["tags"]); "" != tags && "" == ial["custom-subject"] {
+		for _, tag := range strings.Split(tags, ",") {
+			metadata.WriteString("    <dc:subject>" + util.EscapeHTML(strings.TrimSpace(tag)) + "</dc:subject>\n")
+		}
+	}
+	if created, parseErr := time.ParseInLocation("20060102150405", util.TimeFromID(rootTree.ID), time.Local); nil == parseErr {
+		metadata.WriteString("    <dc:date>" + created.UTC().Format(time.RFC3339) + "</dc:date>\n")
+	}
+	metadata.WriteString("    <meta property=\"dcterms:modified\">" + modified.Format("2006-01-02T15:04:05Z") + "</meta>\n")
+
+	opf := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+		"<package xmlns=\"http://www.idpf.org/2007/opf\" version=\"3.0\" unique-identifier=\"book-id\" xml:lang=\"" + lang + "\">\n" +
+		"  <metadata xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n" + metadata.String() + "  </metadata>\n" +
+		"  <manifest>\n" + manifest.String() + "  </manifest>\n" +
+		"  <spine>\n" + spine.String() + "  </spine>\n" +
+		"</package>\n"
+	if err = writeEPUBEntry(w, "OEBPS/content.opf", []byte(opf)); err != nil {
+		return
+	}
+	err = w.Close()
+	return
+}
+
+// renderNav 使用文档层级和文档大纲生成导航文档。
+func (book *epubBook) renderNav(lang string) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE html>\n")
+	buf.WriteString("<html xmlns=\"http://www.w3.org/1999/xhtml\" xmlns:epub=\"http://www.idpf.org/2007/ops\" xml:lang=\"" + lang + "\" lang=\"" + lang + "\">\n")
+	buf.WriteString("<head>\n<meta charset=\"UTF-8\"/>\n<title>" + util.EscapeHTML(book.docs[0].tree.Root.IALAttr("title")) + "</title>\n</head>\n<body>\n")
+	buf.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<ol>\n")
+
+	depth := 0
+	for i, doc := range book.docs {
+		if 0 < i {
+			if doc.depth > depth {
+				buf.WriteString("<ol>\n")
+			} else {
+				buf.WriteString("</li>\n")
+				for ; depth > doc.depth; depth-- {
+					buf.WriteString("</ol>\n</li>\n")
+				}
+			}
+		}
+		depth = doc.depth
+
+		buf.WriteString("<li><a href=\"" + book.docFile[doc.tree.ID] + "\">" + util.EscapeHTML(doc.tree.Root.IALAttr("title")) + "</a>\n")
+		paths, err := Outline(doc.tree.ID, false)
+		if nil != err {
+			logging.LogWarnf("get outline of doc [%s] failed: %s", doc.tree.ID, err)
+			continue
+		}
+		if 0 < len(paths) {
+			buf.WriteString("<ol>\n")
+			for _, p := range paths {
+				book.renderNavItem(buf, p.ID, p.Name, p.Blocks)
+			}
+			buf.WriteString("</ol>\n")
+		}
+	}
+	buf.WriteString("</li>\n")
+	for ; 0 < depth; depth-- {
+		buf.WriteString("</ol>\n</li>\n")
+	}
+	buf.WriteString("</ol>\n</nav>\n</body>\n</html>\n")
+	return buf.String()
+}
+
+func (book *epubBook) renderNavItem(buf *bytes.Buffer, id, content string, children []*Block) {
+	file := book.nodeFile[id]
+	if "" == file {
+		return
+	}
+
+	label := content
+	if doc, err := goquery.NewDocumentFromReader(strings.NewReader(content)); nil == err {
+		label = doc.Text()
+	}
+	buf.WriteString("<li><a href=\"" + file + "#" + epubAnchorID(id) + "\">" + util.EscapeHTML(strings.TrimSpace(label)) + "</a>")
+	if 0 < len(children) {
+		buf.WriteString("\n<ol>\n")
+		for _, child := range children {
+			book.renderNavItem(buf, child.ID, child.Content, child.Children)
+		}
+		buf.WriteString("</ol>\n")
+	}
+	buf.WriteString("</li>\n")
+}
+
+func writeEPUBEntry(w *zip.Writer, name string, data []byte) (err error) {
+	entry, err := w.Create(name)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(entry, bytes.NewReader(data))
+	return
+}
+
+func epubMediaType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".woff2":
+		return "font/woff2"
+	case ".woff":
+		return "font/woff"
+	case ".ttf":
+		return "font/ttf"
+	case ".otf":
+		return "font/otf"
+	case ".svg":
+		return "image/svg+xml"
+	}
+
+	if ret := mime.TypeByExtension(filepath.Ext(name)); "" != ret {
+		if idx := strings.Index(ret, ";"); 0 < idx {
+			ret = ret[:idx]
+		}
+		return ret
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,119 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEpubLang(t *testing.T) {
+	oldConf := Conf
+	Conf = &AppConf{Lang: "zh_CN"}
+	t.Cleanup(func() { Conf = oldConf })
+
+	tests := []struct {
+		customLang string
+		want       string
+	}{
+		{"", "zh-CN"},
+		{"en", "en"},
+		{"pt_BR", "pt-BR"},
+		{"zh-Hant-TW", "zh-Hant-TW"},
+		{"en\" onload=\"x", "zh-CN"},
+		{"<script>", "zh-CN"},
+		{"e", "zh-CN"},
+	}
+	for _, test := range tests {
+		if got := epubLang(test.customLang); got != test.want {
+			t.Errorf("epubLang(%q) = %q, want %q", test.customLang, got, test.want)
+		}
+	}
+
+	Conf.Lang = "bad lang"
+	if got := epubLang(""); "en" != got {
+		t.Errorf("epubLang fallback = %q, want en", got)
+	}
+}
+
+func TestEpubHref(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"assets/image-20240101-abc.png", "assets/image-20240101-abc.png"},
+		{"assets/my image.png", "assets/my%20image.png"},
+		{"assets/a#b.png", "assets/a%23b.png"},
+		{"assets/50%.png", "assets/50%25.png"},
+		{"assets/图片.png", "assets/%E5%9B%BE%E7%89%87.png"},
+	}
+	for _, test := range tests {
+		if got := epubHref(test.path); got != test.want {
+			t.Errorf("epubHref(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestThemeFontFaces(t *testing.T) {
+	themeDir := t.TempDir()
+	for _, name := range []string{"fonts/used.woff2", "fonts/used bold.ttf", "fonts/unused.woff2", "style/theme.css"} {
+		p := filepath.Join(themeDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	css := `@font-face {
+  font-family: "Theme Serif";
+  src: url("../fonts/used.woff2?v=1") format("woff2"), url(../fonts/used%20bold.ttf);
+}
+@font-face {
+  font-family: Remote;
+  src: url(https://example.com/remote.woff2);
+}
+@font-face {
+  font-family: Missing;
+  src: url("../fonts/missing.woff2");
+}
+body { font-family: "Theme Serif"; }
+`
+	if err := os.WriteFile(filepath.Join(themeDir, "style", "theme.css"), []byte(css), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	faces := themeFontFaces(themeDir)
+	got := map[string]string{}
+	for _, face := range faces {
+		rel, _ := filepath.Rel(themeDir, face.path)
+		got[filepath.ToSlash(rel)] = face.family
+	}
+	want := map[string]string{
+		"fonts/used.woff2":    "Theme Serif",
+		"fonts/used bold.ttf": "Theme Serif",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("themeFontFaces() = %v, want %v", got, want)
+	}
+	for p, family := range want {
+		if got[p] != family {
+			t.Errorf("themeFontFaces()[%q] = %q, want %q", p, got[p], family)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// filterExportTree 在渲染前按照导出过滤规则和导出配置方案移除块，文档被配置方案过滤时移除所有块。
+func filterExportTree(exportConf *conf.Export, tree *parse.Tree) {
+	if nil == exportConf || nil == tree {
+		return
+	}
+
+	if isExportDocFiltered(exportConf, tree) {
+		for c := tree.Root.FirstChild; nil != c; {
+			next := c.Next
+			c.Unlink()
+			c = next
+		}
+		tree.Root.AppendChild(treenode.NewParagraph(""))
+		return
+	}
+
+	excludeAttrs, excludeTags, excludeTypes, comment := getExportExcludeRules(exportConf)
+	if 1 > len(excludeAttrs) && 1 > len(excludeTags) && 1 > len(excludeTypes) && !comment {
+		return
+	}
+
+	var unlinks, inlineUnlinks []*ast.Node
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+
+		if comment && ast.NodeInlineHTML == n.Type && bytes.HasPrefix(n.Tokens, []byte("<!--")) {
+			inlineUnlinks = append(inlineUnlinks, n)
+			return ast.WalkContinue
+		}
+
+		if !n.IsBlock() || ast.NodeDocument == n.Type {
+			return ast.WalkContinue
+		}
+
+		if isExportNodeExcluded(n, excludeAttrs, excludeTags, excludeTypes) {
+			unlinks = append(unlinks, n)
+			return ast.WalkSkipChildren
+		}
+
+		if comment {
+			if ast.NodeHTMLBlock == n.Type && bytes.HasPrefix(bytes.TrimSpace(n.Tokens), []byte("<!--")) {
+				unlinks = append(unlinks, n)
+				return ast.WalkSkipChildren
+			}
+			n.RemoveIALAttr("memo")
+		}
+		return ast.WalkContinue
+	})
+
+	for _, n := range inlineUnlinks {
+		n.Unlink()
+	}
+	unlinkExportNodes(tree, unlinks)
+}
+
+// isExportBlockFiltered 判断块是否被导出过滤规则或导出配置方案过滤掉，用于导出数据库行等不经过导出渲染的内容。
+func isExportBlockFiltered(exportConf *conf.Export, id string, treeCache *map[string]*parse.Tree) bool {
+	if nil == exportConf || "" == id {
+		return false
+	}
+
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return false
+	}
+	tree, err := loadExportTreeWithCache(exportConf, bt.RootID, treeCache)
+	if err != nil {
+		return false
+	}
+	// 缓存中的树已经过滤，块或者块所在文档被过滤时树中找不到该块
+	return nil == treenode.GetNodeInTree(tree, id)
+}
+
+// getExportExcludeRules 合并导出过滤规则和导出配置方案中的排除规则。
+func getExportExcludeRules(exportConf *conf.Export) (excludeAttrs map[string]string, excludeTags, excludeTypes []string, comment bool) {
+	excludeAttrs = map[string]string{}
+	if filter := exportConf.Filter; nil != filter {
+		for k, v := range filter.Attrs {
+			excludeAttrs[k] = v
+		}
+		excludeTags = append(excludeTags, filter.Tags...)
+		excludeTypes = append(excludeTypes, filter.BlockTypes...)
+		comment = filter.Comment
+	}
+	if profile := exportConf.Profile; nil != profile {
+		for k, v := range profile.ExcludeAttrs {
+			excludeAttrs[k] = v
+		}
+		excludeTags = append(excludeTags, profile.ExcludeTags...)
+	}
+	return
+}
+
+func isExportNodeExcluded(n *ast.Node, excludeAttrs map[string]string, excludeTags, excludeTypes []string) bool {
+	return gulu.Str.Contains(treenode.TypeAbbr(n.Type.String()), excludeTypes) ||
+		matchExportAttrs(n, excludeAttrs) || matchExportTags(n, excludeTags)
+}
+
+// loadExportTreeWithCache 加载导出时用到的树并按照导出过滤规则过滤，避免脚注等内容中导出被过滤的块。
+//
+// 导出过程中所有放入 treeCache 的树都必须经过过滤，所以命中缓存时不再重复过滤。
+func loadExportTreeWithCache(exportConf *conf.Export, id string, treeCache *map[string]*parse.Tree) (tree *parse.Tree, err error) {
+	if tree = (*treeCache)[id]; nil != tree {
+		return
+	}
+	if tree, err = loadExportTree(exportConf, id); nil == err {
+		(*treeCache)[id] = tree
+	}
+	return
+}
+
+// loadExportTree 加载导出时用到的树并按照导出过滤规则过滤，用于嵌入块等需要修改树的场景。
+func loadExportTree(exportConf *conf.Export, id string) (tree *parse.Tree, err error) {
+	if tree, err = LoadTreeByBlockID(id); nil == err {
+		filterExportTree(exportConf, tree)
+	}
+	return
+}
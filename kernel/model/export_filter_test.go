@@ -0,0 +1,112 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// newExportFilterTrees 返回引用文档和被引用文档，被引用文档的第二个段落带有私有属性。
+func newExportFilterTrees() (refTree, defTree *parse.Tree, publicID, privateID string) {
+	defTree = newExportTestTree("20240101000000-deftree", "", nil)
+	private := treenode.NewParagraph("")
+	private.SetIALAttr("custom-private", "true")
+	defTree.Root.AppendChild(private)
+	publicID, privateID = defTree.Root.FirstChild.ID, private.ID
+
+	refTree = newExportTestTree("20240101000000-reftree", "", nil)
+	ref := &ast.Node{Type: ast.NodeTextMark, TextMarkType: "block-ref", TextMarkBlockRefID: publicID, TextMarkTextContent: "ref"}
+	refTree.Root.FirstChild.AppendChild(ref)
+	return
+}
+
+func newExportFilterConf() *conf.Export {
+	exportConf := &conf.Export{Filter: conf.NewExportFilter()}
+	exportConf.Filter.Attrs["custom-private"] = ""
+	return exportConf
+}
+
+func TestLoadExportTreeWithCache(t *testing.T) {
+	refTree, defTree, publicID, privateID := newExportFilterTrees()
+	setupExportTrees(t, refTree, defTree)
+	exportConf := newExportFilterConf()
+
+	treeCache := map[string]*parse.Tree{}
+	if isExportBlockFiltered(exportConf, publicID, &treeCache) {
+		t.Errorf("public block should not be filtered")
+	}
+	if !isExportBlockFiltered(exportConf, privateID, &treeCache) {
+		t.Errorf("private block should be filtered")
+	}
+
+	// 判断块是否被过滤时放入缓存的树也必须是过滤后的树
+	tree, err := loadExportTreeWithCache(exportConf, defTree.ID, &treeCache)
+	if err != nil {
+		t.Fatalf("load export tree failed: %s", err)
+	}
+	if nil == treenode.GetNodeInTree(tree, publicID) {
+		t.Errorf("public block is missing in the cached tree")
+	}
+	if nil != treenode.GetNodeInTree(tree, privateID) {
+		t.Errorf("private block is not filtered in the cached tree")
+	}
+}
+
+func TestExportRefTreesFiltered(t *testing.T) {
+	refTree, defTree, publicID, privateID := newExportFilterTrees()
+	setupExportTrees(t, refTree, defTree)
+
+	var defBlockIDs []string
+	refs := map[string]*parse.Tree{}
+	treeCache := map[string]*parse.Tree{}
+	exportRefTrees(newExportFilterConf(), refTree, &defBlockIDs, &refs, &treeCache)
+
+	tree := refs[defTree.ID]
+	if nil == tree {
+		t.Fatalf("referenced tree is not collected")
+	}
+	if nil == treenode.GetNodeInTree(tree, publicID) {
+		t.Errorf("public block is missing in the referenced tree")
+	}
+	if nil != treenode.GetNodeInTree(tree, privateID) {
+		t.Errorf("private block is not filtered in the referenced tree")
+	}
+}
+
+func TestRenderBlockMarkdownRFiltered(t *testing.T) {
+	refTree, defTree, publicID, privateID := newExportFilterTrees()
+	setupExportTrees(t, refTree, defTree)
+	exportConf := newExportFilterConf()
+
+	nodes := renderBlockMarkdownR(exportConf, defTree.ID, &[]string{})
+	if 1 != len(nodes) || publicID != nodes[0].ID {
+		var ids []string
+		for _, n := range nodes {
+			ids = append(ids, n.ID)
+		}
+		t.Errorf("render doc got blocks %v, want [%s]", ids, publicID)
+	}
+
+	if nodes = renderBlockMarkdownR(exportConf, privateID, &[]string{}); 0 < len(nodes) {
+		t.Errorf("render filtered block got %d blocks, want none", len(nodes))
+	}
+}
@@ -0,0 +1,637 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/editor"
+	"github.com/88250/lute/html"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ExportLaTeX 将文档导出为 LaTeX，标题映射为章节，标题、图片和表格的块引用转换为 \label/\ref，
+// 引用参考文献数据库中的块转换为 \cite 并生成 .bib 文件。
+func ExportLaTeX(exportConf *conf.Export, id, bibAvID string, merge bool) (name, zipPath string) {
+	defer util.ClearPushProgress(100)
+
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return
+	}
+
+	util.PushEndlessProgress(Conf.language(65))
+
+	tree := prepareExportTree(bt)
+	if merge {
+		var mergeErr error
+		tree, mergeErr = mergeSubDocs(tree)
+		if nil != mergeErr {
+			logging.LogErrorf("merge sub docs failed: %s", mergeErr)
+			return
+		}
+	}
+
+	// 块引用统一转换为 siyuan://blocks/ 块超链接，渲染时再根据定义块决定生成 \ref、\cite 还是锚文本
+	tree = exportTree(exportConf, tree, false, false, true,
+		2, exportConf.BlockEmbedMode, exportConf.FileAnnotationRefMode,
+		exportConf.TagOpenMarker, exportConf.TagCloseMarker,
+		exportConf.BlockRefTextLeft, exportConf.BlockRefTextRight,
+		false, exportConf.InlineMemo, false, true, &map[string]*parse.Tree{})
+
+	if "" == bibAvID {
+		bibAvID = exportConf.BibAvID
+	}
+
+	renderer := newLaTeXRenderer(tree, bibAvID)
+	tex := renderer.render()
+
+	baseName := path.Base(tree.HPath)
+	baseName = util.FilterFileName(baseName)
+	if "" == baseName || "." == baseName {
+		baseName = tree.ID
+	}
+	exportFolder := filepath.Join(util.TempDir, "export", baseName)
+	os.RemoveAll(exportFolder)
+	if err := os.MkdirAll(exportFolder, 0755); err != nil {
+		logging.LogErrorf("create export temp folder failed: %s", err)
+		return
+	}
+
+	texPath := filepath.Join(exportFolder, baseName+".tex")
+	if err := os.WriteFile(texPath, []byte(tex), 0644); err != nil {
+		logging.LogErrorf("write tex [%s] failed: %s", texPath, err)
+		return
+	}
+
+	if bib := renderer.renderBib(); "" != bib {
+		bibPath := filepath.Join(exportFolder, "references.bib")
+		if err := os.WriteFile(bibPath, []byte(bib), 0644); err != nil {
+			logging.LogErrorf("write bib [%s] failed: %s", bibPath, err)
+			return
+		}
+	}
+
+	for _, asset := range renderer.assets {
+		srcAbsPath, err := GetAssetAbsPath(asset)
+		if err != nil {
+			logging.LogWarnf("resolve path of asset [%s] failed: %s", asset, err)
+			continue
+		}
+		targetAbsPath := filepath.Join(exportFolder, asset)
+		if err = filelock.Copy(srcAbsPath, targetAbsPath); err != nil {
+			logging.LogWarnf("copy asset from [%s] to [%s] failed: %s", srcAbsPath, targetAbsPath, err)
+		}
+	}
+
+	zipPath = exportFolder + ".zip"
+	zip, err := gulu.Zip.Create(zipPath)
+	if err != nil {
+		logging.LogErrorf("create export latex zip [%s] failed: %s", exportFolder, err)
+		return "", ""
+	}
+
+	if err = zip.AddDirectory(baseName, exportFolder); err != nil {
+		logging.LogErrorf("create export latex zip [%s] failed: %s", exportFolder, err)
+		return "", ""
+	}
+
+	if err = zip.Close(); err != nil {
+		logging.LogErrorf("close export latex zip failed: %s", err)
+	}
+
+	os.RemoveAll(exportFolder)
+	name = util.GetTreeID(bt.Path)
+	zipPath = "/export/" + url.PathEscape(filepath.Base(zipPath))
+	return
+}
+
+type latexRenderer struct {
+	tree   *parse.Tree
+	buf    *bytes.Buffer
+	labels map[string]bool // 可以被 \ref 引用的块 ID（标题、图片、表格）
+	assets []string
+
+	bibAttrView *av.AttributeView
+	citeKeys    map[string]string // 参考文献块 ID -> 引用键
+	citeIDs     []string          // 按引用顺序排列的参考文献块 ID
+}
+
+func newLaTeXRenderer(tree *parse.Tree, bibAvID string) (ret *latexRenderer) {
+	ret = &latexRenderer{
+		tree:     tree,
+		buf:      &bytes.Buffer{},
+		labels:   map[string]bool{},
+		citeKeys: map[string]string{},
+	}
+
+	if "" != bibAvID && av.IsAttributeViewExist(bibAvID) {
+		attrView, err := av.ParseAttributeView(bibAvID)
+		if nil != err {
+			logging.LogErrorf("parse bibliography attribute view [%s] failed: %s", bibAvID, err)
+		} else {
+			ret.bibAttrView = attrView
+		}
+	}
+
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || "" == n.ID {
+			return ast.WalkContinue
+		}
+
+		switch n.Type {
+		case ast.NodeHeading, ast.NodeTable:
+			ret.labels[n.ID] = true
+		case ast.NodeParagraph:
+			if isLaTeXFigure(n) {
+				ret.labels[n.ID] = true
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+func (r *latexRenderer) render() string {
+	body := r.buf
+	r.renderChildren(r.tree.Root)
+
+	ret := &bytes.Buffer{}
+	ret.WriteString("% Exported from SiYuan, compile with XeLaTeX\n")
+	ret.WriteString("\\documentclass{article}\n")
+	ret.WriteString("\\usepackage{fontspec}\n")
+	if util.ContainsCJK(body.String()) || util.ContainsCJK(r.tree.Root.IALAttr("title")) {
+		ret.WriteString("\\usepackage{xeCJK}\n")
+	}
+	ret.WriteString("\\usepackage{amsmath}\n")
+	ret.WriteString("\\usepackage{amssymb}\n")
+	ret.WriteString("\\usepackage{graphicx}\n")
+	ret.WriteString("\\usepackage[normalem]{ulem}\n")
+	ret.WriteString("\\usepackage{soul}\n")
+	ret.WriteString("\\usepackage{hyperref}\n")
+	ret.WriteString("\n")
+
+	ial := parse.IAL2Map(r.tree.Root.KramdownIAL)
+	ret.WriteString("\\title{" + escapeLaTeX(ial["title"]) + "}\n")
+	if author := ial["custom-author"]; "" != author {
+		ret.WriteString("\\author{" + escapeLaTeX(author) + "}\n")
+	}
+	date := ""
+	if updated, parseErr := time.Parse("20060102150405", ial["updated"]); nil == parseErr {
+		date = updated.Format("2006-01-02")
+	}
+	ret.WriteString("\\date{" + date + "}\n")
+	ret.WriteString("\n\\begin{document}\n\\maketitle\n\n")
+	ret.Write(body.Bytes())
+	if 0 < len(r.citeIDs) {
+		ret.WriteString("\n\\bibliographystyle{plain}\n\\bibliography{references}\n")
+	}
+	ret.WriteString("\n\\end{document}\n")
+	return ret.String()
+}
+
+func (r *latexRenderer) renderChildren(node *ast.Node) {
+	for c := node.FirstChild; nil != c; c = c.Next {
+		r.renderNode(c)
+	}
+}
+
+func (r *latexRenderer) renderNode(n *ast.Node) {
+	switch n.Type {
+	case ast.NodeHeading:
+		cmds := []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph", "subparagraph"}
+		level := n.HeadingLevel
+		if 1 > level {
+			level = 1
+		} else if 6 < level {
+			level = 6
+		}
+		r.buf.WriteString("\\" + cmds[level-1] + "{")
+		r.renderInlines(n)
+		r.buf.WriteString("}")
+		r.writeLabel(n)
+		r.buf.WriteString("\n\n")
+	case ast.NodeParagraph:
+		if isLaTeXFigure(n) {
+			r.renderFigure(n)
+			return
+		}
+		r.renderInlines(n)
+		r.buf.WriteString("\n\n")
+	case ast.NodeBlockquote:
+		r.buf.WriteString("\\begin{quote}\n")
+		r.renderChildren(n)
+		r.buf.WriteString("\\end{quote}\n\n")
+	case ast.NodeSuperBlock:
+		r.renderChildren(n)
+	case ast.NodeList:
+		env := "itemize"
+		if nil != n.ListData && 1 == n.ListData.Typ {
+			env = "enumerate"
+		}
+		r.buf.WriteString("\\begin{" + env + "}\n")
+		r.renderChildren(n)
+		r.buf.WriteString("\\end{" + env + "}\n\n")
+	case ast.NodeListItem:
+		r.buf.WriteString("\\item")
+		if marker := n.FirstChild; nil != marker && ast.NodeParagraph == marker.Type && nil != marker.FirstChild && ast.NodeTaskListItemMarker == marker.FirstChild.Type {
+			if marker.FirstChild.TaskListItemChecked {
+				r.buf.WriteString("[$\\boxtimes$]")
+			} else {
+				r.buf.WriteString("[$\\square$]")
+			}
+		}
+		r.buf.WriteString(" ")
+		r.renderChildren(n)
+	case ast.NodeCodeBlock:
+		code := n.ChildByType(ast.NodeCodeBlockCode)
+		if nil == code {
+			return
+		}
+		r.buf.WriteString("\\begin{verbatim}\n")
+		r.buf.Write(bytes.TrimRight(code.Tokens, "\n"))
+		r.buf.WriteString("\n\\end{verbatim}\n\n")
+	case ast.NodeMathBlock:
+		content := n.ChildByType(ast.NodeMathBlockContent)
+		if nil == content {
+			return
+		}
+
+		// 公式块原样保留，如果公式内容已经是完整的数学环境则不再包裹
+		math := bytes.TrimSpace(content.Tokens)
+		if bytes.HasPrefix(math, []byte("\\begin{")) {
+			r.buf.Write(math)
+		} else {
+			r.buf.WriteString("\\[\n")
+			r.buf.Write(math)
+			r.buf.WriteString("\n\\]")
+		}
+		r.buf.WriteString("\n\n")
+	case ast.NodeTable:
+		r.renderTable(n)
+	case ast.NodeThematicBreak:
+		r.buf.WriteString("\\noindent\\rule{\\linewidth}{0.4pt}\n\n")
+	case ast.NodeFootnotesDefBlock, ast.NodeKramdownBlockIAL, ast.NodeHTMLBlock, ast.NodeIFrame, ast.NodeWidget,
+		ast.NodeAudio, ast.NodeVideo, ast.NodeBlockQueryEmbed, ast.NodeAttributeView, ast.NodeYamlFrontMatter:
+		// 无法在 LaTeX 中表示的块直接忽略
+	default:
+		if n.IsBlock() {
+			r.renderChildren(n)
+		} else {
+			r.renderInline(n)
+		}
+	}
+}
+
+func (r *latexRenderer) renderFigure(n *ast.Node) {
+	img := n.ChildByType(ast.NodeImage)
+	dest := r.imageDest(img)
+	r.buf.WriteString("\\begin{figure}[htbp]\n\\centering\n")
+	r.buf.WriteString("\\includegraphics[width=0.8\\linewidth]{" + dest + "}\n")
+	if linkText := img.ChildByType(ast.NodeLinkText); nil != linkText && "" != strings.TrimSpace(linkText.TokensStr()) && "image" != linkText.TokensStr() {
+		r.buf.WriteString("\\caption{" + escapeLaTeX(linkText.TokensStr()) + "}\n")
+	}
+	r.writeLabel(n)
+	r.buf.WriteString("\n\\end{figure}\n\n")
+}
+
+func (r *latexRenderer) renderTable(n *ast.Node) {
+	var aligns []string
+	for _, align := range n.TableAligns {
+		switch align {
+		case 2:
+			aligns = append(aligns, "c")
+		case 3:
+			aligns = append(aligns, "r")
+		default:
+			aligns = append(aligns, "l")
+		}
+	}
+
+	r.buf.WriteString("\\begin{table}[htbp]\n\\centering\n")
+	r.buf.WriteString("\\begin{tabular}{|" + strings.Join(aligns, "|") + "|}\n\\hline\n")
+	for row := n.FirstChild; nil != row; row = row.Next {
+		tableRow := row
+		if ast.NodeTableHead == row.Type {
+			tableRow = row.FirstChild
+		}
+		if nil == tableRow || ast.NodeTableRow != tableRow.Type {
+			continue
+		}
+
+		first := true
+		for cell := tableRow.FirstChild; nil != cell; cell = cell.Next {
+			if ast.NodeTableCell != cell.Type {
+				continue
+			}
+			if !first {
+				r.buf.WriteString(" & ")
+			}
+			first = false
+
+			if ast.NodeTableHead == row.Type {
+				r.buf.WriteString("\\textbf{")
+				r.renderInlines(cell)
+				r.buf.WriteString("}")
+			} else {
+				r.renderInlines(cell)
+			}
+		}
+		r.buf.WriteString(" \\\\\n\\hline\n")
+	}
+	r.buf.WriteString("\\end{tabular}\n")
+	r.writeLabel(n)
+	r.buf.WriteString("\n\\end{table}\n\n")
+}
+
+func (r *latexRenderer) renderInlines(node *ast.Node) {
+	for c := node.FirstChild; nil != c; c = c.Next {
+		r.renderInline(c)
+	}
+}
+
+func (r *latexRenderer) renderInline(n *ast.Node) {
+	switch n.Type {
+	case ast.NodeText:
+		r.buf.WriteString(escapeLaTeX(strings.ReplaceAll(string(n.Tokens), editor.Zwj, "")))
+	case ast.NodeHardBreak, ast.NodeBr:
+		r.buf.WriteString("\\\\\n")
+	case ast.NodeSoftBreak:
+		r.buf.WriteString("\n")
+	case ast.NodeInlineMath:
+		if content := n.ChildByType(ast.NodeInlineMathContent); nil != content {
+			r.buf.WriteString("$" + strings.TrimSpace(content.TokensStr()) + "$")
+		}
+	case ast.NodeImage:
+		r.buf.WriteString("\\includegraphics[width=0.8\\linewidth]{" + r.imageDest(n) + "}")
+	case ast.NodeLink:
+		dest := n.ChildByType(ast.NodeLinkDest)
+		text := n.ChildByType(ast.NodeLinkText)
+		if nil == dest {
+			return
+		}
+		r.buf.WriteString("\\href{" + escapeLaTeXURL(dest.TokensStr()) + "}{")
+		if nil != text {
+			r.buf.WriteString(escapeLaTeX(text.TokensStr()))
+		} else {
+			r.buf.WriteString(escapeLaTeX(dest.TokensStr()))
+		}
+		r.buf.WriteString("}")
+	case ast.NodeTextMark:
+		r.renderTextMark(n)
+	case ast.NodeTaskListItemMarker, ast.NodeKramdownSpanIAL, ast.NodeKramdownBlockIAL, ast.NodeFootnotesRef:
+	default:
+		if nil != n.FirstChild {
+			r.renderInlines(n)
+		} else if 0 < len(n.Tokens) {
+			r.buf.WriteString(escapeLaTeX(string(n.Tokens)))
+		}
+	}
+}
+
+func (r *latexRenderer) renderTextMark(n *ast.Node) {
+	if n.IsTextMarkType("inline-math") {
+		r.buf.WriteString("$" + strings.TrimSpace(n.TextMarkInlineMathContent) + "$")
+		return
+	}
+
+	var content string
+	if n.IsTextMarkType("code") || n.IsTextMarkType("kbd") {
+		content = "\\texttt{" + escapeLaTeX(html.UnescapeString(n.TextMarkTextContent)) + "}"
+	} else {
+		content = escapeLaTeX(html.UnescapeString(n.TextMarkTextContent))
+	}
+
+	if n.IsTextMarkType("a") {
+		href := n.TextMarkAHref
+		if strings.HasPrefix(href, "siyuan://blocks/") {
+			r.buf.WriteString(r.blockRef(strings.TrimPrefix(href, "siyuan://blocks/"), content))
+			return
+		}
+		content = "\\href{" + escapeLaTeXURL(href) + "}{" + content + "}"
+	}
+
+	cmds := map[string]string{
+		"strong": "textbf",
+		"em":     "emph",
+		"u":      "uline",
+		"s":      "sout",
+		"mark":   "hl",
+		"sup":    "textsuperscript",
+		"sub":    "textsubscript",
+	}
+	for _, typ := range strings.Split(n.TextMarkType, " ") {
+		if cmd := cmds[typ]; "" != cmd {
+			content = "\\" + cmd + "{" + content + "}"
+		}
+	}
+	r.buf.WriteString(content)
+
+	if n.IsTextMarkType("inline-memo") && "" != n.TextMarkInlineMemoContent {
+		r.buf.WriteString("\\footnote{" + escapeLaTeX(n.TextMarkInlineMemoContent) + "}")
+	}
+}
+
+func (r *latexRenderer) blockRef(defID, anchorText string) string {
+	if nil != r.bibAttrView && r.bibAttrView.ExistBlock(defID) {
+		key := r.citeKeys[defID]
+		if "" == key {
+			key = r.citeKey(defID)
+			r.citeKeys[defID] = key
+			r.citeIDs = append(r.citeIDs, defID)
+		}
+		return "\\cite{" + key + "}"
+	}
+
+	if r.labels[defID] {
+		return "\\ref{" + defID + "}"
+	}
+	return anchorText
+}
+
+func (r *latexRenderer) citeKey(blockID string) (ret string) {
+	for _, kv := range r.bibAttrView.KeyValues {
+		switch strings.ToLower(strings.TrimSpace(kv.Key.Name)) {
+		case "citekey", "citationkey", "key":
+			ret = strings.TrimSpace(kv.GetValue(blockID).String(false))
+		}
+	}
+	ret = strings.Join(strings.FieldsFunc(ret, func(r rune) bool {
+		return ' ' == r || ',' == r || '{' == r || '}' == r || '%' == r || '#' == r || '\\' == r
+	}), "")
+	if "" == ret {
+		ret = blockID
+	}
+	return
+}
+
+func (r *latexRenderer) renderBib() string {
+	if nil == r.bibAttrView || 1 > len(r.citeIDs) {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	for _, blockID := range r.citeIDs {
+		typ := "misc"
+		fields := map[string]string{}
+		for _, kv := range r.bibAttrView.KeyValues {
+			field := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(kv.Key.Name), " ", ""))
+			val := kv.GetValue(blockID)
+			if nil == val {
+				continue
+			}
+
+			content := strings.TrimSpace(val.String(true))
+			if av.KeyTypeDate == val.Type && nil != val.Date && ("year" == field || "date" == field) {
+				date := time.UnixMilli(val.Date.Content)
+				if "year" == field {
+					content = date.Format("2006")
+				} else {
+					content = date.Format("2006-01-02")
+				}
+			}
+			if "" == content {
+				continue
+			}
+
+			switch field {
+			case "citekey", "citationkey", "key":
+				continue
+			case "type", "entrytype":
+				typ = strings.ToLower(content)
+				continue
+			}
+
+			if av.KeyTypeBlock == val.Type {
+				if _, ok := fields["title"]; !ok {
+					fields["title"] = content
+				}
+				continue
+			}
+			fields[field] = content
+		}
+
+		buf.WriteString("@" + typ + "{" + r.citeKeys[blockID] + ",\n")
+		var names []string
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			buf.WriteString("  " + name + " = {" + escapeBibValue(name, fields[name]) + "},\n")
+		}
+		buf.WriteString("}\n\n")
+	}
+	return buf.String()
+}
+
+func (r *latexRenderer) writeLabel(n *ast.Node) {
+	if "" != n.ID && r.labels[n.ID] {
+		r.buf.WriteString("\\label{" + n.ID + "}")
+	}
+}
+
+func (r *latexRenderer) imageDest(img *ast.Node) (ret string) {
+	if nil == img {
+		return
+	}
+	dest := img.ChildByType(ast.NodeLinkDest)
+	if nil == dest {
+		return
+	}
+
+	ret = string(html.DecodeDestination(dest.Tokens))
+	if strings.Contains(ret, "?") {
+		ret = ret[:strings.LastIndex(ret, "?")]
+	}
+	if strings.HasPrefix(ret, "assets/") {
+		r.assets = append(r.assets, ret)
+		r.assets = gulu.Str.RemoveDuplicatedElem(r.assets)
+	}
+	return
+}
+
+// isLaTeXFigure 判断段落是否仅包含图片，这样的段落导出为 figure 环境。
+func isLaTeXFigure(n *ast.Node) bool {
+	if ast.NodeParagraph != n.Type {
+		return false
+	}
+
+	images := 0
+	for c := n.FirstChild; nil != c; c = c.Next {
+		switch c.Type {
+		case ast.NodeImage:
+			images++
+		case ast.NodeText:
+			if "" != strings.TrimSpace(strings.ReplaceAll(string(c.Tokens), editor.Zwj, "")) {
+				return false
+			}
+		case ast.NodeKramdownSpanIAL:
+		default:
+			return false
+		}
+	}
+	return 1 == images
+}
+
+var latexEscaper = strings.NewReplacer(
+	"\\", "\\textbackslash{}",
+	"{", "\\{",
+	"}", "\\}",
+	"$", "\\$",
+	"&", "\\&",
+	"#", "\\#",
+	"%", "\\%",
+	"_", "\\_",
+	"^", "\\textasciicircum{}",
+	"~", "\\textasciitilde{}",
+)
+
+func escapeLaTeX(text string) string {
+	return latexEscaper.Replace(text)
+}
+
+// escapeBibValue 转义 .bib 字段值，url 等字段由 \url 原样输出，只需要去掉会破坏字段边界的花括号。
+func escapeBibValue(field, value string) string {
+	switch field {
+	case "url", "doi", "eprint":
+		return strings.NewReplacer("{", "", "}", "").Replace(value)
+	}
+	return escapeLaTeX(value)
+}
+
+func escapeLaTeXURL(href string) string {
+	href = strings.ReplaceAll(href, "\\", "\\\\")
+	href = strings.ReplaceAll(href, "%", "\\%")
+	href = strings.ReplaceAll(href, "#", "\\#")
+	return href
+}
@@ -0,0 +1,71 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+func TestEscapeBibValue(t *testing.T) {
+	tests := []struct {
+		field string
+		value string
+		want  string
+	}{
+		{"title", "Rock & Roll", "Rock \\& Roll"},
+		{"title", "100% #1 snake_case", "100\\% \\#1 snake\\_case"},
+		{"title", "{Braced} $x$", "\\{Braced\\} \\$x\\$"},
+		{"note", "a^b~c\\d", "a\\textasciicircum{}b\\textasciitilde{}c\\textbackslash{}d"},
+		{"url", "https://example.com/a_b?x=1&y=2#top", "https://example.com/a_b?x=1&y=2#top"},
+		{"doi", "10.1000/{abc}_1", "10.1000/abc_1"},
+	}
+
+	for _, test := range tests {
+		if got := escapeBibValue(test.field, test.value); got != test.want {
+			t.Errorf("escapeBibValue(%q, %q) = %q, want %q", test.field, test.value, got, test.want)
+		}
+	}
+}
+
+func TestRenderBib(t *testing.T) {
+	textValue := func(blockID, content string) *av.Value {
+		return &av.Value{BlockID: blockID, Type: av.KeyTypeText, Text: &av.ValueText{Content: content}}
+	}
+
+	r := &latexRenderer{
+		bibAttrView: &av.AttributeView{
+			KeyValues: []*av.KeyValues{
+				{Key: &av.Key{Name: "Type", Type: av.KeyTypeText}, Values: []*av.Value{textValue("b1", "Article")}},
+				{Key: &av.Key{Name: "Title", Type: av.KeyTypeText}, Values: []*av.Value{textValue("b1", "Q&A: 50% of #tags_{x}")}},
+				{Key: &av.Key{Name: "URL", Type: av.KeyTypeText}, Values: []*av.Value{textValue("b1", "https://example.com/a_b#c")}},
+			},
+		},
+		citeKeys: map[string]string{"b1": "smith2020"},
+		citeIDs:  []string{"b1"},
+	}
+
+	got := r.renderBib()
+	want := "@article{smith2020,\n" +
+		"  title = {Q\\&A: 50\\% of \\#tags\\_\\{x\\}},\n" +
+		"  url = {https://example.com/a_b#c},\n" +
+		"}\n\n"
+	if got != want {
+		t.Errorf("renderBib() = %q, want %q", got, want)
+	}
+}
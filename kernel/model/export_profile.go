@@ -0,0 +1,250 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+var (
+	ErrExportProfileNotFound = errors.New("export profile not found")
+	ErrExportDocFiltered     = errors.New("document is excluded by the export profile")
+)
+
+// ResolveExportConf 返回一次导出使用的导出配置，指定导出配置方案时使用配置方案覆盖导出配置。
+//
+// 返回的导出配置是全局导出配置的副本，导出过程中修改全局导出配置不会影响本次导出。
+func ResolveExportConf(profileName string) (ret *conf.Export, err error) {
+	Conf.m.Lock()
+	defer Conf.m.Unlock()
+
+	var profile *conf.ExportProfile
+	if profileName = strings.TrimSpace(profileName); "" != profileName {
+		if profile = Conf.Export.GetProfile(profileName); nil == profile {
+			err = ErrExportProfileNotFound
+			return
+		}
+	}
+
+	if ret, err = overrideExportConf(Conf.Export, profile); err != nil {
+		return
+	}
+
+	if nil != profile {
+		// 配置方案在导出过程中可能被修改，这里使用副本
+		var data []byte
+		if data, err = gulu.JSON.MarshalJSON(profile); err != nil {
+			return
+		}
+		ret.Profile = &conf.ExportProfile{}
+		if err = gulu.JSON.UnmarshalJSON(data, ret.Profile); err != nil {
+			return
+		}
+	}
+	if nil == ret.Filter {
+		ret.Filter = conf.NewExportFilter()
+	}
+	return
+}
+
+func SetExportProfile(profile *conf.ExportProfile) (err error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if "" == profile.Name {
+		return errors.New("export profile name is empty")
+	}
+
+	Conf.m.Lock()
+	// 校验覆盖的导出配置项
+	if _, err = overrideExportConf(Conf.Export, profile); err != nil {
+		Conf.m.Unlock()
+		return
+	}
+
+	// 替换配置方案列表而不是修改已有的配置方案，避免影响正在进行的导出
+	var profiles []*conf.ExportProfile
+	replaced := false
+	for _, existing := range Conf.Export.Profiles {
+		if existing.Name == profile.Name {
+			profiles = append(profiles, profile)
+			replaced = true
+			continue
+		}
+		profiles = append(profiles, existing)
+	}
+	if !replaced {
+		profiles = append(profiles, profile)
+	}
+	Conf.Export.Profiles = profiles
+	Conf.m.Unlock()
+	Conf.Save()
+	return
+}
+
+func RemoveExportProfile(name string) {
+	Conf.m.Lock()
+	var profiles []*conf.ExportProfile
+	for _, profile := range Conf.Export.Profiles {
+		if profile.Name != name {
+			profiles = append(profiles, profile)
+		}
+	}
+	Conf.Export.Profiles = profiles
+	Conf.m.Unlock()
+	Conf.Save()
+}
+
+func overrideExportConf(base *conf.Export, profile *conf.ExportProfile) (ret *conf.Export, err error) {
+	data, err := gulu.JSON.MarshalJSON(base)
+	if err != nil {
+		return
+	}
+
+	m := map[string]interface{}{}
+	if err = gulu.JSON.UnmarshalJSON(data, &m); err != nil {
+		return
+	}
+	if nil == profile {
+		profile = &conf.ExportProfile{}
+	}
+	for k, v := range profile.Overrides {
+		if "profiles" == k || "pandocBin" == k {
+			// 配置方案不允许覆盖配置方案列表和 Pandoc 可执行文件路径
+			continue
+		}
+		m[k] = v
+	}
+
+	if data, err = gulu.JSON.MarshalJSON(m); err != nil {
+		return
+	}
+	ret = &conf.Export{}
+	err = gulu.JSON.UnmarshalJSON(data, ret)
+	return
+}
+
+// CheckExportDocFiltered 导出单个文档前检查文档是否被导出配置方案过滤掉，被过滤时返回 ErrExportDocFiltered。
+func CheckExportDocFiltered(exportConf *conf.Export, id string) error {
+	if nil == exportConf || nil == exportConf.Profile {
+		return nil
+	}
+
+	tree, err := LoadTreeByBlockID(id)
+	if err != nil {
+		// 文档不存在等错误由导出过程处理
+		return nil
+	}
+	if isExportDocFiltered(exportConf, tree) {
+		return ErrExportDocFiltered
+	}
+	return nil
+}
+
+// isExportDocFiltered 判断文档是否被导出配置方案过滤掉。
+func isExportDocFiltered(exportConf *conf.Export, tree *parse.Tree) bool {
+	if nil == exportConf || nil == exportConf.Profile || nil == tree {
+		return false
+	}
+
+	profile := exportConf.Profile
+	root := tree.Root
+	if 0 < len(profile.IncludeTags) || 0 < len(profile.IncludeAttrs) {
+		if !matchExportTags(root, profile.IncludeTags) && !matchExportAttrs(root, profile.IncludeAttrs) {
+			return true
+		}
+	}
+	return matchExportTags(root, profile.ExcludeTags) || matchExportAttrs(root, profile.ExcludeAttrs)
+}
+
+// unlinkExportNodes 移除块，并移除因此变为空的容器块。
+func unlinkExportNodes(tree *parse.Tree, unlinks []*ast.Node) {
+	for _, n := range unlinks {
+		parent := n.Parent
+		n.Unlink()
+		for nil != parent && ast.NodeDocument != parent.Type && !hasBlockChild(parent) {
+			grandparent := parent.Parent
+			parent.Unlink()
+			parent = grandparent
+		}
+	}
+
+	if nil == tree.Root.FirstChild {
+		tree.Root.AppendChild(treenode.NewParagraph(""))
+	}
+}
+
+func hasBlockChild(n *ast.Node) bool {
+	for c := n.FirstChild; nil != c; c = c.Next {
+		if "" != c.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func matchExportAttrs(n *ast.Node, attrs map[string]string) bool {
+	for name, val := range attrs {
+		attrVal := n.IALAttr(name)
+		if "" == attrVal {
+			continue
+		}
+		if "" == val || val == attrVal {
+			return true
+		}
+	}
+	return false
+}
+
+func matchExportTags(n *ast.Node, tags []string) bool {
+	if 1 > len(tags) {
+		return false
+	}
+
+	var nodeTags []string
+	if ast.NodeDocument == n.Type {
+		for _, tag := range strings.Split(n.IALAttr("tags"), ",") {
+			nodeTags = append(nodeTags, strings.TrimSpace(tag))
+		}
+	} else if n.IsContainerBlock() {
+		// 容器块不检查行级标签，由其中的叶子块检查
+		return false
+	} else {
+		ast.Walk(n, func(c *ast.Node, entering bool) ast.WalkStatus {
+			if entering && c.IsTextMarkType("tag") {
+				nodeTags = append(nodeTags, strings.TrimSpace(c.TextMarkTextContent))
+			}
+			return ast.WalkContinue
+		})
+	}
+
+	for _, nodeTag := range nodeTags {
+		for _, tag := range tags {
+			tag = strings.TrimSpace(strings.Trim(tag, "#"))
+			// 匹配标签本身及其子标签
+			if "" != tag && (nodeTag == tag || strings.HasPrefix(nodeTag, tag+"/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestResolveExportConf(t *testing.T) {
+	oldConf := Conf
+	export := conf.NewExport()
+	export.PandocBin = "/usr/bin/pandoc"
+	export.Profiles = []*conf.ExportProfile{{
+		Name:        "blog",
+		Overrides:   map[string]interface{}{"blockRefMode": 3, "pandocBin": "/tmp/evil", "profiles": []interface{}{}},
+		ExcludeTags: []string{"draft"},
+	}}
+	Conf = &AppConf{Export: export}
+	t.Cleanup(func() { Conf = oldConf })
+
+	ret, err := ResolveExportConf("blog")
+	if err != nil {
+		t.Fatalf("resolve export conf failed: %s", err)
+	}
+	if 3 != ret.BlockRefMode {
+		t.Errorf("blockRefMode = %d, want 3", ret.BlockRefMode)
+	}
+	if "/usr/bin/pandoc" != ret.PandocBin {
+		t.Errorf("pandocBin = %q, profile must not override it", ret.PandocBin)
+	}
+	if 1 != len(ret.Profiles) || nil == ret.Profile || "blog" != ret.Profile.Name {
+		t.Fatalf("unexpected profiles in resolved conf: %+v, %+v", ret.Profiles, ret.Profile)
+	}
+	export.Profiles[0].ExcludeTags[0] = "changed"
+	if "draft" != ret.Profile.ExcludeTags[0] {
+		t.Errorf("resolved profile shares state with the saved profile")
+	}
+
+	if _, err = ResolveExportConf("missing"); !errors.Is(err, ErrExportProfileNotFound) {
+		t.Errorf("resolve missing profile err = %v, want %v", err, ErrExportProfileNotFound)
+	}
+}
+
+func TestSetExportProfileInvalid(t *testing.T) {
+	oldConf := Conf
+	Conf = &AppConf{Export: conf.NewExport()}
+	t.Cleanup(func() { Conf = oldConf })
+
+	tests := []*conf.ExportProfile{
+		{Name: "  "},
+		{Name: "bad", Overrides: map[string]interface{}{"blockRefMode": "not a number"}},
+	}
+	for _, profile := range tests {
+		if err := SetExportProfile(profile); nil == err {
+			t.Errorf("set export profile %+v should fail", profile)
+		}
+	}
+	if 0 < len(Conf.Export.Profiles) {
+		t.Errorf("invalid profiles were saved: %+v", Conf.Export.Profiles)
+	}
+}
+
+func TestIsExportDocFiltered(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    string
+		attrs   map[string]string
+		profile *conf.ExportProfile
+		want    bool
+	}{
+		{"no profile", "draft", nil, nil, false},
+		{"exclude tag", "a, draft", nil, &conf.ExportProfile{ExcludeTags: []string{"#draft#"}}, true},
+		{"exclude sub tag", "draft/wip", nil, &conf.ExportProfile{ExcludeTags: []string{"draft"}}, true},
+		{"exclude tag prefix only", "drafts", nil, &conf.ExportProfile{ExcludeTags: []string{"draft"}}, false},
+		{"exclude attr", "", map[string]string{"custom-private": "true"}, &conf.ExportProfile{ExcludeAttrs: map[string]string{"custom-private": ""}}, true},
+		{"exclude attr value mismatch", "", map[string]string{"custom-private": "false"}, &conf.ExportProfile{ExcludeAttrs: map[string]string{"custom-private": "true"}}, false},
+		{"include tag missing", "a", nil, &conf.ExportProfile{IncludeTags: []string{"public"}}, true},
+		{"include tag", "public", nil, &conf.ExportProfile{IncludeTags: []string{"public"}}, false},
+		{"include attr", "", map[string]string{"custom-audience": "team"}, &conf.ExportProfile{IncludeAttrs: map[string]string{"custom-audience": "team"}}, false},
+		{"include and exclude", "public, draft", nil, &conf.ExportProfile{IncludeTags: []string{"public"}, ExcludeTags: []string{"draft"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree := newExportTestTree("20240101000000-doc0000", test.tags, test.attrs)
+			exportConf := &conf.Export{Profile: test.profile}
+			if got := isExportDocFiltered(exportConf, tree); got != test.want {
+				t.Errorf("isExportDocFiltered() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckExportDocFiltered(t *testing.T) {
+	public := newExportTestTree("20240101000000-public0", "public", nil)
+	draft := newExportTestTree("20240101000000-draft00", "draft", nil)
+	setupExportTrees(t, public, draft)
+
+	exportConf := &conf.Export{Profile: &conf.ExportProfile{Name: "blog", ExcludeTags: []string{"draft"}}}
+	if err := CheckExportDocFiltered(exportConf, public.ID); nil != err {
+		t.Errorf("public doc should be exported, got %v", err)
+	}
+	if err := CheckExportDocFiltered(exportConf, draft.ID); !errors.Is(err, ErrExportDocFiltered) {
+		t.Errorf("draft doc err = %v, want %v", err, ErrExportDocFiltered)
+	}
+	if err := CheckExportDocFiltered(&conf.Export{}, draft.ID); nil != err {
+		t.Errorf("draft doc without profile should be exported, got %v", err)
+	}
+}
+
+const exportTestBox = "20240101000000-box0000"
+
+func newExportTestTree(id, tags string, attrs map[string]string) *parse.Tree {
+	tree := treenode.NewTree(exportTestBox, "/"+id+".sy", "/"+id, id)
+	if "" != tags {
+		tree.Root.SetIALAttr("tags", tags)
+	}
+	for k, v := range attrs {
+		tree.Root.SetIALAttr(k, v)
+	}
+	return tree
+}
+
+// setupExportTrees 将树写入临时数据目录并建立块树索引。
+func setupExportTrees(t *testing.T, trees ...*parse.Tree) {
+	dataDir, blockTreeDBPath := util.DataDir, util.BlockTreeDBPath
+	util.DataDir = t.TempDir()
+	util.BlockTreeDBPath = filepath.Join(t.TempDir(), "blocktree.db")
+	treenode.InitBlockTree(true)
+	for _, tree := range trees {
+		if _, err := filesys.WriteTree(tree); err != nil {
+			t.Fatalf("write tree [%s] failed: %s", tree.ID, err)
+		}
+		treenode.IndexBlockTree(tree)
+	}
+	t.Cleanup(func() {
+		treenode.CloseDatabase()
+		util.DataDir, util.BlockTreeDBPath = dataDir, blockTreeDBPath
+	})
+}
@@ -1309,10 +1309,18 @@ func MoveDocs(fromPaths []string, toBoxID, toPath string, callback interface{})
 			util.PushEndlessProgress(fmt.Sprintf(Conf.Language(70), fmt.Sprintf("%d/%d", count, len(fromPaths))))
 		}
 
-		_, err = moveDoc(fromBox, fromPath, toBox, toPath, luteEngine, callback)
+		var newPath string
+		newPath, err = moveDoc(fromBox, fromPath, toBox, toPath, luteEngine, callback)
 		if err != nil {
 			return
 		}
+		fireWebhookEvent(WebhookEventDocMoved, map[string]interface{}{
+			"id":       util.GetTreeID(fromPath),
+			"fromBox":  fromBox.ID,
+			"fromPath": fromPath,
+			"box":      toBoxID,
+			"path":     newPath,
+		})
 	}
 	cache.ClearDocsIAL()
 	IncSync()
@@ -1589,6 +1597,13 @@ func removeDoc(box *Box, p string, luteEngine *lute.Lute) {
 		"ids": removeIDs,
 	}
 	util.PushEvent(evt)
+	fireWebhookEvent(WebhookEventDocRemoved, map[string]interface{}{
+		"box":   box.ID,
+		"id":    tree.ID,
+		"path":  p,
+		"hPath": tree.HPath,
+		"ids":   removeIDs,
+	})
 
 	refreshParentDocInfo(tree)
 	task.AppendTask(task.DatabaseIndex, removeDoc0, tree, childrenDir)
@@ -1654,6 +1669,14 @@ func RenameDoc(boxID, p, title string) (err error) {
 		"refText": refText,
 	}
 	util.PushEvent(evt)
+	fireWebhookEvent(WebhookEventDocRenamed, map[string]interface{}{
+		"box":      boxID,
+		"id":       tree.Root.ID,
+		"path":     p,
+		"hPath":    tree.HPath,
+		"title":    title,
+		"oldTitle": oldTitle,
+	})
 
 	box.renameSubTrees(tree)
 	updateRefTextRenameDoc(tree)
@@ -1772,6 +1795,13 @@ func createDoc(boxID, p, title, dom string) (tree *parse.Tree, err error) {
 	transaction := &Transaction{DoOperations: []*Operation{{Action: "create", Data: tree}}}
 	PerformTransactions(&[]*Transaction{transaction})
 	FlushTxQueue()
+	fireWebhookEvent(WebhookEventDocCreated, map[string]interface{}{
+		"box":   boxID,
+		"id":    id,
+		"path":  p,
+		"hPath": hPath,
+		"title": title,
+	})
 	return
 }
 
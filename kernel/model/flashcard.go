@@ -498,6 +498,12 @@ func ReviewFlashcard(deckID, cardID string, rating riff.Rating, reviewedCardIDs
 		logging.LogErrorf("save review log [%s] failed: %s", deckID, err)
 		return
 	}
+	fireWebhookEvent(WebhookEventFlashcardReviewed, map[string]interface{}{
+		"deckID":  deckID,
+		"cardID":  cardID,
+		"blockID": card.BlockID(),
+		"rating":  rating,
+	})
 
 	_, unreviewedCount, _, _ := getDueFlashcards(deckID, reviewedCardIDs)
 	if 1 > unreviewedCount {
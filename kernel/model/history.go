@@ -764,6 +764,7 @@ const (
 	HistoryOpSync    = "sync"
 	HistoryOpReplace = "replace"
 	HistoryOpOutline = "outline"
+	HistoryOpRestore = "restore"
 )
 
 func generateOpTypeHistory(tree *parse.Tree, opType string) {
@@ -832,7 +833,7 @@ func fullReindexHistory() {
 	return
 }
 
-var validOps = []string{HistoryOpClean, HistoryOpUpdate, HistoryOpDelete, HistoryOpFormat, HistoryOpSync, HistoryOpReplace, HistoryOpOutline}
+var validOps = []string{HistoryOpClean, HistoryOpUpdate, HistoryOpDelete, HistoryOpFormat, HistoryOpSync, HistoryOpReplace, HistoryOpOutline, HistoryOpRestore}
 
 const (
 	HistoryTypeDocName = 0 // Search docs by doc name
@@ -0,0 +1,55 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// apiTokenUserPrefix 通过具名 API 令牌调用时用户标识的前缀，避免和登录用户重名。
+const apiTokenUserPrefix = "token:"
+
+// GetGinContextUser 获取当前请求的用户标识。
+//
+// 通过单点登录、发布服务账号或者具名 API 令牌认证时返回对应的用户，通过访问授权码认证的会话共用同一身份，返回空字符串。
+func GetGinContextUser(c *gin.Context) string {
+	if claims, exists := c.Get(ClaimsContextKey); exists {
+		return GetClaimsUser(claims.(jwt.MapClaims))
+	}
+	if tokenID, exists := c.Get(APITokenContextKey); exists {
+		for _, t := range GetAPITokens() {
+			if t.ID == tokenID.(string) {
+				return apiTokenUserPrefix + t.Name
+			}
+		}
+	}
+	return ""
+}
+
+// GetClaimsUser 获取 JWT 中的用户标识，单点登录会话优先使用用户名和邮箱。
+func GetClaimsUser(claims jwt.MapClaims) string {
+	if oidcSessionSub == claims["sub"] {
+		for _, k := range []string{"preferred_username", "email", "name"} {
+			if v, ok := claims[k].(string); ok && "" != v {
+				return v
+			}
+		}
+	}
+	ret, _ := claims["jti"].(string)
+	return ret
+}
@@ -111,6 +111,8 @@ func ImportSY(zipPath, boxID, toPath string) (err error) {
 	lockSync()
 	defer unlockSync()
 
+	snapshotBeforeRiskyOp("import .sy.zip")
+
 	baseName := filepath.Base(zipPath)
 	ext := filepath.Ext(baseName)
 	baseName = strings.TrimSuffix(baseName, ext)
@@ -700,6 +702,8 @@ func ImportData(zipPath string) (err error) {
 	lockSync()
 	defer unlockSync()
 
+	snapshotBeforeRiskyOp("import data")
+
 	logging.LogInfof("import data from [%s]", zipPath)
 	baseName := filepath.Base(zipPath)
 	ext := filepath.Ext(baseName)
@@ -776,6 +780,8 @@ func ImportFromLocalPath(boxID, localPath string, toPath string) (err error) {
 	lockSync()
 	defer unlockSync()
 
+	snapshotBeforeRiskyOp("import from local path")
+
 	FlushTxQueue()
 
 	var baseHPath, baseTargetPath, boxLocalPath string
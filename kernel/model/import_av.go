@@ -0,0 +1,490 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/xuri/excelize/v2"
+)
+
+// 导入时使用的行 ID 列名，和导出数据库时的 id 列对应
+const avImportRowIDColumn = "id"
+
+// ImportAttributeViewCSV 导入 CSV 或 XLSX 文件中的行到数据库。
+//
+// mapping 为列名到字段 ID 的映射，未映射的列按列名匹配已有字段，匹配不到时推断字段类型并新建字段。
+// upsertKeyID 不为空时按照该字段的值匹配已有的行并更新，匹配不到时新建行；为 id 时按照行 ID 匹配。
+func ImportAttributeViewCSV(avID, filePath string, mapping map[string]string, upsertKeyID string) (inserted, updated int, err error) {
+	records, err := readAttributeViewRecords(filePath)
+	if err != nil {
+		return
+	}
+	if 2 > len(records) {
+		return
+	}
+
+	header, rows := records[0], records[1:]
+	attrView, err := av.ParseAttributeView(avID)
+	if err != nil {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	// 列名匹配字段，匹配不到的列新建字段
+	colKeys := make([]string, len(header))
+	rowIDCol := -1
+	blockKey := attrView.GetBlockKey()
+	blockKeyMapped := false
+	for i, colName := range header {
+		colName = strings.TrimSpace(colName)
+		header[i] = colName
+		if keyID := mapping[colName]; "" != keyID {
+			colKeys[i] = keyID
+		} else if avImportRowIDColumn == colName {
+			rowIDCol = i
+			continue
+		} else if "blockID" == colName || "group" == colName {
+			// 导出数据库时附加的列
+			continue
+		} else {
+			for _, kv := range attrView.KeyValues {
+				if strings.EqualFold(kv.Key.Name, colName) {
+					colKeys[i] = kv.Key.ID
+					break
+				}
+			}
+		}
+		if nil != blockKey && colKeys[i] == blockKey.ID {
+			blockKeyMapped = true
+		}
+	}
+
+	previousKeyID := ""
+	if 0 < len(attrView.KeyValues) {
+		previousKeyID = attrView.KeyValues[len(attrView.KeyValues)-1].Key.ID
+	}
+	if !blockKeyMapped && nil != blockKey {
+		// 没有列对应主键时使用第一个未匹配的列作为主键
+		for i, colName := range header {
+			if "" == colKeys[i] && i != rowIDCol && "blockID" != colName && "group" != colName && "" != colName {
+				colKeys[i] = blockKey.ID
+				blockKeyMapped = true
+				break
+			}
+		}
+	}
+
+	// 新建字段前检查更新时匹配行的字段是否对应了某一列
+	if "" != upsertKeyID {
+		upsertKeyMapped := avImportRowIDColumn == upsertKeyID && -1 < rowIDCol
+		for _, keyID := range colKeys {
+			if keyID == upsertKeyID {
+				upsertKeyMapped = true
+				break
+			}
+		}
+		if !upsertKeyMapped {
+			err = fmt.Errorf("upsert key [%s] is not mapped to any column", upsertKeyID)
+			return
+		}
+	}
+
+	addedKey := false
+	for i, colName := range header {
+		if "" != colKeys[i] || i == rowIDCol || "blockID" == colName || "group" == colName || "" == colName {
+			continue
+		}
+
+		var colVals []string
+		for _, row := range rows {
+			if i < len(row) {
+				colVals = append(colVals, row[i])
+			}
+		}
+		keyID := ast.NewNodeID()
+		if err = AddAttributeViewKey(avID, keyID, colName, string(inferAttributeViewKeyType(colVals)), "", previousKeyID); err != nil {
+			logging.LogErrorf("add attribute view [%s] key [%s] failed: %s", avID, colName, err)
+			return
+		}
+		colKeys[i] = keyID
+		previousKeyID = keyID
+		addedKey = true
+	}
+
+	if addedKey {
+		if attrView, err = av.ParseAttributeView(avID); err != nil {
+			logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+			return
+		}
+	}
+
+	upsertIndex, err := buildAttributeViewUpsertIndex(attrView, upsertKeyID)
+	if err != nil {
+		return
+	}
+
+	now := util.CurrentTimeMillis()
+	var insertValues [][]*av.Value
+	for _, row := range rows {
+		var values []*av.Value
+		itemID := ""
+		for i, cell := range row {
+			if i >= len(colKeys) {
+				break
+			}
+
+			if i == rowIDCol && avImportRowIDColumn == upsertKeyID {
+				itemID = upsertIndex[strings.TrimSpace(cell)]
+				continue
+			}
+			if "" == colKeys[i] {
+				continue
+			}
+
+			key, _ := attrView.GetKey(colKeys[i])
+			if nil == key {
+				continue
+			}
+			value := parseAttributeViewValue(key, cell)
+			if nil == value {
+				continue
+			}
+			values = append(values, value)
+			if "" != upsertKeyID && upsertKeyID == key.ID {
+				itemID = upsertIndex[avImportUpsertValue(value)]
+			}
+		}
+		if 1 > len(values) {
+			continue
+		}
+
+		if "" == itemID {
+			if !blockKeyMapped && nil != blockKey {
+				// 新建的行需要主键值
+				values = append(values, &av.Value{KeyID: blockKey.ID, Type: av.KeyTypeBlock, Block: &av.ValueBlock{}})
+			}
+			insertValues = append(insertValues, values)
+			continue
+		}
+
+		for _, value := range values {
+			setAttributeViewImportValue(attrView, itemID, value, now)
+		}
+		updated++
+	}
+
+	if 0 < updated {
+		if err = av.SaveAttributeView(attrView); err != nil {
+			logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+			return
+		}
+	}
+
+	if 0 < len(insertValues) {
+		if err = AppendAttributeViewDetachedBlocksWithValues(avID, insertValues); err != nil {
+			return
+		}
+		inserted = len(insertValues)
+	} else {
+		ReloadAttrView(avID)
+	}
+	return
+}
+
+// buildAttributeViewUpsertIndex 构建更新时匹配行的索引，键为字段值，值为行 ID。
+func buildAttributeViewUpsertIndex(attrView *av.AttributeView, upsertKeyID string) (ret map[string]string, err error) {
+	ret = map[string]string{}
+	if "" == upsertKeyID {
+		return
+	}
+
+	if avImportRowIDColumn == upsertKeyID {
+		if blockKeyValues := attrView.GetBlockKeyValues(); nil != blockKeyValues {
+			for _, v := range blockKeyValues.Values {
+				ret[v.BlockID] = v.BlockID
+			}
+		}
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(upsertKeyID)
+	if err != nil {
+		return
+	}
+	for _, v := range keyValues.Values {
+		if val := avImportUpsertValue(v); "" != val {
+			ret[val] = v.BlockID
+		}
+	}
+	return
+}
+
+// avImportUpsertValue 返回匹配行时使用的字段值，已有的值和导入的单元格都需要先解析为字段值再比较。
+func avImportUpsertValue(value *av.Value) string {
+	if av.KeyTypeNumber == value.Type && (nil == value.Number || !value.Number.IsNotEmpty) {
+		// 空数字的文本是 0.000000，不能用于匹配
+		return ""
+	}
+	return strings.TrimSpace(value.String(false))
+}
+
+func setAttributeViewImportValue(attrView *av.AttributeView, itemID string, value *av.Value, now int64) {
+	keyValues, _ := attrView.GetKeyValues(value.KeyID)
+	if nil == keyValues {
+		return
+	}
+
+	var blockValue *av.Value
+	if blockKey := attrView.GetBlockKey(); nil != blockKey {
+		blockValue = attrView.GetValue(blockKey.ID, itemID)
+	}
+	isDetached := nil == blockValue || blockValue.IsDetached
+	if av.KeyTypeBlock == value.Type && !isDetached {
+		// 绑定块的主键内容是块内容，导入时不更新
+		return
+	}
+
+	value.BlockID = itemID
+	value.IsDetached = isDetached
+	if av.KeyTypeBlock == value.Type {
+		value.Block.ID = itemID
+		if nil != blockValue && nil != blockValue.Block {
+			value.Block.Created = blockValue.Block.Created
+		}
+		value.Block.Updated = now
+	}
+	saveAttributeViewSelectOptions(attrView, value)
+
+	for i, v := range keyValues.Values {
+		if v.BlockID == itemID {
+			value.ID = v.ID
+			value.CreatedAt = v.CreatedAt
+			value.SetUpdatedAt(now)
+			keyValues.Values[i] = value
+			return
+		}
+	}
+
+	value.ID = ast.NewNodeID()
+	value.CreatedAt = now
+	value.UpdatedAt = now
+	keyValues.Values = append(keyValues.Values, value)
+}
+
+func readAttributeViewRecords(filePath string) (ret [][]string, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv":
+		data, readErr := filelock.ReadFile(filePath)
+		if nil != readErr {
+			err = readErr
+			return
+		}
+		data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		ret, err = reader.ReadAll()
+	case ".xlsx":
+		f, openErr := excelize.OpenFile(filePath)
+		if nil != openErr {
+			err = openErr
+			return
+		}
+		defer f.Close()
+		ret, err = f.GetRows(f.GetSheetName(0))
+	default:
+		err = errors.New("unsupported import file type [" + filepath.Ext(filePath) + "]")
+	}
+	return
+}
+
+var avImportDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/1/2",
+	"2006/01/02",
+	"2006.01.02",
+}
+
+func parseAttributeViewDate(s string) (ret time.Time, isNotTime, ok bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range avImportDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); nil == err {
+			return t, !strings.Contains(layout, "15"), true
+		}
+	}
+	return
+}
+
+func parseAttributeViewCheckbox(s string) (checked, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "yes", "y", "√", "✓", "✔", "x", "是":
+		return true, true
+	case "false", "no", "n", "否":
+		return false, true
+	}
+	return
+}
+
+func parseAttributeViewNumber(s string) (ret float64, ok bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", "")
+	ret, err := strconv.ParseFloat(s, 64)
+	return ret, nil == err
+}
+
+func isAttributeViewURL(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	return nil == err && ("http" == u.Scheme || "https" == u.Scheme) && "" != u.Host
+}
+
+func isAttributeViewEmail(s string) bool {
+	s = strings.TrimSpace(s)
+	addr, err := mail.ParseAddress(s)
+	return nil == err && addr.Address == s
+}
+
+// inferAttributeViewKeyType 根据列中的值推断字段类型。
+func inferAttributeViewKeyType(vals []string) av.KeyType {
+	var nonEmpty []string
+	for _, val := range vals {
+		if val = strings.TrimSpace(val); "" != val {
+			nonEmpty = append(nonEmpty, val)
+		}
+	}
+	if 1 > len(nonEmpty) {
+		return av.KeyTypeText
+	}
+
+	all := func(check func(string) bool) bool {
+		for _, val := range nonEmpty {
+			if !check(val) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if all(func(s string) bool { _, ok := parseAttributeViewNumber(s); return ok }) {
+		return av.KeyTypeNumber
+	}
+	if all(func(s string) bool { _, _, ok := parseAttributeViewDate(strings.Split(s, "→")[0]); return ok }) {
+		return av.KeyTypeDate
+	}
+	if all(func(s string) bool { _, ok := parseAttributeViewCheckbox(s); return ok }) {
+		return av.KeyTypeCheckbox
+	}
+	if all(isAttributeViewURL) {
+		return av.KeyTypeURL
+	}
+	if all(isAttributeViewEmail) {
+		return av.KeyTypeEmail
+	}
+
+	// 取值重复较多并且较短时作为单选
+	distinct := map[string]bool{}
+	for _, val := range nonEmpty {
+		if 32 < len([]rune(val)) {
+			return av.KeyTypeText
+		}
+		distinct[val] = true
+	}
+	if 1 < len(nonEmpty) && len(distinct) <= 16 && len(distinct)*2 <= len(nonEmpty) {
+		return av.KeyTypeSelect
+	}
+	return av.KeyTypeText
+}
+
+// parseAttributeViewValue 按照字段类型解析单元格文本，无法解析时返回 nil。
+func parseAttributeViewValue(key *av.Key, cell string) (ret *av.Value) {
+	cell = strings.TrimSpace(cell)
+	ret = &av.Value{KeyID: key.ID, Type: key.Type}
+	switch key.Type {
+	case av.KeyTypeBlock:
+		ret.Block = &av.ValueBlock{Content: cell}
+	case av.KeyTypeText:
+		ret.Text = &av.ValueText{Content: cell}
+	case av.KeyTypeNumber:
+		ret.Number = &av.ValueNumber{Format: key.NumberFormat}
+		if num, ok := parseAttributeViewNumber(cell); ok {
+			ret.Number.Content = num
+			ret.Number.IsNotEmpty = true
+			ret.Number.FormatNumber()
+		}
+	case av.KeyTypeDate:
+		ret.Date = &av.ValueDate{}
+		parts := strings.Split(cell, "→")
+		if t, isNotTime, ok := parseAttributeViewDate(parts[0]); ok {
+			content2 := int64(0)
+			hasEndDate := false
+			if 1 < len(parts) {
+				if t2, _, ok2 := parseAttributeViewDate(parts[1]); ok2 {
+					content2 = t2.UnixMilli()
+					hasEndDate = true
+				}
+			}
+			ret.Date = av.NewFormattedValueDate(t.UnixMilli(), content2, av.DateFormatNone, isNotTime, hasEndDate)
+		}
+	case av.KeyTypeSelect, av.KeyTypeMSelect:
+		ret.MSelect = []*av.ValueSelect{}
+		opts := []string{cell}
+		if av.KeyTypeMSelect == key.Type {
+			opts = strings.FieldsFunc(cell, func(r rune) bool { return ',' == r || '，' == r || ';' == r })
+		}
+		for _, opt := range opts {
+			if opt = strings.TrimSpace(opt); "" != opt {
+				color := fmt.Sprintf("%d", 1+rand.Intn(14))
+				if existing := key.GetOption(opt); nil != existing {
+					color = existing.Color
+				}
+				ret.MSelect = append(ret.MSelect, &av.ValueSelect{Content: opt, Color: color})
+			}
+		}
+	case av.KeyTypeURL:
+		ret.URL = &av.ValueURL{Content: cell}
+	case av.KeyTypeEmail:
+		ret.Email = &av.ValueEmail{Content: cell}
+	case av.KeyTypePhone:
+		ret.Phone = &av.ValuePhone{Content: cell}
+	case av.KeyTypeCheckbox:
+		checked, _ := parseAttributeViewCheckbox(cell)
+		ret.Checkbox = &av.ValueCheckbox{Checked: checked}
+	default:
+		// 模板、创建时间、更新时间、关联、汇总和序号等字段由数据库计算，不导入
+		return nil
+	}
+	return
+}
@@ -0,0 +1,122 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func newImportAvTestAttrView() *av.AttributeView {
+	blockKey := &av.Key{ID: "20240101000000-keyname", Name: "Name", Type: av.KeyTypeBlock}
+	scoreKey := &av.Key{ID: "20240101000000-keyscor", Name: "Score", Type: av.KeyTypeNumber}
+	tagsKey := &av.Key{ID: "20240101000000-keytags", Name: "Tags", Type: av.KeyTypeMSelect}
+	return &av.AttributeView{
+		ID:   "20240101000000-avimprt",
+		Name: "import",
+		KeyValues: []*av.KeyValues{
+			{Key: blockKey, Values: []*av.Value{
+				{ID: "v1", KeyID: blockKey.ID, BlockID: "row1", Type: av.KeyTypeBlock, IsDetached: true, Block: &av.ValueBlock{ID: "row1", Content: "first"}},
+				{ID: "v2", KeyID: blockKey.ID, BlockID: "row2", Type: av.KeyTypeBlock, IsDetached: true, Block: &av.ValueBlock{ID: "row2", Content: "second"}},
+			}},
+			{Key: scoreKey, Values: []*av.Value{
+				{ID: "v3", KeyID: scoreKey.ID, BlockID: "row1", Type: av.KeyTypeNumber, Number: &av.ValueNumber{Content: 42, IsNotEmpty: true}},
+				{ID: "v4", KeyID: scoreKey.ID, BlockID: "row2", Type: av.KeyTypeNumber, Number: &av.ValueNumber{Content: 7.5, IsNotEmpty: true}},
+			}},
+			{Key: tagsKey, Values: []*av.Value{
+				{ID: "v5", KeyID: tagsKey.ID, BlockID: "row1", Type: av.KeyTypeMSelect, MSelect: []*av.ValueSelect{{Content: "a"}, {Content: "b"}}},
+			}},
+		},
+	}
+}
+
+func TestBuildAttributeViewUpsertIndex(t *testing.T) {
+	attrView := newImportAvTestAttrView()
+
+	tests := []struct {
+		keyID string
+		cell  string
+		want  string
+	}{
+		{"20240101000000-keyscor", "42", "row1"},
+		{"20240101000000-keyscor", " 7.50 ", "row2"},
+		{"20240101000000-keyscor", "8", ""},
+		{"20240101000000-keyscor", "", ""},
+		{"20240101000000-keytags", "a, b", "row1"},
+		{"20240101000000-keyname", "second", "row2"},
+	}
+	for _, test := range tests {
+		index, err := buildAttributeViewUpsertIndex(attrView, test.keyID)
+		if err != nil {
+			t.Fatalf("build upsert index failed: %s", err)
+		}
+		key, _ := attrView.GetKey(test.keyID)
+		if got := index[avImportUpsertValue(parseAttributeViewValue(key, test.cell))]; got != test.want {
+			t.Errorf("upsert [%s] cell %q matched row %q, want %q", key.Name, test.cell, got, test.want)
+		}
+	}
+
+	index, err := buildAttributeViewUpsertIndex(attrView, avImportRowIDColumn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "row2" != index["row2"] {
+		t.Errorf("upsert by row ID failed: %v", index)
+	}
+	if _, err = buildAttributeViewUpsertIndex(attrView, "missing"); nil == err {
+		t.Errorf("build upsert index with missing key should fail")
+	}
+}
+
+func TestImportAttributeViewCSVUnmappedUpsertKey(t *testing.T) {
+	dataDir := util.DataDir
+	util.DataDir = t.TempDir()
+	t.Cleanup(func() { util.DataDir = dataDir })
+
+	attrView := newImportAvTestAttrView()
+	data, err := json.Marshal(attrView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(av.GetAttributeViewDataPath(attrView.ID), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	csvPath := filepath.Join(t.TempDir(), "import.csv")
+	if err = os.WriteFile(csvPath, []byte("Name,Extra\nfirst,x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, upsertKeyID := range []string{"20240101000000-keyscor", "missing", avImportRowIDColumn} {
+		if _, _, err = ImportAttributeViewCSV(attrView.ID, csvPath, nil, upsertKeyID); nil == err {
+			t.Errorf("import with unmapped upsert key [%s] should fail", upsertKeyID)
+		}
+	}
+
+	// 失败时不能新建字段
+	saved, err := av.ParseAttributeView(attrView.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.KeyValues) != len(attrView.KeyValues) {
+		t.Errorf("got %d keys after failed import, want %d", len(saved.KeyValues), len(attrView.KeyValues))
+	}
+}
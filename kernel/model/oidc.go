@@ -0,0 +1,614 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// OIDCSessionCookieName 单点登录会话 Cookie 的名称，值为内核签发的 JWT。
+const OIDCSessionCookieName = "siyuan-oidc"
+
+const (
+	oidcSessionIss = "siyuan-oidc"
+	oidcSessionSub = "oidc"
+
+	// oidcPublishUserPrefix 通过单点登录访问发布服务时 JWT 中的用户名前缀，避免和发布服务账号重名。
+	oidcPublishUserPrefix = "oidc:"
+
+	// oidcPendingLoginExpire 登录请求在跳转到身份提供方后需要在该时长内回调。
+	oidcPendingLoginExpire = 10 * time.Minute
+
+	// oidcProviderCacheExpire 发现文档和公钥的缓存时长。
+	oidcProviderCacheExpire = time.Hour
+)
+
+// oidcSigningMethods ID 令牌允许的签名算法，不接受对称签名和 none。
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	configIssuer string
+	fetched      time.Time
+	keys         map[string]interface{} // kid -> 公钥
+}
+
+type oidcPendingLogin struct {
+	verifier    string // PKCE 验证码
+	nonce       string
+	redirectURL string
+	to          string // 登录后跳转的站内路径
+	created     time.Time
+}
+
+var (
+	oidcLock          = sync.Mutex{}
+	oidcProviderCache *oidcProvider
+	oidcPendingLogins = map[string]*oidcPendingLogin{} // state -> 登录请求
+
+	// oidcSessionKey 会话签名密钥，只保存在内存中，重启后需要重新登录。
+	oidcSessionKey     = make([]byte, 32)
+	oidcSessionKeyOnce = sync.Once{}
+)
+
+func SetOIDC(oidc *conf.OIDC) error {
+	oidc.Issuer = strings.TrimSpace(oidc.Issuer)
+	oidc.ClientID = strings.TrimSpace(oidc.ClientID)
+	oidc.RedirectURL = strings.TrimSpace(oidc.RedirectURL)
+	if oidc.Enabled {
+		if issuer, err := url.Parse(oidc.Issuer); nil != err || ("https" != issuer.Scheme && "http" != issuer.Scheme) || "" == issuer.Host {
+			return errors.New("invalid issuer [" + oidc.Issuer + "]")
+		}
+		if "" == oidc.ClientID {
+			return errors.New("client ID is required")
+		}
+		if redirectURL, err := url.Parse(oidc.RedirectURL); nil != err || ("https" != redirectURL.Scheme && "http" != redirectURL.Scheme) || "" == redirectURL.Host {
+			return errors.New("invalid redirect URL [" + oidc.RedirectURL + "]")
+		}
+	}
+	if 1 > oidc.SessionHours {
+		oidc.SessionHours = 24
+	}
+
+	oidcLock.Lock()
+	oidcProviderCache = nil
+	oidcLock.Unlock()
+
+	Conf.OIDC = oidc
+	Conf.Save()
+	return nil
+}
+
+// OIDCLogin 生成 PKCE 验证码并跳转到身份提供方的授权页面。
+func OIDCLogin(c *gin.Context) {
+	if !IsOIDCEnabled() {
+		c.String(http.StatusNotFound, "single sign-on is not enabled")
+		return
+	}
+
+	provider, err := getOIDCProvider(false)
+	if err != nil {
+		logging.LogErrorf("discover OIDC provider [%s] failed: %s", Conf.OIDC.Issuer, err)
+		c.String(http.StatusBadGateway, "discover OIDC provider failed: "+err.Error())
+		return
+	}
+
+	state, nonce, verifier := oidcRandom(), oidcRandom(), oidcRandom()
+	challenge := sha256.Sum256([]byte(verifier))
+	pending := &oidcPendingLogin{
+		verifier:    verifier,
+		nonce:       nonce,
+		redirectURL: Conf.OIDC.RedirectURL,
+		to:          getOIDCLoginTo(c.Query("to")),
+		created:     time.Now(),
+	}
+
+	oidcLock.Lock()
+	for s, p := range oidcPendingLogins {
+		if oidcPendingLoginExpire < time.Since(p.created) {
+			delete(oidcPendingLogins, s)
+		}
+	}
+	oidcPendingLogins[state] = pending
+	oidcLock.Unlock()
+
+	scopes := []string{"openid"}
+	for _, scope := range Conf.OIDC.Scopes {
+		if scope = strings.TrimSpace(scope); "" != scope && !gulu.Str.Contains(scope, scopes) {
+			scopes = append(scopes, scope)
+		}
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", Conf.OIDC.ClientID)
+	query.Set("redirect_uri", pending.redirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	authURL := provider.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query.Encode()
+	} else {
+		authURL += "?" + query.Encode()
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 使用授权码换取 ID 令牌，校验后根据声明映射角色并签发会话。
+func OIDCCallback(c *gin.Context) {
+	if !IsOIDCEnabled() {
+		c.String(http.StatusNotFound, "single sign-on is not enabled")
+		return
+	}
+
+	if errCode := c.Query("error"); "" != errCode {
+		logging.LogWarnf("OIDC login failed [ip=%s]: %s %s", util.GetRemoteAddr(c.Request), errCode, c.Query("error_description"))
+		c.String(http.StatusUnauthorized, "single sign-on failed: "+errCode)
+		return
+	}
+
+	state := c.Query("state")
+	oidcLock.Lock()
+	pending := oidcPendingLogins[state]
+	delete(oidcPendingLogins, state)
+	oidcLock.Unlock()
+	if nil == pending || oidcPendingLoginExpire < time.Since(pending.created) {
+		c.String(http.StatusBadRequest, "invalid or expired login state")
+		return
+	}
+
+	claims, err := exchangeOIDCCode(c.Query("code"), pending)
+	if err != nil {
+		logging.LogWarnf("OIDC login failed [ip=%s]: %s", util.GetRemoteAddr(c.Request), err)
+		c.String(http.StatusUnauthorized, "single sign-on failed: "+err.Error())
+		return
+	}
+
+	subject, _ := claims["sub"].(string)
+	role, ok := mapOIDCRole(claims)
+	if !ok {
+		logging.LogWarnf("OIDC user [%s] has no role [ip=%s]", subject, util.GetRemoteAddr(c.Request))
+		c.String(http.StatusForbidden, "single sign-on failed: no role is mapped for this user")
+		return
+	}
+
+	session, err := signOIDCSession(claims, role)
+	if err != nil {
+		logging.LogErrorf("sign OIDC session failed: %s", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     OIDCSessionCookieName,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   Conf.OIDC.SessionHours * 60 * 60,
+		Secure:   strings.HasPrefix(pending.redirectURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	logging.LogInfof("OIDC auth success [sub=%s, role=%d, ip=%s]", subject, role, util.GetRemoteAddr(c.Request))
+	c.Redirect(http.StatusFound, pending.to)
+}
+
+// OIDCLogout 清除单点登录会话，不会注销身份提供方的会话。
+func OIDCLogout(c *gin.Context) {
+	clearOIDCSession(c)
+	c.Redirect(http.StatusFound, "/")
+}
+
+func clearOIDCSession(c *gin.Context) {
+	if _, err := c.Request.Cookie(OIDCSessionCookieName); err != nil {
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     OIDCSessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+// ParseOIDCSession 解析单点登录会话，未启用单点登录时返回错误。
+func ParseOIDCSession(tokenString string) (*jwt.Token, error) {
+	if !IsOIDCEnabled() {
+		return nil, errors.New("single sign-on is not enabled")
+	}
+
+	return jwt.Parse(
+		tokenString,
+		func(token *jwt.Token) (interface{}, error) {
+			return getOIDCSessionKey(), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(oidcSessionIss),
+		jwt.WithSubject(oidcSessionSub),
+		jwt.WithAudience(aud),
+		jwt.WithExpirationRequired(),
+	)
+}
+
+// GetOIDCPublishToken 将请求中的单点登录会话转换为发布服务的 JWT，角色固定为读者。
+//
+// 未启用单点登录访问发布服务或者会话无效时返回空字符串。
+func GetOIDCPublishToken(r *http.Request) string {
+	if !Conf.OIDC.Publish {
+		return ""
+	}
+	cookie, err := r.Cookie(OIDCSessionCookieName)
+	if err != nil || "" == cookie.Value {
+		return ""
+	}
+	session, err := ParseOIDCSession(cookie.Value)
+	if err != nil || !session.Valid {
+		return ""
+	}
+
+	username, _ := GetTokenClaims(session)["jti"].(string)
+	t := jwt.NewWithClaims(
+		jwt.SigningMethodHS256,
+		jwt.MapClaims{
+			"iss": iss,
+			"sub": sub,
+			"aud": aud,
+			"jti": oidcPublishUserPrefix + username,
+
+			ClaimsKeyRole: RoleReader,
+		},
+	)
+	ret, err := t.SignedString(key)
+	if err != nil {
+		logging.LogErrorf("JWT signature failed: %s", err)
+		return ""
+	}
+	return ret
+}
+
+// IsOIDCPublishEnabled 判断发布服务是否可以通过单点登录访问。
+func IsOIDCPublishEnabled() bool {
+	return IsOIDCEnabled() && Conf.OIDC.Publish
+}
+
+func isOIDCEditableContext(c *gin.Context) bool {
+	claims, exists := c.Get(ClaimsContextKey)
+	if !exists || oidcSessionSub != claims.(jwt.MapClaims)["sub"] {
+		return false
+	}
+	return RoleEditor == GetGinContextRole(c) && apiTokenEditableGroups[apiRouteGroup(c.Request.URL.Path)]
+}
+
+// IsOIDCEnabled 判断是否启用了单点登录，回调地址必须配置为外部访问的地址，不能根据请求头推断。
+func IsOIDCEnabled() bool {
+	return nil != Conf.OIDC && Conf.OIDC.Enabled && "" != Conf.OIDC.Issuer && "" != Conf.OIDC.ClientID && "" != Conf.OIDC.RedirectURL
+}
+
+func getOIDCSessionKey() []byte {
+	oidcSessionKeyOnce.Do(func() {
+		if _, err := rand.Read(oidcSessionKey); err != nil {
+			logging.LogErrorf("generate OIDC session signing key failed: %s", err)
+		}
+	})
+	return oidcSessionKey
+}
+
+func signOIDCSession(idClaims jwt.MapClaims, role Role) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": oidcSessionIss,
+		"sub": oidcSessionSub,
+		"aud": aud,
+		"jti": idClaims["sub"],
+		"iat": now.Unix(),
+		"exp": now.Add(time.Duration(Conf.OIDC.SessionHours) * time.Hour).Unix(),
+
+		ClaimsKeyRole: role,
+	}
+	for _, k := range []string{"name", "preferred_username", "email"} {
+		if v, ok := idClaims[k].(string); ok && "" != v {
+			claims[k] = v
+		}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(getOIDCSessionKey())
+}
+
+// mapOIDCRole 根据 ID 令牌中的声明映射角色，多个值匹配时使用权限最高的角色。
+func mapOIDCRole(claims jwt.MapClaims) (ret Role, ok bool) {
+	var values []string
+	if "" != Conf.OIDC.RoleClaim {
+		switch v := claims[Conf.OIDC.RoleClaim].(type) {
+		case string:
+			values = strings.Fields(strings.ReplaceAll(v, ",", " "))
+		case []interface{}:
+			for _, item := range v {
+				if s, isStr := item.(string); isStr {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+
+	for _, m := range []struct {
+		role   Role
+		values []string
+	}{
+		{RoleAdministrator, Conf.OIDC.AdminValues},
+		{RoleEditor, Conf.OIDC.EditorValues},
+		{RoleReader, Conf.OIDC.ReaderValues},
+	} {
+		for _, value := range values {
+			if gulu.Str.Contains(value, m.values) {
+				return m.role, true
+			}
+		}
+	}
+
+	if role := Role(Conf.OIDC.DefaultRole); 0 <= Conf.OIDC.DefaultRole && IsValidRole(role, []Role{RoleAdministrator, RoleEditor, RoleReader}) {
+		return role, true
+	}
+	return
+}
+
+// exchangeOIDCCode 使用授权码和 PKCE 验证码换取 ID 令牌并校验签名、签发者、受众、过期时间和 nonce。
+func exchangeOIDCCode(code string, pending *oidcPendingLogin) (ret jwt.MapClaims, err error) {
+	if "" == code {
+		err = errors.New("authorization code is missing")
+		return
+	}
+	provider, err := getOIDCProvider(false)
+	if err != nil {
+		return
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", pending.redirectURL)
+	form.Set("client_id", Conf.OIDC.ClientID)
+	form.Set("code_verifier", pending.verifier)
+	req, err := http.NewRequest(http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if "" != Conf.OIDC.ClientSecret {
+		req.SetBasicAuth(url.QueryEscape(Conf.OIDC.ClientID), url.QueryEscape(Conf.OIDC.ClientSecret))
+	}
+
+	tokenResult := map[string]interface{}{}
+	if err = doOIDCRequest(req, &tokenResult); err != nil {
+		return
+	}
+	if errCode, _ := tokenResult["error"].(string); "" != errCode {
+		desc, _ := tokenResult["error_description"].(string)
+		err = errors.New("token endpoint returned error [" + errCode + "] " + desc)
+		return
+	}
+	idToken, _ := tokenResult["id_token"].(string)
+	if "" == idToken {
+		err = errors.New("token endpoint returned no ID token")
+		return
+	}
+
+	ret = jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(
+		idToken,
+		ret,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return getOIDCPublicKey(kid)
+		},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(Conf.OIDC.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		err = errors.New("invalid ID token: " + err.Error())
+		return
+	}
+	if nonce, _ := ret["nonce"].(string); nonce != pending.nonce {
+		err = errors.New("invalid ID token: nonce mismatch")
+		return
+	}
+	if subject, _ := ret["sub"].(string); "" == subject {
+		err = errors.New("invalid ID token: subject is missing")
+	}
+	return
+}
+
+// getOIDCProvider 获取身份提供方的发现文档和公钥，refresh 为 true 时忽略缓存，用于公钥轮换后重新获取。
+func getOIDCProvider(refresh bool) (ret *oidcProvider, err error) {
+	oidcLock.Lock()
+	defer oidcLock.Unlock()
+
+	issuer := strings.TrimSuffix(strings.TrimSpace(Conf.OIDC.Issuer), "/")
+	if !refresh && nil != oidcProviderCache && issuer == oidcProviderCache.configIssuer && oidcProviderCacheExpire > time.Since(oidcProviderCache.fetched) {
+		return oidcProviderCache, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return
+	}
+	ret = &oidcProvider{configIssuer: issuer, fetched: time.Now()}
+	if err = doOIDCRequest(req, ret); err != nil {
+		return
+	}
+	if strings.TrimSuffix(ret.Issuer, "/") != issuer {
+		err = errors.New("issuer [" + ret.Issuer + "] in discovery document does not match the configured issuer")
+		return
+	}
+	if "" == ret.AuthorizationEndpoint || "" == ret.TokenEndpoint || "" == ret.JWKSURI {
+		err = errors.New("discovery document is missing required endpoints")
+		return
+	}
+	if ret.keys, err = fetchOIDCKeys(ret.JWKSURI); err != nil {
+		return
+	}
+	oidcProviderCache = ret
+	return
+}
+
+func getOIDCPublicKey(kid string) (ret interface{}, err error) {
+	for i := 0; i < 2; i++ {
+		provider, getErr := getOIDCProvider(0 < i)
+		if nil != getErr {
+			return nil, getErr
+		}
+		if ret = provider.keys[kid]; nil != ret {
+			return
+		}
+		if "" == kid && 1 == len(provider.keys) {
+			for _, k := range provider.keys {
+				return k, nil
+			}
+		}
+	}
+	return nil, errors.New("signing key [" + kid + "] not found")
+}
+
+// fetchOIDCKeys 获取 JWKS 中的 RSA 和 EC 签名公钥。
+func fetchOIDCKeys(jwksURI string) (ret map[string]interface{}, err error) {
+	req, err := http.NewRequest(http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return
+	}
+	jwks := struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+	if err = doOIDCRequest(req, &jwks); err != nil {
+		return
+	}
+
+	ret = map[string]interface{}{}
+	for _, k := range jwks.Keys {
+		if "" != k.Use && "sig" != k.Use {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, nErr := base64.RawURLEncoding.DecodeString(k.N)
+			e, eErr := base64.RawURLEncoding.DecodeString(k.E)
+			if nil != nErr || nil != eErr {
+				logging.LogWarnf("skip invalid OIDC RSA key [%s]", k.Kid)
+				continue
+			}
+			ret[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, xErr := base64.RawURLEncoding.DecodeString(k.X)
+			y, yErr := base64.RawURLEncoding.DecodeString(k.Y)
+			if nil != xErr || nil != yErr {
+				logging.LogWarnf("skip invalid OIDC EC key [%s]", k.Kid)
+				continue
+			}
+			ret[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if 1 > len(ret) {
+		err = errors.New("no usable signing key in JWKS")
+	}
+	return
+}
+
+func doOIDCRequest(req *http.Request, result interface{}) (err error) {
+	client := &http.Client{Transport: httpclient.NewTransport(false), Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, result); err != nil {
+		return errors.New("parse response of [" + req.URL.String() + "] failed: " + resp.Status)
+	}
+	if http.StatusOK != resp.StatusCode && http.StatusBadRequest != resp.StatusCode && http.StatusUnauthorized != resp.StatusCode {
+		return errors.New("request [" + req.URL.String() + "] failed: " + resp.Status)
+	}
+	return
+}
+
+// getOIDCLoginTo 只允许跳转到站内路径，避免开放重定向。
+func getOIDCLoginTo(to string) string {
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") || strings.HasPrefix(to, "/\\") {
+		return "/"
+	}
+	return to
+}
+
+func oidcRandom() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logging.LogErrorf("generate random string failed: %s", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
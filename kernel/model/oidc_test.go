@@ -0,0 +1,274 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+const (
+	oidcTestClientID    = "siyuan"
+	oidcTestRedirectURL = "https://notes.example.com/auth/oidc/callback"
+)
+
+// oidcTestProvider 模拟身份提供方，校验 PKCE 后签发 ID 令牌。
+type oidcTestProvider struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	lock     sync.Mutex
+	codes    map[string]url.Values // code -> 授权请求参数
+	signKey  interface{}           // 为 nil 时使用 key 签名
+	method   jwt.SigningMethod     // 为 nil 时使用 RS256
+	idClaims func(claims jwt.MapClaims)
+}
+
+func newOIDCTestProvider(t *testing.T) (ret *oidcTestProvider) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	ret = &oidcTestProvider{key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ret.URL,
+			"authorization_endpoint": ret.URL + "/authorize",
+			"token_endpoint":         ret.URL + "/token",
+			"jwks_uri":               ret.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		ret.lock.Lock()
+		auth := ret.codes[r.PostForm.Get("code")]
+		delete(ret.codes, r.PostForm.Get("code"))
+		ret.lock.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if nil == auth || auth.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(verifier[:]) ||
+			auth.Get("redirect_uri") != r.PostForm.Get("redirect_uri") || auth.Get("client_id") != r.PostForm.Get("client_id") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   ret.URL,
+			"sub":   "alice",
+			"aud":   oidcTestClientID,
+			"nonce": auth.Get("nonce"),
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		if nil != ret.idClaims {
+			ret.idClaims(claims)
+		}
+		method, signKey := ret.method, ret.signKey
+		if nil == method {
+			method = jwt.SigningMethodRS256
+		}
+		if nil == signKey {
+			signKey = key
+		}
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = "test"
+		idToken, signErr := token.SignedString(signKey)
+		if nil != signErr {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+	ret.Server = httptest.NewServer(mux)
+	t.Cleanup(ret.Close)
+	return
+}
+
+// authorize 模拟用户在身份提供方登录，返回授权码。
+func (p *oidcTestProvider) authorize(t *testing.T, authURL string) (state, code string) {
+	u, err := url.Parse(authURL)
+	if nil != err {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if "S256" != query.Get("code_challenge_method") || "" == query.Get("code_challenge") || "" == query.Get("nonce") {
+		t.Fatalf("invalid authorization request [%s]", authURL)
+	}
+
+	code = oidcRandom()
+	p.lock.Lock()
+	p.codes[code] = query
+	p.lock.Unlock()
+	return query.Get("state"), code
+}
+
+func TestOIDCLogin(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		setup      func(p *oidcTestProvider)
+		state      func(state string) string // 篡改回调中的 state
+		verifier   bool                      // 篡改 PKCE 验证码
+		replay     bool                      // 重复使用同一个回调
+		wantStatus int
+	}{
+		{name: "valid", wantStatus: http.StatusFound},
+		{name: "unknown state", state: func(string) string { return "bogus" }, wantStatus: http.StatusBadRequest},
+		{name: "missing state", state: func(string) string { return "" }, wantStatus: http.StatusBadRequest},
+		{name: "replayed state", replay: true, wantStatus: http.StatusBadRequest},
+		{name: "PKCE verifier mismatch", verifier: true, wantStatus: http.StatusUnauthorized},
+		{name: "nonce mismatch", setup: func(p *oidcTestProvider) {
+			p.idClaims = func(claims jwt.MapClaims) { claims["nonce"] = "other" }
+		}, wantStatus: http.StatusUnauthorized},
+		{name: "bad signature", setup: func(p *oidcTestProvider) { p.signKey = otherKey }, wantStatus: http.StatusUnauthorized},
+		{name: "unsigned token", setup: func(p *oidcTestProvider) {
+			p.method, p.signKey = jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType
+		}, wantStatus: http.StatusUnauthorized},
+		{name: "wrong audience", setup: func(p *oidcTestProvider) {
+			p.idClaims = func(claims jwt.MapClaims) { claims["aud"] = "other" }
+		}, wantStatus: http.StatusUnauthorized},
+		{name: "wrong issuer", setup: func(p *oidcTestProvider) {
+			p.idClaims = func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" }
+		}, wantStatus: http.StatusUnauthorized},
+		{name: "expired", setup: func(p *oidcTestProvider) {
+			p.idClaims = func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }
+		}, wantStatus: http.StatusUnauthorized},
+		{name: "missing expiration", setup: func(p *oidcTestProvider) {
+			p.idClaims = func(claims jwt.MapClaims) { delete(claims, "exp") }
+		}, wantStatus: http.StatusUnauthorized},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			provider := newOIDCTestProvider(t)
+			if nil != c.setup {
+				c.setup(provider)
+			}
+
+			origin := Conf
+			Conf = &AppConf{OIDC: &conf.OIDC{
+				Enabled:      true,
+				Issuer:       provider.URL,
+				ClientID:     oidcTestClientID,
+				RedirectURL:  oidcTestRedirectURL,
+				DefaultRole:  int(RoleReader),
+				SessionHours: 1,
+			}}
+			oidcProviderCache = nil
+			t.Cleanup(func() {
+				Conf = origin
+				oidcProviderCache = nil
+			})
+
+			// 回调地址只使用配置，不能被转发请求头改写
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/auth/oidc/login?to=/stage/build/app/", nil)
+			ctx.Request.Header.Set("X-Forwarded-Host", "evil.example.com")
+			ctx.Request.Header.Set("X-Forwarded-Proto", "http")
+			OIDCLogin(ctx)
+			if http.StatusFound != w.Code {
+				t.Fatalf("login: want status [%d], got [%d] %s", http.StatusFound, w.Code, w.Body.String())
+			}
+			authURL, _ := url.Parse(w.Header().Get("Location"))
+			if redirectURI := authURL.Query().Get("redirect_uri"); oidcTestRedirectURL != redirectURI {
+				t.Fatalf("login: want redirect URI [%s], got [%s]", oidcTestRedirectURL, redirectURI)
+			}
+
+			state, code := provider.authorize(t, w.Header().Get("Location"))
+			if c.verifier {
+				oidcLock.Lock()
+				oidcPendingLogins[state].verifier = oidcRandom()
+				oidcLock.Unlock()
+			}
+			if nil != c.state {
+				state = c.state(state)
+			}
+
+			callback := "/auth/oidc/callback?" + url.Values{"state": {state}, "code": {code}}.Encode()
+			w = httptest.NewRecorder()
+			ctx, _ = gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, callback, nil)
+			OIDCCallback(ctx)
+			if c.replay {
+				w = httptest.NewRecorder()
+				ctx, _ = gin.CreateTestContext(w)
+				ctx.Request = httptest.NewRequest(http.MethodGet, callback, nil)
+				OIDCCallback(ctx)
+			}
+			if c.wantStatus != w.Code {
+				t.Fatalf("callback: want status [%d], got [%d] %s", c.wantStatus, w.Code, w.Body.String())
+			}
+
+			var session *http.Cookie
+			for _, cookie := range w.Result().Cookies() {
+				if OIDCSessionCookieName == cookie.Name {
+					session = cookie
+				}
+			}
+			if http.StatusFound != c.wantStatus {
+				if nil != session {
+					t.Fatalf("callback: unexpected session cookie")
+				}
+				return
+			}
+			if nil == session || !session.Secure || !session.HttpOnly {
+				t.Fatalf("callback: invalid session cookie %v", session)
+			}
+			if "/stage/build/app/" != w.Header().Get("Location") {
+				t.Fatalf("callback: unexpected redirect [%s]", w.Header().Get("Location"))
+			}
+			token, err := ParseOIDCSession(session.Value)
+			if nil != err || !token.Valid {
+				t.Fatalf("callback: invalid session: %v", err)
+			}
+			if claims := GetTokenClaims(token); "alice" != claims["jti"] || RoleReader != GetClaimRole(claims) {
+				t.Fatalf("callback: unexpected session claims %v", claims)
+			}
+		})
+	}
+}
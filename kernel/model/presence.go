@@ -0,0 +1,123 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Presence 描述了用户在文档中的状态。
+type Presence struct {
+	User    string `json:"user"`
+	Session string `json:"session"` // 推送会话 ID，同一个用户可以在多个窗口中打开文档
+	Mode    string `json:"mode"`    // viewing：浏览，editing：编辑
+	Updated int64  `json:"updated"`
+}
+
+// presenceExpire 超过该时长没有刷新的状态会被移除，前端需要定时刷新。
+const presenceExpire = 2 * time.Minute
+
+var (
+	presences     = map[string]map[string]*Presence{} // rootID -> {session -> presence}
+	presencesLock = sync.Mutex{}
+)
+
+// UpdatePresence 更新用户在文档中的状态并广播给其他会话，mode 为空时表示离开文档。
+func UpdatePresence(rootID, session, user, mode string) {
+	presencesLock.Lock()
+	// 一个会话同时只在一个文档中
+	for docID, docPresences := range presences {
+		if _, ok := docPresences[session]; ok && docID != rootID {
+			delete(docPresences, session)
+			if 1 > len(docPresences) {
+				delete(presences, docID)
+			}
+			defer pushPresence(docID)
+		}
+	}
+	if "" != mode {
+		if nil == presences[rootID] {
+			presences[rootID] = map[string]*Presence{}
+		}
+		presences[rootID][session] = &Presence{User: user, Session: session, Mode: mode, Updated: time.Now().UnixMilli()}
+	} else if docPresences := presences[rootID]; nil != docPresences {
+		delete(docPresences, session)
+		if 1 > len(docPresences) {
+			delete(presences, rootID)
+		}
+	}
+	presencesLock.Unlock()
+
+	pushPresence(rootID)
+}
+
+// RemovePresenceSession 推送会话断开时移除其状态。
+func RemovePresenceSession(session string) {
+	var rootIDs []string
+	presencesLock.Lock()
+	for rootID, docPresences := range presences {
+		if _, ok := docPresences[session]; ok {
+			delete(docPresences, session)
+			if 1 > len(docPresences) {
+				delete(presences, rootID)
+			}
+			rootIDs = append(rootIDs, rootID)
+		}
+	}
+	presencesLock.Unlock()
+
+	for _, rootID := range rootIDs {
+		pushPresence(rootID)
+	}
+}
+
+func GetPresences(rootID string) (ret []*Presence) {
+	presencesLock.Lock()
+	defer presencesLock.Unlock()
+
+	ret = []*Presence{}
+	expired := time.Now().Add(-presenceExpire).UnixMilli()
+	for session, p := range presences[rootID] {
+		if p.Updated < expired {
+			delete(presences[rootID], session)
+			continue
+		}
+		cloned := *p
+		ret = append(ret, &cloned)
+	}
+	if docPresences, ok := presences[rootID]; ok && 1 > len(docPresences) {
+		delete(presences, rootID)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Updated < ret[j].Updated })
+	return
+}
+
+// pushPresence 广播文档中的用户状态和块锁。
+func pushPresence(rootID string) {
+	if "" == rootID {
+		return
+	}
+	util.BroadcastByType("main", "presence", 0, "", map[string]interface{}{
+		"rootID":    rootID,
+		"presences": GetPresences(rootID),
+		"locks":     GetBlockLocks(rootID),
+	})
+}
@@ -0,0 +1,475 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const publishAccessContextKey = "publishAccess"
+
+// ErrPublishScopeDenied 设置了访问范围的发布服务账号不能调用无法按文档过滤结果的接口。
+//
+// 例如 SQL 查询结果中的 box 和 path 列可以通过别名伪造，资源文件内容不区分所在的文档。
+var ErrPublishScopeDenied = errors.New("this API is not available for publish accounts with an access scope")
+
+// PublishAccess 描述了发布服务账号在当前请求中可以访问的范围。
+//
+// 为 nil 时不限制，所有方法都可以在 nil 上调用。
+type PublishAccess struct {
+	boxes    map[string]bool     // 可以完整访问的笔记本
+	docPaths map[string][]string // 笔记本 -> 可以访问的文档子树路径，不含 .sy 后缀
+}
+
+// GetPublishAccess 获取发布服务账号的访问范围，不是通过发布服务访问或者账号没有设置访问范围时返回 nil。
+func GetPublishAccess(c *gin.Context) *PublishAccess {
+	if cached, exists := c.Get(publishAccessContextKey); exists {
+		return cached.(*PublishAccess)
+	}
+
+	var ret *PublishAccess
+	if claims, exists := c.Get(ClaimsContextKey); exists && sub == claims.(jwt.MapClaims)["sub"] {
+		username, _ := claims.(jwt.MapClaims)["jti"].(string)
+		ret = newPublishAccess(getPublishAccountScope(username))
+	}
+	c.Set(publishAccessContextKey, ret)
+	return ret
+}
+
+// getPublishAccountScope 获取发布服务账号的访问范围，通过单点登录访问时使用单点登录配置中的访问范围。
+func getPublishAccountScope(username string) *conf.PublishScope {
+	if strings.HasPrefix(username, oidcPublishUserPrefix) {
+		return Conf.OIDC.PublishScope
+	}
+	if !Conf.Publish.Auth.Enable {
+		return nil
+	}
+	for _, account := range Conf.Publish.Auth.Accounts {
+		if account.Username == username {
+			return account.Scope
+		}
+	}
+	return nil
+}
+
+func newPublishAccess(scope *conf.PublishScope) (ret *PublishAccess) {
+	if scope.IsEmpty() {
+		return
+	}
+
+	ret = &PublishAccess{boxes: map[string]bool{}, docPaths: map[string][]string{}}
+	for _, box := range scope.Notebooks {
+		ret.boxes[box] = true
+	}
+
+	docIDs := append([]string{}, scope.Docs...)
+	if 0 < len(scope.Tags) {
+		var tags []string
+		for _, tag := range scope.Tags {
+			tags = append(tags, "'"+strings.ReplaceAll(tag, "'", "''")+"'")
+		}
+		// 文档标签和文档内容中的标签都会被索引为标签元素
+		stmt := "SELECT DISTINCT root_id FROM spans WHERE type LIKE '%tag%' AND content IN (" + strings.Join(tags, ",") + ")"
+		rows, err := sql.QueryNoLimit(stmt)
+		if err != nil {
+			logging.LogErrorf("query publish scope tags failed: %s", err)
+		}
+		for _, row := range rows {
+			if rootID, ok := row["root_id"].(string); ok {
+				docIDs = append(docIDs, rootID)
+			}
+		}
+	}
+	for _, bt := range treenode.GetBlockTrees(docIDs) {
+		ret.docPaths[bt.BoxID] = append(ret.docPaths[bt.BoxID], strings.TrimSuffix(bt.Path, ".sy"))
+	}
+	return
+}
+
+// AllowDocPath 判断是否可以访问指定文档的内容。
+func (access *PublishAccess) AllowDocPath(box, p string) bool {
+	if nil == access || access.boxes[box] {
+		return true
+	}
+
+	p = strings.TrimSuffix(p, ".sy")
+	for _, docPath := range access.docPaths[box] {
+		if p == docPath || strings.HasPrefix(p, docPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// VisibleDocPath 判断文档是否在文档树中可见，可以访问的文档的上级文档也是可见的，但是不能访问其内容。
+func (access *PublishAccess) VisibleDocPath(box, p string) bool {
+	if access.AllowDocPath(box, p) {
+		return true
+	}
+	if "/" == p || "" == p {
+		return access.VisibleBox(box)
+	}
+
+	p = strings.TrimSuffix(p, ".sy")
+	for _, docPath := range access.docPaths[box] {
+		if strings.HasPrefix(docPath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (access *PublishAccess) VisibleBox(box string) bool {
+	return nil == access || access.boxes[box] || 0 < len(access.docPaths[box])
+}
+
+// AllowAsset 判断是否可以访问资源文件，引用了该资源的文档中至少有一个可以访问。
+func (access *PublishAccess) AllowAsset(assetPath string) bool {
+	if nil == access {
+		return true
+	}
+
+	stmt := "SELECT box, docpath FROM assets WHERE path = '" + strings.ReplaceAll(assetPath, "'", "''") + "'"
+	rows, err := sql.QueryNoLimit(stmt)
+	if err != nil {
+		logging.LogErrorf("query asset [%s] for publish access failed: %s", assetPath, err)
+		return false
+	}
+	for _, row := range rows {
+		box, _ := row["box"].(string)
+		docPath, _ := row["docpath"].(string)
+		if access.AllowDocPath(box, docPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (access *PublishAccess) FilterNotebooks(boxes []*Box) (ret []*Box) {
+	if nil == access {
+		return boxes
+	}
+
+	ret = []*Box{}
+	for _, box := range boxes {
+		if access.VisibleBox(box.ID) {
+			ret = append(ret, box)
+		}
+	}
+	return
+}
+
+func (access *PublishAccess) FilterFiles(box string, files []*File) (ret []*File) {
+	if nil == access {
+		return files
+	}
+
+	ret = []*File{}
+	for _, file := range files {
+		if access.VisibleDocPath(box, file.Path) {
+			ret = append(ret, file)
+		}
+	}
+	return
+}
+
+// FilterDocs 过滤文档搜索结果，结果中的 box 和 path 分别为笔记本和文档路径。
+func (access *PublishAccess) FilterDocs(docs []map[string]string) (ret []map[string]string) {
+	if nil == access {
+		return docs
+	}
+
+	ret = []map[string]string{}
+	for _, doc := range docs {
+		if access.VisibleDocPath(doc["box"], doc["path"]) {
+			ret = append(ret, doc)
+		}
+	}
+	return
+}
+
+func (access *PublishAccess) FilterBlocks(blocks []*Block) (ret []*Block) {
+	if nil == access {
+		return blocks
+	}
+
+	ret = []*Block{}
+	for _, block := range blocks {
+		if access.AllowDocPath(block.Box, block.Path) {
+			ret = append(ret, block)
+		}
+	}
+	return
+}
+
+// FilterPaths 过滤反向链接，反链路径中没有文档路径，通过块树查询。
+func (access *PublishAccess) FilterPaths(paths []*Path) (ret []*Path) {
+	if nil == access {
+		return paths
+	}
+
+	var ids []string
+	for _, p := range paths {
+		ids = append(ids, p.ID)
+	}
+	bts := treenode.GetBlockTrees(ids)
+	ret = []*Path{}
+	for _, p := range paths {
+		if bt := bts[p.ID]; nil != bt && access.AllowDocPath(bt.BoxID, bt.Path) {
+			ret = append(ret, p)
+		}
+	}
+	return
+}
+
+func (access *PublishAccess) FilterGraph(nodes []*GraphNode, links []*GraphLink) (retNodes []*GraphNode, retLinks []*GraphLink) {
+	if nil == access {
+		return nodes, links
+	}
+
+	retNodes, retLinks = []*GraphNode{}, []*GraphLink{}
+	ids := map[string]bool{}
+	for _, node := range nodes {
+		if access.AllowDocPath(node.Box, node.Path) {
+			retNodes = append(retNodes, node)
+			ids[node.ID] = true
+		}
+	}
+	for _, link := range links {
+		if ids[link.From] && ids[link.To] {
+			retLinks = append(retLinks, link)
+		}
+	}
+	return
+}
+
+// AllowBlock 判断是否可以访问块所在文档的内容，块不存在时不能访问。
+func (access *PublishAccess) AllowBlock(id string) bool {
+	if nil == access {
+		return true
+	}
+
+	bt := treenode.GetBlockTree(id)
+	return nil != bt && access.AllowDocPath(bt.BoxID, bt.Path)
+}
+
+func (access *PublishAccess) FilterBlockIDs(ids []string) (ret []string) {
+	if nil == access {
+		return ids
+	}
+
+	bts := treenode.GetBlockTrees(ids)
+	ret = []string{}
+	for _, id := range ids {
+		if bt := bts[id]; nil != bt && access.AllowDocPath(bt.BoxID, bt.Path) {
+			ret = append(ret, id)
+		}
+	}
+	return
+}
+
+// FilterTreePaths 过滤 {box}/{path} 或者 {path} 形式的文档路径，只保留文档树中可见的文档。
+func (access *PublishAccess) FilterTreePaths(paths []string) (ret []string) {
+	if nil == access {
+		return paths
+	}
+
+	var ids []string
+	for _, p := range paths {
+		ids = append(ids, util.GetTreeID(p))
+	}
+	bts := treenode.GetBlockTrees(ids)
+	ret = []string{}
+	for i, p := range paths {
+		if bt := bts[ids[i]]; nil != bt && access.VisibleDocPath(bt.BoxID, bt.Path) {
+			ret = append(ret, p)
+		}
+	}
+	return
+}
+
+// SQLFilter 返回限制查询范围的条件，用于 blocks、spans 等包含 box 和 path 列的表，不限制时返回空字符串。
+//
+// 搜索需要在查询中过滤，查询后再过滤会导致分页和结果数量不准确。
+func (access *PublishAccess) SQLFilter() string {
+	if nil == access {
+		return ""
+	}
+
+	var conds []string
+	for box := range access.boxes {
+		conds = append(conds, "box = '"+escapePublishSQL(box)+"'")
+	}
+	for box, docPaths := range access.docPaths {
+		if access.boxes[box] {
+			continue
+		}
+		for _, docPath := range docPaths {
+			docPath = escapePublishSQL(docPath)
+			conds = append(conds, "(box = '"+escapePublishSQL(box)+"' AND (path = '"+docPath+".sy' OR path LIKE '"+docPath+"/%'))")
+		}
+	}
+	if 1 > len(conds) {
+		return " AND 1 = 0"
+	}
+	sort.Strings(conds)
+	return " AND (" + strings.Join(conds, " OR ") + ")"
+}
+
+func escapePublishSQL(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// checkPublishAccess 检查设置了访问范围的发布服务账号是否可以调用当前接口。
+//
+// 只能调用 publishScopeRoutes 和 publishScopeUncheckedRoutes 中列出的接口，其他接口一律拒绝。返回 false 时请求已经被终止。
+func checkPublishAccess(c *gin.Context) bool {
+	access := GetPublishAccess(c)
+	if nil == access || !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		return true
+	}
+
+	if publishScopeUncheckedRoutes[c.Request.URL.Path] {
+		return true
+	}
+
+	if !allowPublishScopeRoute(c, access) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func allowPublishScopeRoute(c *gin.Context, access *PublishAccess) bool {
+	resolve := publishScopeRoutes[c.Request.URL.Path]
+	if nil == resolve {
+		return false
+	}
+	arg, _ := readAPIRequestArg(c).(map[string]interface{})
+	if nil == arg {
+		return false
+	}
+	notebooks, ids, ok := resolve(arg)
+	if !ok || (1 > len(notebooks) && 1 > len(ids)) {
+		return false
+	}
+
+	for _, notebook := range notebooks {
+		if !access.VisibleBox(notebook) {
+			return false
+		}
+	}
+	ids = gulu.Str.RemoveDuplicatedElem(ids)
+	bts := treenode.GetBlockTrees(ids)
+	for _, id := range ids {
+		if bt := bts[id]; nil == bt || !access.AllowDocPath(bt.BoxID, bt.Path) {
+			return false
+		}
+	}
+	return true
+}
+
+// publishScopeUncheckedRoutes 设置了访问范围的发布服务账号可以直接调用的接口，这些接口不返回文档内容，或者在处理时已经按访问范围过滤了结果。
+var publishScopeUncheckedRoutes = map[string]bool{
+	"/api/system/getConf":           true,
+	"/api/system/getEmojiConf":      true,
+	"/api/storage/getLocalStorage":  true,
+	"/api/storage/getCriteria":      true,
+	"/api/snippet/getSnippet":       true,
+	"/api/petal/loadPetals":         true,
+	"/api/setting/getCloudUser":     true,
+	"/api/bazaar/getInstalledIcon":  true,
+	"/api/bazaar/getInstalledTheme": true,
+
+	// 以下接口在处理时按访问范围过滤结果
+	"/api/search/fullTextSearchBlock": true,
+	"/api/notebook/lsNotebooks":       true,
+	"/api/v2/notebook/list":           true,
+	"/api/filetree/searchDocs":        true,
+	"/api/filetree/getHPathsByPaths":  true,
+	"/api/search/searchTag":           true,
+	"/api/search/searchAsset":         true,
+	"/api/graph/getGraph":             true,
+}
+
+// publishScopeRoutes 设置了访问范围的发布服务账号可以调用的接口，以及每个接口确定笔记本和块的方式。
+//
+// 参数中的笔记本需要在文档树中可见，块所在的文档需要可以访问。结果可能跨文档的接口需要在处理时再按访问范围过滤。
+var publishScopeRoutes = map[string]apiTokenRouteNotebooks{
+	"/api/notebook/getNotebookConf": apiTokenArgNotebooks("notebook"),
+
+	"/api/filetree/listDocsByPath":   apiTokenArgNotebooks("notebook"),
+	"/api/filetree/getHPathByPath":   apiTokenArgNotebooks("notebook"),
+	"/api/filetree/getDoc":           apiTokenArgBlocks("id"),
+	"/api/filetree/getHPathByID":     apiTokenArgBlocks("id"),
+	"/api/filetree/getFullHPathByID": apiTokenArgBlocks("id"),
+	"/api/filetree/getPathByID":      apiTokenArgBlocks("id"),
+
+	"/api/block/getBlockInfo":          apiTokenArgBlocks("id"),
+	"/api/block/getBlockDOM":           apiTokenArgBlocks("id"),
+	"/api/block/getBlockDOMs":          apiTokenArgBlocks("ids"),
+	"/api/block/getBlockKramdown":      apiTokenArgBlocks("id"),
+	"/api/block/getChildBlocks":        apiTokenArgBlocks("id"),
+	"/api/block/getTailChildBlocks":    apiTokenArgBlocks("id"),
+	"/api/block/getBlockBreadcrumb":    apiTokenArgBlocks("id"),
+	"/api/block/getBlockIndex":         apiTokenArgBlocks("id"),
+	"/api/block/getBlocksIndexes":      apiTokenArgBlocks("ids"),
+	"/api/block/getRefText":            apiTokenArgBlocks("id"),
+	"/api/block/getTreeStat":           apiTokenArgBlocks("id"),
+	"/api/block/getBlocksWordCount":    apiTokenArgBlocks("ids"),
+	"/api/block/getDocInfo":            apiTokenArgBlocks("id"),
+	"/api/block/getDocsInfo":           apiTokenArgBlocks("ids"),
+	"/api/block/checkBlockExist":       apiTokenArgBlocks("id"),
+	"/api/block/checkBlockFold":        apiTokenArgBlocks("id"),
+	"/api/block/getUnfoldedParentID":   apiTokenArgBlocks("id"),
+	"/api/block/getHeadingChildrenIDs": apiTokenArgBlocks("id"),
+	"/api/block/getHeadingChildrenDOM": apiTokenArgBlocks("id"),
+	"/api/block/getBlockSiblingID":     apiTokenArgBlocks("id"),
+	"/api/block/getBlockTreeInfos":     apiTokenArgBlocks("ids"),
+	"/api/v2/block/getKramdown":        apiTokenArgBlocks("id"),
+	"/api/v2/block/getChildren":        apiTokenArgBlocks("id"),
+
+	"/api/attr/getBlockAttrs":      apiTokenArgBlocks("id"),
+	"/api/attr/batchGetBlockAttrs": apiTokenArgBlocks("ids"),
+	"/api/v2/attr/get":             apiTokenArgBlocks("id"),
+
+	"/api/outline/getDocOutline":   apiTokenArgBlocks("id"),
+	"/api/asset/getDocAssets":      apiTokenArgBlocks("id"),
+	"/api/asset/getDocImageAssets": apiTokenArgBlocks("id"),
+	"/api/export/preview":          apiTokenArgBlocks("id"),
+	"/api/ref/getBacklinkDoc":      apiTokenArgBlocks("defID", "refTreeID"),
+	"/api/ref/getBackmentionDoc":   apiTokenArgBlocks("defID", "refTreeID"),
+
+	// 以下接口的结果可能来自其他文档，处理时会再按访问范围过滤
+	"/api/ref/getBacklink":       apiTokenArgBlocks("id"),
+	"/api/ref/getBacklink2":      apiTokenArgBlocks("id"),
+	"/api/graph/getLocalGraph":   apiTokenArgBlocks("id"),
+	"/api/search/getEmbedBlock":  apiTokenArgBlocks("embedBlockID"),
+	"/api/search/searchRefBlock": apiTokenArgBlocks("id", "rootID"),
+}
@@ -0,0 +1,175 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestPublishAccessDocPath(t *testing.T) {
+	access := &PublishAccess{
+		boxes:    map[string]bool{"20240101000000-aaaaaaa": true},
+		docPaths: map[string][]string{"20240101000000-bbbbbbb": {"/20240101000000-ccccccc"}},
+	}
+
+	cases := []struct {
+		name        string
+		box         string
+		path        string
+		wantAllow   bool
+		wantVisible bool
+	}{
+		{"whole notebook", "20240101000000-aaaaaaa", "/20240101000000-xxxxxxx.sy", true, true},
+		{"scoped doc", "20240101000000-bbbbbbb", "/20240101000000-ccccccc.sy", true, true},
+		{"scoped doc child", "20240101000000-bbbbbbb", "/20240101000000-ccccccc/20240101000000-ddddddd.sy", true, true},
+		{"sibling with same prefix", "20240101000000-bbbbbbb", "/20240101000000-ccccccc1.sy", false, false},
+		{"other doc", "20240101000000-bbbbbbb", "/20240101000000-eeeeeee.sy", false, false},
+		{"notebook root", "20240101000000-bbbbbbb", "/", false, true},
+		{"other notebook", "20240101000000-fffffff", "/20240101000000-ccccccc.sy", false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if allow := access.AllowDocPath(c.box, c.path); c.wantAllow != allow {
+				t.Fatalf("allow: want [%t], got [%t]", c.wantAllow, allow)
+			}
+			if visible := access.VisibleDocPath(c.box, c.path); c.wantVisible != visible {
+				t.Fatalf("visible: want [%t], got [%t]", c.wantVisible, visible)
+			}
+		})
+	}
+}
+
+func TestPublishAccessSQLFilter(t *testing.T) {
+	cases := []struct {
+		name   string
+		access *PublishAccess
+		want   string
+	}{
+		{"unrestricted", nil, ""},
+		{"empty scope", &PublishAccess{boxes: map[string]bool{}, docPaths: map[string][]string{}}, " AND 1 = 0"},
+		{
+			"notebook",
+			&PublishAccess{boxes: map[string]bool{"20240101000000-aaaaaaa": true}, docPaths: map[string][]string{}},
+			" AND (box = '20240101000000-aaaaaaa')",
+		},
+		{
+			"doc subtree",
+			&PublishAccess{boxes: map[string]bool{}, docPaths: map[string][]string{"20240101000000-bbbbbbb": {"/20240101000000-ccccccc"}}},
+			" AND ((box = '20240101000000-bbbbbbb' AND (path = '/20240101000000-ccccccc.sy' OR path LIKE '/20240101000000-ccccccc/%')))",
+		},
+		{
+			"doc in whole notebook",
+			&PublishAccess{boxes: map[string]bool{"20240101000000-aaaaaaa": true}, docPaths: map[string][]string{"20240101000000-aaaaaaa": {"/20240101000000-ccccccc"}}},
+			" AND (box = '20240101000000-aaaaaaa')",
+		},
+		{
+			"quote",
+			&PublishAccess{boxes: map[string]bool{"a' OR '1'='1": true}, docPaths: map[string][]string{}},
+			" AND (box = 'a'' OR ''1''=''1')",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.access.SQLFilter(); c.want != got {
+				t.Fatalf("want %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestGetPublishAccountScope(t *testing.T) {
+	origin := Conf
+	oidcScope := &conf.PublishScope{Notebooks: []string{"20240101000000-aaaaaaa"}}
+	accountScope := &conf.PublishScope{Docs: []string{"20240101000000-bbbbbbb"}}
+	Conf = &AppConf{
+		OIDC: &conf.OIDC{Publish: true, PublishScope: oidcScope},
+		Publish: &conf.Publish{Auth: &conf.BasicAuth{Enable: true, Accounts: []*conf.BasicAuthAccount{
+			{Username: "alice", Scope: accountScope},
+			{Username: "bob"},
+		}}},
+	}
+	t.Cleanup(func() { Conf = origin })
+
+	cases := []struct {
+		name     string
+		username string
+		want     *conf.PublishScope
+	}{
+		{"account with scope", "alice", accountScope},
+		{"account without scope", "bob", nil},
+		{"unknown account", "carol", nil},
+		{"oidc session", oidcPublishUserPrefix + "alice", oidcScope},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := getPublishAccountScope(c.username); c.want != got {
+				t.Fatalf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckPublishAccess(t *testing.T) {
+	const visible = "20240101000000-aaaaaaa"
+	const hidden = "20240101000000-bbbbbbb"
+	access := &PublishAccess{boxes: map[string]bool{visible: true}, docPaths: map[string][]string{}}
+
+	cases := []struct {
+		name   string
+		access *PublishAccess
+		path   string
+		body   string
+		wantOK bool
+	}{
+		{"unscoped account", nil, "/api/query/sql", `{"stmt":"SELECT * FROM blocks"}`, true},
+		{"not an API", access, "/stage/build/app/index.html", ``, true},
+		{"unchecked route", access, "/api/system/getConf", ``, true},
+		{"filtered route", access, "/api/notebook/lsNotebooks", `{}`, true},
+		{"visible notebook", access, "/api/filetree/listDocsByPath", `{"notebook":"` + visible + `","path":"/"}`, true},
+		{"hidden notebook", access, "/api/filetree/listDocsByPath", `{"notebook":"` + hidden + `","path":"/"}`, false},
+		{"notebook missing", access, "/api/filetree/listDocsByPath", `{"path":"/"}`, false},
+		{"invalid block id", access, "/api/filetree/getDoc", `{"id":"../foo"}`, false},
+		{"invalid body", access, "/api/notebook/getNotebookConf", `notebook=` + visible, false},
+		{"unlisted route with visible notebook", access, "/api/filetree/getIDsByHPath", `{"notebook":"` + visible + `","path":"/foo"}`, false},
+		{"raw path route", access, "/api/file/getFile", `{"path":"/data/` + hidden + `/x.sy","notebook":"` + visible + `"}`, false},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+			ctx.Set(publishAccessContextKey, c.access)
+			if ok := checkPublishAccess(ctx); c.wantOK != ok {
+				t.Fatalf("want ok [%t], got [%t]", c.wantOK, ok)
+			}
+			if !c.wantOK && http.StatusForbidden != recorder.Code {
+				t.Fatalf("want status [%d], got [%d]", http.StatusForbidden, recorder.Code)
+			}
+		})
+	}
+}
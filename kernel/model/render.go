@@ -30,6 +30,7 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
 	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -222,7 +223,7 @@ func renderBlockContentByNodes(nodes []*ast.Node) string {
 	return buf.String()
 }
 
-func resolveEmbedR(n *ast.Node, blockEmbedMode int, luteEngine *lute.Lute, resolved *[]string, depth *int) {
+func resolveEmbedR(exportConf *conf.Export, n *ast.Node, blockEmbedMode int, luteEngine *lute.Lute, resolved *[]string, depth *int) {
 	var children []*ast.Node
 	if ast.NodeHeading == n.Type {
 		children = append(children, n)
@@ -271,8 +272,10 @@ func resolveEmbedR(n *ast.Node, blockEmbedMode int, luteEngine *lute.Lute, resol
 						continue
 					}
 
-					subTree, _ := LoadTreeByBlockID(sqlBlock.ID)
-					if nil == subTree {
+					// 嵌入的树会被修改，这里不使用缓存
+					subTree, _ := loadExportTree(exportConf, sqlBlock.ID)
+					if nil == subTree || nil == treenode.GetNodeInTree(subTree, sqlBlock.ID) {
+						// 嵌入的块被导出过滤规则过滤掉了
 						continue
 					}
 
@@ -379,7 +382,7 @@ func resolveEmbedR(n *ast.Node, blockEmbedMode int, luteEngine *lute.Lute, resol
 							return ast.WalkContinue
 						}
 
-						resolveEmbedR(insert, blockEmbedMode, luteEngine, resolved, depth)
+						resolveEmbedR(exportConf, insert, blockEmbedMode, luteEngine, resolved, depth)
 					}
 				}
 				unlinks = append(unlinks, n)
@@ -394,7 +397,7 @@ func resolveEmbedR(n *ast.Node, blockEmbedMode int, luteEngine *lute.Lute, resol
 	return
 }
 
-func renderBlockMarkdownR(id string, rendered *[]string) (ret []*ast.Node) {
+func renderBlockMarkdownR(exportConf *conf.Export, id string, rendered *[]string) (ret []*ast.Node) {
 	if gulu.Str.Contains(id, *rendered) {
 		return
 	}
@@ -407,7 +410,7 @@ func renderBlockMarkdownR(id string, rendered *[]string) (ret []*ast.Node) {
 
 	var err error
 	var t *parse.Tree
-	if t, err = LoadTreeByBlockID(b.ID); err != nil {
+	if t, err = loadExportTree(exportConf, b.ID); err != nil {
 		return
 	}
 	node := treenode.GetNodeInTree(t, b.ID)
@@ -440,7 +443,7 @@ func renderBlockMarkdownR(id string, rendered *[]string) (ret []*ast.Node) {
 				stmt = strings.ReplaceAll(stmt, editor.IALValEscNewLine, "\n")
 				sqlBlocks := sql.SelectBlocksRawStmt(stmt, 1, Conf.Search.Limit)
 				for _, sqlBlock := range sqlBlocks {
-					subNodes := renderBlockMarkdownR(sqlBlock.ID, rendered)
+					subNodes := renderBlockMarkdownR(exportConf, sqlBlock.ID, rendered)
 					for _, subNode := range subNodes {
 						inserts = append(inserts, subNode)
 					}
@@ -555,6 +555,7 @@ func ImportRepoKey(base64Key string) (retKey string, err error) {
 	}
 
 	Conf.Repo.Key = key
+	Conf.Repo.RotatingKey = nil
 	Conf.Save()
 	logging.LogInfof("imported repo key [%x]", sha1.Sum(Conf.Repo.Key))
 
@@ -573,11 +574,13 @@ func ResetRepo() (err error) {
 	logging.LogInfof("resetting data repo...")
 	msgId := util.PushMsg(Conf.Language(144), 1000*60)
 
+	Conf.Repo.RotatingKey = nil // 重置会放弃未完成的密钥轮换
 	repo, err := newRepository()
 	if err != nil {
 		return
 	}
 
+	backupRepoBeforeReset()
 	if err = repo.Reset(); err != nil {
 		logging.LogErrorf("reset data repo failed: %s", err)
 		return
@@ -654,22 +657,14 @@ func InitRepoKeyFromPassphrase(passphrase string) (err error) {
 		return
 	}
 
-	var key []byte
-	base64Data, base64Err := base64.StdEncoding.DecodeString(passphrase)
-	if nil == base64Err && 32 == len(base64Data) {
-		// 改进数据仓库 `通过密码生成密钥` https://github.com/siyuan-note/siyuan/issues/6782
-		logging.LogInfof("passphrase is base64 encoded, use it as key directly")
-		key = base64Data
-	} else {
-		salt := fmt.Sprintf("%x", sha256.Sum256([]byte(passphrase)))[:16]
-		key, err = encryption.KDF(passphrase, salt)
-		if err != nil {
-			logging.LogErrorf("init data repo key failed: %s", err)
-			return
-		}
+	key, err := repoKeyFromPassphrase(passphrase)
+	if err != nil {
+		logging.LogErrorf("init data repo key failed: %s", err)
+		return
 	}
 
 	Conf.Repo.Key = key
+	Conf.Repo.RotatingKey = nil
 	Conf.Save()
 	logging.LogInfof("inited repo key [%x]", sha1.Sum(Conf.Repo.Key))
 
@@ -677,6 +672,20 @@ func InitRepoKeyFromPassphrase(passphrase string) (err error) {
 	return
 }
 
+func repoKeyFromPassphrase(passphrase string) (ret []byte, err error) {
+	base64Data, base64Err := base64.StdEncoding.DecodeString(passphrase)
+	if nil == base64Err && 32 == len(base64Data) {
+		// 改进数据仓库 `通过密码生成密钥` https://github.com/siyuan-note/siyuan/issues/6782
+		logging.LogInfof("passphrase is base64 encoded, use it as key directly")
+		ret = base64Data
+		return
+	}
+
+	salt := fmt.Sprintf("%x", sha256.Sum256([]byte(passphrase)))[:16]
+	ret, err = encryption.KDF(passphrase, salt)
+	return
+}
+
 func InitRepoKey() (err error) {
 	util.PushMsg(Conf.Language(136), 3000)
 
@@ -707,6 +716,7 @@ func InitRepoKey() (err error) {
 		return
 	}
 	Conf.Repo.Key = key
+	Conf.Repo.RotatingKey = nil
 	Conf.Save()
 	logging.LogInfof("inited repo key [%x]", sha1.Sum(Conf.Repo.Key))
 
@@ -803,7 +813,7 @@ func DownloadCloudSnapshot(tag, id string) (err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP, conf.ProviderGit, conf.ProviderLAN:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -845,7 +855,7 @@ func UploadCloudSnapshot(tag, id string) (err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP, conf.ProviderGit, conf.ProviderLAN:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -891,7 +901,7 @@ func RemoveCloudRepoTag(tag string) (err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP, conf.ProviderGit, conf.ProviderLAN:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -923,7 +933,7 @@ func GetCloudRepoTagSnapshots() (ret []*dejavu.Log, err error) {
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP, conf.ProviderGit, conf.ProviderLAN:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -959,7 +969,7 @@ func GetCloudRepoSnapshots(page int) (ret []*dejavu.Log, pageCount, totalCount i
 			util.PushErrMsg(Conf.Language(29), 5000)
 			return
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP, conf.ProviderGit, conf.ProviderLAN:
 		if !IsPaidUser() {
 			util.PushErrMsg(Conf.Language(214), 5000)
 			return
@@ -1142,7 +1152,12 @@ func syncRepoDownload() (err error) {
 
 	logging.LogInfof("downloading data repo [device=%s, kernel=%s, provider=%d, mode=%s/%t]", Conf.System.ID, KernelID, Conf.Sync.Provider, "d", true)
 	start := time.Now()
+	baseIndex := getSyncBaseIndex(repo)
+	revertDownloadOnlyNotebooks(repo)
 	_, _, err = indexRepoBeforeCloudSync(repo)
+	if nil == err {
+		err = indexSyncScope(repo)
+	}
 	if err != nil {
 		planSyncAfter(fixSyncInterval)
 
@@ -1179,13 +1194,14 @@ func syncRepoDownload() (err error) {
 
 	util.PushStatusBar(fmt.Sprintf(Conf.Language(149), elapsed.Seconds()))
 	Conf.Sync.Synced = util.CurrentTimeMillis()
+	recordSyncedIndex(repo)
 	msg := fmt.Sprintf(Conf.Language(150), trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomFloor(uint64(trafficStat.DownloadBytes), 2))
 	Conf.Sync.Stat = msg
 	Conf.Save()
 	autoSyncErrCount = 0
 	BootSyncSucc = 0
 
-	processSyncMergeResult(false, true, mergeResult, trafficStat, "d", elapsed)
+	processSyncMergeResult(false, true, baseIndex, mergeResult, trafficStat, "d", elapsed)
 	return
 }
 
@@ -1213,7 +1229,11 @@ func syncRepoUpload() (err error) {
 
 	logging.LogInfof("uploading data repo [device=%s, kernel=%s, provider=%d, mode=%s/%t]", Conf.System.ID, KernelID, Conf.Sync.Provider, "u", true)
 	start := time.Now()
+	revertDownloadOnlyNotebooks(repo)
 	_, _, err = indexRepoBeforeCloudSync(repo)
+	if nil == err {
+		err = indexSyncScope(repo)
+	}
 	if err != nil {
 		planSyncAfter(fixSyncInterval)
 
@@ -1250,13 +1270,14 @@ func syncRepoUpload() (err error) {
 
 	util.PushStatusBar(fmt.Sprintf(Conf.Language(149), elapsed.Seconds()))
 	Conf.Sync.Synced = util.CurrentTimeMillis()
+	recordSyncedIndex(repo)
 	msg := fmt.Sprintf(Conf.Language(150), trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomCeil(uint64(trafficStat.DownloadBytes), 2))
 	Conf.Sync.Stat = msg
 	Conf.Save()
 	autoSyncErrCount = 0
 	BootSyncSucc = 0
 
-	processSyncMergeResult(false, true, &dejavu.MergeResult{}, trafficStat, "u", elapsed)
+	processSyncMergeResult(false, true, nil, &dejavu.MergeResult{}, trafficStat, "u", elapsed)
 	return
 }
 
@@ -1429,7 +1450,12 @@ func syncRepo(exit, byHand bool) (dataChanged bool, err error) {
 
 	logging.LogInfof("syncing data repo [device=%s, kernel=%s, provider=%d, mode=%s/%t]", Conf.System.ID, KernelID, Conf.Sync.Provider, "a", byHand)
 	start := time.Now()
+	baseIndex := getSyncBaseIndex(repo)
+	revertDownloadOnlyNotebooks(repo)
 	beforeIndex, afterIndex, err := indexRepoBeforeCloudSync(repo)
+	if nil == err {
+		err = indexSyncScope(repo)
+	}
 	if err != nil {
 		autoSyncErrCount++
 		planSyncAfter(fixSyncInterval)
@@ -1480,12 +1506,13 @@ func syncRepo(exit, byHand bool) (dataChanged bool, err error) {
 
 	util.PushStatusBar(fmt.Sprintf(Conf.Language(149), elapsed.Seconds()))
 	Conf.Sync.Synced = util.CurrentTimeMillis()
+	recordSyncedIndex(repo)
 	msg := fmt.Sprintf(Conf.Language(150), trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomCeil(uint64(trafficStat.DownloadBytes), 2))
 	Conf.Sync.Stat = msg
 	Conf.Save()
 	autoSyncErrCount = 0
 
-	processSyncMergeResult(exit, byHand, mergeResult, trafficStat, "a", elapsed)
+	processSyncMergeResult(exit, byHand, baseIndex, mergeResult, trafficStat, "a", elapsed)
 
 	if !exit {
 		go func() {
@@ -1498,22 +1525,37 @@ func syncRepo(exit, byHand bool) (dataChanged bool, err error) {
 	return
 }
 
-func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult, trafficStat *dejavu.TrafficStat, mode string, elapsed time.Duration) {
+func processSyncMergeResult(exit, byHand bool, baseIndex *entity.Index, mergeResult *dejavu.MergeResult, trafficStat *dejavu.TrafficStat, mode string, elapsed time.Duration) {
 	logging.LogInfof("synced data repo [device=%s, kernel=%s, provider=%d, mode=%s/%t, ufc=%d, dfc=%d, ucc=%d, dcc=%d, ub=%s, db=%s] in [%.2fs], merge result [conflicts=%d, upserts=%d, removes=%d]\n\n",
 		Conf.System.ID, KernelID, Conf.Sync.Provider, mode, byHand,
 		trafficStat.UploadFileCount, trafficStat.DownloadFileCount, trafficStat.UploadChunkCount, trafficStat.DownloadChunkCount, humanize.BytesCustomCeil(uint64(trafficStat.UploadBytes), 2), humanize.BytesCustomCeil(uint64(trafficStat.DownloadBytes), 2),
 		elapsed.Seconds(),
 		len(mergeResult.Conflicts), len(mergeResult.Upserts), len(mergeResult.Removes))
+	fireWebhookEvent(WebhookEventSyncCompleted, map[string]interface{}{
+		"mode":          mode,
+		"byHand":        byHand,
+		"elapsed":       elapsed.Milliseconds(),
+		"uploadBytes":   trafficStat.UploadBytes,
+		"downloadBytes": trafficStat.DownloadBytes,
+		"conflicts":     len(mergeResult.Conflicts),
+		"upserts":       len(mergeResult.Upserts),
+		"removes":       len(mergeResult.Removes),
+	})
 
 	//logSyncMergeResult(mergeResult)
 
 	var needReloadFiletree bool
 	if 0 < len(mergeResult.Conflicts) {
 		luteEngine := util.NewLute()
+
+		// 先基于上次同步完成时的快照进行块级合并，块级合并不依赖是否生成冲突副本
+		conflicts := mergeSyncConflicts(baseIndex, mergeResult, luteEngine)
+		needReloadFiletree = len(conflicts) < len(mergeResult.Conflicts)
+
 		if Conf.Sync.GenerateConflictDoc {
 			// 云端同步发生冲突时生成副本 https://github.com/siyuan-note/siyuan/issues/5687
-
-			for _, file := range mergeResult.Conflicts {
+			// 无法合并的文档生成冲突副本
+			for _, file := range conflicts {
 				if !strings.HasSuffix(file.Path, ".sy") {
 					continue
 				}
@@ -1823,12 +1865,32 @@ func indexRepoBeforeCloudSync(repo *dejavu.Repo) (beforeIndex, afterIndex *entit
 }
 
 func newRepository() (ret *dejavu.Repo, err error) {
+	if isRepoKeyRotating() {
+		err = errRepoKeyRotating
+		return
+	}
+
+	cloudRepo, err := newCloudRepo()
+	if err != nil {
+		return
+	}
+
+	ignoreLines := getSyncIgnoreLines()
+	ignoreLines = append(ignoreLines, "/.siyuan/conf.json") // 忽略旧版同步配置
+	ret, err = dejavu.NewRepo(util.DataDir, util.RepoDir, util.HistoryDir, util.TempDir, Conf.System.ID, Conf.System.Name, Conf.System.OS, Conf.Repo.Key, ignoreLines, cloudRepo)
+	if err != nil {
+		logging.LogErrorf("init data repo failed: %s", err)
+		return
+	}
+	return
+}
+
+func newCloudRepo() (cloudRepo cloud.Cloud, err error) {
 	cloudConf, err := buildCloudConf()
 	if err != nil {
 		return
 	}
 
-	var cloudRepo cloud.Cloud
 	switch Conf.Sync.Provider {
 	case conf.ProviderSiYuan:
 		cloudRepo = cloud.NewSiYuan(&cloud.BaseCloud{Conf: cloudConf})
@@ -1847,18 +1909,23 @@ func newRepository() (ret *dejavu.Repo, err error) {
 		cloudRepo = cloud.NewWebDAV(&cloud.BaseCloud{Conf: cloudConf}, webdavClient)
 	case conf.ProviderLocal:
 		cloudRepo = cloud.NewLocal(&cloud.BaseCloud{Conf: cloudConf})
+	case conf.ProviderSFTP:
+		sftpConf := Conf.Sync.SFTP
+		cloudRepo = newMirrorCloud(cloudConf, newSFTPRemote(sftpConf), sftpConf.Username+"@"+sftpConf.Endpoint+":"+sftpConf.Path, sftpConf.Timeout, sftpConf.ConcurrentReqs)
+	case conf.ProviderGit:
+		gitConf := Conf.Sync.Git
+		cloudRepo = newMirrorCloud(cloudConf, newGitRemote(gitConf), gitConf.Endpoint+"#"+gitConf.Branch, gitConf.Timeout, gitConf.ConcurrentReqs)
+	case conf.ProviderLAN:
+		lanConf := Conf.Sync.LAN
+		if isLANSyncHost() {
+			cloudRepo = cloud.NewLocal(&cloud.BaseCloud{Conf: cloudConf})
+		} else {
+			cloudRepo = newMirrorCloud(cloudConf, newLANRemote(lanConf), lanConf.Endpoint, lanConf.Timeout, lanConf.ConcurrentReqs)
+		}
 	default:
 		err = fmt.Errorf("unknown cloud provider [%d]", Conf.Sync.Provider)
 		return
 	}
-
-	ignoreLines := getSyncIgnoreLines()
-	ignoreLines = append(ignoreLines, "/.siyuan/conf.json") // 忽略旧版同步配置
-	ret, err = dejavu.NewRepo(util.DataDir, util.RepoDir, util.HistoryDir, util.TempDir, Conf.System.ID, Conf.System.Name, Conf.System.OS, Conf.Repo.Key, ignoreLines, cloudRepo)
-	if err != nil {
-		logging.LogErrorf("init data repo failed: %s", err)
-		return
-	}
 	return
 }
 
@@ -2137,6 +2204,17 @@ func buildCloudConf() (ret *cloud.Conf, err error) {
 			Timeout:        Conf.Sync.Local.Timeout,
 			ConcurrentReqs: Conf.Sync.Local.ConcurrentReqs,
 		}
+	case conf.ProviderSFTP, conf.ProviderGit:
+		// 使用本地镜像目录，在 newMirrorCloud 中配置
+	case conf.ProviderLAN:
+		if isLANSyncHost() {
+			ret.Local = &cloud.ConfLocal{
+				Endpoint:       lanSyncHostDir(),
+				Timeout:        Conf.Sync.LAN.Timeout,
+				ConcurrentReqs: Conf.Sync.LAN.ConcurrentReqs,
+			}
+		}
+		// 对端使用本地镜像目录，在 newMirrorCloud 中配置
 	default:
 		err = fmt.Errorf("invalid provider [%d]", Conf.Sync.Provider)
 		return
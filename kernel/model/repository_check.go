@@ -0,0 +1,290 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// RepoCheckResult 描述了数据仓库的检查结果。
+//
+// 分块对象的 ID 是分块内容的 SHA-1 摘要，解码后重新计算摘要和 ID 比较即可确定分块是否损坏；
+// 快照和文件对象的 ID 不是内容摘要，通过解码后的 ID 是否一致来校验。
+type RepoCheckResult struct {
+	Indexes         int                   `json:"indexes"`         // 检查的快照数
+	Files           int                   `json:"files"`           // 检查的文件对象数
+	Chunks          int                   `json:"chunks"`          // 检查的分块对象数
+	MissingObjects  []string              `json:"missingObjects"`  // 缺失的对象
+	CorruptObjects  []string              `json:"corruptObjects"`  // 无法读取的对象
+	BrokenSnapshots []*RepoBrokenSnapshot `json:"brokenSnapshots"` // 无法完整恢复的快照
+	Repaired        int                   `json:"repaired"`        // 修复的对象数
+}
+
+type RepoBrokenSnapshot struct {
+	ID      string   `json:"id"`
+	Memo    string   `json:"memo"`
+	Created int64    `json:"created"`
+	Paths   []string `json:"paths"` // 无法恢复的文件，快照本身无法读取时为空
+}
+
+func (result *RepoCheckResult) healthy() bool {
+	return 1 > len(result.MissingObjects) && 1 > len(result.CorruptObjects)
+}
+
+func (result *RepoCheckResult) brokenObjects() (ret map[string]bool) {
+	ret = map[string]bool{}
+	for _, id := range result.MissingObjects {
+		ret[id] = true
+	}
+	for _, id := range result.CorruptObjects {
+		ret[id] = true
+	}
+	return
+}
+
+// CheckRepo 校验数据仓库中所有的快照、文件和分块对象。
+//
+// repair 为 true 时尝试修复：先将损坏的对象移到隔离目录，然后从工作空间重新索引、从云端下载快照补齐缺失的对象，
+// 仍然无法修复的对象会被移回原处，修复过程不会让数据仓库变得更糟。
+func CheckRepo(repair bool) (ret *RepoCheckResult, err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+
+	lockSync()
+	defer unlockSync()
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	util.PushEndlessProgress("Checking data repo...")
+	defer util.PushClearProgress()
+
+	start := time.Now()
+	ret = checkRepo(repo)
+	logging.LogInfof("checked data repo [indexes=%d, files=%d, chunks=%d, missing=%d, corrupt=%d, broken=%d] in [%.2fs]",
+		ret.Indexes, ret.Files, ret.Chunks, len(ret.MissingObjects), len(ret.CorruptObjects), len(ret.BrokenSnapshots), time.Since(start).Seconds())
+	if !repair || ret.healthy() {
+		return
+	}
+
+	before := ret.brokenObjects()
+	repairRepo(repo, ret)
+	ret = checkRepo(repo)
+	after := ret.brokenObjects()
+	for id := range before {
+		if !after[id] {
+			ret.Repaired++
+		}
+	}
+	logging.LogInfof("repaired [%d] objects of data repo, [%d] objects still broken", ret.Repaired, len(after))
+	return
+}
+
+func checkRepo(repo *dejavu.Repo) (ret *RepoCheckResult) {
+	ret = &RepoCheckResult{MissingObjects: []string{}, CorruptObjects: []string{}, BrokenSnapshots: []*RepoBrokenSnapshot{}}
+
+	indexIDs := listRepoIndexIDs()
+	brokenFiles := map[string]string{} // 文件 ID -> 路径，路径未知时为文件 ID
+	checkedFiles := map[string]bool{}
+	checkedChunks := map[string]bool{}
+	missing, corrupt := map[string]bool{}, map[string]bool{}
+	for i, indexID := range indexIDs {
+		if 0 == i%16 {
+			util.PushStatusBar(fmt.Sprintf("Checking data repo snapshots [%d/%d]", i+1, len(indexIDs)))
+		}
+
+		index, err := repo.GetIndex(indexID)
+		if nil != err || index.ID != indexID {
+			logging.LogErrorf("check index [%s] failed: %v", indexID, err)
+			corrupt[indexID] = true
+			ret.BrokenSnapshots = append(ret.BrokenSnapshots, &RepoBrokenSnapshot{ID: indexID, Paths: []string{}})
+			continue
+		}
+		ret.Indexes++
+
+		var brokenPaths []string
+		for _, fileID := range index.Files {
+			if !checkedFiles[fileID] {
+				checkedFiles[fileID] = true
+				if p, broken := checkRepoFile(repo, fileID, checkedChunks, missing, corrupt); broken {
+					brokenFiles[fileID] = p
+				}
+			}
+			if p, broken := brokenFiles[fileID]; broken {
+				brokenPaths = append(brokenPaths, p)
+			}
+		}
+		if 0 < len(brokenPaths) {
+			sort.Strings(brokenPaths)
+			ret.BrokenSnapshots = append(ret.BrokenSnapshots, &RepoBrokenSnapshot{ID: index.ID, Memo: index.Memo, Created: index.Created, Paths: brokenPaths})
+		}
+	}
+
+	ret.Files = len(checkedFiles)
+	ret.Chunks = len(checkedChunks)
+	for id := range missing {
+		ret.MissingObjects = append(ret.MissingObjects, id)
+	}
+	for id := range corrupt {
+		ret.CorruptObjects = append(ret.CorruptObjects, id)
+	}
+	sort.Strings(ret.MissingObjects)
+	sort.Strings(ret.CorruptObjects)
+	return
+}
+
+func checkRepoFile(repo *dejavu.Repo, fileID string, checkedChunks, missing, corrupt map[string]bool) (p string, broken bool) {
+	p = fileID
+	if !gulu.File.IsExist(repoObjectPath(fileID)) {
+		missing[fileID] = true
+		return p, true
+	}
+
+	file, err := repo.GetFile(fileID)
+	if nil != err || file.ID != fileID {
+		logging.LogErrorf("check file [%s] failed: %v", fileID, err)
+		corrupt[fileID] = true
+		return p, true
+	}
+	p = file.Path
+
+	for _, chunkID := range file.Chunks {
+		if !checkedChunks[chunkID] {
+			checkedChunks[chunkID] = true
+			checkRepoChunk(repo, chunkID, missing, corrupt)
+		}
+		if missing[chunkID] || corrupt[chunkID] {
+			broken = true
+		}
+	}
+	return
+}
+
+// checkRepoChunk 解码分块后重新计算摘要，和分块 ID 不一致时说明分块已经损坏。
+func checkRepoChunk(repo *dejavu.Repo, chunkID string, missing, corrupt map[string]bool) {
+	if !gulu.File.IsExist(repoObjectPath(chunkID)) {
+		missing[chunkID] = true
+		return
+	}
+
+	// 读取只包含该分块的文件，由数据仓库负责解密和解压
+	data, err := repo.OpenFile(&entity.File{Chunks: []string{chunkID}})
+	if err != nil {
+		logging.LogErrorf("read chunk [%s] failed: %s", chunkID, err)
+		corrupt[chunkID] = true
+		return
+	}
+	if hash := fmt.Sprintf("%x", sha1.Sum(data)); hash != chunkID {
+		logging.LogErrorf("chunk [%s] hash mismatch [%s]", chunkID, hash)
+		corrupt[chunkID] = true
+	}
+}
+
+func repairRepo(repo *dejavu.Repo, result *RepoCheckResult) {
+	// 隔离目录放在数据仓库目录下，和数据仓库位于同一个文件系统上，移动对象不需要复制，临时目录被清理时也不会丢失
+	quarantineDir := filepath.Join(Conf.Repo.GetSaveDir(), "quarantine", time.Now().Format("20060102150405"))
+	quarantined := map[string]string{} // 隔离后的路径 -> 原路径
+	quarantine := func(src string) {
+		if !gulu.File.IsExist(src) {
+			return
+		}
+		rel, _ := filepath.Rel(Conf.Repo.GetSaveDir(), src)
+		dst := filepath.Join(quarantineDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			logging.LogErrorf("create quarantine dir failed: %s", err)
+			return
+		}
+		if err := os.Rename(src, dst); err != nil {
+			logging.LogErrorf("quarantine [%s] failed: %s", src, err)
+			return
+		}
+		quarantined[dst] = src
+	}
+
+	for _, id := range result.CorruptObjects {
+		quarantine(repoObjectPath(id))
+		quarantine(repoIndexPath(id))
+	}
+
+	// 工作空间中未修改的文件会重新生成缺失的分块
+	FlushTxQueue()
+	if _, err := repo.Index("[Repair] Check data repo", true, map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar}); err != nil {
+		logging.LogErrorf("index data repo for repair failed: %s", err)
+	}
+
+	// 从云端下载损坏的快照，已经存在的对象不会重复下载
+	if Conf.Sync.Enabled && isProviderOnline(true) {
+		for _, snapshot := range result.BrokenSnapshots {
+			_, _, _, err := repo.DownloadIndex(snapshot.ID, map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar})
+			if err != nil {
+				logging.LogWarnf("download snapshot [%s] from cloud for repair failed: %s", snapshot.ID, err)
+			}
+		}
+	}
+
+	// 仍然缺失的对象移回原处，保留原状
+	for dst, src := range quarantined {
+		if gulu.File.IsExist(src) {
+			continue
+		}
+		if err := os.Rename(dst, src); err != nil {
+			logging.LogErrorf("restore quarantined [%s] failed: %s", dst, err)
+		}
+	}
+}
+
+func listRepoIndexIDs() (ret []string) {
+	entries, err := os.ReadDir(filepath.Join(Conf.Repo.GetSaveDir(), "indexes"))
+	if err != nil {
+		logging.LogErrorf("read data repo indexes failed: %s", err)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			ret = append(ret, entry.Name())
+		}
+	}
+	return
+}
+
+func repoObjectPath(id string) string {
+	if 3 > len(id) {
+		return filepath.Join(Conf.Repo.GetSaveDir(), "objects", id)
+	}
+	return filepath.Join(Conf.Repo.GetSaveDir(), "objects", id[:2], id[2:])
+}
+
+func repoIndexPath(id string) string {
+	return filepath.Join(Conf.Repo.GetSaveDir(), "indexes", id)
+}
@@ -0,0 +1,146 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestCheckRepo(t *testing.T) {
+	cases := []struct {
+		name         string
+		damage       string // corrupt 为损坏分块，remove 为删除分块，为空时不破坏
+		editData     bool   // 修复前修改工作空间中的文件，分块无法重新生成
+		wantRepaired bool
+	}{
+		{name: "healthy"},
+		{name: "corrupt chunk is repaired from workspace", damage: "corrupt", wantRepaired: true},
+		{name: "missing chunk is repaired from workspace", damage: "remove", wantRepaired: true},
+		{name: "unrepairable chunk is restored from quarantine", damage: "corrupt", editData: true},
+	}
+
+	for _, c := range cases {
+		repo, dataDir := setupCheckTestRepo(t)
+		dataPath := filepath.Join(dataDir, "test.txt")
+		if err := os.WriteFile(dataPath, []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		index, err := repo.Index("test", false, map[string]interface{}{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := repo.GetFile(index.Files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunkID := file.Chunks[0]
+		chunkPath := repoObjectPath(chunkID)
+
+		switch c.damage {
+		case "corrupt":
+			err = os.WriteFile(chunkPath, []byte("broken"), 0644)
+		case "remove":
+			err = os.Remove(chunkPath)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := checkRepo(repo)
+		if 1 != result.Indexes || 1 != result.Files || 1 != result.Chunks {
+			t.Fatalf("%s: checked [indexes=%d, files=%d, chunks=%d]", c.name, result.Indexes, result.Files, result.Chunks)
+		}
+		if "" == c.damage {
+			if !result.healthy() || 0 < len(result.BrokenSnapshots) {
+				t.Fatalf("%s: want healthy, got %+v", c.name, result)
+			}
+			continue
+		}
+
+		broken := result.CorruptObjects
+		if "remove" == c.damage {
+			broken = result.MissingObjects
+		}
+		if 1 != len(broken) || chunkID != broken[0] {
+			t.Fatalf("%s: broken objects: want [%s], got %v", c.name, chunkID, broken)
+		}
+		if 1 != len(result.BrokenSnapshots) || index.ID != result.BrokenSnapshots[0].ID || "/test.txt" != strings.Join(result.BrokenSnapshots[0].Paths, ",") {
+			t.Fatalf("%s: broken snapshots: got %+v", c.name, result.BrokenSnapshots)
+		}
+
+		if c.editData {
+			if err = os.WriteFile(dataPath, []byte("changed"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		repairRepo(repo, result)
+		if after := checkRepo(repo); c.wantRepaired != after.healthy() {
+			t.Fatalf("%s: repaired: want [%t], got %+v", c.name, c.wantRepaired, after)
+		}
+
+		// 无法修复的对象从隔离目录移回原处
+		if !c.wantRepaired {
+			data, readErr := os.ReadFile(chunkPath)
+			if nil != readErr || "broken" != string(data) {
+				t.Fatalf("%s: quarantined chunk is not restored: [%s], [%v]", c.name, data, readErr)
+			}
+			if quarantined := countCheckTestFiles(filepath.Join(Conf.Repo.GetSaveDir(), "quarantine")); 0 != quarantined {
+				t.Fatalf("%s: quarantine files: want [0], got [%d]", c.name, quarantined)
+			}
+		}
+	}
+}
+
+// setupCheckTestRepo 在临时工作空间中创建数据仓库。
+func setupCheckTestRepo(t *testing.T) (repo *dejavu.Repo, dataDir string) {
+	oldConf, oldWorkspaceDir := Conf, util.WorkspaceDir
+	Conf = &AppConf{
+		System: &conf.System{ID: "test-device", Name: "test", OS: "linux"},
+		Repo:   &conf.Repo{Key: make([]byte, 32)},
+		Sync:   &conf.Sync{},
+	}
+	util.WorkspaceDir = t.TempDir()
+	t.Cleanup(func() { Conf, util.WorkspaceDir = oldConf, oldWorkspaceDir })
+
+	dataDir = filepath.Join(util.WorkspaceDir, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := dejavu.NewRepo(dataDir, Conf.Repo.GetSaveDir(), filepath.Join(util.WorkspaceDir, "history"), filepath.Join(util.WorkspaceDir, "temp"),
+		Conf.System.ID, Conf.System.Name, Conf.System.OS, Conf.Repo.Key, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func countCheckTestFiles(dir string) (ret int) {
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if nil == err && !d.IsDir() {
+			ret++
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,474 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	BlockDiffInsert = "insert"
+	BlockDiffDelete = "delete"
+	BlockDiffMove   = "move"
+	BlockDiffUpdate = "update"
+
+	InlineDiffEqual  = "equal"
+	InlineDiffInsert = "insert"
+	InlineDiffDelete = "delete"
+)
+
+type DocBlockDiff struct {
+	Path   string       `json:"path"`
+	RootID string       `json:"rootID"`
+	Title  string       `json:"title"`
+	Blocks []*BlockDiff `json:"blocks"`
+}
+
+type BlockDiff struct {
+	ID       string        `json:"id"`
+	Type     string        `json:"type"`
+	Op       string        `json:"op"`
+	ParentID string        `json:"parentID"` // 块在右侧所处的父块，删除时为左侧的父块
+	Left     string        `json:"left"`     // 左侧 Markdown，容器块为块属性
+	Right    string        `json:"right"`    // 右侧 Markdown，容器块为块属性
+	Inlines  []*InlineDiff `json:"inlines"`  // 词级内容差异
+	HTML     string        `json:"html"`     // 渲染后的差异视图
+}
+
+type InlineDiff struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffRepoSnapshotBlocks 按块比较两个快照中的文档，right 为空时和当前工作空间比较。paths 为空时比较所有发生变化的文档。
+func DiffRepoSnapshotBlocks(left, right string, paths []string) (ret []*DocBlockDiff, err error) {
+	ret = []*DocBlockDiff{}
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	leftFiles, err := getSnapshotDocFiles(repo, left)
+	if err != nil {
+		return
+	}
+	var rightFiles map[string]*entity.File
+	if "" != right {
+		if rightFiles, err = getSnapshotDocFiles(repo, right); err != nil {
+			return
+		}
+	} else {
+		rightFiles = getWorkspaceDocFiles()
+	}
+
+	if 1 > len(paths) {
+		for p, l := range leftFiles {
+			if r := rightFiles[p]; nil == r || !isSameSnapshotFile(l, r) {
+				paths = append(paths, p)
+			}
+		}
+		for p := range rightFiles {
+			if nil == leftFiles[p] {
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	luteEngine := util.NewLute()
+	for _, p := range paths {
+		leftTree, loadErr := loadSnapshotDocTree(repo, leftFiles[p], luteEngine)
+		if nil != loadErr {
+			err = loadErr
+			return
+		}
+		rightTree, loadErr := loadSnapshotDocTree(repo, rightFiles[p], luteEngine)
+		if nil != loadErr {
+			err = loadErr
+			return
+		}
+		if nil == leftTree && nil == rightTree {
+			continue
+		}
+
+		doc := &DocBlockDiff{Path: p}
+		for _, tree := range []*parse.Tree{rightTree, leftTree} {
+			if nil != tree {
+				doc.RootID, doc.Title = tree.Root.ID, tree.Root.IALAttr("title")
+				break
+			}
+		}
+		doc.Blocks = diffTreeBlocks(leftTree, rightTree, luteEngine)
+		if 0 < len(doc.Blocks) {
+			ret = append(ret, doc)
+		}
+	}
+	return
+}
+
+func getSnapshotDocFiles(repo *dejavu.Repo, id string) (ret map[string]*entity.File, err error) {
+	index, err := repo.GetIndex(id)
+	if err != nil {
+		return
+	}
+	files, err := repo.GetFiles(index)
+	if err != nil {
+		return
+	}
+
+	ret = map[string]*entity.File{}
+	for _, f := range files {
+		if strings.HasSuffix(f.Path, ".sy") {
+			ret[f.Path] = f
+		}
+	}
+	return
+}
+
+// getWorkspaceDocFiles 返回当前工作空间中的文档，ID 为空表示该文件不在快照中。
+func getWorkspaceDocFiles() (ret map[string]*entity.File) {
+	ret = map[string]*entity.File{}
+	filepath.WalkDir(util.DataDir, func(absPath string, d fs.DirEntry, err error) error {
+		if nil != err {
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") || (filepath.Dir(absPath) == util.DataDir && !ast.IsNodeIDPattern(d.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".sy") {
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if nil != infoErr {
+			return nil
+		}
+		rel, _ := filepath.Rel(util.DataDir, absPath)
+		p := "/" + filepath.ToSlash(rel)
+		ret[p] = &entity.File{Path: p, Size: info.Size(), Updated: info.ModTime().UnixMilli()}
+		return nil
+	})
+	return
+}
+
+func isSameSnapshotFile(left, right *entity.File) bool {
+	if "" != left.ID && "" != right.ID {
+		return left.ID == right.ID
+	}
+	return left.Size == right.Size && left.Updated == right.Updated
+}
+
+func loadSnapshotDocTree(repo *dejavu.Repo, file *entity.File, luteEngine *lute.Lute) (ret *parse.Tree, err error) {
+	if nil == file {
+		return
+	}
+
+	var data []byte
+	if "" == file.ID {
+		data, err = filelock.ReadFile(filepath.Join(util.DataDir, filepath.FromSlash(file.Path)))
+	} else {
+		data, err = repo.OpenFile(file)
+	}
+	if err != nil {
+		return
+	}
+	return filesys.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
+}
+
+// diffTreeBlocks 按照块 ID 对齐两棵树，找出新增、删除、移动和修改的块。
+func diffTreeBlocks(left, right *parse.Tree, luteEngine *lute.Lute) (ret []*BlockDiff) {
+	leftBlocks, rightBlocks := map[string]*syncMergeBlock{}, map[string]*syncMergeBlock{}
+	if nil != left {
+		leftBlocks = indexSyncMergeBlocks(left, luteEngine)
+	}
+	if nil != right {
+		rightBlocks = indexSyncMergeBlocks(right, luteEngine)
+	}
+
+	moved := diffMovedBlocks(leftBlocks, rightBlocks)
+	if nil != right {
+		ast.Walk(right.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || !n.IsBlock() || "" == n.ID || ast.NodeDocument == n.Type {
+				return ast.WalkContinue
+			}
+
+			r := rightBlocks[n.ID]
+			l := leftBlocks[n.ID]
+			if nil == l {
+				ret = append(ret, newBlockDiff(BlockDiffInsert, nil, n, r.parentID, luteEngine))
+				return ast.WalkSkipChildren // 子块随父块一起新增
+			}
+			if moved[n.ID] {
+				ret = append(ret, newBlockDiff(BlockDiffMove, l.node, n, r.parentID, luteEngine))
+			}
+			if l.content != r.content {
+				ret = append(ret, newBlockDiff(BlockDiffUpdate, l.node, n, r.parentID, luteEngine))
+			}
+			return ast.WalkContinue
+		})
+	}
+
+	if nil != left {
+		ast.Walk(left.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || !n.IsBlock() || "" == n.ID || ast.NodeDocument == n.Type {
+				return ast.WalkContinue
+			}
+
+			if nil == rightBlocks[n.ID] {
+				ret = append(ret, newBlockDiff(BlockDiffDelete, n, nil, leftBlocks[n.ID].parentID, luteEngine))
+				return ast.WalkSkipChildren // 子块随父块一起删除
+			}
+			return ast.WalkContinue
+		})
+	}
+	return
+}
+
+// diffMovedBlocks 找出移动过的块：父块发生变化，或者在同一父块下和其他块的相对顺序发生变化。
+func diffMovedBlocks(leftBlocks, rightBlocks map[string]*syncMergeBlock) (ret map[string]bool) {
+	ret = map[string]bool{}
+	leftChildren := map[string][]string{}
+	for id, r := range rightBlocks {
+		l := leftBlocks[id]
+		if nil == l || ast.NodeDocument == r.node.Type {
+			continue
+		}
+		if l.parentID != r.parentID {
+			ret[id] = true
+		}
+	}
+
+	siblingIDs := func(blocks map[string]*syncMergeBlock, parentID string, others map[string]*syncMergeBlock) (ids []string) {
+		parent := blocks[parentID]
+		if nil == parent {
+			return
+		}
+		for c := parent.node.FirstChild; nil != c; c = c.Next {
+			if o := others[c.ID]; "" != c.ID && nil != o && o.parentID == parentID {
+				ids = append(ids, c.ID)
+			}
+		}
+		return
+	}
+
+	for id, l := range leftBlocks {
+		if nil != rightBlocks[id] && nil != l.node.FirstChild {
+			leftChildren[id] = siblingIDs(leftBlocks, id, rightBlocks)
+		}
+	}
+	rightChildren := map[string][]string{}
+	cells := 0
+	for parentID, leftIDs := range leftChildren {
+		rightChildren[parentID] = siblingIDs(rightBlocks, parentID, leftBlocks)
+		cells += len(leftIDs) * len(rightChildren[parentID])
+	}
+	if isLCSTooLarge(cells, 1) {
+		// 子块过多时不再比较相对顺序，只识别父块发生变化的移动
+		return
+	}
+
+	for parentID, leftIDs := range leftChildren {
+		rightIDs := rightChildren[parentID]
+		kept := map[string]bool{}
+		for _, i := range lcsIndexes(leftIDs, rightIDs) {
+			kept[leftIDs[i]] = true
+		}
+		for _, id := range rightIDs {
+			if !kept[id] {
+				ret[id] = true
+			}
+		}
+	}
+	return
+}
+
+func newBlockDiff(op string, left, right *ast.Node, parentID string, luteEngine *lute.Lute) (ret *BlockDiff) {
+	n := right
+	if nil == n {
+		n = left
+	}
+	ret = &BlockDiff{ID: n.ID, Type: n.Type.String(), Op: op, ParentID: parentID}
+	if nil != left {
+		ret.Left = blockDiffText(left, BlockDiffUpdate == op, luteEngine)
+	}
+	if nil != right {
+		ret.Right = blockDiffText(right, BlockDiffUpdate == op, luteEngine)
+	}
+
+	switch op {
+	case BlockDiffInsert:
+		ret.Inlines = []*InlineDiff{{Op: InlineDiffInsert, Text: ret.Right}}
+	case BlockDiffDelete:
+		ret.Inlines = []*InlineDiff{{Op: InlineDiffDelete, Text: ret.Left}}
+	case BlockDiffUpdate:
+		ret.Inlines = diffWords(ret.Left, ret.Right)
+	default:
+		ret.Inlines = []*InlineDiff{{Op: InlineDiffEqual, Text: ret.Right}}
+	}
+	ret.HTML = renderInlineDiffs(ret.Inlines)
+	return
+}
+
+// blockDiffText 返回块的 Markdown，修改比较时容器块只比较块属性（子块单独比较）。
+func blockDiffText(n *ast.Node, self bool, luteEngine *lute.Lute) string {
+	if self && n.IsContainerBlock() {
+		var attrs []string
+		for _, kv := range n.KramdownIAL {
+			if "updated" != kv[0] {
+				attrs = append(attrs, kv[0]+"=\""+kv[1]+"\"")
+			}
+		}
+		sort.Strings(attrs)
+		return strings.Join(attrs, " ")
+	}
+	return strings.TrimSpace(treenode.ExportNodeStdMd(n, luteEngine))
+}
+
+var diffWordRegexp = regexp.MustCompile(`[\p{Han}\p{Hiragana}\p{Katakana}\p{Hangul}]|[\p{L}\p{N}_]+|\s+|.`)
+
+// diffWords 计算两段文本的词级差异，中日韩文字按字比较。
+func diffWords(left, right string) (ret []*InlineDiff) {
+	leftWords := diffWordRegexp.FindAllString(left, -1)
+	rightWords := diffWordRegexp.FindAllString(right, -1)
+
+	appendDiff := func(op, text string) {
+		if last := len(ret) - 1; 0 <= last && ret[last].Op == op {
+			ret[last].Text += text
+			return
+		}
+		ret = append(ret, &InlineDiff{Op: op, Text: text})
+	}
+
+	// 文本过长时不再逐词比较
+	if isLCSTooLarge(len(leftWords), len(rightWords)) {
+		appendDiff(InlineDiffDelete, left)
+		appendDiff(InlineDiffInsert, right)
+		return
+	}
+
+	i, j := 0, 0
+	for _, k := range lcsPairs(leftWords, rightWords) {
+		for ; i < k[0]; i++ {
+			appendDiff(InlineDiffDelete, leftWords[i])
+		}
+		for ; j < k[1]; j++ {
+			appendDiff(InlineDiffInsert, rightWords[j])
+		}
+		appendDiff(InlineDiffEqual, leftWords[i])
+		i, j = i+1, j+1
+	}
+	for ; i < len(leftWords); i++ {
+		appendDiff(InlineDiffDelete, leftWords[i])
+	}
+	for ; j < len(rightWords); j++ {
+		appendDiff(InlineDiffInsert, rightWords[j])
+	}
+	return
+}
+
+func renderInlineDiffs(diffs []*InlineDiff) string {
+	buf := bytes.Buffer{}
+	for _, d := range diffs {
+		text := html.EscapeString(d.Text)
+		switch d.Op {
+		case InlineDiffInsert:
+			buf.WriteString("<ins>" + text + "</ins>")
+		case InlineDiffDelete:
+			buf.WriteString("<del>" + text + "</del>")
+		default:
+			buf.WriteString(text)
+		}
+	}
+	return buf.String()
+}
+
+// lcsIndexes 返回最长公共子序列在 a 中的下标。
+func lcsIndexes(a, b []string) (ret []int) {
+	for _, p := range lcsPairs(a, b) {
+		ret = append(ret, p[0])
+	}
+	return
+}
+
+// maxLCSCells 是最长公共子序列动态规划表的最大单元格数，超过时调用方需要退化为不比较顺序的差异。
+const maxLCSCells = 1024 * 1024 * 4
+
+func isLCSTooLarge(n, m int) bool {
+	return maxLCSCells < n*m
+}
+
+// lcsPairs 返回最长公共子序列在 a 和 b 中的下标对，规模超过 maxLCSCells 时返回空。
+func lcsPairs(a, b []string) (ret [][2]int) {
+	n, m := len(a), len(b)
+	if 1 > n || 1 > m || isLCSTooLarge(n, m) {
+		return
+	}
+
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; 0 <= i; i-- {
+		for j := m - 1; 0 <= j; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < n && j < m; {
+		if a[i] == b[j] {
+			ret = append(ret, [2]int{i, j})
+			i++
+			j++
+		} else if dp[i+1][j] >= dp[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return
+}
@@ -0,0 +1,149 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestLCSPairs(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []string
+		want [][2]int
+	}{
+		{"empty", nil, []string{"a"}, nil},
+		{"same", []string{"a", "b"}, []string{"a", "b"}, [][2]int{{0, 0}, {1, 1}}},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, [][2]int{{0, 0}, {1, 2}}},
+		{"disjoint", []string{"a"}, []string{"b"}, nil},
+		{"too large", make([]string, 2100), make([]string, 2100), nil},
+	}
+
+	for _, c := range cases {
+		if got := lcsPairs(c.a, c.b); !reflect.DeepEqual(c.want, got) {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	long := strings.Repeat("a ", 2100)
+	cases := []struct {
+		name        string
+		left, right string
+		want        []*InlineDiff
+	}{
+		{
+			name:  "insert word",
+			left:  "hello world",
+			right: "hello there world",
+			want:  []*InlineDiff{{Op: InlineDiffEqual, Text: "hello "}, {Op: InlineDiffInsert, Text: "there "}, {Op: InlineDiffEqual, Text: "world"}},
+		},
+		{
+			name:  "cjk by character",
+			left:  "你好",
+			right: "你们好",
+			want:  []*InlineDiff{{Op: InlineDiffEqual, Text: "你"}, {Op: InlineDiffInsert, Text: "们"}, {Op: InlineDiffEqual, Text: "好"}},
+		},
+		{
+			name:  "too large",
+			left:  long,
+			right: long + "b",
+			want:  []*InlineDiff{{Op: InlineDiffDelete, Text: long}, {Op: InlineDiffInsert, Text: long + "b"}},
+		},
+	}
+
+	for _, c := range cases {
+		if got := diffWords(c.left, c.right); !reflect.DeepEqual(c.want, got) {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestDiffMovedBlocks(t *testing.T) {
+	cases := []struct {
+		name        string
+		left, right []int
+		want        []string
+	}{
+		{
+			name:  "move to front",
+			left:  []int{1, 2, 3},
+			right: []int{3, 1, 2},
+			want:  []string{diffTestBlockID(3)},
+		},
+		{
+			name:  "unchanged",
+			left:  []int{1, 2, 3},
+			right: []int{1, 2, 3},
+		},
+		{
+			// 超过上限时不再比较顺序
+			name:  "too many blocks",
+			left:  diffTestRange(2100, false),
+			right: diffTestRange(2100, true),
+		},
+	}
+
+	luteEngine := util.NewLute()
+	for _, c := range cases {
+		left := parseDiffTestTree(c.left)
+		right := parseDiffTestTree(c.right)
+		moved := diffMovedBlocks(indexSyncMergeBlocks(left, luteEngine), indexSyncMergeBlocks(right, luteEngine))
+
+		var got []string
+		for id := range moved {
+			got = append(got, id)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(c.want, got) {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func parseDiffTestTree(blocks []int) (ret *parse.Tree) {
+	buf := strings.Builder{}
+	for _, i := range blocks {
+		buf.WriteString(fmt.Sprintf("p%d\n{: id=\"%s\"}\n\n", i, diffTestBlockID(i)))
+	}
+	ret = parseSyncMergeTestTree(buf.String(), util.NewLute().ParseOptions)
+	ret.Root.ID = "20240101000000-docdocd"
+	return
+}
+
+func diffTestBlockID(i int) string {
+	return fmt.Sprintf("20240101000000-%07d", i)
+}
+
+func diffTestRange(n int, reverse bool) (ret []int) {
+	for i := 0; i < n; i++ {
+		if reverse {
+			ret = append(ret, n-i)
+		} else {
+			ret = append(ret, i+1)
+		}
+	}
+	return
+}
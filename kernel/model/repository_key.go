@@ -0,0 +1,462 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/encryption"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var (
+	errRepoKeyRotating      = errors.New("data repo key rotation is in progress, please rotate the key again to resume it")
+	errRepoKeyCorruptObject = errors.New("some objects in the local data repo are corrupted, please check and repair the data repo before rotating the key")
+)
+
+// repoKeyRotationConcurrentReqs 轮换密钥时上传云端对象的并发数。
+const repoKeyRotationConcurrentReqs = 8
+
+func isRepoKeyRotating() bool {
+	return 0 < len(Conf.Repo.RotatingKey)
+}
+
+// RotateRepoKey 使用新密钥重新加密本地和云端数据仓库中的所有对象，快照历史保持不变。
+//
+// 需要开启同步，轮换期间持有云端锁。passphrase 为空时生成随机密钥。轮换中断后再次调用会忽略 passphrase 并继续之前的轮换，轮换完成前数据仓库和同步不可用。
+// 其他设备需要在轮换完成后导入新密钥。
+func RotateRepoKey(passphrase string) (ret string, err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+	if !Conf.Sync.Enabled {
+		// 关闭同步时无法重新加密云端数据仓库，之后开启同步时云端对象仍然是旧密钥加密的，所以拒绝轮换
+		err = errors.New("please enable sync before rotating the data repo key so that the cloud data repo can be re-encrypted")
+		return
+	}
+	if !isProviderOnline(true) {
+		err = errors.New(Conf.Language(28))
+		return
+	}
+
+	lockSync()
+	defer unlockSync()
+	defer util.PushClearProgress()
+
+	// 整个轮换期间持有云端锁，避免其他设备在下载快照之后上传新的快照，或者读取到新旧密钥混合的数据
+	cloudRepo, err := newCloudRepo()
+	if err != nil {
+		return
+	}
+	unlockCloud, err := lockCloudRepo(cloudRepo)
+	if err != nil {
+		return
+	}
+	defer unlockCloud()
+
+	resuming := isRepoKeyRotating()
+	if !resuming {
+		var newKey []byte
+		if newKey, err = newRotatingRepoKey(passphrase); err != nil {
+			return
+		}
+		if err = prepareRepoKeyRotation(); err != nil {
+			return
+		}
+
+		Conf.Repo.RotatingKey = newKey
+		Conf.Save()
+		logging.LogInfof("rotating repo key [%x] to [%x]", sha1.Sum(Conf.Repo.Key), sha1.Sum(Conf.Repo.RotatingKey))
+	} else {
+		logging.LogInfof("resuming rotating repo key [%x] to [%x]", sha1.Sum(Conf.Repo.Key), sha1.Sum(Conf.Repo.RotatingKey))
+	}
+
+	if err = rotateLocalRepoKey(); err != nil {
+		if errors.Is(err, errRepoKeyCorruptObject) && !resuming {
+			// 还没有重新加密任何对象，取消本次轮换
+			Conf.Repo.RotatingKey = nil
+			Conf.Save()
+		}
+		return
+	}
+	if err = rotateCloudRepoKey(cloudRepo); err != nil {
+		return
+	}
+
+	Conf.Repo.Key = Conf.Repo.RotatingKey
+	Conf.Repo.RotatingKey = nil
+	Conf.Save()
+	if err = os.RemoveAll(repoKeyRotationDir()); err != nil {
+		logging.LogWarnf("remove repo key rotation journal failed: %s", err)
+		err = nil
+	}
+	logging.LogInfof("rotated repo key [%x]", sha1.Sum(Conf.Repo.Key))
+
+	ret = base64.StdEncoding.EncodeToString(Conf.Repo.Key)
+	return
+}
+
+func newRotatingRepoKey(passphrase string) (ret []byte, err error) {
+	passphrase = gulu.Str.RemoveInvisible(passphrase)
+	passphrase = strings.TrimSpace(passphrase)
+	if "" == passphrase {
+		ret = make([]byte, 32)
+		_, err = rand.Read(ret)
+		return
+	}
+
+	if ret, err = repoKeyFromPassphrase(passphrase); err != nil {
+		return
+	}
+	if bytes.Equal(ret, Conf.Repo.Key) {
+		err = errors.New("the new key is the same as the current key")
+	}
+	return
+}
+
+// prepareRepoKeyRotation 在轮换前下载云端的所有快照，并记录需要在云端重新加密的对象。
+//
+// 轮换后旧密钥加密的对象将无法读取，所以本地缺失的云端对象必须先下载下来。
+func prepareRepoKeyRotation() (err error) {
+	if err = os.RemoveAll(repoKeyRotationDir()); err != nil {
+		return
+	}
+	if err = os.MkdirAll(repoKeyRotationDir(), 0755); err != nil {
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	util.PushEndlessProgress("Downloading cloud snapshots before rotating data repo key...")
+	indexIDs := map[string]bool{}
+	for page := 1; ; page++ {
+		logs, pageCount, _, getErr := repo.GetCloudRepoLogs(page)
+		if nil != getErr {
+			return getErr
+		}
+		for _, l := range logs {
+			indexIDs[l.ID] = true
+		}
+		if page >= pageCount {
+			break
+		}
+	}
+	tagLogs, err := repo.GetCloudRepoTagLogs(map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar})
+	if err != nil {
+		return
+	}
+	for _, l := range tagLogs {
+		indexIDs[l.ID] = true
+	}
+
+	rels := map[string]bool{}
+	i := 0
+	for indexID := range indexIDs {
+		i++
+		util.PushProgress(util.PushProgressCodeProgressed, i, len(indexIDs), fmt.Sprintf("Downloading cloud snapshots [%d/%d]", i, len(indexIDs)))
+		if err = collectCloudRepoObjects(repo, indexID, rels); err != nil {
+			logging.LogErrorf("download cloud snapshot [%s] before rotating repo key failed: %s", indexID, err)
+			return
+		}
+	}
+
+	var sortedRels []string
+	for rel := range rels {
+		sortedRels = append(sortedRels, rel)
+	}
+	sort.Strings(sortedRels)
+	err = gulu.File.WriteFileSafer(filepath.Join(repoKeyRotationDir(), "cloud"), []byte(strings.Join(sortedRels, "\n")), 0644)
+	return
+}
+
+func collectCloudRepoObjects(repo *dejavu.Repo, indexID string, rels map[string]bool) (err error) {
+	if _, _, _, err = repo.DownloadIndex(indexID, map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar}); err != nil {
+		return
+	}
+
+	index, err := repo.GetIndex(indexID)
+	if err != nil {
+		return
+	}
+	rels["indexes/"+indexID] = true
+	for _, fileID := range index.Files {
+		if rels[repoObjectRel(fileID)] {
+			continue
+		}
+		rels[repoObjectRel(fileID)] = true
+
+		file, getErr := repo.GetFile(fileID)
+		if nil != getErr {
+			return getErr
+		}
+		for _, chunkID := range file.Chunks {
+			rels[repoObjectRel(chunkID)] = true
+		}
+	}
+	return
+}
+
+// rotateLocalRepoKey 重新加密本地数据仓库中的快照和对象，已经使用新密钥加密的对象会被跳过，所以可以重复执行。
+//
+// 损坏的对象无法重新加密，继续轮换的话会被上传覆盖云端完好的副本，所以先检查所有对象，有无法解密的对象时在修改任何对象之前中止。
+func rotateLocalRepoKey() (err error) {
+	var paths []string
+	for _, dir := range []string{"indexes", "objects"} {
+		root := filepath.Join(Conf.Repo.GetSaveDir(), dir)
+		if !gulu.File.IsDir(root) {
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+			if nil != walkErr {
+				return walkErr
+			}
+			if !d.IsDir() {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	oldKey, newKey := Conf.Repo.Key, Conf.Repo.RotatingKey
+	var rotatings []string
+	var corrupted int
+	for i, p := range paths {
+		if 0 == i%64 {
+			util.PushProgress(util.PushProgressCodeProgressed, i, len(paths), fmt.Sprintf("Checking local data repo [%d/%d]", i, len(paths)))
+		}
+
+		data, readErr := os.ReadFile(p)
+		if nil != readErr {
+			return readErr
+		}
+		if _, decryptErr := decryptRepoObject(data, newKey); nil == decryptErr {
+			continue
+		}
+		if _, decryptErr := decryptRepoObject(data, oldKey); nil != decryptErr {
+			logging.LogErrorf("decrypt [%s] with current repo key failed: %s", p, decryptErr)
+			corrupted++
+			continue
+		}
+		rotatings = append(rotatings, p)
+	}
+	if 0 < corrupted {
+		logging.LogErrorf("[%d] corrupted objects found in local data repo, abort rotating repo key", corrupted)
+		return errRepoKeyCorruptObject
+	}
+
+	for i, p := range rotatings {
+		if 0 == i%64 {
+			util.PushProgress(util.PushProgressCodeProgressed, i, len(rotatings), fmt.Sprintf("Re-encrypting local data repo [%d/%d]", i, len(rotatings)))
+		}
+
+		data, readErr := os.ReadFile(p)
+		if nil != readErr {
+			return readErr
+		}
+		if data, err = decryptRepoObject(data, oldKey); err != nil {
+			return
+		}
+		if data, err = encryption.AesEncrypt(data, newKey); err != nil {
+			return
+		}
+		if err = gulu.File.WriteFileSafer(p, data, 0644); err != nil {
+			return
+		}
+	}
+	logging.LogInfof("re-encrypted [%d] local data repo objects, [%d] objects already re-encrypted", len(rotatings), len(paths)-len(rotatings))
+	return
+}
+
+// decryptRepoObject 解密数据仓库中的对象，截断到比 AES-GCM 随机数还短的对象直接返回错误，避免解密时越界。
+func decryptRepoObject(data, key []byte) ([]byte, error) {
+	if 12 > len(data) {
+		return nil, errors.New("object is truncated")
+	}
+	return encryption.AesDecrypt(data, key)
+}
+
+// rotateCloudRepoKey 使用本地重新加密后的对象覆盖云端对象，已上传的对象记录在日志中，中断后继续时会跳过。
+//
+// 轮换前云端没有被快照引用的对象不会重新加密，可以通过清理云端数据仓库删除。
+func rotateCloudRepoKey(cloudRepo cloud.Cloud) (err error) {
+	data, err := os.ReadFile(filepath.Join(repoKeyRotationDir(), "cloud"))
+	if err != nil {
+		return
+	}
+	journalPath := filepath.Join(repoKeyRotationDir(), "uploaded")
+	uploaded := map[string]bool{}
+	if journal, readErr := os.ReadFile(journalPath); nil == readErr {
+		for _, rel := range strings.Split(string(journal), "\n") {
+			uploaded[rel] = true
+		}
+	}
+
+	var rels []string
+	for _, rel := range strings.Split(string(data), "\n") {
+		if "" != rel && !uploaded[rel] {
+			rels = append(rels, rel)
+		}
+	}
+
+	journal, err := os.OpenFile(journalPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer journal.Close()
+
+	var journalLock sync.Mutex
+	total, count := len(uploaded)+len(rels), len(uploaded)
+	err = mirrorParallel(repoKeyRotationConcurrentReqs, rels, func(rel string) error {
+		objData, readErr := os.ReadFile(filepath.Join(Conf.Repo.GetSaveDir(), rel))
+		if nil != readErr {
+			return readErr
+		}
+		if _, uploadErr := cloudRepo.UploadBytes(rel, objData, true); nil != uploadErr {
+			return uploadErr
+		}
+
+		journalLock.Lock()
+		defer journalLock.Unlock()
+		if _, writeErr := journal.WriteString(rel + "\n"); nil != writeErr {
+			return writeErr
+		}
+		count++
+		if 0 == count%64 || total == count {
+			util.PushProgress(util.PushProgressCodeProgressed, count, total, fmt.Sprintf("Re-encrypting cloud data repo [%d/%d]", count, total))
+		}
+		return nil
+	})
+	if err != nil {
+		logging.LogErrorf("upload re-encrypted objects to cloud failed: %s", err)
+		return
+	}
+
+	// 重新写入最新引用，通过本地镜像接入的存储在写入引用时才会推送
+	latest, err := cloudRepo.DownloadObject("refs/latest")
+	if err != nil {
+		logging.LogWarnf("download cloud latest ref failed: %s", err)
+		err = nil
+		return
+	}
+	_, err = cloudRepo.UploadBytes("refs/latest", latest, true)
+	logging.LogInfof("re-encrypted [%d] cloud data repo objects", total)
+	return
+}
+
+const (
+	cloudSyncLockPath    = "lock-sync"      // 数据同步使用的云端锁
+	cloudSyncLockTimeout = 65 * time.Second // 云端锁超过该时间没有刷新视为已经失效
+)
+
+// lockCloudRepo 获取数据同步使用的云端锁，锁的格式和数据同步一致，持有期间每隔 30 秒刷新一次。
+//
+// 持有锁期间其他设备的同步会因为获取不到锁而失败，不会读写正在重新加密的云端数据仓库。
+func lockCloudRepo(cloudRepo cloud.Cloud) (unlock func(), err error) {
+	data, err := cloudRepo.DownloadObject(cloudSyncLockPath)
+	if nil == err {
+		if deviceID, held := cloudSyncLockHeld(data); held {
+			logging.LogWarnf("cloud data repo is locked by device [%s]", deviceID)
+			err = dejavu.ErrLockCloudFailed
+			return
+		}
+	} else if !errors.Is(err, cloud.ErrCloudObjectNotFound) {
+		return
+	}
+
+	writeLock := func() error {
+		lockData, _ := gulu.JSON.MarshalJSON(map[string]interface{}{"deviceID": Conf.System.ID, "time": time.Now().UnixMilli()})
+		_, uploadErr := cloudRepo.UploadBytes(cloudSyncLockPath, lockData, true)
+		return uploadErr
+	}
+	if err = writeLock(); err != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if refreshErr := writeLock(); nil != refreshErr {
+					logging.LogWarnf("refresh cloud lock failed: %s", refreshErr)
+				}
+			}
+		}
+	}()
+	unlock = func() {
+		close(stop)
+		if removeErr := cloudRepo.RemoveObject(cloudSyncLockPath); nil != removeErr {
+			logging.LogWarnf("unlock cloud failed: %s", removeErr)
+		}
+	}
+	return
+}
+
+// cloudSyncLockHeld 解析云端锁，返回持有锁的设备以及锁是否被其他设备持有并且还没有失效。
+func cloudSyncLockHeld(data []byte) (deviceID string, held bool) {
+	deviceID, live := cloudSyncLockOwner(data)
+	held = live && Conf.System.ID != deviceID
+	return
+}
+
+// cloudSyncLockOwner 解析云端锁，返回持有锁的设备以及锁是否还没有失效，无法解析时返回空。
+func cloudSyncLockOwner(data []byte) (deviceID string, live bool) {
+	lock := map[string]interface{}{}
+	if nil != gulu.JSON.UnmarshalJSON(data, &lock) {
+		return
+	}
+	deviceID, _ = lock["deviceID"].(string)
+	lockTime, _ := lock["time"].(float64)
+	live = cloudSyncLockTimeout > time.Since(time.UnixMilli(int64(lockTime)))
+	return
+}
+
+func repoKeyRotationDir() string {
+	return filepath.Join(Conf.Repo.GetSaveDir(), "key-rotation")
+}
+
+func repoObjectRel(id string) string {
+	return "objects/" + id[:2] + "/" + id[2:]
+}
@@ -0,0 +1,102 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/siyuan-note/encryption"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestRotateLocalRepoKey(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	cases := []struct {
+		name    string
+		objects map[string]string // 相对数据仓库目录的路径 -> old、new 或者 corrupt
+		wantErr error
+	}{
+		{
+			name:    "rotate",
+			objects: map[string]string{"indexes/a": "old", "objects/ab/cdef": "old"},
+		},
+		{
+			name:    "resume skips rotated objects",
+			objects: map[string]string{"indexes/a": "new", "objects/ab/cdef": "old", "objects/cd/ef01": "new"},
+		},
+		{
+			name:    "already rotated",
+			objects: map[string]string{"indexes/a": "new", "objects/ab/cdef": "new"},
+		},
+		{
+			name:    "corrupt object aborts before changing anything",
+			objects: map[string]string{"indexes/a": "old", "objects/ab/cdef": "corrupt", "objects/cd/ef01": "new"},
+			wantErr: errRepoKeyCorruptObject,
+		},
+	}
+
+	oldConf, oldWorkspaceDir := Conf, util.WorkspaceDir
+	t.Cleanup(func() { Conf, util.WorkspaceDir = oldConf, oldWorkspaceDir })
+	for _, c := range cases {
+		Conf = &AppConf{Repo: &conf.Repo{Key: oldKey, RotatingKey: newKey}}
+		util.WorkspaceDir = t.TempDir()
+
+		before := map[string][]byte{}
+		for rel, kind := range c.objects {
+			data := []byte("broken")
+			var err error
+			switch kind {
+			case "old":
+				data, err = encryption.AesEncrypt([]byte(rel), oldKey)
+			case "new":
+				data, err = encryption.AesEncrypt([]byte(rel), newKey)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeMirrorTestFile(t, Conf.Repo.GetSaveDir(), rel, string(data))
+			before[rel] = data
+		}
+
+		err := rotateLocalRepoKey()
+		if !errors.Is(err, c.wantErr) {
+			t.Fatalf("%s: want error [%v], got [%v]", c.name, c.wantErr, err)
+		}
+
+		for rel, kind := range c.objects {
+			data, readErr := os.ReadFile(filepath.Join(Conf.Repo.GetSaveDir(), filepath.FromSlash(rel)))
+			if nil != readErr {
+				t.Fatal(readErr)
+			}
+			// 中止时不修改任何对象，已经重新加密的对象不会再次写入
+			if nil != c.wantErr || "new" == kind {
+				if !bytes.Equal(before[rel], data) {
+					t.Fatalf("%s: object [%s] should not be changed", c.name, rel)
+				}
+				continue
+			}
+			if plain, decryptErr := encryption.AesDecrypt(data, newKey); nil != decryptErr || rel != string(plain) {
+				t.Fatalf("%s: object [%s] is not re-encrypted: [%s], [%v]", c.name, rel, plain, decryptErr)
+			}
+		}
+	}
+}
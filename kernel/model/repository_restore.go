@@ -0,0 +1,399 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+var ErrSnapshotFileNotFound = errors.New("file not found in snapshot")
+
+// RestoreRepoSnapshotDocs 从快照中恢复指定的文档，paths 为文档在快照中的路径，例如 /20210808180117-6v0mkxr/20200923234011-ieuun1p.sy。
+//
+// 工作空间中已有的文档会被覆盖，覆盖前会生成数据历史；已经删除的文档恢复到原来的位置，父文档不存在时恢复到笔记本根路径下。
+func RestoreRepoSnapshotDocs(id string, paths []string) (err error) {
+	repo, files, err := getRestoreSnapshotFiles(id)
+	if err != nil {
+		return
+	}
+
+	FlushTxQueue()
+
+	luteEngine := util.NewLute()
+	var historyDir string
+	var avIDs []string
+	for _, p := range paths {
+		file := files[p]
+		if nil == file || !strings.HasSuffix(p, ".sy") {
+			err = ErrSnapshotFileNotFound
+			return
+		}
+
+		boxID := strings.Split(strings.TrimPrefix(p, "/"), "/")[0]
+		if nil == Conf.Box(boxID) {
+			err = ErrBoxNotFound
+			return
+		}
+
+		tree, loadErr := loadSnapshotDocTree(repo, file, luteEngine)
+		if nil != loadErr {
+			err = loadErr
+			return
+		}
+
+		rootID := tree.ID
+		workingDoc := treenode.GetBlockTree(rootID)
+		if nil != workingDoc && "d" == workingDoc.Type {
+			// 文档可能已经被移动到其他位置或者其他笔记本，恢复到当前位置
+			if workingTree, _ := LoadTreeByBlockID(rootID); nil != workingTree {
+				backupRestoreTree(workingTree, &historyDir)
+			}
+			tree.Box, tree.Path, tree.HPath = workingDoc.BoxID, workingDoc.Path, workingDoc.HPath
+			treenode.RemoveBlockTreesByRootID(rootID)
+		} else {
+			tree.Box = boxID
+			tree.Path = strings.TrimPrefix(p, "/"+boxID)
+			parentID := path.Base(path.Dir(tree.Path))
+			if parent := treenode.GetBlockTree(parentID); nil != parent && parent.BoxID == boxID && "d" == parent.Type {
+				tree.Path = strings.TrimSuffix(parent.Path, ".sy") + "/" + path.Base(tree.Path)
+				tree.HPath = parent.HPath + "/" + tree.Root.IALAttr("title")
+			} else {
+				tree.Path = "/" + path.Base(tree.Path)
+				tree.HPath = "/" + tree.Root.IALAttr("title")
+			}
+		}
+
+		resetRestoreDuplicatedIDs(tree, tree.Root, rootID)
+		if restoreErr := restoreSnapshotAttributeViews(repo, files, tree.Root); nil != restoreErr {
+			logging.LogErrorf("restore attribute views of [%s] failed: %s", p, restoreErr)
+		}
+		for _, avNode := range tree.Root.ChildrenByType(ast.NodeAttributeView) {
+			avIDs = append(avIDs, avNode.AttributeViewID)
+		}
+
+		if err = os.MkdirAll(filepath.Join(util.DataDir, tree.Box, path.Dir(tree.Path)), 0755); err != nil {
+			return
+		}
+		sql.RemoveTreeQueue(rootID)
+		if err = indexWriteTreeUpsertQueue(tree); err != nil {
+			return
+		}
+		ReloadProtyle(tree.ID)
+	}
+
+	if "" != historyDir {
+		indexHistoryDir(filepath.Base(historyDir), luteEngine)
+	}
+	ReloadFiletree()
+	for _, avID := range gulu.Str.RemoveDuplicatedElem(avIDs) {
+		ReloadAttrView(avID)
+	}
+	IncSync()
+	util.PushMsg(Conf.Language(102), 3000)
+	return
+}
+
+// RestoreRepoSnapshotBlock 从快照中恢复一个块及其子块，docPath 为块所在文档在快照中的路径，为空时根据工作空间中的块查找。
+//
+// 工作空间中存在该块时替换该块，否则按照快照中的位置插入到文档中。
+func RestoreRepoSnapshotBlock(id, docPath, blockID string) (err error) {
+	if "" == docPath {
+		bt := treenode.GetBlockTree(blockID)
+		if nil == bt {
+			err = ErrBlockNotFound
+			return
+		}
+		docPath = "/" + bt.BoxID + bt.Path
+	}
+
+	repo, files, err := getRestoreSnapshotFiles(id)
+	if err != nil {
+		return
+	}
+	file := files[docPath]
+	if nil == file {
+		err = ErrSnapshotFileNotFound
+		return
+	}
+
+	luteEngine := util.NewLute()
+	snapshotTree, err := loadSnapshotDocTree(repo, file, luteEngine)
+	if err != nil {
+		return
+	}
+	node := treenode.GetNodeInTree(snapshotTree, blockID)
+	if nil == node || ast.NodeDocument == node.Type {
+		err = ErrBlockNotFound
+		return
+	}
+
+	FlushTxQueue()
+
+	tree, err := LoadTreeByBlockID(snapshotTree.ID)
+	if err != nil {
+		return
+	}
+
+	var historyDir string
+	backupRestoreTree(tree, &historyDir)
+
+	var prevID, parentID string
+	for prev := node.Previous; nil != prev; prev = prev.Previous {
+		if "" != prev.ID {
+			prevID = prev.ID
+			break
+		}
+	}
+	if nil != node.Parent {
+		parentID = node.Parent.ID
+	}
+
+	node.Unlink()
+	if working := treenode.GetNodeInTree(tree, blockID); nil != working {
+		working.InsertBefore(node)
+		working.Unlink()
+	} else {
+		insertRestoreNode(tree, node, prevID, parentID)
+	}
+
+	resetRestoreDuplicatedIDs(tree, node, tree.ID)
+	if restoreErr := restoreSnapshotAttributeViews(repo, files, node); nil != restoreErr {
+		logging.LogErrorf("restore attribute views of block [%s] failed: %s", blockID, restoreErr)
+	}
+
+	if err = indexWriteTreeUpsertQueue(tree); err != nil {
+		return
+	}
+
+	if "" != historyDir {
+		indexHistoryDir(filepath.Base(historyDir), luteEngine)
+	}
+	for _, avNode := range node.ChildrenByType(ast.NodeAttributeView) {
+		ReloadAttrView(avNode.AttributeViewID)
+	}
+	ReloadProtyle(tree.ID)
+	IncSync()
+	util.PushMsg(Conf.Language(102), 3000)
+	return
+}
+
+// RestoreRepoSnapshotAttributeView 从快照中恢复一个属性视图。
+func RestoreRepoSnapshotAttributeView(id, avID string) (err error) {
+	repo, files, err := getRestoreSnapshotFiles(id)
+	if err != nil {
+		return
+	}
+
+	file := files["/storage/av/"+avID+".json"]
+	if nil == file {
+		err = ErrSnapshotFileNotFound
+		return
+	}
+
+	var historyDir string
+	if err = restoreSnapshotAttributeView(repo, file, avID, &historyDir); err != nil {
+		return
+	}
+	if "" != historyDir {
+		indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+	}
+
+	ReloadAttrView(avID)
+	IncSync()
+	util.PushMsg(Conf.Language(102), 3000)
+	return
+}
+
+func getRestoreSnapshotFiles(id string) (repo *dejavu.Repo, ret map[string]*entity.File, err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+
+	repo, err = newRepository()
+	if err != nil {
+		return
+	}
+	index, err := repo.GetIndex(id)
+	if err != nil {
+		return
+	}
+	files, err := repo.GetFiles(index)
+	if err != nil {
+		return
+	}
+
+	ret = map[string]*entity.File{}
+	for _, f := range files {
+		ret[f.Path] = f
+	}
+	return
+}
+
+// backupRestoreTree 恢复前将工作空间中的文档保存到数据历史中。
+func backupRestoreTree(tree *parse.Tree, historyDir *string) {
+	if "" == *historyDir {
+		var err error
+		if *historyDir, err = GetHistoryDir(HistoryOpRestore); err != nil {
+			return
+		}
+	}
+
+	historyPath := filepath.Join(*historyDir, tree.Box, tree.Path)
+	if err := filelock.Copy(filepath.Join(util.DataDir, tree.Box, tree.Path), historyPath); err != nil {
+		logging.LogErrorf("backup [%s] before restore failed: %s", tree.Path, err)
+	}
+}
+
+// resetRestoreDuplicatedIDs 重置恢复的块中和其他文档或者本文档其他位置重复的块 ID。
+func resetRestoreDuplicatedIDs(tree *parse.Tree, restored *ast.Node, rootID string) {
+	restoredIDs := map[string]bool{}
+	var ids []string
+	ast.Walk(restored, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && "" != n.ID {
+			restoredIDs[n.ID] = true
+			ids = append(ids, n.ID)
+		}
+		return ast.WalkContinue
+	})
+
+	duplicated := map[string]bool{}
+	for id, bt := range treenode.GetBlockTrees(ids) {
+		if bt.RootID != rootID {
+			duplicated[id] = true
+		}
+	}
+	if ast.NodeDocument != restored.Type {
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering {
+				return ast.WalkContinue
+			}
+			if n == restored {
+				return ast.WalkSkipChildren
+			}
+			if n.IsBlock() && restoredIDs[n.ID] {
+				duplicated[n.ID] = true
+			}
+			return ast.WalkContinue
+		})
+	}
+	if 1 > len(duplicated) {
+		return
+	}
+
+	ast.Walk(restored, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || !duplicated[n.ID] {
+			return ast.WalkContinue
+		}
+
+		treenode.ResetNodeID(n)
+		if ast.NodeDocument == n.Type {
+			tree.ID = n.ID
+			tree.Path = tree.Path[:strings.LastIndex(tree.Path, "/")] + "/" + n.ID + ".sy"
+		}
+		return ast.WalkContinue
+	})
+	logging.LogInfof("reset [%d] duplicated block IDs when restoring [%s]", len(duplicated), tree.Path)
+}
+
+// insertRestoreNode 将工作空间中已经删除的块插入到快照中的位置：前一个兄弟块之后，或者父块的第一个子块，都找不到时插入到文档末尾。
+func insertRestoreNode(tree *parse.Tree, node *ast.Node, prevID, parentID string) {
+	if prev := treenode.GetNodeInTree(tree, prevID); "" != prevID && nil != prev {
+		prev.InsertAfter(node)
+		return
+	}
+	if parent := treenode.GetNodeInTree(tree, parentID); "" != parentID && nil != parent && parent.IsContainerBlock() {
+		for c := parent.FirstChild; nil != c; c = c.Next {
+			if "" != c.ID {
+				c.InsertBefore(node)
+				return
+			}
+		}
+		if last := parent.LastChild; nil != last && ast.NodeSuperBlockCloseMarker == last.Type {
+			last.InsertBefore(node)
+			return
+		}
+		parent.AppendChild(node)
+		return
+	}
+	tree.Root.AppendChild(node)
+}
+
+// restoreSnapshotAttributeViews 恢复块中引用的、工作空间中已经不存在的属性视图。
+func restoreSnapshotAttributeViews(repo *dejavu.Repo, files map[string]*entity.File, node *ast.Node) (err error) {
+	for _, avNode := range node.ChildrenByType(ast.NodeAttributeView) {
+		avID := avNode.AttributeViewID
+		if filelock.IsExist(av.GetAttributeViewDataPath(avID)) {
+			continue
+		}
+
+		file := files["/storage/av/"+avID+".json"]
+		if nil == file {
+			continue
+		}
+		if err = restoreSnapshotAttributeView(repo, file, avID, nil); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func restoreSnapshotAttributeView(repo *dejavu.Repo, file *entity.File, avID string, historyDir *string) (err error) {
+	data, err := repo.OpenFile(file)
+	if err != nil {
+		return
+	}
+	if !json.Valid(data) {
+		err = errors.New("invalid attribute view data")
+		return
+	}
+
+	avPath := av.GetAttributeViewDataPath(avID)
+	if nil != historyDir && filelock.IsExist(avPath) {
+		if "" == *historyDir {
+			if *historyDir, err = GetHistoryDir(HistoryOpRestore); err != nil {
+				return
+			}
+		}
+		if copyErr := filelock.Copy(avPath, filepath.Join(*historyDir, "storage", "av", avID+".json")); nil != copyErr {
+			logging.LogErrorf("backup attribute view [%s] before restore failed: %s", avID, copyErr)
+		}
+	}
+
+	if err = os.MkdirAll(filepath.Dir(avPath), 0755); err != nil {
+		return
+	}
+	return filelock.WriteFile(avPath, data)
+}
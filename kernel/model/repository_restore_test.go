@@ -0,0 +1,68 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+func TestInsertRestoreNode(t *testing.T) {
+	cases := []struct {
+		name     string
+		prevID   string
+		parentID string
+		want     string
+	}{
+		{"after previous sibling", "20240101000000-aaaaaaa", "", "a q b d x"},
+		{"after previous sibling in container", "20240101000000-bbbbbbb", "20240101000000-qqqqqqq", "a q b x d"},
+		{"first child of parent", "20240101000000-missing", "20240101000000-qqqqqqq", "a q x b d"},
+		{"parent is not a container", "", "20240101000000-aaaaaaa", "a q b d x"},
+		{"no position found", "20240101000000-missing", "20240101000000-missing", "a q b d x"},
+	}
+
+	for _, c := range cases {
+		tree := newRestoreTestTree()
+		insertRestoreNode(tree, treenode.NewParagraph("20240101000000-xxxxxxx"), c.prevID, c.parentID)
+
+		var got []string
+		ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if entering && n.IsBlock() && ast.NodeDocument != n.Type {
+				got = append(got, n.ID[len(n.ID)-1:])
+			}
+			return ast.WalkContinue
+		})
+		if c.want != strings.Join(got, " ") {
+			t.Errorf("%s: want [%s], got [%s]", c.name, c.want, strings.Join(got, " "))
+		}
+	}
+}
+
+// newRestoreTestTree 构造文档 a, q(b), d，其中 q 为引述块。
+func newRestoreTestTree() (ret *parse.Tree) {
+	ret = &parse.Tree{Root: &ast.Node{Type: ast.NodeDocument, ID: "20240101000000-docdocd"}}
+	quote := &ast.Node{Type: ast.NodeBlockquote, ID: "20240101000000-qqqqqqq"}
+	quote.AppendChild(treenode.NewParagraph("20240101000000-bbbbbbb"))
+	ret.Root.AppendChild(treenode.NewParagraph("20240101000000-aaaaaaa"))
+	ret.Root.AppendChild(quote)
+	ret.Root.AppendChild(treenode.NewParagraph("20240101000000-ddddddd"))
+	return
+}
@@ -0,0 +1,200 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 定时快照的标签前缀，每个周期的第一个定时快照会被打上对应周期的标签，未被标签引用的快照由自动清理数据仓库处理。
+const (
+	autoSnapshotTagHourly  = "auto-hourly-"
+	autoSnapshotTagDaily   = "auto-daily-"
+	autoSnapshotTagWeekly  = "auto-weekly-"
+	autoSnapshotTagMonthly = "auto-monthly-"
+)
+
+// riskyOpFindReplaceBlocks 批量替换的块数超过该值时视为高风险操作。
+const riskyOpFindReplaceBlocks = 64
+
+var lastAutoSnapshotRepo = time.Now() // 启动后等待一个间隔再创建第一个定时快照
+
+func AutoSnapshotRepoJob() {
+	if 1 > Conf.Repo.SnapshotInterval || 1 > len(Conf.Repo.Key) || isRepoKeyRotating() {
+		return
+	}
+	if time.Since(lastAutoSnapshotRepo) < time.Duration(Conf.Repo.SnapshotInterval)*time.Minute {
+		return
+	}
+	if isSyncing.Load() {
+		return // 同步时会创建快照，等同步结束后再创建
+	}
+
+	task.AppendTask(task.RepoAutoSnapshot, autoSnapshotRepo)
+}
+
+func autoSnapshotRepo() {
+	now := time.Now()
+	lastAutoSnapshotRepo = now
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	FlushTxQueue()
+	index, err := repo.Index("[Auto] Scheduled snapshot", true, map[string]interface{}{
+		eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar,
+	})
+	if err != nil {
+		logging.LogErrorf("create scheduled snapshot failed: %s", err)
+		return
+	}
+	logging.LogInfof("created scheduled snapshot [%s] in [%.2fs]", index.ID, time.Since(now).Seconds())
+
+	tagLogs, err := repo.GetTagLogs()
+	if err != nil {
+		logging.LogErrorf("get tag logs failed: %s", err)
+		return
+	}
+	tagAutoSnapshot(repo, index, tagLogs, now)
+	if tagLogs, err = repo.GetTagLogs(); err != nil {
+		logging.LogErrorf("get tag logs failed: %s", err)
+		return
+	}
+	pruneAutoSnapshotTags(repo, tagLogs)
+}
+
+// tagAutoSnapshot 为定时快照打上所在周期的标签，周期内已经有标签的话保留原来的快照。
+func tagAutoSnapshot(repo *dejavu.Repo, index *entity.Index, tagLogs []*dejavu.Log, now time.Time) {
+	tags := map[string]bool{}
+	for _, l := range tagLogs {
+		tags[l.Tag] = true
+	}
+
+	retention := Conf.Repo.SnapshotRetention
+	year, week := now.ISOWeek()
+	for _, tier := range []struct {
+		prefix string
+		count  int
+		period string
+	}{
+		{autoSnapshotTagHourly, retention.Hourly, now.Format("2006-01-02-15")},
+		{autoSnapshotTagDaily, retention.Daily, now.Format("2006-01-02")},
+		{autoSnapshotTagWeekly, retention.Weekly, fmt.Sprintf("%d-W%02d", year, week)},
+		{autoSnapshotTagMonthly, retention.Monthly, now.Format("2006-01")},
+	} {
+		tag := tier.prefix + tier.period
+		if 1 > tier.count || tags[tag] {
+			continue
+		}
+		if err := repo.AddTag(index.ID, tag); err != nil {
+			logging.LogErrorf("add tag [%s] to snapshot [%s] failed: %s", tag, index.ID, err)
+		}
+	}
+}
+
+// pruneAutoSnapshotTags 按照保留策略移除过期的定时快照标签，用户手动添加的标签不受影响。
+func pruneAutoSnapshotTags(repo *dejavu.Repo, tagLogs []*dejavu.Log) {
+	retention := Conf.Repo.SnapshotRetention
+	counts := map[string]int{
+		autoSnapshotTagHourly:  retention.Hourly,
+		autoSnapshotTagDaily:   retention.Daily,
+		autoSnapshotTagWeekly:  retention.Weekly,
+		autoSnapshotTagMonthly: retention.Monthly,
+	}
+
+	for prefix, count := range counts {
+		var tags []string
+		for _, l := range tagLogs {
+			if strings.HasPrefix(l.Tag, prefix) {
+				tags = append(tags, l.Tag)
+			}
+		}
+		if len(tags) <= count {
+			continue
+		}
+
+		// 标签中的周期可以直接按字符串排序
+		sort.Sort(sort.Reverse(sort.StringSlice(tags)))
+		for _, tag := range tags[max(count, 0):] {
+			if err := repo.RemoveTag(tag); err != nil {
+				logging.LogErrorf("remove tag [%s] failed: %s", tag, err)
+				continue
+			}
+			logging.LogInfof("removed expired snapshot tag [%s]", tag)
+		}
+	}
+}
+
+// snapshotBeforeRiskyOp 在高风险操作前创建数据快照，操作结果不符合预期时可以从快照回滚。
+func snapshotBeforeRiskyOp(op string) {
+	if !Conf.Repo.SnapshotBeforeRiskyOps || 1 > len(Conf.Repo.Key) {
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	start := time.Now()
+	FlushTxQueue()
+	// 不检查分块以减少操作前的等待
+	index, err := repo.Index("[Auto] Before "+op, false, map[string]interface{}{
+		eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar,
+	})
+	if err != nil {
+		logging.LogErrorf("create snapshot before [%s] failed: %s", op, err)
+		return
+	}
+	logging.LogInfof("created snapshot [%s] before [%s] in [%.2fs]", index.ID, op, time.Since(start).Seconds())
+}
+
+// backupRepoBeforeReset 在重置数据仓库前创建快照，并将数据仓库移动到临时目录中，仅保留最近一次的备份。
+//
+// 备份的数据仓库仍然使用原来的密钥加密，需要导入原来的密钥才能恢复。
+func backupRepoBeforeReset() {
+	if !Conf.Repo.SnapshotBeforeRiskyOps || 1 > len(Conf.Repo.Key) {
+		return
+	}
+
+	snapshotBeforeRiskyOp("reset data repo")
+
+	backupDir := filepath.Join(util.TempDir, "repo-reset-backup")
+	if err := os.RemoveAll(backupDir); err != nil {
+		logging.LogErrorf("remove data repo backup [%s] failed: %s", backupDir, err)
+		return
+	}
+	if err := os.Rename(Conf.Repo.GetSaveDir(), backupDir); err != nil {
+		logging.LogErrorf("backup data repo [%s] to [%s] failed: %s", Conf.Repo.GetSaveDir(), backupDir, err)
+		return
+	}
+	logging.LogInfof("backed up data repo to [%s]", backupDir)
+}
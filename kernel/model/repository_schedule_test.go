@@ -0,0 +1,157 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestTagAutoSnapshot(t *testing.T) {
+	setScheduleTestConf(t, &conf.SnapshotRetention{Hourly: 2, Daily: 1, Weekly: 0, Monthly: 1})
+	repo, dataDir := newScheduleTestRepo(t)
+
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.Local)
+	steps := []struct {
+		name string
+		now  time.Time
+		want string // 标签=快照序号
+	}{
+		{
+			name: "first snapshot is tagged for every kept period",
+			now:  now,
+			want: "auto-daily-2024-03-15=0,auto-hourly-2024-03-15-10=0,auto-monthly-2024-03=0",
+		},
+		{
+			name: "same hour keeps the earlier snapshot",
+			now:  now.Add(10 * time.Minute),
+			want: "auto-daily-2024-03-15=0,auto-hourly-2024-03-15-10=0,auto-monthly-2024-03=0",
+		},
+		{
+			name: "next hour",
+			now:  now.Add(time.Hour),
+			want: "auto-daily-2024-03-15=0,auto-hourly-2024-03-15-10=0,auto-hourly-2024-03-15-11=2,auto-monthly-2024-03=0",
+		},
+	}
+
+	var indexIDs []string
+	for i, step := range steps {
+		index := indexScheduleTestRepo(t, repo, dataDir, i)
+		indexIDs = append(indexIDs, index.ID)
+
+		tagLogs, err := repo.GetTagLogs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tagAutoSnapshot(repo, index, tagLogs, step.now)
+		if got := scheduleTestTags(t, repo, indexIDs); step.want != got {
+			t.Fatalf("%s: tags: want [%s], got [%s]", step.name, step.want, got)
+		}
+	}
+}
+
+func TestPruneAutoSnapshotTags(t *testing.T) {
+	setScheduleTestConf(t, &conf.SnapshotRetention{Hourly: 2, Daily: 1, Weekly: 0, Monthly: 3})
+	repo, dataDir := newScheduleTestRepo(t)
+	index := indexScheduleTestRepo(t, repo, dataDir, 0)
+
+	for _, tag := range []string{
+		"auto-hourly-2024-03-15-09", "auto-hourly-2024-03-15-10", "auto-hourly-2024-03-15-11",
+		"auto-daily-2024-03-14", "auto-daily-2024-03-15",
+		"auto-weekly-2024-W11",
+		"auto-monthly-2024-02", "auto-monthly-2024-03",
+		"v1.0", // 用户手动添加的标签
+	} {
+		if err := repo.AddTag(index.ID, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tagLogs, err := repo.GetTagLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruneAutoSnapshotTags(repo, tagLogs)
+
+	want := "auto-daily-2024-03-15=0,auto-hourly-2024-03-15-10=0,auto-hourly-2024-03-15-11=0,auto-monthly-2024-02=0,auto-monthly-2024-03=0,v1.0=0"
+	if got := scheduleTestTags(t, repo, []string{index.ID}); want != got {
+		t.Fatalf("tags: want [%s], got [%s]", want, got)
+	}
+}
+
+func setScheduleTestConf(t *testing.T, retention *conf.SnapshotRetention) {
+	oldConf := Conf
+	Conf = &AppConf{
+		System: &conf.System{ID: "test-device", Name: "test", OS: "linux"},
+		Repo:   &conf.Repo{SnapshotRetention: retention},
+	}
+	t.Cleanup(func() { Conf = oldConf })
+}
+
+func newScheduleTestRepo(t *testing.T) (repo *dejavu.Repo, dataDir string) {
+	root := t.TempDir()
+	dataDir = filepath.Join(root, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	repo, err := dejavu.NewRepo(dataDir, filepath.Join(root, "repo"), filepath.Join(root, "history"), filepath.Join(root, "temp"),
+		Conf.System.ID, Conf.System.Name, Conf.System.OS, key, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// indexScheduleTestRepo 修改数据后创建一个快照。
+func indexScheduleTestRepo(t *testing.T, repo *dejavu.Repo, dataDir string, i int) *entity.Index {
+	if err := os.WriteFile(filepath.Join(dataDir, "test.txt"), []byte(strings.Repeat("a", i+1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := repo.Index("test", false, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+// scheduleTestTags 返回排序后的标签和所指快照在 indexIDs 中的序号。
+func scheduleTestTags(t *testing.T, repo *dejavu.Repo, indexIDs []string) string {
+	tagLogs, err := repo.GetTagLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret []string
+	for _, l := range tagLogs {
+		for i, id := range indexIDs {
+			if id == l.ID {
+				ret = append(ret, l.Tag+"="+strconv.Itoa(i))
+			}
+		}
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ",")
+}
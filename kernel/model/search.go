@@ -312,7 +312,7 @@ func buildEmbedBlock(embedBlockID string, excludeIDs []string, headingMode int,
 	return
 }
 
-func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets, isDatabase bool) (ret []*Block, newDoc bool) {
+func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets, isDatabase bool, access *PublishAccess) (ret []*Block, newDoc bool) {
 	cachedTrees := map[string]*parse.Tree{}
 
 	onlyDoc := false
@@ -357,6 +357,7 @@ func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets,
 			block.RefText = maxContent(block.RefText, Conf.Editor.BlockRefDynamicAnchorTextMaxLen)
 			ret = append(ret, block)
 		}
+		ret = access.FilterBlocks(ret)
 		if 1 > len(ret) {
 			ret = []*Block{}
 		}
@@ -366,7 +367,7 @@ func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets,
 		return
 	}
 
-	ret = fullTextSearchRefBlock(keyword, beforeLen, onlyDoc)
+	ret = fullTextSearchRefBlock(keyword, beforeLen, onlyDoc, access.SQLFilter())
 	tmp := ret[:0]
 	var btsID []string
 	for _, b := range ret {
@@ -455,7 +456,7 @@ func prependNotebookNameInHPath(blocks []*Block) {
 	}
 }
 
-func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids []string, paths, boxes []string, types map[string]bool, method, orderBy, groupBy int) (err error) {
+func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids []string, paths, boxes []string, types map[string]bool, method, orderBy, groupBy int, user string) (err error) {
 	// method：0：文本，1：查询语法，2：SQL，3：正则表达式
 	if 2 == method {
 		err = errors.New(Conf.Language(132))
@@ -498,11 +499,17 @@ func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids
 
 	if 1 > len(ids) {
 		// `Replace All` is no longer affected by pagination https://github.com/siyuan-note/siyuan/issues/8265
-		blocks, _, _, _, _ := FullTextSearchBlock(keyword, boxes, paths, types, method, orderBy, groupBy, 1, math.MaxInt)
+		blocks, _, _, _, _ := FullTextSearchBlock(keyword, boxes, paths, types, method, orderBy, groupBy, 1, math.MaxInt, nil)
 		for _, block := range blocks {
 			ids = append(ids, block.ID)
 		}
 	}
+	if err = CheckBlocksLocked(user, ids...); err != nil {
+		return
+	}
+	if riskyOpFindReplaceBlocks <= len(ids) {
+		snapshotBeforeRiskyOp("find replace")
+	}
 
 	for _, id := range ids {
 		bt := treenode.GetBlockTree(id)
@@ -1135,9 +1142,10 @@ func mergeSamePreNext(n *ast.Node) {
 // method：0：关键字，1：查询语法，2：SQL，3：正则表达式
 // orderBy: 0：按块类型（默认），1：按创建时间升序，2：按创建时间降序，3：按更新时间升序，4：按更新时间降序，5：按内容顺序（仅在按文档分组时），6：按相关度升序，7：按相关度降序
 // groupBy：0：不分组，1：按文档分组
-func FullTextSearchBlock(query string, boxes, paths []string, types map[string]bool, method, orderBy, groupBy, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
+// FullTextSearchBlock 搜索块，access 不为 nil 时只搜索发布服务账号可以访问的文档，并且不支持 SQL 搜索。
+func FullTextSearchBlock(query string, boxes, paths []string, types map[string]bool, method, orderBy, groupBy, page, pageSize int, access *PublishAccess) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
 	ret = []*Block{}
-	if "" == query {
+	if "" == query || (nil != access && 2 == method) {
 		return
 	}
 
@@ -1152,6 +1160,7 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		}
 		ignoreFilter += buf.String()
 	}
+	ignoreFilter += access.SQLFilter()
 
 	beforeLen := 36
 	var blocks []*Block
@@ -1162,7 +1171,7 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		boxFilter := buildBoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		if ast.IsNodeIDPattern(query) {
-			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", beforeLen, page, pageSize)
+			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'"+access.SQLFilter(), beforeLen, page, pageSize)
 		} else {
 			blocks, matchedBlockCount, matchedRootCount = fullTextSearchByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
 		}
@@ -1178,7 +1187,7 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		boxFilter := buildBoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		if ast.IsNodeIDPattern(query) {
-			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'", beforeLen, page, pageSize)
+			blocks, matchedBlockCount, matchedRootCount = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+query+"'"+access.SQLFilter(), beforeLen, page, pageSize)
 		} else {
 			if 2 > len(strings.Split(strings.TrimSpace(query), " ")) {
 				query = stringQuery(query)
@@ -1454,11 +1463,11 @@ func removeLimitClause(stmt string) string {
 	return stmt
 }
 
-func fullTextSearchRefBlock(keyword string, beforeLen int, onlyDoc bool) (ret []*Block) {
+func fullTextSearchRefBlock(keyword string, beforeLen int, onlyDoc bool, scopeFilter string) (ret []*Block) {
 	keyword = filterQueryInvisibleChars(keyword)
 
 	if id := extractID(keyword); "" != id {
-		ret, _, _ = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+id+"'", 36, 1, 32)
+		ret, _, _ = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+id+"'"+scopeFilter, 36, 1, 32)
 		return
 	}
 
@@ -1492,6 +1501,7 @@ func fullTextSearchRefBlock(keyword string, beforeLen int, onlyDoc bool) (ret []
 		}
 		stmt += buf.String()
 	}
+	stmt += scopeFilter
 
 	orderBy := ` ORDER BY CASE
              WHEN name = '${keyword}' THEN 10
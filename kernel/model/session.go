@@ -44,6 +44,7 @@ func LogoutAuth(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
+	clearOIDCSession(c)
 	if "" == Conf.AccessAuthCode {
 		ret.Code = -1
 		ret.Msg = Conf.Language(86)
@@ -199,7 +200,9 @@ func CheckAuth(c *gin.Context) {
 		RoleEditor,
 		RoleReader,
 	}) {
-		c.Next()
+		if checkPublishAccess(c) {
+			c.Next()
+		}
 		return
 	}
 
@@ -222,6 +225,12 @@ func CheckAuth(c *gin.Context) {
 				c.Next()
 				return
 			}
+			if found, ok := authAPIToken(c, token); found {
+				if ok {
+					c.Next()
+				}
+				return
+			}
 
 			c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [header: Authorization]"})
 			c.Abort()
@@ -236,6 +245,12 @@ func CheckAuth(c *gin.Context) {
 			c.Next()
 			return
 		}
+		if found, ok := authAPIToken(c, token); found {
+			if ok {
+				c.Next()
+			}
+			return
+		}
 
 		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [query: token]"})
 		c.Abort()
@@ -372,7 +387,7 @@ func CheckAuth(c *gin.Context) {
 }
 
 func CheckAdminRole(c *gin.Context) {
-	if IsAdminRoleContext(c) {
+	if IsAdminRoleContext(c) || isAPITokenEditableContext(c) || isOIDCEditableContext(c) {
 		c.Next()
 	} else {
 		c.AbortWithStatus(http.StatusForbidden)
@@ -465,6 +480,7 @@ func ControlConcurrency(c *gin.Context) {
 		strings.HasPrefix(reqPath, "/api/search/") ||
 		strings.HasPrefix(reqPath, "/api/network/") ||
 		strings.HasPrefix(reqPath, "/api/broadcast/") ||
+		strings.HasPrefix(reqPath, "/api/sync/peer/") ||
 		strings.HasPrefix(reqPath, "/es/") {
 		c.Next()
 		return
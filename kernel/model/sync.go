@@ -19,8 +19,10 @@ package model
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -124,6 +126,7 @@ func SyncDataJob() {
 func BootSyncData() {
 	defer logging.Recover()
 
+	refreshLANSyncAdvertise()
 	if Conf.Sync.Perception {
 		connectSyncWebSocket()
 	}
@@ -221,6 +224,10 @@ func syncData(exit, byHand bool) {
 			logging.LogErrorf("write websocket message failed: %v", writeErr)
 		}
 	}
+	if 1 == Conf.Sync.Mode && Conf.Sync.Perception && dataChanged && isLANSyncHost() {
+		// 局域网同步主机直接通知已连接的对端
+		notifyLANSyncPeers(nil)
+	}
 	return
 }
 
@@ -233,6 +240,17 @@ func checkSync(boot, exit, byHand bool) bool {
 		return false
 	}
 
+	if !boot && !exit && !byHand && isSyncPlanPending() { // 等待确认同步预览时暂停自动同步
+		return false
+	}
+
+	if isRepoKeyRotating() {
+		if byHand {
+			util.PushMsg(errRepoKeyRotating.Error(), 5000)
+		}
+		return false
+	}
+
 	if !Conf.Sync.Enabled {
 		if byHand {
 			util.PushMsg(Conf.Language(124), 5000)
@@ -252,7 +270,7 @@ func checkSync(boot, exit, byHand bool) bool {
 		if !IsSubscriber() {
 			return false
 		}
-	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal:
+	case conf.ProviderWebDAV, conf.ProviderS3, conf.ProviderLocal, conf.ProviderSFTP, conf.ProviderGit, conf.ProviderLAN:
 		if !IsPaidUser() {
 			return false
 		}
@@ -386,6 +404,7 @@ func SetSyncGenerateConflictDoc(b bool) {
 func SetSyncEnable(b bool) {
 	Conf.Sync.Enabled = b
 	Conf.Save()
+	refreshLANSyncAdvertise()
 	return
 }
 
@@ -428,6 +447,7 @@ func SetSyncMode(mode int) {
 func SetSyncProvider(provider int) (err error) {
 	Conf.Sync.Provider = provider
 	Conf.Save()
+	refreshLANSyncAdvertise()
 	return
 }
 
@@ -510,6 +530,92 @@ func SetSyncProviderLocal(local *conf.Local) (err error) {
 	return
 }
 
+func SetSyncProviderSFTP(sftp *conf.SFTP) (err error) {
+	sftp.Endpoint = strings.TrimSpace(sftp.Endpoint)
+	if "" == sftp.Endpoint {
+		err = errors.New(fmt.Sprintf(Conf.Language(77), "endpoint is empty"))
+		return
+	}
+	if _, _, splitErr := net.SplitHostPort(sftp.Endpoint); nil != splitErr {
+		sftp.Endpoint = net.JoinHostPort(sftp.Endpoint, "22")
+	}
+	sftp.Username = strings.TrimSpace(sftp.Username)
+	sftp.PrivateKey = filepath.Clean(strings.TrimSpace(sftp.PrivateKey))
+	if !gulu.File.IsExist(sftp.PrivateKey) {
+		msg := fmt.Sprintf("private key [%s] not exist", sftp.PrivateKey)
+		logging.LogErrorf(msg)
+		err = errors.New(fmt.Sprintf(Conf.Language(77), msg))
+		return
+	}
+	sftp.HostKey = strings.TrimSpace(sftp.HostKey)
+	sftp.Path = strings.TrimSpace(sftp.Path)
+	sftp.Timeout = util.NormalizeTimeout(sftp.Timeout)
+	sftp.ConcurrentReqs = util.NormalizeConcurrentReqs(sftp.ConcurrentReqs, conf.ProviderSFTP)
+
+	Conf.Sync.SFTP = sftp
+	Conf.Save()
+	return
+}
+
+func SetSyncProviderGit(git *conf.Git) (err error) {
+	git.Endpoint = strings.TrimSpace(git.Endpoint)
+	if "" == git.Endpoint {
+		err = errors.New(fmt.Sprintf(Conf.Language(77), "endpoint is empty"))
+		return
+	}
+	if _, lookErr := exec.LookPath("git"); nil != lookErr {
+		msg := "git is not installed"
+		logging.LogErrorf(msg)
+		err = errors.New(fmt.Sprintf(Conf.Language(77), msg))
+		return
+	}
+	git.Branch = strings.TrimSpace(git.Branch)
+	if "" == git.Branch {
+		git.Branch = "main"
+	}
+	git.PrivateKey = strings.TrimSpace(git.PrivateKey)
+	if "" != git.PrivateKey {
+		git.PrivateKey = filepath.Clean(git.PrivateKey)
+	}
+	git.Timeout = util.NormalizeTimeout(git.Timeout)
+	git.ConcurrentReqs = util.NormalizeConcurrentReqs(git.ConcurrentReqs, conf.ProviderGit)
+
+	Conf.Sync.Git = git
+	Conf.Save()
+	return
+}
+
+func SetSyncProviderLAN(lan *conf.LAN) (err error) {
+	lan.Endpoint = strings.TrimSpace(lan.Endpoint)
+	lan.Token = strings.TrimSpace(lan.Token)
+	if "" == lan.Endpoint {
+		// 作为主机时需要开启网络伺服，否则对端无法访问
+		if !Conf.System.NetworkServe && util.ContainerDocker != util.Container {
+			err = errors.New(fmt.Sprintf(Conf.Language(77), "network serving is not enabled"))
+			return
+		}
+		if "" == lan.Token {
+			lan.Token = gulu.Rand.String(32)
+		}
+	}
+	if 16 > len(lan.Token) {
+		err = errors.New(fmt.Sprintf(Conf.Language(77), "token must be at least 16 characters"))
+		return
+	}
+	lan.Timeout = util.NormalizeTimeout(lan.Timeout)
+	lan.ConcurrentReqs = util.NormalizeConcurrentReqs(lan.ConcurrentReqs, conf.ProviderLAN)
+
+	Conf.Sync.LAN = lan
+	Conf.Save()
+
+	closeSyncWebSocket()
+	refreshLANSyncAdvertise()
+	if Conf.Sync.Perception {
+		connectSyncWebSocket()
+	}
+	return
+}
+
 var (
 	syncLock  = sync.Mutex{}
 	isSyncing = atomic.Bool{}
@@ -733,12 +839,35 @@ func isProviderOnline(byHand bool) (ret bool) {
 	case conf.ProviderLocal:
 		checkURL = "file://" + Conf.Sync.Local.Endpoint
 		timeout = Conf.Sync.Local.Timeout * 1000
+	case conf.ProviderSFTP:
+		if ret = util.IsTCPOnline(Conf.Sync.SFTP.Endpoint, Conf.Sync.SFTP.Timeout*1000); ret {
+			return
+		}
+	case conf.ProviderGit:
+		addr := gitRemoteAddr(Conf.Sync.Git.Endpoint)
+		if "" == addr {
+			checkURL = "file://" + strings.TrimPrefix(Conf.Sync.Git.Endpoint, "file://")
+			timeout = Conf.Sync.Git.Timeout * 1000
+			break
+		}
+		if ret = util.IsTCPOnline(addr, Conf.Sync.Git.Timeout*1000); ret {
+			return
+		}
+	case conf.ProviderLAN:
+		if isLANSyncHost() {
+			return true // 主机上的数据仓库目录总是可用
+		}
+		checkURL = lanSyncHostURL(Conf.Sync.LAN.Endpoint) + "/api/system/version"
+		timeout = Conf.Sync.LAN.Timeout * 1000
 	default:
 		logging.LogWarnf("unknown provider: %d", Conf.Sync.Provider)
 		return false
 	}
 
-	if ret = util.IsOnline(checkURL, skipTlsVerify, timeout); !ret {
+	if "" != checkURL {
+		ret = util.IsOnline(checkURL, skipTlsVerify, timeout)
+	}
+	if !ret {
 		if 1 > autoSyncErrCount || byHand {
 			util.PushErrMsg(Conf.Language(76)+" (Provider: "+conf.ProviderToStr(Conf.Sync.Provider)+")", 5000)
 		}
@@ -802,7 +931,14 @@ func closeSyncWebSocket() {
 func connectSyncWebSocket() {
 	defer logging.Recover()
 
-	if !Conf.Sync.Enabled || !IsSubscriber() || conf.ProviderSiYuan != Conf.Sync.Provider {
+	if !Conf.Sync.Enabled {
+		return
+	}
+	if conf.ProviderLAN == Conf.Sync.Provider {
+		if isLANSyncHost() {
+			return // 主机通过 ServeLANSyncPeerWebSocket 接受对端的连接
+		}
+	} else if !IsSubscriber() || conf.ProviderSiYuan != Conf.Sync.Provider {
 		return
 	}
 
@@ -845,7 +981,7 @@ func connectSyncWebSocket() {
 				reconnected := false
 				for retries := 0; retries < 7; retries++ {
 					time.Sleep(7 * time.Second)
-					if nil == Conf.GetUser() {
+					if conf.ProviderSiYuan == Conf.Sync.Provider && nil == Conf.GetUser() {
 						return
 					}
 
@@ -898,6 +1034,10 @@ func connectSyncWebSocket() {
 var KernelID = gulu.Rand.String(7)
 
 func dialSyncWebSocket() (c *websocket.Conn, err error) {
+	if conf.ProviderLAN == Conf.Sync.Provider {
+		return dialLANSyncWebSocket()
+	}
+
 	endpoint := util.GetCloudWebSocketServer() + "/apis/siyuan/dejavu/ws"
 	header := http.Header{
 		"User-Agent":        []string{util.UserAgent},
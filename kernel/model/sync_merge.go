@@ -0,0 +1,421 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 块级合并时标记冲突块的属性，属性值为同步时间
+const syncConflictAttr = "custom-sync-conflict"
+
+// syncMergeBlock 描述了合并时块在树中的位置和内容摘要。
+type syncMergeBlock struct {
+	node     *ast.Node
+	parentID string
+	prevID   string
+	content  string
+}
+
+// getSyncBaseIndex 返回上次同步完成时的快照，作为下次同步块级合并的共同祖先，需要在同步前调用。
+func getSyncBaseIndex(repo *dejavu.Repo) (ret *entity.Index) {
+	if "" == Conf.Sync.SyncedIndex {
+		return
+	}
+
+	ret, err := repo.GetIndex(Conf.Sync.SyncedIndex)
+	if err != nil {
+		logging.LogWarnf("get synced index [%s] failed: %s", Conf.Sync.SyncedIndex, err)
+		return nil
+	}
+	return
+}
+
+// mergeSyncConflicts 对同步冲突的文档进行块级三路合并，返回无法合并的冲突文件。
+//
+// 共同祖先使用上次同步完成时的快照，数据目录中的文档为同步后保留的版本，冲突目录中的文档为被覆盖的版本。
+// 只修改了一方的块、新增的块和移动的块自动合并，双方都修改了的块保留两个版本并使用属性 custom-sync-conflict 标记。
+func mergeSyncConflicts(baseIndex *entity.Index, mergeResult *dejavu.MergeResult, luteEngine *lute.Lute) (unmerged []*entity.File) {
+	var baseFiles map[string]*entity.File
+	var repo *dejavu.Repo
+	if nil != baseIndex {
+		var err error
+		if repo, err = newRepository(); nil == err {
+			if files, getErr := repo.GetFiles(baseIndex); nil == getErr {
+				baseFiles = map[string]*entity.File{}
+				for _, f := range files {
+					if strings.HasSuffix(f.Path, ".sy") {
+						baseFiles[f.Path] = f
+					}
+				}
+			} else {
+				logging.LogErrorf("get files of index [%s] failed: %s", baseIndex.ID, getErr)
+			}
+		}
+	}
+
+	conflictTime := mergeResult.Time.Format("20060102150405")
+	for _, file := range mergeResult.Conflicts {
+		if !strings.HasSuffix(file.Path, ".sy") {
+			unmerged = append(unmerged, file)
+			continue
+		}
+
+		baseFile := baseFiles[file.Path]
+		if nil == baseFile {
+			unmerged = append(unmerged, file)
+			continue
+		}
+
+		if err := mergeSyncConflict(repo, baseFile, file, conflictTime, mergeResult, luteEngine); nil != err {
+			logging.LogWarnf("merge conflicted file [%s] failed: %s", file.Path, err)
+			unmerged = append(unmerged, file)
+		}
+	}
+	return
+}
+
+func mergeSyncConflict(repo *dejavu.Repo, baseFile, file *entity.File, conflictTime string, mergeResult *dejavu.MergeResult, luteEngine *lute.Lute) (err error) {
+	data, err := repo.OpenFile(baseFile)
+	if err != nil {
+		return
+	}
+	baseTree, err := filesys.ParseJSONWithoutFix(data, luteEngine.ParseOptions)
+	if err != nil {
+		return
+	}
+
+	otherPath := filepath.Join(util.TempDir, "repo", "sync", "conflicts", mergeResult.Time.Format("2006-01-02-150405"), file.Path)
+	otherTree, err := loadTree(otherPath, luteEngine)
+	if err != nil {
+		return
+	}
+
+	localPath := filepath.Join(util.DataDir, file.Path)
+	if !filelock.IsExist(localPath) {
+		return errors.New("merged file not found")
+	}
+	tree, err := loadTree(localPath, luteEngine)
+	if err != nil {
+		return
+	}
+
+	if tree.ID != baseTree.ID || tree.ID != otherTree.ID {
+		return errors.New("tree ID mismatch")
+	}
+
+	conflicts := mergeSyncTrees(baseTree, tree, otherTree, conflictTime, luteEngine)
+
+	parts := strings.Split(file.Path[1:], "/")
+	tree.Box = parts[0]
+	tree.Path = strings.TrimPrefix(file.Path, "/"+tree.Box)
+	if err = indexWriteTreeUpsertQueue(tree); err != nil {
+		return
+	}
+	logging.LogInfof("merged conflicted file [%s] with [%d] conflicted blocks", file.Path, conflicts)
+	return
+}
+
+// mergeSyncTrees 将 theirs 相对于 base 的修改合并到 ours 中，返回冲突块数。
+func mergeSyncTrees(base, ours, theirs *parse.Tree, conflictTime string, luteEngine *lute.Lute) (conflicts int) {
+	baseBlocks := indexSyncMergeBlocks(base, luteEngine)
+	ourBlocks := indexSyncMergeBlocks(ours, luteEngine)
+	theirBlocks := indexSyncMergeBlocks(theirs, luteEngine)
+
+	// 合并块内容
+	for id, their := range theirBlocks {
+		our := ourBlocks[id]
+		if nil == our || ast.NodeDocument == our.node.Type {
+			continue
+		}
+
+		baseContent := ""
+		if b := baseBlocks[id]; nil != b {
+			baseContent = b.content
+		}
+		if their.content == our.content || their.content == baseContent {
+			continue
+		}
+
+		if our.node.IsContainerBlock() {
+			// 容器块只比较块属性，逐个属性合并
+			var baseNode *ast.Node
+			if b := baseBlocks[id]; nil != b {
+				baseNode = b.node
+			}
+			if mergeSyncIAL(baseNode, our.node, their.node) {
+				our.node.SetIALAttr(syncConflictAttr, conflictTime)
+				conflicts++
+			}
+			continue
+		}
+
+		if our.content == baseContent {
+			// 只有对方修改了块
+			our.node.InsertBefore(their.node)
+			our.node.Unlink()
+			our.node = their.node
+			continue
+		}
+
+		// 双方都修改了块，保留两个版本
+		our.node.SetIALAttr(syncConflictAttr, conflictTime)
+		their.node.ID = ast.NewNodeID()
+		their.node.SetIALAttr("id", their.node.ID)
+		their.node.SetIALAttr(syncConflictAttr, conflictTime)
+		our.node.InsertAfter(their.node)
+		conflicts++
+	}
+
+	// 按照对方文档顺序合并新增和移动的块，合并过程中会移动对方树上的节点，所以先收集再遍历
+	var theirNodes []*ast.Node
+	ast.Walk(theirs.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if entering && n.IsBlock() && "" != n.ID && ast.NodeDocument != n.Type {
+			theirNodes = append(theirNodes, n)
+		}
+		return ast.WalkContinue
+	})
+	merged := map[string]bool{}
+	for _, n := range theirNodes {
+		their := theirBlocks[n.ID]
+		if nil == their || merged[n.ID] {
+			continue
+		}
+
+		our := ourBlocks[n.ID]
+		b := baseBlocks[n.ID]
+		if nil == our {
+			skip := nil != b && isSyncMergeSubtreeUnchanged(n, baseBlocks, theirBlocks) // 我方删除了块，对方没有修改
+			if !skip {
+				// 对方新增的块，或者我方删除了但对方修改了的块
+				n.Unlink()
+				adoptSyncMergeSubtree(n, ourBlocks)
+				insertSyncMergeBlock(ours, n, their, ourBlocks)
+			}
+			ast.Walk(n, func(c *ast.Node, entering bool) ast.WalkStatus {
+				if entering && c.IsBlock() && "" != c.ID {
+					merged[c.ID] = true
+					if !skip {
+						ourBlocks[c.ID] = &syncMergeBlock{node: c}
+					}
+				}
+				return ast.WalkContinue
+			})
+			continue
+		}
+
+		if nil != b && (their.parentID != b.parentID || their.prevID != b.prevID) &&
+			our.parentID == b.parentID && our.prevID == b.prevID {
+			// 只有对方移动了块
+			our.node.Unlink()
+			insertSyncMergeBlock(ours, our.node, their, ourBlocks)
+		}
+	}
+
+	// 合并对方删除的块
+	var unlinks []*ast.Node
+	for id, b := range baseBlocks {
+		if nil != theirBlocks[id] || ast.NodeDocument == b.node.Type {
+			continue
+		}
+
+		our := ourBlocks[id]
+		if nil == our || nil == our.node.Parent {
+			continue
+		}
+		if isSyncMergeSubtreeUnchanged(our.node, baseBlocks, ourBlocks) {
+			unlinks = append(unlinks, our.node)
+		}
+	}
+	for _, n := range unlinks {
+		n.Unlink()
+	}
+
+	if nil == ours.Root.FirstChild {
+		ours.Root.AppendChild(treenode.NewParagraph(""))
+	}
+	return
+}
+
+// mergeSyncIAL 将 theirs 相对于 base 修改的块属性合并到 ours 中，双方都修改了的属性保留我方的值并返回 true。
+func mergeSyncIAL(base, ours, theirs *ast.Node) (conflicted bool) {
+	baseAttrs := map[string]string{}
+	if nil != base {
+		baseAttrs = parse.IAL2Map(base.KramdownIAL)
+	}
+	ourAttrs := parse.IAL2Map(ours.KramdownIAL)
+	theirAttrs := parse.IAL2Map(theirs.KramdownIAL)
+
+	var keys []string
+	for k := range ourAttrs {
+		keys = append(keys, k)
+	}
+	for k := range theirAttrs {
+		if _, ok := ourAttrs[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if "id" == k || "updated" == k || syncConflictAttr == k {
+			continue
+		}
+
+		baseVal, inBase := baseAttrs[k]
+		ourVal, inOurs := ourAttrs[k]
+		theirVal, inTheirs := theirAttrs[k]
+		if (inOurs == inTheirs && ourVal == theirVal) || (inTheirs == inBase && theirVal == baseVal) {
+			continue
+		}
+
+		if inOurs == inBase && ourVal == baseVal {
+			// 只有对方修改了属性
+			if inTheirs {
+				ours.SetIALAttr(k, theirVal)
+			} else {
+				ours.RemoveIALAttr(k)
+			}
+			continue
+		}
+		conflicted = true
+	}
+	return
+}
+
+// indexSyncMergeBlocks 索引树中所有的块，记录块的位置和内容摘要。
+func indexSyncMergeBlocks(tree *parse.Tree, luteEngine *lute.Lute) (ret map[string]*syncMergeBlock) {
+	ret = map[string]*syncMergeBlock{}
+	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !n.IsBlock() || "" == n.ID {
+			return ast.WalkContinue
+		}
+
+		block := &syncMergeBlock{node: n, content: syncMergeContent(n, luteEngine)}
+		if nil != n.Parent {
+			block.parentID = n.Parent.ID
+		}
+		for prev := n.Previous; nil != prev; prev = prev.Previous {
+			if "" != prev.ID {
+				block.prevID = prev.ID
+				break
+			}
+		}
+		ret[n.ID] = block
+		return ast.WalkContinue
+	})
+	return
+}
+
+// syncMergeContent 返回块自身的内容摘要，容器块只包含块属性，忽略更新时间属性。
+func syncMergeContent(n *ast.Node, luteEngine *lute.Lute) string {
+	buf := &strings.Builder{}
+	buf.WriteString(n.Type.String())
+	var attrs []string
+	for _, kv := range n.KramdownIAL {
+		if "updated" == kv[0] {
+			continue
+		}
+		attrs = append(attrs, kv[0]+"="+kv[1])
+	}
+	sort.Strings(attrs)
+	buf.WriteString(strings.Join(attrs, " "))
+
+	if !n.IsContainerBlock() && ast.NodeDocument != n.Type {
+		buf.WriteString("\n")
+		buf.WriteString(treenode.ExportNodeStdMd(n, luteEngine))
+	}
+	return buf.String()
+}
+
+func isSyncMergeSubtreeUnchanged(n *ast.Node, baseBlocks, blocks map[string]*syncMergeBlock) (ret bool) {
+	ret = true
+	ast.Walk(n, func(c *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || !c.IsBlock() || "" == c.ID {
+			return ast.WalkContinue
+		}
+
+		b, cur := baseBlocks[c.ID], blocks[c.ID]
+		if nil == b || nil == cur || b.content != cur.content {
+			ret = false
+			return ast.WalkStop
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// adoptSyncMergeSubtree 将即将插入的子树中我方已有的块替换为我方的块，避免出现重复的块。
+func adoptSyncMergeSubtree(node *ast.Node, ourBlocks map[string]*syncMergeBlock) {
+	var replaces []*ast.Node
+	ast.Walk(node, func(c *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || c == node || !c.IsBlock() || "" == c.ID {
+			return ast.WalkContinue
+		}
+		if nil != ourBlocks[c.ID] {
+			replaces = append(replaces, c)
+			return ast.WalkSkipChildren
+		}
+		return ast.WalkContinue
+	})
+
+	for _, c := range replaces {
+		our := ourBlocks[c.ID].node
+		our.Unlink()
+		c.InsertBefore(our)
+		c.Unlink()
+	}
+}
+
+// insertSyncMergeBlock 按照对方文档中的位置插入块，找不到位置时插入到文档末尾。
+func insertSyncMergeBlock(tree *parse.Tree, node *ast.Node, their *syncMergeBlock, ourBlocks map[string]*syncMergeBlock) {
+	if prev := ourBlocks[their.prevID]; nil != prev && nil != prev.node.Parent && prev.node.Parent.ID == their.parentID {
+		prev.node.InsertAfter(node)
+		return
+	}
+
+	parent := tree.Root
+	if p := ourBlocks[their.parentID]; nil != p && nil != p.node.Parent {
+		parent = p.node
+	}
+
+	for c := parent.FirstChild; nil != c; c = c.Next {
+		if "" != c.ID {
+			c.InsertBefore(node)
+			return
+		}
+	}
+	if last := parent.LastChild; nil != last && ast.NodeSuperBlockCloseMarker == last.Type {
+		last.InsertBefore(node)
+		return
+	}
+	parent.AppendChild(node)
+}
@@ -0,0 +1,197 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const syncMergeTestBase = "foo\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar\n{: id=\"20240101000000-bbbbbbb\"}\n"
+
+func TestMergeSyncTrees(t *testing.T) {
+	cases := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      []string
+		conflicts int
+	}{
+		{
+			name:   "only theirs edited",
+			base:   syncMergeTestBase,
+			ours:   syncMergeTestBase,
+			theirs: "foo\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar2\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			want:   []string{"foo", "bar2"},
+		},
+		{
+			name:   "only ours edited",
+			base:   syncMergeTestBase,
+			ours:   "foo1\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			theirs: syncMergeTestBase,
+			want:   []string{"foo1", "bar"},
+		},
+		{
+			name:   "concurrent edits on different blocks",
+			base:   syncMergeTestBase,
+			ours:   "foo1\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			theirs: "foo\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar2\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			want:   []string{"foo1", "bar2"},
+		},
+		{
+			name:      "concurrent edits on the same block",
+			base:      syncMergeTestBase,
+			ours:      "foo1\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			theirs:    "foo2\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			want:      []string{"foo1", "foo2", "bar"},
+			conflicts: 1,
+		},
+		{
+			name:   "ours deleted, theirs edited",
+			base:   syncMergeTestBase,
+			ours:   "foo\n{: id=\"20240101000000-aaaaaaa\"}\n",
+			theirs: "foo\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar2\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			want:   []string{"foo", "bar2"},
+		},
+		{
+			name:   "ours edited, theirs deleted",
+			base:   syncMergeTestBase,
+			ours:   "foo\n{: id=\"20240101000000-aaaaaaa\"}\n\nbar1\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			theirs: "foo\n{: id=\"20240101000000-aaaaaaa\"}\n",
+			want:   []string{"foo", "bar1"},
+		},
+		{
+			name:   "theirs deleted unchanged block",
+			base:   syncMergeTestBase,
+			ours:   syncMergeTestBase,
+			theirs: "foo\n{: id=\"20240101000000-aaaaaaa\"}\n",
+			want:   []string{"foo"},
+		},
+		{
+			name:   "theirs added block",
+			base:   syncMergeTestBase,
+			ours:   syncMergeTestBase,
+			theirs: "foo\n{: id=\"20240101000000-aaaaaaa\"}\n\nbaz\n{: id=\"20240101000000-ccccccc\"}\n\nbar\n{: id=\"20240101000000-bbbbbbb\"}\n",
+			want:   []string{"foo", "baz", "bar"},
+		},
+	}
+
+	luteEngine := util.NewLute()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			base := parseSyncMergeTestTree(c.base, luteEngine.ParseOptions)
+			ours := parseSyncMergeTestTree(c.ours, luteEngine.ParseOptions)
+			theirs := parseSyncMergeTestTree(c.theirs, luteEngine.ParseOptions)
+
+			conflicts := mergeSyncTrees(base, ours, theirs, "20240102000000", luteEngine)
+			if c.conflicts != conflicts {
+				t.Fatalf("conflicts: want [%d], got [%d]", c.conflicts, conflicts)
+			}
+
+			var got []string
+			var marked int
+			ast.Walk(ours.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+				if entering && ast.NodeParagraph == n.Type {
+					got = append(got, n.Text())
+					if "" != n.IALAttr(syncConflictAttr) {
+						marked++
+					}
+				}
+				return ast.WalkContinue
+			})
+			if strings.Join(c.want, "|") != strings.Join(got, "|") {
+				t.Fatalf("blocks: want %q, got %q", c.want, got)
+			}
+			if 0 < c.conflicts && 2*c.conflicts != marked {
+				t.Fatalf("conflict marks: want [%d], got [%d]", 2*c.conflicts, marked)
+			}
+		})
+	}
+}
+
+func TestMergeSyncIAL(t *testing.T) {
+	cases := []struct {
+		name       string
+		base       [][]string
+		ours       [][]string
+		theirs     [][]string
+		want       map[string]string
+		conflicted bool
+	}{
+		{
+			name:   "theirs changed",
+			base:   [][]string{{"custom-a", "1"}},
+			ours:   [][]string{{"custom-a", "1"}},
+			theirs: [][]string{{"custom-a", "2"}},
+			want:   map[string]string{"custom-a": "2"},
+		},
+		{
+			name:   "different keys changed",
+			base:   [][]string{{"custom-a", "1"}},
+			ours:   [][]string{{"custom-a", "2"}},
+			theirs: [][]string{{"custom-a", "1"}, {"custom-b", "1"}},
+			want:   map[string]string{"custom-a": "2", "custom-b": "1"},
+		},
+		{
+			name:   "theirs removed",
+			base:   [][]string{{"custom-a", "1"}, {"custom-b", "1"}},
+			ours:   [][]string{{"custom-a", "2"}, {"custom-b", "1"}},
+			theirs: [][]string{{"custom-a", "1"}},
+			want:   map[string]string{"custom-a": "2"},
+		},
+		{
+			name:       "same key changed",
+			base:       [][]string{{"custom-a", "1"}},
+			ours:       [][]string{{"custom-a", "2"}},
+			theirs:     [][]string{{"custom-a", "3"}},
+			want:       map[string]string{"custom-a": "2"},
+			conflicted: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			base := &ast.Node{Type: ast.NodeBlockquote, KramdownIAL: c.base}
+			ours := &ast.Node{Type: ast.NodeBlockquote, KramdownIAL: c.ours}
+			theirs := &ast.Node{Type: ast.NodeBlockquote, KramdownIAL: c.theirs}
+			if conflicted := mergeSyncIAL(base, ours, theirs); c.conflicted != conflicted {
+				t.Fatalf("conflicted: want [%t], got [%t]", c.conflicted, conflicted)
+			}
+
+			got := parse.IAL2Map(ours.KramdownIAL)
+			if len(c.want) != len(got) {
+				t.Fatalf("attrs: want %v, got %v", c.want, got)
+			}
+			for k, v := range c.want {
+				if got[k] != v {
+					t.Fatalf("attrs: want %v, got %v", c.want, got)
+				}
+			}
+		})
+	}
+}
+
+func parseSyncMergeTestTree(kramdown string, options *parse.Options) (ret *parse.Tree) {
+	ret = parse.Parse("", []byte(kramdown), options)
+	normalizeTree(ret)
+	return
+}
@@ -0,0 +1,348 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/lute"
+	"github.com/dustin/go-humanize"
+	ignore "github.com/sabhiram/go-gitignore"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+// SyncPlan 描述了同步将要执行的变更，由 PreviewSync 计算得出，不会修改本地数据。
+type SyncPlan struct {
+	LocalIndex *DiffIndex          `json:"localIndex"` // 本地最新快照
+	CloudIndex *DiffIndex          `json:"cloudIndex"` // 云端最新快照，云端还没有数据时为空
+	BaseIndex  *DiffIndex          `json:"baseIndex"`  // 合并基准，即上次同步完成时的快照，没有基准时为空
+	Upserts    []*DiffFile         `json:"upserts"`    // 云端新增或修改的文件，同步后会更新到本地
+	Removes    []*DiffFile         `json:"removes"`    // 云端删除的文件，同步后会从本地删除
+	Uploads    []*DiffFile         `json:"uploads"`    // 本地新增、修改或删除的文件，同步后会更新到云端
+	Conflicts  []*SyncPlanConflict `json:"conflicts"`  // 本地和云端都修改过的文件
+}
+
+// SyncPlanConflict 描述了一个冲突文件，一端删除时对应的文件为空。
+type SyncPlanConflict struct {
+	Local *DiffFile `json:"local"`
+	Cloud *DiffFile `json:"cloud"`
+}
+
+var (
+	pendingSyncPlan     *SyncPlan
+	pendingSyncPlanTime time.Time
+	pendingSyncPlanLock = sync.Mutex{}
+)
+
+// pendingSyncPlanTimeout 预览后等待确认的最长时间，超时后恢复自动同步。
+const pendingSyncPlanTimeout = 30 * time.Minute
+
+// isSyncPlanPending 判断是否有等待确认的同步预览，等待确认期间暂停自动同步。
+func isSyncPlanPending() bool {
+	pendingSyncPlanLock.Lock()
+	defer pendingSyncPlanLock.Unlock()
+
+	if nil != pendingSyncPlan && pendingSyncPlanTimeout < time.Since(pendingSyncPlanTime) {
+		pendingSyncPlan = nil
+	}
+	return nil != pendingSyncPlan
+}
+
+// PreviewSync 拉取云端最新快照并计算同步计划，不会修改本地数据。
+//
+// 本地数据会先索引为快照，合并基准为上次同步完成时的快照，没有基准时两端不一致的文件都视为冲突。
+func PreviewSync() (ret *SyncPlan, err error) {
+	if 1 > len(Conf.Repo.Key) {
+		err = errors.New(Conf.Language(26))
+		return
+	}
+	if !Conf.Sync.Enabled {
+		err = errors.New(Conf.Language(124))
+		return
+	}
+	if !isProviderOnline(true) {
+		err = errors.New(Conf.Language(28))
+		return
+	}
+
+	lockSync()
+	defer unlockSync()
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+
+	_, localIndex, err := indexRepoBeforeCloudSync(repo)
+	if err != nil {
+		return
+	}
+	localFiles, err := repo.GetFiles(localIndex)
+	if err != nil {
+		return
+	}
+
+	syncContext := map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar}
+	var cloudFiles []*entity.File
+	cloudIndex, err := repo.GetCloudLatest(syncContext)
+	if err != nil {
+		if !errors.Is(err, cloud.ErrCloudObjectNotFound) {
+			return
+		}
+		err = nil
+		cloudIndex = nil
+	}
+	if nil != cloudIndex {
+		if _, err = repo.GetSyncCloudFiles(cloudIndex, syncContext); err != nil {
+			return
+		}
+		if cloudFiles, err = repo.GetFiles(cloudIndex); err != nil {
+			return
+		}
+	}
+
+	// 不参与同步的路径使用云端的版本，和同步时计算上传和合并的文件一致
+	if ignoreLines := getSyncScopeIgnoreLines(); 0 < len(ignoreLines) {
+		localFiles, _ = scopeSyncFiles(localFiles, cloudFiles, ignore.CompileIgnoreLines(ignoreLines...))
+	}
+
+	// 和同步后的块级合并使用同一个基准
+	var baseFiles []*entity.File
+	baseIndex := getSyncBaseIndex(repo)
+	if nil != baseIndex {
+		if baseFiles, err = repo.GetFiles(baseIndex); err != nil {
+			return
+		}
+	}
+
+	ret = buildSyncPlan(repo, localFiles, cloudFiles, baseFiles, nil != baseIndex)
+	ret.LocalIndex = &DiffIndex{ID: localIndex.ID, Created: localIndex.Created}
+	if nil != cloudIndex {
+		ret.CloudIndex = &DiffIndex{ID: cloudIndex.ID, Created: cloudIndex.Created}
+	}
+	if nil != baseIndex {
+		ret.BaseIndex = &DiffIndex{ID: baseIndex.ID, Created: baseIndex.Created}
+	}
+
+	logging.LogInfof("previewed sync [%s]", ret)
+
+	pendingSyncPlanLock.Lock()
+	pendingSyncPlan = ret
+	pendingSyncPlanTime = time.Now()
+	pendingSyncPlanLock.Unlock()
+	return
+}
+
+// ConfirmSync 按照预览结果执行同步，本地数据、云端数据或者合并基准在预览后发生变化时需要重新预览。
+func ConfirmSync(cloudIndexID string) (err error) {
+	pendingSyncPlanLock.Lock()
+	plan := pendingSyncPlan
+	pendingSyncPlanLock.Unlock()
+	if nil == plan {
+		err = errors.New("no sync preview to confirm")
+		return
+	}
+
+	previewed := ""
+	if nil != plan.CloudIndex {
+		previewed = plan.CloudIndex.ID
+	}
+	if cloudIndexID != previewed {
+		err = errors.New("sync preview has changed, please preview again")
+		return
+	}
+
+	if err = checkSyncPlanLocal(plan); err != nil {
+		return
+	}
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+	latest := ""
+	cloudIndex, err := repo.GetCloudLatest(map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar})
+	if err != nil {
+		if !errors.Is(err, cloud.ErrCloudObjectNotFound) {
+			return
+		}
+		err = nil
+	} else {
+		latest = cloudIndex.ID
+	}
+	if latest != previewed {
+		err = errors.New("cloud data has changed since the preview, please preview again")
+		return
+	}
+
+	CancelSync()
+	SyncData(true)
+	return
+}
+
+// checkSyncPlanLocal 重新索引本地数据，检查本地快照和合并基准是否和预览时一致。
+func checkSyncPlanLocal(plan *SyncPlan) (err error) {
+	lockSync()
+	defer unlockSync()
+
+	repo, err := newRepository()
+	if err != nil {
+		return
+	}
+	_, localIndex, err := indexRepoBeforeCloudSync(repo)
+	if err != nil {
+		return
+	}
+	if localIndex.ID != plan.LocalIndex.ID {
+		err = errors.New("local data has changed since the preview, please preview again")
+		return
+	}
+
+	base := ""
+	if nil != plan.BaseIndex {
+		base = plan.BaseIndex.ID
+	}
+	if baseIndex := getSyncBaseIndex(repo); (nil == baseIndex && "" != base) || (nil != baseIndex && baseIndex.ID != base) {
+		err = errors.New("sync base has changed since the preview, please preview again")
+		return
+	}
+	return
+}
+
+// CancelSync 取消等待确认的同步预览并恢复自动同步。
+func CancelSync() {
+	pendingSyncPlanLock.Lock()
+	pendingSyncPlan = nil
+	pendingSyncPlanLock.Unlock()
+}
+
+func buildSyncPlan(repo *dejavu.Repo, localFiles, cloudFiles, baseFiles []*entity.File, hasBase bool) (ret *SyncPlan) {
+	ret = &SyncPlan{Upserts: []*DiffFile{}, Removes: []*DiffFile{}, Uploads: []*DiffFile{}, Conflicts: []*SyncPlanConflict{}}
+
+	locals, clouds, bases := map[string]*entity.File{}, map[string]*entity.File{}, map[string]*entity.File{}
+	paths := map[string]bool{}
+	for _, f := range localFiles {
+		locals[f.Path] = f
+		paths[f.Path] = true
+	}
+	for _, f := range cloudFiles {
+		clouds[f.Path] = f
+		paths[f.Path] = true
+	}
+	for _, f := range baseFiles {
+		bases[f.Path] = f
+	}
+
+	downloadOnlyBoxIDs := getDownloadOnlyBoxIDs()
+	luteEngine := NewLute()
+	var sortedPaths []string
+	for p := range paths {
+		sortedPaths = append(sortedPaths, p)
+	}
+	sort.Strings(sortedPaths)
+	for _, p := range sortedPaths {
+		local, cloudFile, base := locals[p], clouds[p], bases[p]
+		if isDownloadOnlyPath(p, downloadOnlyBoxIDs) {
+			local = base // 仅下载的笔记本在同步前会丢弃本地修改
+		}
+		if isSameSyncFile(local, cloudFile) {
+			continue
+		}
+
+		localChanged, cloudChanged := !isSameSyncFile(local, base), !isSameSyncFile(cloudFile, base)
+		if !hasBase {
+			localChanged, cloudChanged = nil != local, nil != cloudFile
+		}
+		switch {
+		case localChanged && cloudChanged:
+			ret.Conflicts = append(ret.Conflicts, &SyncPlanConflict{
+				Local: newSyncPlanFile(repo, local, true, luteEngine),
+				Cloud: newSyncPlanFile(repo, cloudFile, false, luteEngine),
+			})
+		case localChanged:
+			if nil == local {
+				ret.Uploads = append(ret.Uploads, newSyncPlanFile(repo, base, true, luteEngine))
+			} else {
+				ret.Uploads = append(ret.Uploads, newSyncPlanFile(repo, local, true, luteEngine))
+			}
+		case cloudChanged:
+			if nil == cloudFile {
+				ret.Removes = append(ret.Removes, newSyncPlanFile(repo, local, true, luteEngine))
+			} else {
+				ret.Upserts = append(ret.Upserts, newSyncPlanFile(repo, cloudFile, false, luteEngine))
+			}
+		}
+	}
+	return
+}
+
+func isSameSyncFile(f1, f2 *entity.File) bool {
+	if nil == f1 || nil == f2 {
+		return f1 == f2
+	}
+	return f1.ID == f2.ID
+}
+
+func isDownloadOnlyPath(p string, boxIDs []string) bool {
+	for _, boxID := range boxIDs {
+		if strings.HasPrefix(p, "/"+boxID+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// newSyncPlanFile 构建同步计划中的文件，云端文件的内容还没有下载，文档标题优先从本地块树中获取。
+func newSyncPlanFile(repo *dejavu.Repo, file *entity.File, local bool, luteEngine *lute.Lute) *DiffFile {
+	if nil == file {
+		return nil
+	}
+
+	title := path.Base(file.Path)
+	if strings.HasSuffix(file.Path, ".sy") {
+		if bt := treenode.GetBlockTree(strings.TrimSuffix(title, ".sy")); nil != bt {
+			title = path.Base(bt.HPath)
+		} else if local {
+			if snapshotTitle, err := parseTitleInSnapshot(file.ID, repo, luteEngine); nil == err && "" != snapshotTitle {
+				title = snapshotTitle
+			}
+		}
+	}
+
+	return &DiffFile{
+		FileID:  file.ID,
+		Title:   title,
+		Path:    file.Path,
+		HSize:   humanize.BytesCustomCeil(uint64(file.Size), 2),
+		Updated: file.Updated,
+	}
+}
+
+func (plan *SyncPlan) String() string {
+	return fmt.Sprintf("upserts=%d, removes=%d, uploads=%d, conflicts=%d", len(plan.Upserts), len(plan.Removes), len(plan.Uploads), len(plan.Conflicts))
+}
@@ -0,0 +1,160 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestBuildSyncPlan(t *testing.T) {
+	const downloadOnly = "20240101000000-aaaaaaa"
+	oldConf := Conf
+	Conf = &AppConf{
+		Editor: conf.NewEditor(),
+		Export: conf.NewExport(),
+		Sync:   &conf.Sync{Notebooks: map[string]int{downloadOnly: conf.SyncScopeDownloadOnly}},
+	}
+	t.Cleanup(func() { Conf = oldConf })
+
+	p := "/20240101000000-bbbbbbb/a.json"
+	d := "/" + downloadOnly + "/b.json"
+	file := func(path, id string) *entity.File { return &entity.File{Path: path, ID: id} }
+	cases := []struct {
+		name               string
+		local, cloud, base []*entity.File
+		hasBase            bool
+		want               string
+	}{
+		{
+			name:    "unchanged",
+			local:   []*entity.File{file(p, "1")},
+			cloud:   []*entity.File{file(p, "1")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+		},
+		{
+			name:    "local changed",
+			local:   []*entity.File{file(p, "2")},
+			cloud:   []*entity.File{file(p, "1")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+			want:    "upload " + p + "@2",
+		},
+		{
+			name:    "local removed",
+			cloud:   []*entity.File{file(p, "1")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+			want:    "upload " + p + "@1",
+		},
+		{
+			name:    "cloud changed",
+			local:   []*entity.File{file(p, "1")},
+			cloud:   []*entity.File{file(p, "2")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+			want:    "upsert " + p + "@2",
+		},
+		{
+			name:    "cloud removed",
+			local:   []*entity.File{file(p, "1")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+			want:    "remove " + p + "@1",
+		},
+		{
+			name:    "both changed",
+			local:   []*entity.File{file(p, "2")},
+			cloud:   []*entity.File{file(p, "3")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+			want:    "conflict " + p + "@2/3",
+		},
+		{
+			name:    "both changed to the same file",
+			local:   []*entity.File{file(p, "2")},
+			cloud:   []*entity.File{file(p, "2")},
+			base:    []*entity.File{file(p, "1")},
+			hasBase: true,
+		},
+		{
+			name:    "download-only local change is discarded",
+			local:   []*entity.File{file(d, "2")},
+			cloud:   []*entity.File{file(d, "1")},
+			base:    []*entity.File{file(d, "1")},
+			hasBase: true,
+		},
+		{
+			name:    "download-only local change is overwritten by cloud",
+			local:   []*entity.File{file(d, "2")},
+			cloud:   []*entity.File{file(d, "3")},
+			base:    []*entity.File{file(d, "1")},
+			hasBase: true,
+			want:    "upsert " + d + "@3",
+		},
+		{
+			name:    "download-only local file is not uploaded",
+			local:   []*entity.File{file(d, "2")},
+			hasBase: true,
+		},
+		{
+			name:  "no base uploads local-only files",
+			local: []*entity.File{file(p, "1")},
+			want:  "upload " + p + "@1",
+		},
+		{
+			name:  "no base downloads cloud-only files",
+			cloud: []*entity.File{file(p, "1")},
+			want:  "upsert " + p + "@1",
+		},
+		{
+			name:  "no base treats different files as conflicts",
+			local: []*entity.File{file(p, "1")},
+			cloud: []*entity.File{file(p, "2")},
+			base:  []*entity.File{file(p, "1")},
+			want:  "conflict " + p + "@1/2",
+		},
+	}
+
+	for _, c := range cases {
+		plan := buildSyncPlan(nil, c.local, c.cloud, c.base, c.hasBase)
+		if got := syncPlanTestString(plan); c.want != got {
+			t.Errorf("%s: want [%s], got [%s]", c.name, c.want, got)
+		}
+	}
+}
+
+func syncPlanTestString(plan *SyncPlan) string {
+	var ret []string
+	for _, f := range plan.Upserts {
+		ret = append(ret, "upsert "+f.Path+"@"+f.FileID)
+	}
+	for _, f := range plan.Removes {
+		ret = append(ret, "remove "+f.Path+"@"+f.FileID)
+	}
+	for _, f := range plan.Uploads {
+		ret = append(ret, "upload "+f.Path+"@"+f.FileID)
+	}
+	for _, c := range plan.Conflicts {
+		ret = append(ret, "conflict "+c.Local.Path+"@"+c.Local.FileID+"/"+c.Cloud.FileID)
+	}
+	return strings.Join(ret, ", ")
+}
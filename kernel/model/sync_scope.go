@@ -0,0 +1,343 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	ignore "github.com/sabhiram/go-gitignore"
+	"github.com/siyuan-note/dejavu"
+	"github.com/siyuan-note/dejavu/cloud"
+	"github.com/siyuan-note/dejavu/entity"
+	"github.com/siyuan-note/eventbus"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/cache"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// SetSyncNotebookScope 设置笔记本在当前设备上的同步范围。
+//
+// 同步范围保存在当前设备的 conf.json 中，不会同步到其他设备，所以每台设备可以有各自的同步范围。
+func SetSyncNotebookScope(boxID string, scope int) (err error) {
+	if !ast.IsNodeIDPattern(boxID) {
+		err = ErrBoxNotFound
+		return
+	}
+
+	switch scope {
+	case conf.SyncScopeSync, conf.SyncScopeLocalOnly, conf.SyncScopeDownloadOnly:
+	default:
+		err = errors.New("invalid sync scope")
+		return
+	}
+
+	if conf.SyncScopeSync == scope {
+		delete(Conf.Sync.Notebooks, boxID)
+	} else {
+		Conf.Sync.Notebooks[boxID] = scope
+	}
+	Conf.Save()
+	return
+}
+
+func SetSyncExcludePaths(paths []string) {
+	var excludePaths []string
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if "" == p || strings.HasPrefix(p, "#") {
+			continue
+		}
+		excludePaths = append(excludePaths, p)
+	}
+	Conf.Sync.ExcludePaths = gulu.Str.RemoveDuplicatedElem(excludePaths)
+	Conf.Save()
+}
+
+// getSyncScopeIgnoreLines 返回当前设备不参与同步的路径。
+func getSyncScopeIgnoreLines() (ret []string) {
+	for boxID, scope := range Conf.Sync.Notebooks {
+		if conf.SyncScopeLocalOnly == scope {
+			ret = append(ret, boxID+"/**/*")
+		}
+	}
+	ret = append(ret, Conf.Sync.ExcludePaths...)
+	return
+}
+
+// indexSyncScope 基于本地最新快照构建应用了同步范围的快照并设置为最新快照，数据同步以该快照计算上传和合并的文件。
+//
+// 同步范围不能作为数据仓库的忽略规则，否则本地快照中会缺少这些文件，同步时会被当作本地删除推送到云端。
+// 下次索引时本地最新快照会恢复为完整的本地数据。
+func indexSyncScope(repo *dejavu.Repo) (err error) {
+	ignoreLines := getSyncScopeIgnoreLines()
+	if 1 > len(ignoreLines) {
+		return
+	}
+
+	latest, err := repo.Latest()
+	if err != nil {
+		return
+	}
+	localFiles, err := repo.GetFiles(latest)
+	if err != nil {
+		return
+	}
+
+	syncContext := map[string]interface{}{eventbus.CtxPushMsg: eventbus.CtxPushMsgToStatusBar}
+	var cloudFiles []*entity.File
+	cloudLatest, err := repo.GetCloudLatest(syncContext)
+	if err != nil {
+		if !errors.Is(err, cloud.ErrCloudObjectNotFound) {
+			return
+		}
+		err = nil
+	} else {
+		if _, err = repo.GetSyncCloudFiles(cloudLatest, syncContext); err != nil {
+			return
+		}
+		if cloudFiles, err = repo.GetFiles(cloudLatest); err != nil {
+			return
+		}
+	}
+
+	files, changed := scopeSyncFiles(localFiles, cloudFiles, ignore.CompileIgnoreLines(ignoreLines...))
+	if !changed {
+		return
+	}
+
+	index := &entity.Index{
+		Memo:       "[Sync] Cloud sync scope",
+		Created:    time.Now().UnixMilli(),
+		SystemID:   Conf.System.ID,
+		SystemName: Conf.System.Name,
+		SystemOS:   Conf.System.OS,
+	}
+	hash := sha1.New()
+	for _, f := range files {
+		index.Files = append(index.Files, f.ID)
+		index.Size += f.Size
+		hash.Write([]byte(f.ID))
+	}
+	index.Count = len(index.Files)
+	index.ID = fmt.Sprintf("%x", hash.Sum(nil))
+	if err = repo.PutIndex(index); err != nil {
+		return
+	}
+	if err = repo.UpdateLatest(index); err != nil {
+		return
+	}
+	logging.LogInfof("indexed sync scope [%s] from local latest [%s]", index.ID, latest.ID)
+	return
+}
+
+// scopeSyncFiles 将本地快照中不参与同步的路径替换为云端的版本，这些路径在本地和云端之间没有差异，既不会上传和删除，也不会下载覆盖本地数据。
+func scopeSyncFiles(localFiles, cloudFiles []*entity.File, matcher *ignore.GitIgnore) (ret []*entity.File, changed bool) {
+	scopedLocals := map[string]string{}
+	for _, f := range localFiles {
+		if matcher.MatchesPath(f.Path) {
+			scopedLocals[f.Path] = f.ID
+			continue
+		}
+		ret = append(ret, f)
+	}
+
+	scopedClouds := 0
+	for _, f := range cloudFiles {
+		if !matcher.MatchesPath(f.Path) {
+			continue
+		}
+		ret = append(ret, f)
+		scopedClouds++
+		if scopedLocals[f.Path] != f.ID {
+			changed = true
+		}
+	}
+	changed = changed || scopedClouds != len(scopedLocals)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+	return
+}
+
+func getDownloadOnlyBoxIDs() (ret []string) {
+	for boxID, scope := range Conf.Sync.Notebooks {
+		if conf.SyncScopeDownloadOnly == scope {
+			ret = append(ret, boxID)
+		}
+	}
+	return
+}
+
+// recordSyncedIndex 记录同步完成后的快照，仅下载的笔记本以该快照为准。
+func recordSyncedIndex(repo *dejavu.Repo) {
+	latest, err := repo.Latest()
+	if err != nil {
+		logging.LogErrorf("get latest index failed: %s", err)
+		return
+	}
+	Conf.Sync.SyncedIndex = latest.ID
+}
+
+// revertDownloadOnlyNotebooks 在同步前将仅下载的笔记本恢复到上次同步完成时的状态，本地修改不会被上传。
+//
+// 被还原的本地修改会先保存到数据历史中。
+func revertDownloadOnlyNotebooks(repo *dejavu.Repo) {
+	boxIDs := getDownloadOnlyBoxIDs()
+	if 1 > len(boxIDs) || "" == Conf.Sync.SyncedIndex {
+		return
+	}
+
+	// 先将事务队列中的修改写入磁盘，否则这些修改会在还原之后写入，导致还原失效并被上传
+	FlushTxQueue()
+
+	index, err := repo.GetIndex(Conf.Sync.SyncedIndex)
+	if err != nil {
+		logging.LogErrorf("get synced index [%s] failed: %s", Conf.Sync.SyncedIndex, err)
+		return
+	}
+	files, err := repo.GetFiles(index)
+	if err != nil {
+		logging.LogErrorf("get files of synced index [%s] failed: %s", index.ID, err)
+		return
+	}
+
+	var upserts, removes []string
+	var historyDir string
+	for _, boxID := range boxIDs {
+		boxFiles := map[string]*entity.File{}
+		for _, f := range files {
+			if strings.HasPrefix(f.Path, "/"+boxID+"/") {
+				boxFiles[f.Path] = f
+			}
+		}
+
+		boxUpserts, boxRemoves := revertDownloadOnlyNotebook(repo, boxID, boxFiles, &historyDir)
+		upserts = append(upserts, boxUpserts...)
+		removes = append(removes, boxRemoves...)
+	}
+
+	if 1 > len(upserts) && 1 > len(removes) {
+		return
+	}
+
+	logging.LogInfof("reverted download-only notebooks [upserts=%d, removes=%d]", len(upserts), len(removes))
+	if "" != historyDir {
+		indexHistoryDir(filepath.Base(historyDir), util.NewLute())
+	}
+	incReindex(upserts, removes)
+	cache.ClearDocsIAL()
+	util.ReloadUI()
+}
+
+func revertDownloadOnlyNotebook(repo *dejavu.Repo, boxID string, files map[string]*entity.File, historyDir *string) (upserts, removes []string) {
+	backup := func(p, absPath string) {
+		if "" == *historyDir {
+			var err error
+			if *historyDir, err = GetHistoryDir(HistoryOpSync); err != nil {
+				logging.LogErrorf("get history dir failed: %s", err)
+				return
+			}
+		}
+		if err := filelock.Copy(absPath, filepath.Join(*historyDir, filepath.FromSlash(p))); err != nil {
+			logging.LogErrorf("backup [%s] to history failed: %s", absPath, err)
+		}
+	}
+
+	locals := map[string]bool{}
+	boxDir := filepath.Join(util.DataDir, boxID)
+	filepath.WalkDir(boxDir, func(absPath string, d fs.DirEntry, err error) error {
+		if nil != err || d.IsDir() {
+			return err
+		}
+
+		rel, _ := filepath.Rel(util.DataDir, absPath)
+		p := "/" + filepath.ToSlash(rel)
+		locals[p] = true
+		f := files[p]
+		if nil == f {
+			// 本地新增的文件
+			backup(p, absPath)
+			if removeErr := filelock.Remove(absPath); nil != removeErr {
+				logging.LogErrorf("remove [%s] failed: %s", absPath, removeErr)
+				return nil
+			}
+			removes = append(removes, p)
+			return nil
+		}
+
+		info, infoErr := d.Info()
+		if nil != infoErr || (info.Size() == f.Size && info.ModTime().UnixMilli() == f.Updated) {
+			return nil
+		}
+
+		// 本地修改的文件
+		data, openErr := repo.OpenFile(f)
+		if nil != openErr {
+			logging.LogErrorf("open file [%s] in repo failed: %s", f.Path, openErr)
+			return nil
+		}
+		if local, readErr := filelock.ReadFile(absPath); nil == readErr && bytes.Equal(local, data) {
+			return nil
+		}
+		backup(p, absPath)
+		if restoreErr := restoreSyncedFile(data, f, absPath); nil == restoreErr {
+			upserts = append(upserts, p)
+		}
+		return nil
+	})
+
+	for p, f := range files {
+		if locals[p] {
+			continue
+		}
+
+		// 本地删除的文件
+		data, openErr := repo.OpenFile(f)
+		if nil != openErr {
+			logging.LogErrorf("open file [%s] in repo failed: %s", f.Path, openErr)
+			continue
+		}
+		if restoreErr := restoreSyncedFile(data, f, filepath.Join(util.DataDir, filepath.FromSlash(p))); nil == restoreErr {
+			upserts = append(upserts, p)
+		}
+	}
+	return
+}
+
+func restoreSyncedFile(data []byte, file *entity.File, absPath string) (err error) {
+	if err = os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return
+	}
+	if err = filelock.WriteFile(absPath, data); err != nil {
+		logging.LogErrorf("restore file [%s] failed: %s", absPath, err)
+		return
+	}
+	updated := time.UnixMilli(file.Updated)
+	os.Chtimes(absPath, updated, updated)
+	return
+}
@@ -0,0 +1,89 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	ignore "github.com/sabhiram/go-gitignore"
+	"github.com/siyuan-note/dejavu/entity"
+)
+
+func TestScopeSyncFiles(t *testing.T) {
+	const localOnly = "20240101000000-aaaaaaa"
+	matcher := ignore.CompileIgnoreLines(localOnly+"/**/*", "/assets/private/")
+
+	cases := []struct {
+		name        string
+		local       []*entity.File
+		cloud       []*entity.File
+		want        string
+		wantChanged bool
+	}{
+		{
+			name:  "no scoped files",
+			local: []*entity.File{{Path: "/box/a.sy", ID: "1"}},
+			cloud: []*entity.File{{Path: "/box/a.sy", ID: "2"}},
+			want:  "/box/a.sy=1",
+		},
+		{
+			name: "local-only notebook is not uploaded",
+			local: []*entity.File{
+				{Path: "/box/a.sy", ID: "1"},
+				{Path: "/" + localOnly + "/b.sy", ID: "2"},
+				{Path: "/" + localOnly + "/sub/c.sy", ID: "3"},
+			},
+			want:        "/box/a.sy=1",
+			wantChanged: true,
+		},
+		{
+			name:        "cloud files under scope are kept instead of being removed",
+			local:       []*entity.File{{Path: "/box/a.sy", ID: "1"}},
+			cloud:       []*entity.File{{Path: "/" + localOnly + "/b.sy", ID: "2"}, {Path: "/assets/private/x.png", ID: "3"}},
+			want:        "/" + localOnly + "/b.sy=2,/assets/private/x.png=3,/box/a.sy=1",
+			wantChanged: true,
+		},
+		{
+			name:        "local changes under scope are replaced by cloud version",
+			local:       []*entity.File{{Path: "/" + localOnly + "/b.sy", ID: "local"}},
+			cloud:       []*entity.File{{Path: "/" + localOnly + "/b.sy", ID: "cloud"}},
+			want:        "/" + localOnly + "/b.sy=cloud",
+			wantChanged: true,
+		},
+		{
+			name:  "same files under scope",
+			local: []*entity.File{{Path: "/" + localOnly + "/b.sy", ID: "2"}},
+			cloud: []*entity.File{{Path: "/" + localOnly + "/b.sy", ID: "2"}},
+			want:  "/" + localOnly + "/b.sy=2",
+		},
+	}
+
+	for _, c := range cases {
+		files, changed := scopeSyncFiles(c.local, c.cloud, matcher)
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Path+"="+f.ID)
+		}
+		if c.want != strings.Join(got, ",") {
+			t.Errorf("%s: files: want [%s], got [%s]", c.name, c.want, strings.Join(got, ","))
+		}
+		if c.wantChanged != changed {
+			t.Errorf("%s: changed: want [%t], got [%t]", c.name, c.wantChanged, changed)
+		}
+	}
+}
@@ -300,12 +300,12 @@ func sortTags(tags Tags) {
 	}
 }
 
-func SearchTags(keyword string) (ret []string) {
+func SearchTags(keyword string, access *PublishAccess) (ret []string) {
 	ret = []string{}
 
 	sql.FlushQueue()
 
-	labels := labelBlocksByKeyword(keyword)
+	labels := labelBlocksByKeyword(keyword, access.SQLFilter())
 	keyword = strings.Join(strings.Split(keyword, " "), search.TermSep)
 	for label := range labels {
 		if "" == keyword {
@@ -320,10 +320,10 @@ func SearchTags(keyword string) (ret []string) {
 	return
 }
 
-func labelBlocksByKeyword(keyword string) (ret map[string]TagBlocks) {
+func labelBlocksByKeyword(keyword, scopeFilter string) (ret map[string]TagBlocks) {
 	ret = map[string]TagBlocks{}
 
-	tags := sql.QueryTagSpansByKeyword(keyword, Conf.Search.Limit)
+	tags := sql.QueryTagSpansByKeyword(keyword, scopeFilter, Conf.Search.Limit)
 	set := hashset.New()
 	for _, tag := range tags {
 		set.Add(tag.BlockID)
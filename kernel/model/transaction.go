@@ -96,6 +96,13 @@ func flushTx(tx *Transaction) {
 		case TxErrCodeBlockNotFound:
 			util.PushTxErr("Transaction failed", txErr.code, nil)
 			return
+		case TxErrCodeBlockLocked:
+			pushBlockLockedErr(txErr)
+			return
+		case TxErrCodeConflict:
+			// 冲突由接口推送给提交者
+			logging.LogInfof("transaction conflicted on block [%s]: %s", txErr.id, txErr.msg)
+			return
 		case TxErrCodeDataIsSyncing:
 			util.PushMsg(Conf.Language(222), 5000)
 		case TxErrHandleAttributeView:
@@ -127,6 +134,8 @@ const (
 	TxErrCodeDataIsSyncing   = 1
 	TxErrCodeWriteTree       = 2
 	TxErrHandleAttributeView = 3
+	TxErrCodeBlockLocked     = 4
+	TxErrCodeConflict        = 5
 )
 
 type TxErr struct {
@@ -168,6 +177,11 @@ func performTx(tx *Transaction) (ret *TxErr) {
 	}()
 
 	for _, op := range tx.DoOperations {
+		if ret = checkBlockLocks(tx.Author, op); nil != ret {
+			tx.rollback()
+			return
+		}
+
 		switch op.Action {
 		case "create":
 			ret = tx.doCreate(op)
@@ -317,6 +331,7 @@ func performTx(tx *Transaction) (ret *TxErr) {
 		logging.LogErrorf("commit tx failed: %s", cr)
 		return &TxErr{msg: cr.Error()}
 	}
+	fireTxWebhookEvents(tx)
 	return
 }
 
@@ -325,6 +340,9 @@ func (tx *Transaction) doMove(operation *Operation) (ret *TxErr) {
 	id := operation.ID
 	srcTree, err := tx.loadTree(id)
 	if err != nil {
+		if ret = tx.checkCollabDeletedBlock(id); nil != ret {
+			return
+		}
 		logging.LogErrorf("load tree [%s] failed: %s", id, err)
 		return &TxErr{code: TxErrCodeBlockNotFound, id: id}
 	}
@@ -334,6 +352,9 @@ func (tx *Transaction) doMove(operation *Operation) (ret *TxErr) {
 		logging.LogErrorf("get node [%s] in tree [%s] failed", id, srcTree.Root.ID)
 		return &TxErr{code: TxErrCodeBlockNotFound, id: id}
 	}
+	if ret = tx.checkConcurrentMove(operation, srcNode, srcTree.ID); nil != ret {
+		return
+	}
 
 	// 生成文档历史 https://github.com/siyuan-note/siyuan/issues/14359
 	generateOpTypeHistory(srcTree, HistoryOpUpdate)
@@ -361,6 +382,9 @@ func (tx *Transaction) doMove(operation *Operation) (ret *TxErr) {
 		var targetTree *parse.Tree
 		targetTree, err = tx.loadTree(targetPreviousID)
 		if err != nil {
+			if ret = tx.checkCollabDeletedBlock(targetPreviousID); nil != ret {
+				return
+			}
 			logging.LogErrorf("load tree [%s] failed: %s", targetPreviousID, err)
 			return &TxErr{code: TxErrCodeBlockNotFound, id: targetPreviousID}
 		}
@@ -423,6 +447,9 @@ func (tx *Transaction) doMove(operation *Operation) (ret *TxErr) {
 
 	targetTree, err := tx.loadTree(targetParentID)
 	if err != nil {
+		if ret = tx.checkCollabDeletedBlock(targetParentID); nil != ret {
+			return
+		}
 		logging.LogErrorf("load tree [%s] failed: %s", targetParentID, err)
 		return &TxErr{code: TxErrCodeBlockNotFound, id: targetParentID}
 	}
@@ -800,6 +827,7 @@ func (tx *Transaction) doDelete(operation *Operation) (ret *TxErr) {
 	if nil == node {
 		return nil // move 以后的情况，列表项移动导致的状态异常 https://github.com/siyuan-note/insider/issues/961
 	}
+	recordCollabBlockDelete(id, tree.ID, tx.Session)
 
 	// 收集引用的定义块 ID
 	refDefIDs := getRefDefIDs(node)
@@ -1189,6 +1217,9 @@ func (tx *Transaction) doUpdate(operation *Operation) (ret *TxErr) {
 	id := operation.ID
 	tree, err := tx.loadTree(id)
 	if err != nil {
+		if ret = tx.checkCollabDeletedBlock(id); nil != ret {
+			return
+		}
 		logging.LogErrorf("load tree [%s] failed: %s", id, err)
 		return &TxErr{code: TxErrCodeBlockNotFound, id: id}
 	}
@@ -1198,6 +1229,10 @@ func (tx *Transaction) doUpdate(operation *Operation) (ret *TxErr) {
 		logging.LogErrorf("update data is nil")
 		return &TxErr{code: TxErrCodeBlockNotFound, id: id}
 	}
+	if merged, ok := tx.mergeConcurrentUpdate(operation, tree, data); ok {
+		data = merged
+		operation.Data = merged
+	}
 
 	subTree := tx.luteEngine.BlockDOM2Tree(data)
 	subTree.ID, subTree.Box, subTree.Path = tree.ID, tree.Box, tree.Path
@@ -1530,6 +1565,8 @@ type Transaction struct {
 	Timestamp      int64        `json:"timestamp"`
 	DoOperations   []*Operation `json:"doOperations"`
 	UndoOperations []*Operation `json:"undoOperations"`
+	Author         string       `json:"author"` // 提交事务的用户，由内核根据请求的身份设置
+	Session        string       `json:"-"`      // 提交事务的编辑器会话
 
 	trees map[string]*parse.Tree // 事务中变更的树
 	nodes map[string]*ast.Node   // 事务中变更的节点
@@ -1541,6 +1578,18 @@ type Transaction struct {
 	luteEngine *lute.Lute
 	m          *sync.Mutex
 	state      atomic.Int32 // 0: 初始化，1：未提交，:2: 已提交，3: 已回滚
+	mergedOps  []*Operation // 合并了其他会话并发修改的操作
+	conflicts  []string     // 和其他会话的修改冲突的块
+}
+
+// MergedOperations 返回合并了其他会话并发修改的操作，这些操作的结果需要推送给提交者。
+func (tx *Transaction) MergedOperations() []*Operation {
+	return tx.mergedOps
+}
+
+// Conflicts 返回和其他会话的修改冲突的块，需要在 WaitForCommit 之后调用。
+func (tx *Transaction) Conflicts() []string {
+	return tx.conflicts
 }
 
 func (tx *Transaction) WaitForCommit() {
@@ -1563,6 +1612,13 @@ func (tx *Transaction) begin() (err error) {
 }
 
 func (tx *Transaction) commit() (err error) {
+	if "" != tx.Author {
+		// 记录最后修改块的用户，只记录在被修改的块上
+		for _, node := range tx.nodes {
+			node.SetIALAttr("custom-updated-by", tx.Author)
+		}
+	}
+
 	for _, tree := range tx.trees {
 		if err = writeTreeUpsertQueue(tree); err != nil {
 			return
@@ -0,0 +1,509 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/task"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Webhook 事件
+const (
+	WebhookEventDocCreated        = "doc.created"
+	WebhookEventDocRenamed        = "doc.renamed"
+	WebhookEventDocMoved          = "doc.moved"
+	WebhookEventDocRemoved        = "doc.removed"
+	WebhookEventBlockUpdated      = "block.updated"
+	WebhookEventAttrChanged       = "attr.changed"
+	WebhookEventAvRowAdded        = "av.row.added"
+	WebhookEventAvRowChanged      = "av.row.changed"
+	WebhookEventFlashcardReviewed = "flashcard.reviewed"
+	WebhookEventSyncCompleted     = "sync.completed"
+	WebhookEventPing              = "ping" // 测试投递，总是发送
+)
+
+var WebhookEvents = []string{
+	WebhookEventDocCreated,
+	WebhookEventDocRenamed,
+	WebhookEventDocMoved,
+	WebhookEventDocRemoved,
+	WebhookEventBlockUpdated,
+	WebhookEventAttrChanged,
+	WebhookEventAvRowAdded,
+	WebhookEventAvRowChanged,
+	WebhookEventFlashcardReviewed,
+	WebhookEventSyncCompleted,
+}
+
+const (
+	webhookMaxAttempts  = 10               // 最大投递次数，超过后丢弃
+	webhookRetryBase    = 30 * time.Second // 重试间隔基数，按照 2 的幂次递增
+	webhookRetryMax     = 6 * time.Hour    // 最大重试间隔
+	webhookMaxQueueSize = 4096             // 队列最大长度，超过后丢弃最早的投递
+)
+
+// webhookDelivery 描述了一次待投递的 Webhook 请求，请求体在事件发生时生成，重试时保持不变。
+type webhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookID"`
+	Event     string `json:"event"`
+	Body      string `json:"body"`
+	Attempts  int    `json:"attempts"`
+	NextAt    int64  `json:"nextAt"`
+	LastError string `json:"lastError"`
+	Created   int64  `json:"created"`
+}
+
+var (
+	webhooksLock       = sync.Mutex{}
+	webhookQueue       []*webhookDelivery
+	webhookQueueLoaded bool
+	webhookQueueDirty  bool // 内存中的队列有尚未持久化的变更
+	webhookQueueLock   = sync.Mutex{}
+	webhookDeliverLock = sync.Mutex{} // 异步任务会并发执行，投递过程需要串行
+)
+
+func GetWebhooks() (ret []*conf.Webhook) {
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	ret = []*conf.Webhook{}
+	for _, w := range Conf.Webhooks {
+		cloned := *w
+		ret = append(ret, &cloned)
+	}
+	return
+}
+
+// SetWebhook 新建或者更新 Webhook，ID 为空时新建，密钥为空时自动生成。
+func SetWebhook(webhook *conf.Webhook) (ret *conf.Webhook, err error) {
+	webhook.Name = strings.TrimSpace(webhook.Name)
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	u, err := url.Parse(webhook.URL)
+	if nil != err || ("https" != u.Scheme && "http" != u.Scheme) || "" == u.Host {
+		err = errors.New("invalid webhook URL [" + webhook.URL + "]")
+		return
+	}
+	if nil == webhook.Events {
+		webhook.Events = []string{}
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			err = errors.New("invalid webhook event [" + event + "]")
+			return
+		}
+	}
+	if "" == webhook.Secret {
+		randomBytes := make([]byte, 24)
+		if _, err = rand.Read(randomBytes); err != nil {
+			return
+		}
+		webhook.Secret = hex.EncodeToString(randomBytes)
+	}
+
+	webhooksLock.Lock()
+	if "" == webhook.ID {
+		webhook.ID = ast.NewNodeID()
+		webhook.Created = time.Now().UnixMilli()
+		Conf.Webhooks = append(Conf.Webhooks, webhook)
+	} else {
+		found := false
+		for i, w := range Conf.Webhooks {
+			if w.ID == webhook.ID {
+				webhook.Created = w.Created
+				Conf.Webhooks[i] = webhook
+				found = true
+				break
+			}
+		}
+		if !found {
+			webhooksLock.Unlock()
+			err = errors.New("webhook [" + webhook.ID + "] not found")
+			return
+		}
+	}
+	cloned := *webhook
+	ret = &cloned
+	webhooksLock.Unlock()
+	Conf.Save()
+	logging.LogInfof("set webhook [%s, %s]", ret.ID, ret.URL)
+	return
+}
+
+func RemoveWebhook(id string) {
+	webhooksLock.Lock()
+	var webhooks []*conf.Webhook
+	for _, w := range Conf.Webhooks {
+		if w.ID != id {
+			webhooks = append(webhooks, w)
+		}
+	}
+	if nil == webhooks {
+		webhooks = []*conf.Webhook{}
+	}
+	Conf.Webhooks = webhooks
+	webhooksLock.Unlock()
+	Conf.Save()
+
+	webhookQueueLock.Lock()
+	loadWebhookQueue()
+	webhookQueue = slices.DeleteFunc(webhookQueue, func(d *webhookDelivery) bool { return d.WebhookID == id })
+	saveWebhookQueue()
+	webhookQueueLock.Unlock()
+	logging.LogInfof("removed webhook [%s]", id)
+}
+
+// TestWebhook 向指定的 Webhook 投递 ping 事件，不进入重试队列，直接返回投递结果。
+func TestWebhook(id string) (err error) {
+	webhook := getWebhook(id)
+	if nil == webhook {
+		return errors.New("webhook [" + id + "] not found")
+	}
+
+	delivery := newWebhookDelivery(webhook, WebhookEventPing, map[string]interface{}{"webhookID": id})
+	if nil == delivery {
+		return errors.New("marshal webhook payload failed")
+	}
+	return postWebhook(webhook, delivery)
+}
+
+// WebhookRetryJob 定时投递队列中到期的重试请求。
+func WebhookRetryJob() {
+	webhookQueueLock.Lock()
+	loadWebhookQueue()
+	size := len(webhookQueue)
+	webhookQueueLock.Unlock()
+	if 0 < size {
+		deliverWebhooks()
+	}
+}
+
+// FlushWebhookQueue 持久化内存中尚未保存的待投递队列，退出内核时调用。
+func FlushWebhookQueue() {
+	flushWebhookBlockUpdates()
+
+	webhookQueueLock.Lock()
+	defer webhookQueueLock.Unlock()
+	if webhookQueueDirty {
+		saveWebhookQueue()
+	}
+}
+
+// fireWebhookEvent 将事件加入所有订阅了该事件的 Webhook 的投递队列，随后在异步任务中持久化并投递。
+//
+// 这里只修改内存中的队列，事务等调用方不需要等待写盘，同一时间触发的多个事件在异步任务中一起持久化。
+func fireWebhookEvent(event string, data map[string]interface{}) {
+	webhooks := getSubscribedWebhooks(event)
+	if 1 > len(webhooks) {
+		return
+	}
+
+	webhookQueueLock.Lock()
+	loadWebhookQueue()
+	for _, w := range webhooks {
+		if delivery := newWebhookDelivery(w, event, data); nil != delivery {
+			webhookQueue = append(webhookQueue, delivery)
+		}
+	}
+	if overflow := len(webhookQueue) - webhookMaxQueueSize; 0 < overflow {
+		logging.LogWarnf("webhook queue is full, dropped [%d] deliveries", overflow)
+		webhookQueue = webhookQueue[overflow:]
+	}
+	webhookQueueDirty = true
+	webhookQueueLock.Unlock()
+
+	task.AppendAsyncTaskWithDelay(task.WebhookDeliver, 0, deliverWebhooks)
+}
+
+func newWebhookDelivery(webhook *conf.Webhook, event string, data map[string]interface{}) *webhookDelivery {
+	now := time.Now().UnixMilli()
+	id := ast.NewNodeID()
+	body, err := gulu.JSON.MarshalJSON(map[string]interface{}{
+		"id":        id,
+		"event":     event,
+		"webhookID": webhook.ID,
+		"timestamp": now,
+		"data":      data,
+	})
+	if err != nil {
+		logging.LogErrorf("marshal webhook [%s] payload failed: %s", event, err)
+		return nil
+	}
+
+	return &webhookDelivery{
+		ID:        id,
+		WebhookID: webhook.ID,
+		Event:     event,
+		Body:      string(body),
+		Created:   now,
+	}
+}
+
+func deliverWebhooks() {
+	webhookDeliverLock.Lock()
+	defer webhookDeliverLock.Unlock()
+
+	now := time.Now().UnixMilli()
+	webhookQueueLock.Lock()
+	loadWebhookQueue()
+	if webhookQueueDirty {
+		// 投递前先持久化新加入的事件，投递过程中内核退出也不会丢失
+		saveWebhookQueue()
+	}
+	var dues []*webhookDelivery
+	for _, d := range webhookQueue {
+		if d.NextAt <= now {
+			dues = append(dues, d)
+		}
+	}
+	webhookQueueLock.Unlock()
+	if 1 > len(dues) {
+		return
+	}
+
+	done := map[string]bool{}
+	failed := map[string]int64{} // 本轮投递失败的 Webhook -> 下次投递时间
+	for _, d := range dues {
+		webhook := getWebhook(d.WebhookID)
+		if nil == webhook || !webhook.Enabled {
+			done[d.ID] = true
+			continue
+		}
+		if nextAt, ok := failed[d.WebhookID]; ok {
+			// 接收方不可用时不再逐个等待超时，剩余的投递推迟到下次重试，不计入投递次数
+			webhookQueueLock.Lock()
+			d.NextAt = nextAt
+			webhookQueueLock.Unlock()
+			continue
+		}
+
+		err := postWebhook(webhook, d)
+		webhookQueueLock.Lock()
+		if nil == err {
+			done[d.ID] = true
+		} else {
+			d.Attempts++
+			d.LastError = err.Error()
+			delay := webhookRetryBase << (d.Attempts - 1)
+			if webhookRetryMax < delay || 0 >= delay {
+				delay = webhookRetryMax
+			}
+			if webhookMaxAttempts <= d.Attempts {
+				logging.LogErrorf("deliver webhook [%s, %s] to [%s] failed after [%d] attempts, dropped: %s", d.Event, d.ID, webhook.URL, d.Attempts, err)
+				done[d.ID] = true
+				delay = webhookRetryBase
+			} else {
+				d.NextAt = time.Now().Add(delay).UnixMilli()
+				logging.LogWarnf("deliver webhook [%s, %s] to [%s] failed, will retry in [%s]: %s", d.Event, d.ID, webhook.URL, delay, err)
+			}
+			failed[d.WebhookID] = time.Now().Add(delay).UnixMilli()
+		}
+		webhookQueueLock.Unlock()
+	}
+
+	webhookQueueLock.Lock()
+	webhookQueue = slices.DeleteFunc(webhookQueue, func(d *webhookDelivery) bool { return done[d.ID] })
+	saveWebhookQueue()
+	webhookQueueLock.Unlock()
+}
+
+// postWebhook 发送请求，请求头 X-SiYuan-Signature 为使用密钥对请求体计算的 HMAC-SHA256，接收方返回 2xx 视为投递成功。
+func postWebhook(webhook *conf.Webhook, delivery *webhookDelivery) (err error) {
+	body := []byte(delivery.Body)
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(body)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", util.UserAgent)
+	req.Header.Set("X-SiYuan-Event", delivery.Event)
+	req.Header.Set("X-SiYuan-Delivery", delivery.ID)
+	req.Header.Set("X-SiYuan-Delivery-Attempt", strconv.Itoa(delivery.Attempts+1))
+	req.Header.Set("X-SiYuan-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	client := &http.Client{Transport: httpclient.NewTransport(false), Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if 200 > resp.StatusCode || 299 < resp.StatusCode {
+		return errors.New("response status " + resp.Status)
+	}
+	return
+}
+
+func getSubscribedWebhooks(event string) (ret []*conf.Webhook) {
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	for _, w := range Conf.Webhooks {
+		if w.Enabled && (1 > len(w.Events) || slices.Contains(w.Events, event)) {
+			ret = append(ret, w)
+		}
+	}
+	return
+}
+
+func getWebhook(id string) *conf.Webhook {
+	webhooksLock.Lock()
+	defer webhooksLock.Unlock()
+
+	for _, w := range Conf.Webhooks {
+		if w.ID == id {
+			cloned := *w
+			return &cloned
+		}
+	}
+	return nil
+}
+
+// loadWebhookQueue 首次使用时从磁盘加载待投递队列，调用方需要持有 webhookQueueLock。
+func loadWebhookQueue() {
+	if webhookQueueLoaded {
+		return
+	}
+	webhookQueueLoaded = true
+
+	data, err := os.ReadFile(util.WebhookQueuePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.LogErrorf("read webhook queue failed: %s", err)
+		}
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &webhookQueue); err != nil {
+		logging.LogErrorf("unmarshal webhook queue failed: %s", err)
+		webhookQueue = nil
+	}
+}
+
+// saveWebhookQueue 将待投递队列持久化到磁盘，调用方需要持有 webhookQueueLock。
+func saveWebhookQueue() {
+	webhookQueueDirty = false
+	if 1 > len(webhookQueue) {
+		if err := os.Remove(util.WebhookQueuePath); err != nil && !os.IsNotExist(err) {
+			logging.LogErrorf("remove webhook queue failed: %s", err)
+		}
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(webhookQueue)
+	if err != nil {
+		logging.LogErrorf("marshal webhook queue failed: %s", err)
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(util.WebhookQueuePath), 0755); err != nil {
+		logging.LogErrorf("create webhook queue dir failed: %s", err)
+		return
+	}
+	if err = gulu.File.WriteFileSafer(util.WebhookQueuePath, data, 0644); err != nil {
+		logging.LogErrorf("write webhook queue failed: %s", err)
+	}
+}
+
+// fireTxWebhookEvents 事务提交后触发块更新事件。
+//
+// 块更新事件覆盖更新、插入、移动块和设置块属性的操作，事件数据中的 action 为操作类型。
+// 删除块不触发块更新事件，删除文档使用 doc.removed 事件。
+// 编辑时每次输入都会提交事务，同一个块在 webhookBlockUpdatedWindow 内的多次操作只触发一次事件，action 为最后一次操作。
+func fireTxWebhookEvents(tx *Transaction) {
+	if 1 > len(getSubscribedWebhooks(WebhookEventBlockUpdated)) {
+		return
+	}
+
+	webhookBlockUpdatesLock.Lock()
+	defer webhookBlockUpdatesLock.Unlock()
+	schedule := 1 > len(webhookBlockUpdates)
+	for _, op := range tx.DoOperations {
+		if !slices.Contains(webhookBlockUpdatedActions, op.Action) || "" == op.ID {
+			continue
+		}
+
+		if _, ok := webhookBlockUpdates[op.ID]; !ok {
+			webhookBlockUpdateIDs = append(webhookBlockUpdateIDs, op.ID)
+		}
+		webhookBlockUpdates[op.ID] = &webhookBlockUpdate{action: op.Action, author: tx.Author}
+	}
+	if schedule && 0 < len(webhookBlockUpdates) {
+		task.AppendAsyncTaskWithDelay(task.WebhookBlockUpdated, webhookBlockUpdatedWindow, flushWebhookBlockUpdates)
+	}
+}
+
+// webhookBlockUpdatedWindow 合并同一个块的更新事件的时长。
+const webhookBlockUpdatedWindow = 3 * time.Second
+
+type webhookBlockUpdate struct {
+	action string
+	author string
+}
+
+var (
+	webhookBlockUpdates     = map[string]*webhookBlockUpdate{} // 块 ID -> 窗口内最后一次操作
+	webhookBlockUpdateIDs   []string                           // 按照首次操作的顺序触发事件
+	webhookBlockUpdatesLock = sync.Mutex{}
+)
+
+// flushWebhookBlockUpdates 触发窗口内合并后的块更新事件，块的位置在触发时获取，移动后的事件数据为移动后的位置。
+func flushWebhookBlockUpdates() {
+	webhookBlockUpdatesLock.Lock()
+	updates, ids := webhookBlockUpdates, webhookBlockUpdateIDs
+	webhookBlockUpdates, webhookBlockUpdateIDs = map[string]*webhookBlockUpdate{}, nil
+	webhookBlockUpdatesLock.Unlock()
+
+	for _, id := range ids {
+		update := updates[id]
+		data := map[string]interface{}{"id": id, "action": update.action, "author": update.author}
+		if bt := treenode.GetBlockTree(id); nil != bt {
+			data["rootID"] = bt.RootID
+			data["box"] = bt.BoxID
+			data["path"] = bt.Path
+		}
+		fireWebhookEvent(WebhookEventBlockUpdated, data)
+	}
+}
+
+// webhookBlockUpdatedActions 触发块更新事件的事务操作。
+var webhookBlockUpdatedActions = []string{"update", "insert", "appendInsert", "prependInsert", "append", "move", "setAttrs"}
@@ -0,0 +1,246 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// webhookReceiver 是测试用的 Webhook 接收方，校验签名并按照 statuses 依次返回状态码。
+type webhookReceiver struct {
+	secret     string
+	statuses   []int
+	m          sync.Mutex
+	deliveries []string
+	signatures []bool
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	mac := hmac.New(sha256.New, []byte(r.secret))
+	mac.Write(body)
+
+	r.m.Lock()
+	r.deliveries = append(r.deliveries, req.Header.Get("X-SiYuan-Delivery"))
+	r.signatures = append(r.signatures, "sha256="+hex.EncodeToString(mac.Sum(nil)) == req.Header.Get("X-SiYuan-Signature"))
+	status := http.StatusNoContent
+	if len(r.deliveries) <= len(r.statuses) {
+		status = r.statuses[len(r.deliveries)-1]
+	}
+	r.m.Unlock()
+	w.WriteHeader(status)
+}
+
+func setupWebhookTest(t *testing.T, receiver *webhookReceiver) *conf.Webhook {
+	server := httptest.NewServer(receiver)
+	webhook := &conf.Webhook{ID: "20240101000000-webhook", URL: server.URL, Secret: receiver.secret, Enabled: true}
+
+	oldConf, oldQueuePath := Conf, util.WebhookQueuePath
+	Conf = &AppConf{Webhooks: []*conf.Webhook{webhook}}
+	util.WebhookQueuePath = filepath.Join(t.TempDir(), "webhook-queue.json")
+	resetWebhookQueue()
+	t.Cleanup(func() {
+		server.Close()
+		Conf, util.WebhookQueuePath = oldConf, oldQueuePath
+		resetWebhookQueue()
+	})
+	return webhook
+}
+
+// resetWebhookQueue 清空内存中的队列，模拟内核重启。
+func resetWebhookQueue() {
+	webhookQueueLock.Lock()
+	webhookQueue, webhookQueueLoaded, webhookQueueDirty = nil, false, false
+	webhookQueueLock.Unlock()
+}
+
+func TestPostWebhook(t *testing.T) {
+	cases := []struct {
+		name          string
+		secret        string
+		status        int
+		wantErr       bool
+		wantSignature bool
+	}{
+		{"ok", "secret", http.StatusOK, false, true},
+		{"no content", "secret", http.StatusNoContent, false, true},
+		{"server error", "secret", http.StatusInternalServerError, true, true},
+		{"redirect", "secret", http.StatusMovedPermanently, true, true},
+		{"wrong secret", "other", http.StatusOK, false, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			receiver := &webhookReceiver{secret: "secret", statuses: []int{c.status}}
+			webhook := setupWebhookTest(t, receiver)
+			webhook.Secret = c.secret
+
+			delivery := newWebhookDelivery(webhook, WebhookEventPing, map[string]interface{}{"foo": "bar"})
+			if err := postWebhook(webhook, delivery); c.wantErr != (nil != err) {
+				t.Fatalf("want err [%t], got [%v]", c.wantErr, err)
+			}
+			if 1 != len(receiver.deliveries) || delivery.ID != receiver.deliveries[0] {
+				t.Fatalf("want delivery [%s], got %v", delivery.ID, receiver.deliveries)
+			}
+			if c.wantSignature != receiver.signatures[0] {
+				t.Fatalf("want signature valid [%t], got [%t]", c.wantSignature, receiver.signatures[0])
+			}
+		})
+	}
+}
+
+func TestDeliverWebhooksRetry(t *testing.T) {
+	receiver := &webhookReceiver{secret: "secret", statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	setupWebhookTest(t, receiver)
+
+	fireWebhookEvent(WebhookEventDocCreated, map[string]interface{}{"id": "20240101000000-doc0000"})
+	steps := []struct {
+		name         string
+		wantAttempts int // 投递后队列中的投递次数，-1 表示已经从队列中移除
+	}{
+		{"first attempt fails", 1},
+		{"second attempt fails", 2},
+		{"third attempt succeeds", -1},
+	}
+	for _, step := range steps {
+		deliverWebhooks()
+
+		webhookQueueLock.Lock()
+		queue := webhookQueue
+		if 0 < len(queue) {
+			if queue[0].NextAt <= queue[0].Created {
+				t.Fatalf("%s: want retry scheduled later, got next at [%d]", step.name, queue[0].NextAt)
+			}
+			queue[0].NextAt = 0 // 跳过重试等待
+		}
+		webhookQueueLock.Unlock()
+
+		if -1 == step.wantAttempts {
+			if 0 != len(queue) {
+				t.Fatalf("%s: want queue empty, got [%d]", step.name, len(queue))
+			}
+			if _, err := os.Stat(util.WebhookQueuePath); !os.IsNotExist(err) {
+				t.Fatalf("%s: want queue file removed, got [%v]", step.name, err)
+			}
+			continue
+		}
+		if 1 != len(queue) || step.wantAttempts != queue[0].Attempts {
+			t.Fatalf("%s: want [%d] attempts, got %v", step.name, step.wantAttempts, queue)
+		}
+	}
+
+	if 3 != len(receiver.deliveries) || receiver.deliveries[0] != receiver.deliveries[2] {
+		t.Fatalf("want the same delivery retried [3] times, got %v", receiver.deliveries)
+	}
+}
+
+func TestDeliverWebhooksSkipAfterFailure(t *testing.T) {
+	receiver := &webhookReceiver{secret: "secret", statuses: []int{http.StatusInternalServerError}}
+	setupWebhookTest(t, receiver)
+
+	for _, id := range []string{"20240101000000-doc0001", "20240101000000-doc0002", "20240101000000-doc0003"} {
+		fireWebhookEvent(WebhookEventDocCreated, map[string]interface{}{"id": id})
+	}
+	deliverWebhooks()
+
+	if 1 != len(receiver.deliveries) {
+		t.Fatalf("want only the first delivery posted, got %v", receiver.deliveries)
+	}
+	webhookQueueLock.Lock()
+	defer webhookQueueLock.Unlock()
+	if 3 != len(webhookQueue) {
+		t.Fatalf("want [3] deliveries in queue, got [%d]", len(webhookQueue))
+	}
+	first := webhookQueue[0]
+	if 1 != first.Attempts || first.NextAt <= first.Created {
+		t.Fatalf("want first delivery retried later, got attempts [%d] next at [%d]", first.Attempts, first.NextAt)
+	}
+	for _, d := range webhookQueue[1:] {
+		if 0 != d.Attempts || first.NextAt != d.NextAt {
+			t.Fatalf("want skipped delivery postponed with the first one, got attempts [%d] next at [%d]", d.Attempts, d.NextAt)
+		}
+	}
+}
+
+func TestFireTxWebhookEvents(t *testing.T) {
+	const id1 = "20240101000000-block01"
+	const id2 = "20240101000000-block02"
+	setupWebhookTest(t, &webhookReceiver{secret: "secret"})
+	resetWebhookBlockUpdates := func() {
+		webhookBlockUpdatesLock.Lock()
+		webhookBlockUpdates, webhookBlockUpdateIDs = map[string]*webhookBlockUpdate{}, nil
+		webhookBlockUpdatesLock.Unlock()
+	}
+	resetWebhookBlockUpdates()
+	t.Cleanup(resetWebhookBlockUpdates)
+
+	fireTxWebhookEvents(&Transaction{Author: "foo", DoOperations: []*Operation{
+		{Action: "update", ID: id1},
+		{Action: "update", ID: id1},
+		{Action: "insert", ID: id2},
+		{Action: "delete", ID: "20240101000000-block03"},
+	}})
+	fireTxWebhookEvents(&Transaction{Author: "bar", DoOperations: []*Operation{{Action: "setAttrs", ID: id1}}})
+
+	webhookBlockUpdatesLock.Lock()
+	defer webhookBlockUpdatesLock.Unlock()
+	if want := id1 + "," + id2; want != strings.Join(webhookBlockUpdateIDs, ",") {
+		t.Fatalf("want pending blocks [%s], got %v", want, webhookBlockUpdateIDs)
+	}
+	if update := webhookBlockUpdates[id1]; "setAttrs" != update.action || "bar" != update.author {
+		t.Fatalf("want the last operation kept, got [%s] by [%s]", update.action, update.author)
+	}
+	webhookQueueLock.Lock()
+	defer webhookQueueLock.Unlock()
+	if 0 != len(webhookQueue) {
+		t.Fatalf("want no delivery queued before the window ends, got [%d]", len(webhookQueue))
+	}
+}
+
+func TestWebhookQueuePersistence(t *testing.T) {
+	receiver := &webhookReceiver{secret: "secret"}
+	setupWebhookTest(t, receiver)
+
+	fireWebhookEvent(WebhookEventDocCreated, map[string]interface{}{"id": "20240101000000-doc0000"})
+	if _, err := os.Stat(util.WebhookQueuePath); !os.IsNotExist(err) {
+		t.Fatalf("want queue persisted asynchronously, got [%v]", err)
+	}
+	webhookQueueLock.Lock()
+	id := webhookQueue[0].ID
+	webhookQueueLock.Unlock()
+
+	FlushWebhookQueue()
+	resetWebhookQueue()
+	deliverWebhooks()
+
+	if 1 != len(receiver.deliveries) || id != receiver.deliveries[0] || !receiver.signatures[0] {
+		t.Fatalf("want delivery [%s] after restart, got %v", id, receiver.deliveries)
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
+	"strings"
 
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/model"
@@ -124,6 +126,10 @@ func rewrite(r *httputil.ProxyRequest) {
 }
 
 func (PublishServiceTransport) RoundTrip(request *http.Request) (response *http.Response, err error) {
+	// 单点登录会话只在这里转换为发布服务的 JWT，不能直接转发给内核，否则会获得会话原本的角色
+	oidcToken := model.GetOIDCPublishToken(request)
+	removeCookie(request, model.OIDCSessionCookieName)
+
 	if model.Conf.Publish.Auth.Enable {
 		// Basic Auth
 		username, password, ok := request.BasicAuth()
@@ -134,20 +140,42 @@ func (PublishServiceTransport) RoundTrip(request *http.Request) (response *http.
 			account.Username == "" || // 匿名用户
 			account.Password != password {
 
-			return &http.Response{
-				StatusCode: http.StatusUnauthorized,
-				Status:     http.StatusText(http.StatusUnauthorized),
-				Proto:      request.Proto,
-				ProtoMajor: request.ProtoMajor,
-				ProtoMinor: request.ProtoMinor,
-				Request:    request,
-				Header: http.Header{
-					model.BasicAuthHeaderKey: {model.BasicAuthHeaderValue},
-				},
-				Body:          http.NoBody,
-				Close:         false,
-				ContentLength: -1,
-			}, nil
+			if "" != oidcToken {
+				request.Header.Set(model.XAuthTokenKey, oidcToken)
+			} else if model.IsOIDCPublishEnabled() && strings.HasPrefix(request.URL.Path, "/auth/oidc/") {
+				// 单点登录流程不需要鉴权
+			} else if model.IsOIDCPublishEnabled() && !ok && http.MethodGet == request.Method {
+				// 未登录时跳转到单点登录
+				return &http.Response{
+					StatusCode: http.StatusFound,
+					Status:     http.StatusText(http.StatusFound),
+					Proto:      request.Proto,
+					ProtoMajor: request.ProtoMajor,
+					ProtoMinor: request.ProtoMinor,
+					Request:    request,
+					Header: http.Header{
+						"Location": {"/auth/oidc/login?to=" + url.QueryEscape(request.URL.RequestURI())},
+					},
+					Body:          http.NoBody,
+					Close:         false,
+					ContentLength: -1,
+				}, nil
+			} else {
+				return &http.Response{
+					StatusCode: http.StatusUnauthorized,
+					Status:     http.StatusText(http.StatusUnauthorized),
+					Proto:      request.Proto,
+					ProtoMajor: request.ProtoMajor,
+					ProtoMinor: request.ProtoMinor,
+					Request:    request,
+					Header: http.Header{
+						model.BasicAuthHeaderKey: {model.BasicAuthHeaderValue},
+					},
+					Body:          http.NoBody,
+					Close:         false,
+					ContentLength: -1,
+				}, nil
+			}
 		} else {
 			// set JWT
 			request.Header.Set(model.XAuthTokenKey, account.Token)
@@ -159,3 +187,13 @@ func (PublishServiceTransport) RoundTrip(request *http.Request) (response *http.
 	response, err = http.DefaultTransport.RoundTrip(request)
 	return
 }
+
+func removeCookie(request *http.Request, name string) {
+	cookies := request.Cookies()
+	request.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if name != cookie.Name {
+			request.AddCookie(cookie)
+		}
+	}
+}
@@ -166,6 +166,7 @@ func Serve(fastMode bool) {
 	serveSnippets(ginServer)
 	serveRepoDiff(ginServer)
 	serveCheckAuth(ginServer)
+	serveOIDC(ginServer)
 	serveFixedStaticFiles(ginServer)
 	api.ServeAPI(ginServer)
 
@@ -406,6 +407,12 @@ func serveCheckAuth(ginServer *gin.Engine) {
 	ginServer.GET("/check-auth", serveAuthPage)
 }
 
+func serveOIDC(ginServer *gin.Engine) {
+	ginServer.GET("/auth/oidc/login", model.OIDCLogin)
+	ginServer.GET("/auth/oidc/callback", model.OIDCCallback)
+	ginServer.GET("/auth/oidc/logout", model.OIDCLogout)
+}
+
 func serveAuthPage(c *gin.Context) {
 	data, err := os.ReadFile(filepath.Join(util.WorkingDir, "stage/auth.html"))
 	if err != nil {
@@ -459,6 +466,7 @@ func serveAuthPage(c *gin.Context) {
 		"keymapGeneralToggleWin": keymapHideWindow,
 		"trayMenuLangs":          util.TrayMenuLangs[util.Lang],
 		"workspaceDir":           util.WorkspaceDir,
+		"oidc":                   model.IsOIDCEnabled(),
 	}
 	buf := &bytes.Buffer{}
 	if err = tpl.Execute(buf, model); err != nil {
@@ -471,7 +479,7 @@ func serveAuthPage(c *gin.Context) {
 }
 
 func serveAssets(ginServer *gin.Engine) {
-	ginServer.POST("/upload", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, model.Upload)
+	ginServer.POST("/upload", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, model.Audit, model.Upload)
 
 	ginServer.GET("/assets/*path", model.CheckAuth, func(context *gin.Context) {
 		requestPath := context.Param("path")
@@ -482,6 +490,11 @@ func serveAssets(ginServer *gin.Engine) {
 		}
 
 		relativePath := path.Join("assets", requestPath)
+		if !model.GetPublishAccess(context).AllowAsset(relativePath) {
+			context.Status(http.StatusForbidden)
+			return
+		}
+
 		p, err := model.GetAssetAbsPath(relativePath)
 		if err != nil {
 			if strings.Contains(strings.TrimPrefix(requestPath, "/"), "/") {
@@ -630,6 +643,9 @@ func serveWebSocket(ginServer *gin.Engine) {
 
 	util.WebSocketServer.HandleDisconnect(func(s *melody.Session) {
 		util.RemovePushChan(s)
+		if id, ok := s.Get("id"); ok {
+			model.RemovePresenceSession(id.(string))
+		}
 		//sessionId, _ := s.Get("id")
 		//logging.LogInfof("ws [%s] disconnected", sessionId)
 	})
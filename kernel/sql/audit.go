@@ -0,0 +1,203 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Audit 描述了一条审计日志。
+type Audit struct {
+	ID      int64  `json:"id"`
+	Created int64  `json:"created"` // 时间，单位毫秒
+	Role    int    `json:"role"`    // 调用者角色
+	Token   string `json:"token"`   // 调用者使用的具名 API 令牌名称
+	IP      string `json:"ip"`      // 调用者 IP
+	App     string `json:"app"`     // 调用者应用 ID
+	Op      string `json:"op"`      // 操作，即接口路径
+	IDs     string `json:"ids"`     // 涉及的笔记本、文档和块 ID，以逗号分隔
+	Payload string `json:"payload"` // 精简后的请求参数
+	Status  int    `json:"status"`  // HTTP 响应状态码
+}
+
+// AuditCondition 描述了审计日志的查询条件，为空的条件不参与过滤。
+type AuditCondition struct {
+	Op    string // 操作前缀
+	ID    string // 涉及的 ID
+	IP    string
+	Token string
+	From  int64 // 开始时间，单位毫秒
+	To    int64 // 结束时间，单位毫秒
+}
+
+var (
+	auditDB     *sql.DB
+	auditDBLock = sync.Mutex{}
+)
+
+// InitAuditDatabase 初始化审计日志数据库，审计日志不能从数据重建，所以数据库不放在临时目录中。
+func InitAuditDatabase() {
+	auditDBLock.Lock()
+	defer auditDBLock.Unlock()
+
+	if nil != auditDB {
+		auditDB.Close()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(util.AuditDBPath), 0755); err != nil {
+		logging.LogErrorf("create audit database dir failed: %s", err)
+		return
+	}
+
+	dsn := util.AuditDBPath + "?_journal_mode=WAL" +
+		"&_synchronous=NORMAL" +
+		"&_busy_timeout=7000"
+	var err error
+	auditDB, err = sql.Open("sqlite3_extended", dsn)
+	if err != nil {
+		logging.LogErrorf("create audit database failed: %s", err)
+		return
+	}
+	auditDB.SetMaxIdleConns(1)
+	auditDB.SetMaxOpenConns(1)
+	auditDB.SetConnMaxLifetime(365 * 24 * time.Hour)
+
+	stmts := []string{
+		"CREATE TABLE IF NOT EXISTS audits (id INTEGER PRIMARY KEY AUTOINCREMENT, created INTEGER, role INTEGER, token TEXT, ip TEXT, app TEXT, op TEXT, ids TEXT, payload TEXT, status INTEGER)",
+		"CREATE INDEX IF NOT EXISTS idx_audits_created ON audits(created)",
+		"CREATE INDEX IF NOT EXISTS idx_audits_op ON audits(op)",
+		// 审计日志只能追加，过期的日志只能按保留策略整体删除
+		"CREATE TRIGGER IF NOT EXISTS audits_append_only BEFORE UPDATE ON audits BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END",
+	}
+	for _, stmt := range stmts {
+		if _, err = auditDB.Exec(stmt); err != nil {
+			logging.LogErrorf("init audit database [%s] failed: %s", stmt, err)
+			return
+		}
+	}
+}
+
+func CloseAuditDatabase() {
+	auditDBLock.Lock()
+	defer auditDBLock.Unlock()
+
+	if nil == auditDB {
+		return
+	}
+	if err := auditDB.Close(); err != nil {
+		logging.LogErrorf("close audit database failed: %s", err)
+	}
+	auditDB = nil
+}
+
+func InsertAudit(audit *Audit) (err error) {
+	auditDBLock.Lock()
+	defer auditDBLock.Unlock()
+
+	if nil == auditDB {
+		return
+	}
+	_, err = auditDB.Exec("INSERT INTO audits (created, role, token, ip, app, op, ids, payload, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		audit.Created, audit.Role, audit.Token, audit.IP, audit.App, audit.Op, audit.IDs, audit.Payload, audit.Status)
+	return
+}
+
+func QueryAudits(cond *AuditCondition, page, pageSize int) (ret []*Audit, total int, err error) {
+	ret = []*Audit{}
+
+	auditDBLock.Lock()
+	defer auditDBLock.Unlock()
+
+	if nil == auditDB {
+		return
+	}
+
+	var where []string
+	var args []interface{}
+	if "" != cond.Op {
+		where = append(where, "op LIKE ?")
+		args = append(args, cond.Op+"%")
+	}
+	if "" != cond.ID {
+		where = append(where, "ids LIKE ?")
+		args = append(args, "%"+cond.ID+"%")
+	}
+	if "" != cond.IP {
+		where = append(where, "ip = ?")
+		args = append(args, cond.IP)
+	}
+	if "" != cond.Token {
+		where = append(where, "token = ?")
+		args = append(args, cond.Token)
+	}
+	if 0 < cond.From {
+		where = append(where, "created >= ?")
+		args = append(args, cond.From)
+	}
+	if 0 < cond.To {
+		where = append(where, "created <= ?")
+		args = append(args, cond.To)
+	}
+	whereClause := ""
+	if 0 < len(where) {
+		whereClause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	if err = auditDB.QueryRow("SELECT COUNT(*) FROM audits"+whereClause, args...).Scan(&total); err != nil {
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := auditDB.Query("SELECT id, created, role, token, ip, app, op, ids, payload, status FROM audits"+whereClause+" ORDER BY id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		audit := &Audit{}
+		if err = rows.Scan(&audit.ID, &audit.Created, &audit.Role, &audit.Token, &audit.IP, &audit.App, &audit.Op, &audit.IDs, &audit.Payload, &audit.Status); err != nil {
+			return
+		}
+		ret = append(ret, audit)
+	}
+	err = rows.Err()
+	return
+}
+
+// RemoveAuditsBefore 删除指定时间之前的审计日志，返回删除的条数。
+func RemoveAuditsBefore(created int64) (ret int64, err error) {
+	auditDBLock.Lock()
+	defer auditDBLock.Unlock()
+
+	if nil == auditDB {
+		return
+	}
+	result, err := auditDB.Exec("DELETE FROM audits WHERE created < ?", created)
+	if err != nil {
+		return
+	}
+	ret, err = result.RowsAffected()
+	return
+}
@@ -1304,6 +1304,7 @@ func CloseDatabase() {
 		logging.LogErrorf("close asset content database failed: %s", err)
 		return
 	}
+	CloseAuditDatabase()
 	treenode.CloseDatabase()
 	logging.LogInfof("closed database")
 }
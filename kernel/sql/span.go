@@ -95,7 +95,8 @@ func QueryTagSpansByLabel(label string) (ret []*Span) {
 	return
 }
 
-func QueryTagSpansByKeyword(keyword string, limit int) (ret []*Span) {
+// QueryTagSpansByKeyword 按关键字查询标签，filter 为附加的查询条件，以 " AND " 开头。
+func QueryTagSpansByKeyword(keyword, filter string, limit int) (ret []*Span) {
 	// 标签搜索支持空格分隔关键字 Tag search supports space-separated keywords https://github.com/siyuan-note/siyuan/issues/14580
 	keywords := strings.Split(keyword, " ")
 	contentLikes := ""
@@ -105,7 +106,7 @@ func QueryTagSpansByKeyword(keyword string, limit int) (ret []*Span) {
 		}
 		contentLikes += "content LIKE '%" + k + "%'"
 	}
-	stmt := "SELECT * FROM spans WHERE type LIKE '%tag%' AND (" + contentLikes + ") GROUP BY markdown LIMIT " + strconv.Itoa(limit)
+	stmt := "SELECT * FROM spans WHERE type LIKE '%tag%' AND (" + contentLikes + ")" + filter + " GROUP BY markdown LIMIT " + strconv.Itoa(limit)
 	rows, err := query(stmt)
 	if err != nil {
 		logging.LogErrorf("sql query failed: %s", err)
@@ -120,6 +120,7 @@ func getCurrentTasks() (ret []*Task) {
 const (
 	RepoCheckout                    = "task.repo.checkout"                 // 从快照中检出
 	RepoAutoPurge                   = "task.repo.autoPurge"                // 自动清理数据仓库
+	RepoAutoSnapshot                = "task.repo.autoSnapshot"             // 定时创建快照
 	DatabaseIndexFull               = "task.database.index.full"           // 重建索引
 	DatabaseIndex                   = "task.database.index"                // 数据库索引
 	DatabaseIndexCommit             = "task.database.index.commit"         // 数据库索引提交
@@ -142,12 +143,15 @@ const (
 	SetDefRefCount                  = "task.def.setRefCount"               // 设置定义的引用计数
 	UpdateIDs                       = "task.update.ids"                    // 更新 ID
 	PushMsg                         = "task.push.msg"                      // 推送消息
+	WebhookDeliver                  = "task.webhook.deliver"               // 投递 Webhook
+	WebhookBlockUpdated             = "task.webhook.blockUpdated"          // 触发合并后的块更新 Webhook 事件
 )
 
 // uniqueActions 描述了唯一的任务，即队列中只能存在一个在执行的任务。
 var uniqueActions = []string{
 	RepoCheckout,
 	RepoAutoPurge,
+	RepoAutoSnapshot,
 	DatabaseIndexFull,
 	DatabaseIndexCommit,
 	OCRImage,
@@ -163,6 +167,7 @@ var uniqueActions = []string{
 	SetRefDynamicText,
 	SetDefRefCount,
 	UpdateIDs,
+	WebhookDeliver,
 }
 
 func ContainIndexTask() bool {
@@ -0,0 +1,202 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+)
+
+// StreamEvent 描述了推送给外部订阅者的事件，Seq 在内核运行期间单调递增，重启后从 1 开始。
+//
+// ID 由内核启动纪元 Epoch 和序号组成，订阅者使用 ID 恢复订阅，纪元不一致说明内核已经重启，序号不再可比。
+type StreamEvent struct {
+	ID    string          `json:"id"`
+	Epoch string          `json:"epoch"`
+	Seq   uint64          `json:"seq"`
+	Cmd   string          `json:"cmd"`
+	App   string          `json:"app"`
+	Code  int             `json:"code"`
+	Msg   string          `json:"msg"`
+	Data  json.RawMessage `json:"data"`
+	Time  int64           `json:"time"`
+}
+
+// StreamEpoch 内核启动纪元，每次启动都不同。
+var StreamEpoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// StreamEventID 返回纪元 epoch 中序号 seq 对应的事件 ID。
+func StreamEventID(epoch string, seq uint64) string {
+	return epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// ParseStreamEventID 解析事件 ID，格式不正确时返回空的纪元和序号 0。
+func ParseStreamEventID(id string) (epoch string, seq uint64) {
+	epoch, seqStr, found := strings.Cut(id, "-")
+	if !found || "" == epoch {
+		return "", 0
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return "", 0
+	}
+	return
+}
+
+const (
+	streamEventBufferSize     = 2048 // 保留的最近事件数，用于断线后从序号恢复
+	streamSubscriberQueueSize = 256  // 订阅者的待发送事件数，超过后断开订阅者，由订阅者重连后从序号恢复
+)
+
+// streamIgnoredCmds 仅用于界面展示的推送，不推送给外部订阅者。
+var streamIgnoredCmds = map[string]bool{
+	"msg":                    true,
+	"cmsg":                   true,
+	"progress":               true,
+	"cprogress":              true,
+	"statusbar":              true,
+	"backgroundtask":         true,
+	"downloadProgress":       true,
+	"setLocalShorthandCount": true,
+	"addLoading":             true,
+}
+
+var (
+	streamEvents      []*StreamEvent // 环形缓冲，按序号排列
+	streamSeq         uint64
+	streamSubscribers = map[chan *StreamEvent]bool{}
+	streamEnabled     bool // 第一次订阅后才开始记录事件
+	streamLock        = sync.Mutex{}
+)
+
+// SubscribeStreamEvents 订阅事件，返回事件 ID since 之后仍在缓冲中的事件和后续事件的通道，since 为空时从头订阅，latest 为最新的事件 ID。
+//
+// reset 为 true 说明 since 来自之前启动的内核，gap 为 true 说明 since 之后的部分事件已经不在缓冲中，
+// 这两种情况订阅者都需要重新全量获取数据。
+// 订阅者处理过慢时通道会被关闭，订阅者应该使用最后收到的事件 ID 重新订阅。
+func SubscribeStreamEvents(since string) (ch chan *StreamEvent, backlog []*StreamEvent, gap, reset bool, latest string) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	streamEnabled = true
+	backlog, gap, reset = getStreamEvents0(since, 0)
+	latest = StreamEventID(StreamEpoch, streamSeq)
+	ch = make(chan *StreamEvent, streamSubscriberQueueSize)
+	streamSubscribers[ch] = true
+	return
+}
+
+func UnsubscribeStreamEvents(ch chan *StreamEvent) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	if streamSubscribers[ch] {
+		delete(streamSubscribers, ch)
+		close(ch)
+	}
+}
+
+// GetStreamEvents 获取事件 ID since 之后仍在缓冲中的事件，limit 为 0 时不限制个数，latest 为最新的事件 ID。
+func GetStreamEvents(since string, limit int) (ret []*StreamEvent, gap, reset bool, latest string) {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	streamEnabled = true
+	ret, gap, reset = getStreamEvents0(since, limit)
+	latest = StreamEventID(StreamEpoch, streamSeq)
+	return
+}
+
+func getStreamEvents0(since string, limit int) (ret []*StreamEvent, gap, reset bool) {
+	ret = []*StreamEvent{}
+	if "" == since {
+		return getStreamEventsAfter(0, limit), false, false
+	}
+
+	epoch, seq := ParseStreamEventID(since)
+	if StreamEpoch != epoch || seq > streamSeq {
+		// 之前启动的内核的事件 ID，序号不可比，从当前最新的事件之后开始
+		reset = true
+		return
+	}
+
+	if 0 < len(streamEvents) && seq+1 < streamEvents[0].Seq {
+		gap = true
+	}
+	ret = getStreamEventsAfter(seq, limit)
+	return
+}
+
+func getStreamEventsAfter(seq uint64, limit int) (ret []*StreamEvent) {
+	ret = []*StreamEvent{}
+	for _, evt := range streamEvents {
+		if evt.Seq <= seq {
+			continue
+		}
+		ret = append(ret, evt)
+		if 0 < limit && limit <= len(ret) {
+			break
+		}
+	}
+	return
+}
+
+// publishStreamEvent 记录推送事件并分发给订阅者，推送数据在调用时序列化，避免后续被修改。
+func publishStreamEvent(cmd, app string, code int, msg string, data interface{}) {
+	if streamIgnoredCmds[cmd] {
+		return
+	}
+
+	streamLock.Lock()
+	enabled := streamEnabled
+	streamLock.Unlock()
+	if !enabled {
+		return
+	}
+
+	raw, err := gulu.JSON.MarshalJSON(data)
+	if err != nil {
+		logging.LogErrorf("marshal stream event [%s] failed: %s", cmd, err)
+		return
+	}
+
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	streamSeq++
+	evt := &StreamEvent{ID: StreamEventID(StreamEpoch, streamSeq), Epoch: StreamEpoch, Seq: streamSeq, Cmd: cmd, App: app, Code: code, Msg: msg, Data: raw, Time: time.Now().UnixMilli()}
+	streamEvents = append(streamEvents, evt)
+	if overflow := len(streamEvents) - streamEventBufferSize; 0 < overflow {
+		streamEvents = streamEvents[overflow:]
+	}
+
+	for ch := range streamSubscribers {
+		select {
+		case ch <- evt:
+		default:
+			logging.LogWarnf("stream event subscriber is too slow, disconnect it at seq [%d]", evt.Seq)
+			delete(streamSubscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"testing"
+)
+
+func TestParseStreamEventID(t *testing.T) {
+	cases := []struct {
+		id        string
+		wantEpoch string
+		wantSeq   uint64
+	}{
+		{"abc-42", "abc", 42},
+		{"abc-0", "abc", 0},
+		{"42", "", 0},
+		{"-42", "", 0},
+		{"abc-x", "", 0},
+		{"", "", 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.id, func(t *testing.T) {
+			epoch, seq := ParseStreamEventID(c.id)
+			if c.wantEpoch != epoch || c.wantSeq != seq {
+				t.Fatalf("want [%s, %d], got [%s, %d]", c.wantEpoch, c.wantSeq, epoch, seq)
+			}
+		})
+	}
+}
+
+func TestGetStreamEvents(t *testing.T) {
+	streamLock.Lock()
+	streamEvents, streamSeq, streamEnabled = nil, 0, true
+	streamLock.Unlock()
+	t.Cleanup(func() {
+		streamLock.Lock()
+		streamEvents, streamSeq, streamEnabled = nil, 0, false
+		streamLock.Unlock()
+	})
+
+	for i := 0; i < streamEventBufferSize+10; i++ {
+		publishStreamEvent("transactions", "", 0, "", nil)
+	}
+	first := uint64(11) // 最早的 10 个事件已经不在缓冲中
+	latest := uint64(streamEventBufferSize + 10)
+
+	cases := []struct {
+		name      string
+		since     string
+		wantFirst uint64 // 返回的第一个事件的序号，0 表示不返回事件
+		wantGap   bool
+		wantReset bool
+	}{
+		{"from start", "", first, false, false},
+		{"in buffer", StreamEventID(StreamEpoch, 100), 101, false, false},
+		{"latest", StreamEventID(StreamEpoch, latest), 0, false, false},
+		{"right before buffer", StreamEventID(StreamEpoch, first-1), first, false, false},
+		{"dropped events", StreamEventID(StreamEpoch, 1), first, true, false},
+		{"earlier epoch", StreamEventID("other", 100), 0, false, true},
+		{"sequence without epoch", "100", 0, false, true},
+		{"future sequence", StreamEventID(StreamEpoch, latest+1), 0, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			events, gap, reset, next := GetStreamEvents(c.since, 0)
+			if c.wantGap != gap || c.wantReset != reset {
+				t.Fatalf("want gap [%t] reset [%t], got gap [%t] reset [%t]", c.wantGap, c.wantReset, gap, reset)
+			}
+			if 0 == c.wantFirst {
+				if 0 != len(events) {
+					t.Fatalf("want no events, got [%d]", len(events))
+				}
+			} else if 1 > len(events) || c.wantFirst != events[0].Seq || latest != events[len(events)-1].Seq {
+				t.Fatalf("want events [%d, %d], got [%d]", c.wantFirst, latest, len(events))
+			}
+			if StreamEventID(StreamEpoch, latest) != next {
+				t.Fatalf("want latest [%s], got [%s]", StreamEventID(StreamEpoch, latest), next)
+			}
+		})
+	}
+}
@@ -58,6 +58,21 @@ func LoadSysFonts() (ret []string) {
 	return
 }
 
+// GetSysFontPath 返回系统字体族对应的字体文件路径，仅返回可以嵌入的 .otf/.ttf 字体文件。
+func GetSysFontPath(family string) string {
+	for _, font := range loadFonts() {
+		if !strings.EqualFold(family, font.Family) {
+			continue
+		}
+
+		lowerPath := strings.ToLower(font.Path)
+		if strings.HasSuffix(lowerPath, ".otf") || strings.HasSuffix(lowerPath, ".ttf") {
+			return font.Path
+		}
+	}
+	return ""
+}
+
 type Font struct {
 	Path   string
 	Family string
@@ -97,6 +97,17 @@ func IsOnline(checkURL string, skipTlsVerify bool, timeout int) bool {
 	return false
 }
 
+// IsTCPOnline 判断 TCP 地址 host:port 是否可以连接，timeout 单位为毫秒。
+func IsTCPOnline(addr string, timeout int) bool {
+	conn, err := net.DialTimeout("tcp", addr, time.Duration(timeout)*time.Millisecond)
+	if err != nil {
+		logging.LogWarnf("network is offline [addr=%s]: %s", addr, err)
+		return false
+	}
+	conn.Close()
+	return true
+}
+
 func IsPortOpen(port string) bool {
 	timeout := time.Second
 	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", port), timeout)
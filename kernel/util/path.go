@@ -231,6 +231,14 @@ func NormalizeConcurrentReqs(concurrentReqs int, provider int) int {
 			concurrentReqs = 1024
 		default:
 		}
+	case 5, 6, 7: // SFTP, Git, LAN
+		switch {
+		case concurrentReqs < 1:
+			concurrentReqs = 8
+		case concurrentReqs > 64:
+			concurrentReqs = 64
+		default:
+		}
 	}
 	return concurrentReqs
 }
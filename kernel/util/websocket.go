@@ -54,6 +54,8 @@ func BroadcastByTypeAndApp(typ, app, cmd string, code int, msg string, data inte
 
 // BroadcastByType 广播所有实例上 typ 类型的会话。
 func BroadcastByType(typ, cmd string, code int, msg string, data interface{}) {
+	publishStreamEvent(cmd, "", code, msg, data)
+
 	typeSessions := SessionsByType(typ)
 	for _, sess := range typeSessions {
 		event := NewResult()
@@ -301,6 +303,10 @@ func PushDownloadProgress(id string, percent float32) {
 }
 
 func PushEvent(event *Result) {
+	if PushModeSingleSelf != event.PushMode {
+		publishStreamEvent(event.Cmd, event.AppId, event.Code, event.Msg, event.Data)
+	}
+
 	msg := event.Bytes()
 	mode := event.PushMode
 	switch mode {